@@ -0,0 +1,146 @@
+package s3fs
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"strings"
+)
+
+// accessCheckPrefix is where CheckAccess writes its probe object.
+const accessCheckPrefix = ".s3fs-access-check/"
+
+// ErrNotChecked is reported by CheckAccess for a permission it had no way to check.
+var ErrNotChecked = errors.New("not checked")
+
+// AccessReport is the result of CheckAccess. Each permission is nil if it was
+// verified, ErrNotChecked if it couldn't be, or the error from the request that
+// needed it.
+type AccessReport struct {
+	ListBucket   error
+	GetObject    error
+	PutObject    error
+	DeleteObject error
+
+	// Writable is whether PutObject and DeleteObject were checked. They aren't for
+	// FSs created with WithReadOnly.
+	Writable bool
+}
+
+// Err returns an error naming every permission that is missing, or nil if they are
+// all there. Permissions that weren't checked aren't counted as missing.
+func (r *AccessReport) Err() error {
+	checks := []struct {
+		name string
+		err  error
+	}{
+		{"s3:ListBucket", r.ListBucket},
+		{"s3:GetObject", r.GetObject},
+		{"s3:PutObject", r.PutObject},
+		{"s3:DeleteObject", r.DeleteObject},
+	}
+
+	msgs := []string{}
+	for _, check := range checks {
+		if check.err != nil && !errors.Is(check.err, ErrNotChecked) {
+			msgs = append(msgs, fmt.Sprintf("%s: %s", check.name, check.err))
+		}
+	}
+
+	if len(msgs) == 0 {
+		return nil
+	}
+
+	return fmt.Errorf("missing access: %s", strings.Join(msgs, "; "))
+}
+
+// CheckAccess makes a few cheap requests to find out whether the FS can do what it
+// will need to: list the bucket, read an object from it, and, unless the FS is read
+// only, write and delete an object. Writing is checked by creating and removing an
+// empty object under ".s3fs-access-check/". Reading is checked on the first non-empty
+// object listed, or if there isn't one, on that empty object, so it is ErrNotChecked
+// if listing failed or found nothing and the FS is read only. Call it at startup to fail fast with a
+// clear message instead of on the first request that needs a missing permission.
+func (s *S3FS) CheckAccess(ctx context.Context) *AccessReport {
+	r := &AccessReport{Writable: !s.readOnly}
+
+	if s.bucketErr != nil {
+		r.ListBucket, r.GetObject = s.bucketErr, s.bucketErr
+		if r.Writable {
+			r.PutObject, r.DeleteObject = s.bucketErr, s.bucketErr
+		}
+
+		return r
+	}
+
+	// read the first non-empty object we find. Reading a key that doesn't exist
+	// proves nothing, since without s3:ListBucket S3 says AccessDenied for those.
+	readKey := ""
+	r.ListBucket = s.store.List(ctx, "", ListOptions{}, func(page *ListPage) bool {
+		for _, obj := range page.Objects {
+			if obj.Size > 0 {
+				readKey = obj.Key
+				return false
+			}
+		}
+
+		return false
+	})
+
+	r.GetObject = ErrNotChecked
+	if readKey != "" {
+		r.GetObject = s.checkRead(ctx, readKey, GetOptions{Length: 1})
+	}
+
+	if !r.Writable {
+		return r
+	}
+
+	probe, err := accessCheckKey()
+	if err != nil {
+		r.PutObject, r.DeleteObject = err, err
+		return r
+	}
+
+	_, r.PutObject = s.store.Put(ctx, probe, bytes.NewReader(nil), PutOptions{})
+
+	// with nothing else to read, read back the probe, which is empty so it can't be
+	// read by range
+	if readKey == "" && r.PutObject == nil {
+		r.GetObject = s.checkRead(ctx, probe, GetOptions{})
+	}
+
+	r.DeleteObject = s.store.Delete(ctx, probe)
+
+	return r
+}
+
+// checkRead reads key, returning the error if that fails. A key that was listed but
+// is gone by now was still readable as far as S3 is concerned.
+func (s *S3FS) checkRead(ctx context.Context, key string, opts GetOptions) error {
+	object, err := s.store.Get(ctx, key, opts)
+	if err == nil {
+		_, err = io.Copy(io.Discard, object.Body)
+		object.Body.Close()
+	}
+
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	return nil
+}
+
+func accessCheckKey() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("could not generate probe key: %w", err)
+	}
+
+	return accessCheckPrefix + hex.EncodeToString(buf), nil
+}
@@ -0,0 +1,104 @@
+package s3fs_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"testing"
+
+	"github.com/packrat386/s3fs"
+	"github.com/packrat386/s3fs/s3fstest"
+	"github.com/stretchr/testify/require"
+)
+
+// readOnlyRole is a store whose credentials can't write.
+type readOnlyRole struct {
+	*s3fstest.MemStore
+}
+
+var errAccessDenied = errors.New("AccessDenied: Access Denied")
+
+func (readOnlyRole) Put(ctx context.Context, key string, body io.Reader, opts s3fs.PutOptions) (s3fs.ObjectInfo, error) {
+	return s3fs.ObjectInfo{}, errAccessDenied
+}
+
+// noListRole is a store whose credentials can't list, so like S3 it says access is
+// denied for keys that don't exist rather than that they don't.
+type noListRole struct {
+	*s3fstest.MemStore
+}
+
+func (noListRole) List(ctx context.Context, prefix string, opts s3fs.ListOptions, fn func(*s3fs.ListPage) bool) error {
+	return errAccessDenied
+}
+
+func (n noListRole) Get(ctx context.Context, key string, opts s3fs.GetOptions) (*s3fs.Object, error) {
+	if _, err := n.MemStore.Head(ctx, key); errors.Is(err, fs.ErrNotExist) {
+		return nil, errAccessDenied
+	}
+
+	return n.MemStore.Get(ctx, key, opts)
+}
+
+func TestCheckAccess(t *testing.T) {
+	store := s3fstest.NewMemStore()
+	store.WriteFile("empty.json", ``)
+	store.WriteFile("foo.json", `{"data":"foo"}`)
+
+	ctx := context.Background()
+
+	report := s3fs.NewFS(store).CheckAccess(ctx)
+	require.True(t, report.Writable)
+	require.Nil(t, report.Err())
+
+	// the probe object is cleaned up
+	keys := []string{}
+	store.List(ctx, "", s3fs.ListOptions{}, func(page *s3fs.ListPage) bool {
+		for _, obj := range page.Objects {
+			keys = append(keys, obj.Key)
+		}
+
+		return true
+	})
+	require.Equal(t, []string{"empty.json", "foo.json"}, keys)
+
+	report = s3fs.NewFS(readOnlyRole{store}).CheckAccess(ctx)
+	require.Nil(t, report.ListBucket)
+	require.Nil(t, report.GetObject)
+	require.Equal(t, errAccessDenied, report.PutObject)
+	require.Contains(t, report.Err().Error(), "s3:PutObject: AccessDenied")
+
+	report = s3fs.NewFS(readOnlyRole{store}, s3fs.WithReadOnly()).CheckAccess(ctx)
+	require.False(t, report.Writable)
+	require.Nil(t, report.Err())
+}
+
+func TestCheckAccess_WithoutListBucket(t *testing.T) {
+	store := s3fstest.NewMemStore()
+	store.WriteFile("foo.json", `{"data":"foo"}`)
+
+	ctx := context.Background()
+
+	// reading is checked on the probe object
+	report := s3fs.NewFS(noListRole{store}).CheckAccess(ctx)
+	require.Equal(t, errAccessDenied, report.ListBucket)
+	require.Nil(t, report.GetObject)
+	require.Nil(t, report.PutObject)
+	require.Nil(t, report.DeleteObject)
+
+	// and can't be checked at all without writing
+	report = s3fs.NewFS(noListRole{store}, s3fs.WithReadOnly()).CheckAccess(ctx)
+	require.Equal(t, s3fs.ErrNotChecked, report.GetObject)
+	require.Equal(t, "missing access: s3:ListBucket: AccessDenied: Access Denied", report.Err().Error())
+}
+
+func TestCheckAccess_EmptyBucket(t *testing.T) {
+	report := s3fs.NewFS(s3fstest.NewMemStore()).CheckAccess(context.Background())
+	require.Nil(t, report.Err())
+	require.Nil(t, report.GetObject)
+
+	report = s3fs.NewFS(s3fstest.NewMemStore(), s3fs.WithReadOnly()).CheckAccess(context.Background())
+	require.Nil(t, report.Err())
+	require.Equal(t, s3fs.ErrNotChecked, report.GetObject)
+}
@@ -0,0 +1,82 @@
+package s3fs
+
+import (
+	"context"
+	"fmt"
+)
+
+// ACL is the owner of an object and the grants in its access control list.
+type ACL struct {
+	Owner  Grantee
+	Grants []Grant
+}
+
+// Grant gives a Grantee a permission: "READ", "WRITE", "READ_ACP", "WRITE_ACP" or
+// "FULL_CONTROL".
+type Grant struct {
+	Grantee    Grantee
+	Permission string
+}
+
+// Grantee is who a Grant is for. Type is "CanonicalUser", "AmazonCustomerByEmail" or
+// "Group"; groups are identified by URI and the others by ID or Email.
+type Grantee struct {
+	Type        string
+	ID          string
+	DisplayName string
+	Email       string
+	URI         string
+}
+
+const (
+	allUsersURI           = "http://acs.amazonaws.com/groups/global/AllUsers"
+	authenticatedUsersURI = "http://acs.amazonaws.com/groups/global/AuthenticatedUsers"
+)
+
+// PublicRead reports whether the ACL lets anyone read the object, whether anonymously
+// or with any AWS account.
+func (a ACL) PublicRead() bool {
+	for _, g := range a.Grants {
+		if g.Grantee.URI != allUsersURI && g.Grantee.URI != authenticatedUsersURI {
+			continue
+		}
+
+		if g.Permission == "READ" || g.Permission == "FULL_CONTROL" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// aclStore is implemented by stores that have per object ACLs.
+type aclStore interface {
+	ACL(ctx context.Context, key string) (ACL, error)
+}
+
+// ACL returns the owner and access control list of the file at name. It costs a
+// request per call, so ACLs aren't fetched unless asked for. Buckets with ACLs
+// disabled (the default for new buckets) report only the bucket owner with full
+// control.
+func (s *S3FS) ACL(name string) (ACL, error) {
+	if s.bucketErr != nil {
+		return ACL{}, s.bucketErr
+	}
+
+	key, err := s.key("acl", name)
+	if err != nil {
+		return ACL{}, err
+	}
+
+	as, ok := s.baseStore().(aclStore)
+	if !ok {
+		return ACL{}, fmt.Errorf("could not get ACL of %s: store doesn't have ACLs", key)
+	}
+
+	acl, err := as.ACL(context.Background(), key)
+	if err != nil {
+		return ACL{}, fmt.Errorf("could not get ACL of %s: %w", key, err)
+	}
+
+	return acl, nil
+}
@@ -0,0 +1,53 @@
+package s3fs_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/packrat386/s3fs"
+	"github.com/packrat386/s3fs/s3fstest"
+	"github.com/stretchr/testify/require"
+)
+
+// aclStore makes everything under "public/" readable by anyone.
+type aclStore struct {
+	*s3fstest.MemStore
+}
+
+func (a aclStore) ACL(ctx context.Context, key string) (s3fs.ACL, error) {
+	owner := s3fs.Grantee{Type: "CanonicalUser", ID: "owner"}
+	acl := s3fs.ACL{
+		Owner:  owner,
+		Grants: []s3fs.Grant{{Grantee: owner, Permission: "FULL_CONTROL"}},
+	}
+
+	if strings.HasPrefix(key, "public/") {
+		acl.Grants = append(acl.Grants, s3fs.Grant{
+			Grantee:    s3fs.Grantee{Type: "Group", URI: "http://acs.amazonaws.com/groups/global/AllUsers"},
+			Permission: "READ",
+		})
+	}
+
+	return acl, nil
+}
+
+func TestACL(t *testing.T) {
+	store := s3fstest.NewMemStore()
+	store.WriteFile("public/index.html", `hi`)
+	store.WriteFile("private/secrets.json", `{}`)
+
+	myFS := s3fs.NewFS(aclStore{store})
+
+	acl, err := myFS.ACL("public/index.html")
+	require.Nil(t, err)
+	require.Equal(t, "owner", acl.Owner.ID)
+	require.True(t, acl.PublicRead())
+
+	acl, err = myFS.ACL("private/secrets.json")
+	require.Nil(t, err)
+	require.False(t, acl.PublicRead())
+
+	_, err = s3fs.NewFS(store).ACL("public/index.html")
+	require.NotNil(t, err)
+}
@@ -0,0 +1,91 @@
+package s3fs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+)
+
+// appendStore is implemented by stores that can append to an object without
+// downloading and re-uploading all of it.
+type appendStore interface {
+	Append(ctx context.Context, key string, body io.Reader, opts PutOptions) (ObjectInfo, error)
+}
+
+// Append returns a Writer that adds to the end of the file at name, creating it if it
+// doesn't exist. As with Create, the file isn't changed until the Writer is closed.
+//
+// On S3, files of at least 5 MiB are appended to by copying the existing object as
+// the first part of a multipart upload, so the existing data never leaves S3.
+// Smaller files (and other stores) are downloaded and written back along with the
+// new data. If a large S3 file changes while it is being appended to the append
+// fails, but the download and rewrite path can lose concurrent changes.
+func (s *S3FS) Append(name string) (*Writer, error) {
+	key, err := s.writableKey("append", name)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.encryption != nil {
+		return nil, fmt.Errorf("could not append to %s: encrypted files can't be appended to", key)
+	}
+
+	if s.compression != nil {
+		return nil, fmt.Errorf("could not append to %s: compressed files can't be appended to", key)
+	}
+
+	if s.chunkSize > 0 {
+		return nil, fmt.Errorf("could not append to %s: chunked files can't be appended to", key)
+	}
+
+	return s.newWriter(key, false, func(r io.Reader) error {
+		ctx := context.Background()
+		opts := s.putOptions(key)
+
+		// append to the staged writes, not to what was there before them
+		if s.writeBack != nil {
+			s.writeBack.wait(key)
+		}
+
+		var info ObjectInfo
+		var err error
+
+		if as, ok := s.baseStore().(appendStore); ok {
+			info, err = as.Append(ctx, key, r, opts)
+		} else {
+			info, err = appendByRewrite(ctx, s.store, key, r, opts)
+		}
+
+		if err == nil {
+			s.mutated("append", key, "", info.ETag)
+		}
+
+		return err
+	})
+}
+
+// appendByRewrite appends body to key by writing the existing data followed by body
+// back to key.
+func appendByRewrite(ctx context.Context, store ObjectStore, key string, body io.Reader, opts PutOptions) (ObjectInfo, error) {
+	existing, err := store.Get(ctx, key, GetOptions{})
+	if errors.Is(err, fs.ErrNotExist) {
+		return store.Put(ctx, key, body, opts)
+	}
+
+	if err != nil {
+		return ObjectInfo{}, fmt.Errorf("could not read existing data: %w", err)
+	}
+	defer existing.Body.Close()
+
+	if opts.ContentType == "" {
+		opts.ContentType = existing.Info.ContentType
+	}
+
+	if opts.Metadata == nil {
+		opts.Metadata = existing.Info.Metadata
+	}
+
+	return store.Put(ctx, key, io.MultiReader(existing.Body, body), opts)
+}
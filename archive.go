@@ -0,0 +1,95 @@
+package s3fs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrObjectArchived is returned when reading an object that is in an archive storage
+// class (S3 Glacier Flexible Retrieval or Deep Archive) and hasn't been restored.
+// Use Restore and WaitRestored to make it readable.
+var ErrObjectArchived = errors.New("object is archived")
+
+// RestoreTier is how quickly, and how expensively, an archived object is restored.
+type RestoreTier string
+
+const (
+	RestoreExpedited RestoreTier = "Expedited"
+	RestoreStandard  RestoreTier = "Standard"
+	RestoreBulk      RestoreTier = "Bulk"
+)
+
+// restorePollInterval is how often WaitRestored checks on a restore. Restores take
+// minutes at best and usually hours, so there's no point asking more often.
+var restorePollInterval = time.Minute
+
+// restoreStore is implemented by stores that can archive objects.
+type restoreStore interface {
+	Restore(ctx context.Context, key string, tier RestoreTier, days int) error
+}
+
+// isArchived reports whether objects in storageClass have to be restored before they
+// can be read.
+func isArchived(storageClass string) bool {
+	return storageClass == "GLACIER" || storageClass == "DEEP_ARCHIVE"
+}
+
+// Restore starts restoring the archived file at name, making a temporary copy that
+// can be read for days days. Restoring a file that is already being restored is not
+// an error, and neither is restoring a file that isn't archived. Use WaitRestored to
+// wait for the restore to finish.
+func (s *S3FS) Restore(name string, tier RestoreTier, days int) error {
+	key, err := s.writableKey("restore", name)
+	if err != nil {
+		return err
+	}
+
+	rs, ok := s.baseStore().(restoreStore)
+	if !ok {
+		return fmt.Errorf("could not restore %s: store doesn't archive objects", key)
+	}
+
+	if err := rs.Restore(context.Background(), key, tier, days); err != nil {
+		return fmt.Errorf("could not restore %s: %w", key, err)
+	}
+
+	return nil
+}
+
+// WaitRestored waits until the file at name can be read, checking on it every minute
+// until it has been restored or ctx is done. It returns an error wrapping
+// ErrObjectArchived if name is archived and no restore has been started.
+func (s *S3FS) WaitRestored(ctx context.Context, name string) error {
+	if s.bucketErr != nil {
+		return s.bucketErr
+	}
+
+	key, err := s.key("restore", name)
+	if err != nil {
+		return err
+	}
+
+	for {
+		info, err := s.store.Head(ctx, key)
+		if err != nil {
+			return fmt.Errorf("could not stat %s: %w", key, err)
+		}
+
+		l := info.Lifecycle()
+		if !isArchived(info.StorageClass) || (!l.Restoring && !l.RestoredUntil.IsZero()) {
+			return nil
+		}
+
+		if !l.Restoring {
+			return fmt.Errorf("%w: no restore of %s has been started", ErrObjectArchived, key)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-s.clock.After(restorePollInterval):
+		}
+	}
+}
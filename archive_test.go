@@ -0,0 +1,151 @@
+package s3fs_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/packrat386/s3fs"
+	"github.com/packrat386/s3fs/s3fstest"
+	"github.com/stretchr/testify/require"
+)
+
+// glacierStore archives every object, and lets tests decide how restores go.
+type glacierStore struct {
+	*s3fstest.MemStore
+
+	mu      sync.Mutex
+	restore map[string]string
+}
+
+func (g *glacierStore) Head(ctx context.Context, key string) (s3fs.ObjectInfo, error) {
+	info, err := g.MemStore.Head(ctx, key)
+	if err != nil {
+		return info, err
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	info.StorageClass = "GLACIER"
+	info.Restore = g.restore[key]
+
+	return info, nil
+}
+
+func (g *glacierStore) Get(ctx context.Context, key string, opts s3fs.GetOptions) (*s3fs.Object, error) {
+	info, err := g.Head(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	if info.Lifecycle().RestoredUntil.IsZero() {
+		return nil, fmt.Errorf("%w: InvalidObjectState", s3fs.ErrObjectArchived)
+	}
+
+	return g.MemStore.Get(ctx, key, opts)
+}
+
+func (g *glacierStore) Restore(ctx context.Context, key string, tier s3fs.RestoreTier, days int) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.restore[key] = `ongoing-request="true"`
+
+	return nil
+}
+
+func (g *glacierStore) finishRestore(key string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.restore[key] = `ongoing-request="false", expiry-date="Fri, 21 Dec 2012 00:00:00 GMT"`
+}
+
+func TestRestore(t *testing.T) {
+	store := &glacierStore{MemStore: s3fstest.NewMemStore(), restore: map[string]string{}}
+	store.WriteFile("old.json", `{"data":"old"}`)
+
+	myFS := s3fs.NewFS(store)
+	ctx := context.Background()
+
+	_, err := fs.ReadFile(myFS, "old.json")
+	require.True(t, errors.Is(err, s3fs.ErrObjectArchived))
+
+	err = myFS.WaitRestored(ctx, "old.json")
+	require.True(t, errors.Is(err, s3fs.ErrObjectArchived))
+
+	require.Nil(t, myFS.Restore("old.json", s3fs.RestoreBulk, 1))
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	require.True(t, errors.Is(myFS.WaitRestored(canceled, "old.json"), context.Canceled))
+
+	store.finishRestore("old.json")
+	require.Nil(t, myFS.WaitRestored(ctx, "old.json"))
+
+	data, err := fs.ReadFile(myFS, "old.json")
+	require.Nil(t, err)
+	require.Equal(t, `{"data":"old"}`, string(data))
+}
+
+func TestRestore_NotSupported(t *testing.T) {
+	store := s3fstest.NewMemStore()
+	store.WriteFile("new.json", `{"data":"new"}`)
+
+	myFS := s3fs.NewFS(store)
+
+	require.NotNil(t, myFS.Restore("new.json", s3fs.RestoreStandard, 1))
+	require.Nil(t, myFS.WaitRestored(context.Background(), "new.json"))
+}
+
+// coldStore reports objects whose keys end in ".old" as being in Deep Archive.
+type coldStore struct {
+	*s3fstest.MemStore
+}
+
+func (c coldStore) List(ctx context.Context, prefix string, opts s3fs.ListOptions, fn func(*s3fs.ListPage) bool) error {
+	return c.MemStore.List(ctx, prefix, opts, func(page *s3fs.ListPage) bool {
+		for i, obj := range page.Objects {
+			if strings.HasSuffix(obj.Key, ".old") {
+				page.Objects[i].StorageClass = "DEEP_ARCHIVE"
+			}
+		}
+
+		return fn(page)
+	})
+}
+
+func TestWithSkipStorageClasses(t *testing.T) {
+	store := s3fstest.NewMemStore()
+	store.WriteFile("data/a.json", `{"data":"a"}`)
+	store.WriteFile("data/b.old", `{"data":"b"}`)
+	store.WriteFile("archive/c.old", `{"data":"c"}`)
+
+	myFS := s3fs.NewFS(coldStore{store}, s3fs.WithSkipStorageClasses("GLACIER", "DEEP_ARCHIVE"))
+
+	entries, err := fs.ReadDir(myFS, "data")
+	require.Nil(t, err)
+	require.Equal(t, 1, len(entries))
+	require.Equal(t, "a.json", entries[0].Name())
+
+	// a directory of nothing but archived objects is still there, just empty
+	entries, err = fs.ReadDir(myFS, "archive")
+	require.Nil(t, err)
+	require.Equal(t, 0, len(entries))
+
+	walked := []string{}
+	err = fs.WalkDir(myFS, ".", func(path string, d fs.DirEntry, err error) error {
+		if !d.IsDir() {
+			walked = append(walked, path)
+		}
+
+		return err
+	})
+	require.Nil(t, err)
+	require.Equal(t, []string{"data/a.json"}, walked)
+}
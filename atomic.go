@@ -0,0 +1,118 @@
+package s3fs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"strings"
+)
+
+// defaultAtomicPrefix is where WithAtomicWrites stages data if no prefix is given.
+const defaultAtomicPrefix = ".s3fs-tmp/"
+
+// WithAtomicWrites makes files written through the FS go to a temporary key under
+// tmpPrefix first, and only get copied into place once they are complete. The copy
+// is conditional on the destination being unchanged since the write started (or
+// still not existing, for new files), so two writers racing on the same file can't
+// silently clobber each other; the loser gets an error wrapping
+// ErrPreconditionFailed. Temporary keys are visible in listings of tmpPrefix while
+// writes are in progress. If tmpPrefix is empty ".s3fs-tmp/" is used.
+func WithAtomicWrites(tmpPrefix string) Option {
+	return func(s *S3FS) {
+		if tmpPrefix == "" {
+			tmpPrefix = defaultAtomicPrefix
+		}
+
+		if !strings.HasSuffix(tmpPrefix, "/") {
+			tmpPrefix += "/"
+		}
+
+		s.atomicPrefix = tmpPrefix
+	}
+}
+
+// put writes body to key, going through a temporary key if atomic writes are on.
+// With WithSkipUnchanged, nothing is written if key already has the content of body.
+func (s *S3FS) put(ctx context.Context, key string, body io.Reader, opts PutOptions) (ObjectInfo, error) {
+	if rs, ok := body.(io.ReadSeeker); ok && s.skipUnchanged {
+		info, same, err := s.unchanged(ctx, key, rs)
+		if err != nil || same {
+			return info, err
+		}
+	}
+
+	body, opts, err := s.encode(ctx, body, opts)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+
+	if s.chunkSize > 0 {
+		return s.putChunked(ctx, key, body, opts)
+	}
+
+	if s.atomicPrefix == "" {
+		info, err := s.store.Put(ctx, key, body, opts)
+		if err == nil {
+			s.mutated("put", key, "", info.ETag)
+		}
+
+		return info, err
+	}
+
+	conds, err := s.atomicConds(ctx, key)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+
+	return s.putAtomic(ctx, key, body, opts, conds)
+}
+
+// atomicConds returns the preconditions for replacing key as it is now.
+func (s *S3FS) atomicConds(ctx context.Context, key string) (CopyOptions, error) {
+	current, err := s.store.Head(ctx, key)
+	if errors.Is(err, fs.ErrNotExist) {
+		return CopyOptions{IfNoneMatch: "*"}, nil
+	}
+
+	if err != nil {
+		return CopyOptions{}, err
+	}
+
+	return CopyOptions{IfMatch: current.ETag}, nil
+}
+
+// putAtomic writes body to a temporary key and copies it to key if conds still hold.
+func (s *S3FS) putAtomic(ctx context.Context, key string, body io.Reader, opts PutOptions, conds CopyOptions) (ObjectInfo, error) {
+	tmp, err := s.tempKey()
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+
+	info, err := s.store.Put(ctx, tmp, body, opts)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	defer s.store.Delete(context.Background(), tmp)
+
+	if err := s.store.Copy(ctx, tmp, key, conds); err != nil {
+		return ObjectInfo{}, fmt.Errorf("could not move %s into place: %w", key, err)
+	}
+
+	info.Key = key
+	s.mutated("put", key, "", info.ETag)
+
+	return info, nil
+}
+
+func (s *S3FS) tempKey() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("could not generate temporary key: %w", err)
+	}
+
+	return s.atomicPrefix + hex.EncodeToString(buf), nil
+}
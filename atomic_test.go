@@ -0,0 +1,51 @@
+package s3fs_test
+
+import (
+	"errors"
+	"io/fs"
+	"testing"
+
+	"github.com/packrat386/s3fs"
+	"github.com/packrat386/s3fs/s3fstest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithAtomicWrites(t *testing.T) {
+	store := s3fstest.NewMemStore()
+	myFS := s3fs.NewFS(store, s3fs.WithAtomicWrites(""))
+
+	require.Nil(t, myFS.WriteFile("foo.txt", []byte("one")))
+	require.Nil(t, myFS.WriteFile("foo.txt", []byte("two")))
+
+	data, err := fs.ReadFile(myFS, "foo.txt")
+	require.Nil(t, err)
+	require.Equal(t, "two", string(data))
+
+	// nothing left behind in the staging area
+	_, err = fs.ReadDir(myFS, ".s3fs-tmp")
+	require.True(t, errors.Is(err, fs.ErrNotExist))
+}
+
+func TestWithAtomicWrites_Conflict(t *testing.T) {
+	store := s3fstest.NewMemStore()
+	myFS := s3fs.NewFS(store, s3fs.WithAtomicWrites("staging"))
+
+	w, err := myFS.Create("foo.txt")
+	require.Nil(t, err)
+
+	_, err = w.Write([]byte("mine"))
+	require.Nil(t, err)
+
+	// someone else gets there first
+	store.WriteFile("foo.txt", "theirs")
+
+	err = w.Close()
+	require.True(t, errors.Is(err, s3fs.ErrPreconditionFailed))
+
+	data, err := fs.ReadFile(myFS, "foo.txt")
+	require.Nil(t, err)
+	require.Equal(t, "theirs", string(data))
+
+	_, err = fs.ReadDir(myFS, "staging")
+	require.True(t, errors.Is(err, fs.ErrNotExist))
+}
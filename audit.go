@@ -0,0 +1,78 @@
+package s3fs
+
+import (
+	"fmt"
+	"reflect"
+	"runtime"
+	"strings"
+)
+
+// Op is a kind of read reported to an audit hook.
+type Op string
+
+const (
+	// OpOpen is opening a file or directory, with Open, OpenIf, OpenWithFallback, or
+	// anything built on them such as fs.ReadFile and DownloadMany.
+	OpOpen Op = "open"
+
+	// OpStat is StatFile.
+	OpStat Op = "stat"
+
+	// OpReadDir is ReadDirAfter and OpenDirFrom.
+	OpReadDir Op = "readdir"
+
+	// OpPresign is handing out a URL to read a file with PresignURL.
+	OpPresign Op = "presign"
+)
+
+// AuditFunc is told about every read made through the FS: what kind it was, the key
+// (relative to the bucket) that was read, and, if asked for, who read it.
+type AuditFunc func(op Op, path string, caller string)
+
+// WithAuditHook calls fn before every read made through the FS, including reads that
+// go on to fail, so reads of sensitive prefixes can be logged. With callers, fn is also
+// told which code made the read, as "function (file:line)" of the first caller outside
+// this package and io/fs, so that e.g. an fs.ReadFile is attributed to the code that
+// called it. Finding callers walks the stack on every read, so it's opt-in.
+func WithAuditHook(fn AuditFunc, callers bool) Option {
+	return func(s *S3FS) {
+		s.audit = fn
+		s.auditCallers = callers
+	}
+}
+
+// auditRead reports a read of key to the audit hook, if there is one.
+func (s *S3FS) auditRead(op Op, key string) {
+	if s.audit == nil {
+		return
+	}
+
+	caller := ""
+	if s.auditCallers {
+		caller = externalCaller()
+	}
+
+	s.audit(op, key, caller)
+}
+
+// packagePrefix is how the names of functions in this package start.
+var packagePrefix = reflect.TypeOf(S3FS{}).PkgPath() + "."
+
+// externalCaller describes the first function on the stack outside this package and
+// io/fs.
+func externalCaller() string {
+	pcs := make([]uintptr, 32)
+	n := runtime.Callers(3, pcs)
+
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		frame, more := frames.Next()
+		if !strings.HasPrefix(frame.Function, packagePrefix) && !strings.HasPrefix(frame.Function, "io/fs.") {
+			return fmt.Sprintf("%s (%s:%d)", frame.Function, frame.File, frame.Line)
+		}
+
+		if !more {
+			return ""
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package s3fs_test
+
+import (
+	"context"
+	"io/fs"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/packrat386/s3fs"
+	"github.com/packrat386/s3fs/s3fstest"
+	"github.com/stretchr/testify/require"
+)
+
+type auditRecord struct {
+	op     s3fs.Op
+	path   string
+	caller string
+}
+
+func TestWithAuditHook(t *testing.T) {
+	store := s3fstest.NewMemStore()
+	store.WriteFile("secrets/key.pem", "secret")
+	store.WriteFile("public/index.html", "hello")
+
+	mu := sync.Mutex{}
+	records := []auditRecord{}
+	hook := func(op s3fs.Op, path string, caller string) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		records = append(records, auditRecord{op, path, caller})
+	}
+
+	myFS := s3fs.NewFS(store, s3fs.WithAuditHook(hook, true))
+
+	_, err := fs.ReadFile(myFS, "secrets/key.pem")
+	require.Nil(t, err)
+
+	_, err = myFS.StatFile(context.Background(), "public/index.html")
+	require.Nil(t, err)
+
+	_, err = myFS.ReadDirAfter(context.Background(), "secrets", "", 10)
+	require.Nil(t, err)
+
+	_, err = myFS.Open("secrets/missing.pem")
+	require.ErrorIs(t, err, fs.ErrNotExist)
+
+	require.Len(t, records, 4)
+	require.Equal(t, s3fs.OpOpen, records[0].op)
+	require.Equal(t, "secrets/key.pem", records[0].path)
+	require.Equal(t, s3fs.OpStat, records[1].op)
+	require.Equal(t, s3fs.OpReadDir, records[2].op)
+	require.Equal(t, "secrets", records[2].path)
+	require.Equal(t, "secrets/missing.pem", records[3].path)
+
+	// attributed to this test rather than fs.ReadFile or the FS itself
+	for _, r := range records {
+		require.True(t, strings.HasPrefix(r.caller, "github.com/packrat386/s3fs_test.TestWithAuditHook ("), r.caller)
+		require.Contains(t, r.caller, "audit_test.go:")
+	}
+
+	// callers are opt-in
+	records = nil
+	quiet := s3fs.NewFS(store, s3fs.WithAuditHook(hook, false))
+	_, err = fs.ReadFile(quiet, "public/index.html")
+	require.Nil(t, err)
+	require.Equal(t, []auditRecord{{s3fs.OpOpen, "public/index.html", ""}}, records)
+}
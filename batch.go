@@ -0,0 +1,342 @@
+package s3fs
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3control"
+	"github.com/aws/aws-sdk-go/service/s3control/s3controliface"
+)
+
+// batchJobPollInterval is how often Wait checks on a batch job.
+var batchJobPollInterval = 30 * time.Second
+
+// BatchOperation is what an S3 Batch Operations job does to each file. It is one of
+// BatchRestore, BatchCopy or BatchTag.
+type BatchOperation interface {
+	jobOperation(s *S3FS, bucket string) (*s3control.JobOperation, error)
+}
+
+// BatchRestore restores archived files, as Restore does. Batch Operations only do
+// RestoreStandard and RestoreBulk restores.
+type BatchRestore struct {
+	Tier RestoreTier
+	Days int
+}
+
+func (op BatchRestore) jobOperation(s *S3FS, bucket string) (*s3control.JobOperation, error) {
+	restore := &s3control.S3InitiateRestoreObjectOperation{
+		ExpirationInDays: aws.Int64(int64(op.Days)),
+	}
+
+	if op.Tier != "" {
+		restore.GlacierJobTier = aws.String(strings.ToUpper(string(op.Tier)))
+	}
+
+	return &s3control.JobOperation{S3InitiateRestoreObject: restore}, nil
+}
+
+// BatchCopy copies files into the directory Dst of the same bucket, keeping their
+// names relative to the bucket. StorageClass, if set, is the storage class of the
+// copies.
+type BatchCopy struct {
+	Dst          string
+	StorageClass string
+}
+
+func (op BatchCopy) jobOperation(s *S3FS, bucket string) (*s3control.JobOperation, error) {
+	dst, err := s.key("batch", op.Dst)
+	if err != nil {
+		return nil, err
+	}
+
+	cp := &s3control.S3CopyObjectOperation{
+		TargetResource: aws.String(bucketARN(bucket)),
+	}
+
+	if dst != "" {
+		cp.TargetKeyPrefix = aws.String(dst + "/")
+	}
+
+	if op.StorageClass != "" {
+		cp.StorageClass = aws.String(op.StorageClass)
+	}
+
+	return &s3control.JobOperation{S3PutObjectCopy: cp}, nil
+}
+
+// BatchTag replaces the tags of files with Tags.
+type BatchTag struct {
+	Tags map[string]string
+}
+
+func (op BatchTag) jobOperation(s *S3FS, bucket string) (*s3control.JobOperation, error) {
+	keys := make([]string, 0, len(op.Tags))
+	for k := range op.Tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	tags := []*s3control.S3Tag{}
+	for _, k := range keys {
+		tags = append(tags, &s3control.S3Tag{Key: aws.String(k), Value: aws.String(op.Tags[k])})
+	}
+
+	return &s3control.JobOperation{S3PutObjectTagging: &s3control.S3SetObjectTaggingOperation{TagSet: tags}}, nil
+}
+
+// BatchJobConfig is what S3 Batch Operations needs to know to run a job.
+type BatchJobConfig struct {
+	// AccountID is the AWS account that owns the job.
+	AccountID string
+
+	// RoleARN is the IAM role S3 assumes to run the job. It needs to be able to read
+	// the manifest, do the operation and write the report.
+	RoleARN string
+
+	// Bucket is the name of the FS's bucket. It defaults to the bucket the FS was
+	// created with by NewS3FS.
+	Bucket string
+
+	// ManifestName is the name the list of files is written to in the FS, e.g.
+	// "batch/restore-2021.csv". It must not be under the directory being processed.
+	ManifestName string
+
+	// ReportDir is the directory in the FS that S3 writes its completion report to. It
+	// defaults to the directory of ManifestName.
+	ReportDir string
+
+	// Priority orders jobs in the account relative to each other; higher runs first.
+	Priority int
+
+	// Description is shown in the S3 console.
+	Description string
+}
+
+// BatchJob is a submitted S3 Batch Operations job.
+type BatchJob struct {
+	ID string
+
+	client    s3controliface.S3ControlAPI
+	accountID string
+	clock     Clock
+}
+
+// BatchJobStatus is how far along a BatchJob is.
+type BatchJobStatus struct {
+	// Status is S3's status of the job, e.g. "Active", "Complete" or "Failed".
+	Status string
+
+	Total     int64
+	Succeeded int64
+	Failed    int64
+
+	// FailureReasons are why the job failed, if it did.
+	FailureReasons []string
+}
+
+// Done reports whether the job has finished, successfully or not.
+func (st BatchJobStatus) Done() bool {
+	switch st.Status {
+	case s3control.JobStatusComplete, s3control.JobStatusFailed, s3control.JobStatusCancelled:
+		return true
+	}
+
+	return false
+}
+
+// bucketStore is implemented by stores that know the name of their bucket.
+type bucketStore interface {
+	bucketName() string
+}
+
+// SubmitBatchJob applies op to every file under the directory name ("." for the whole
+// bucket) with S3 Batch Operations, for jobs over far more files than it's reasonable
+// to process one request at a time. The files are listed into a CSV manifest, which is
+// written to cfg.ManifestName, and the job is created with client in the account
+// cfg.AccountID without needing confirmation. Use Status or Wait on the returned job
+// to follow it.
+func (s *S3FS) SubmitBatchJob(ctx context.Context, client s3controliface.S3ControlAPI, name string, op BatchOperation, cfg BatchJobConfig) (*BatchJob, error) {
+	key, err := s.writableKey("batch", cfg.ManifestName)
+	if err != nil {
+		return nil, err
+	}
+
+	bucket := cfg.Bucket
+	if bs, ok := s.baseStore().(bucketStore); ok && bucket == "" {
+		bucket = bs.bucketName()
+	}
+
+	if bucket == "" {
+		return nil, fmt.Errorf("could not submit batch job: bucket isn't known")
+	}
+
+	if cfg.ReportDir == "" {
+		cfg.ReportDir = path.Dir(cfg.ManifestName)
+	}
+
+	reportDir, err := s.key("batch", cfg.ReportDir)
+	if err != nil {
+		return nil, err
+	}
+
+	operation, err := op.jobOperation(s, bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix, err := s.key("batch", name)
+	if err != nil {
+		return nil, err
+	}
+
+	if prefix != "" {
+		prefix += "/"
+	}
+
+	manifest := &bytes.Buffer{}
+	count := 0
+	err = s.store.List(ctx, prefix, ListOptions{}, func(page *ListPage) bool {
+		for _, obj := range page.Objects {
+			if obj.Key == key || strings.HasSuffix(obj.Key, "/") {
+				continue
+			}
+
+			fmt.Fprintf(manifest, "%s,%s\n", bucket, batchEscape(obj.Key))
+			count++
+		}
+
+		return true
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("could not list s3 objects: %w", err)
+	}
+
+	if count == 0 {
+		return nil, fmt.Errorf("could not submit batch job: no files under %s", name)
+	}
+
+	info, err := s.store.Put(ctx, key, manifest, PutOptions{ContentType: "text/csv"})
+	if err != nil {
+		return nil, fmt.Errorf("could not write batch manifest %s: %w", key, err)
+	}
+
+	s.mutated("put", key, "", info.ETag)
+
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		return nil, fmt.Errorf("could not generate request token: %w", err)
+	}
+
+	report := &s3control.JobReport{
+		Bucket:      aws.String(bucketARN(bucket)),
+		Enabled:     aws.Bool(true),
+		Format:      aws.String(s3control.JobReportFormatReportCsv20180820),
+		ReportScope: aws.String(s3control.JobReportScopeAllTasks),
+	}
+
+	if reportDir != "" {
+		report.Prefix = aws.String(reportDir)
+	}
+
+	input := &s3control.CreateJobInput{
+		AccountId:            aws.String(cfg.AccountID),
+		ClientRequestToken:   aws.String(hex.EncodeToString(token)),
+		ConfirmationRequired: aws.Bool(false),
+		Manifest: &s3control.JobManifest{
+			Location: &s3control.JobManifestLocation{
+				ObjectArn: aws.String(bucketARN(bucket) + "/" + key),
+				ETag:      aws.String(strings.Trim(info.ETag, `"`)),
+			},
+			Spec: &s3control.JobManifestSpec{
+				Format: aws.String(s3control.JobManifestFormatS3batchOperationsCsv20180820),
+				Fields: aws.StringSlice([]string{s3control.JobManifestFieldNameBucket, s3control.JobManifestFieldNameKey}),
+			},
+		},
+		Operation: operation,
+		Priority:  aws.Int64(int64(cfg.Priority)),
+		Report:    report,
+		RoleArn:   aws.String(cfg.RoleARN),
+	}
+
+	if cfg.Description != "" {
+		input.Description = aws.String(cfg.Description)
+	}
+
+	out, err := client.CreateJobWithContext(ctx, input)
+	if err != nil {
+		return nil, fmt.Errorf("could not create batch job: %w", err)
+	}
+
+	return &BatchJob{
+		ID:        aws.StringValue(out.JobId),
+		client:    client,
+		accountID: cfg.AccountID,
+		clock:     s.clock,
+	}, nil
+}
+
+// Status returns the current status of the job.
+func (j *BatchJob) Status(ctx context.Context) (BatchJobStatus, error) {
+	out, err := j.client.DescribeJobWithContext(ctx, &s3control.DescribeJobInput{
+		AccountId: aws.String(j.accountID),
+		JobId:     aws.String(j.ID),
+	})
+	if err != nil {
+		return BatchJobStatus{}, fmt.Errorf("could not describe batch job %s: %w", j.ID, err)
+	}
+
+	st := BatchJobStatus{}
+	if out.Job == nil {
+		return st, nil
+	}
+
+	st.Status = aws.StringValue(out.Job.Status)
+	if p := out.Job.ProgressSummary; p != nil {
+		st.Total = aws.Int64Value(p.TotalNumberOfTasks)
+		st.Succeeded = aws.Int64Value(p.NumberOfTasksSucceeded)
+		st.Failed = aws.Int64Value(p.NumberOfTasksFailed)
+	}
+
+	for _, f := range out.Job.FailureReasons {
+		st.FailureReasons = append(st.FailureReasons, aws.StringValue(f.FailureReason))
+	}
+
+	return st, nil
+}
+
+// Wait checks on the job every 30 seconds until it is done or ctx is done, and returns
+// its final status.
+func (j *BatchJob) Wait(ctx context.Context) (BatchJobStatus, error) {
+	for {
+		st, err := j.Status(ctx)
+		if err != nil || st.Done() {
+			return st, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return st, ctx.Err()
+		case <-j.clock.After(batchJobPollInterval):
+		}
+	}
+}
+
+func bucketARN(bucket string) string {
+	return "arn:aws:s3:::" + bucket
+}
+
+// batchEscape URL encodes key, as keys in Batch Operations manifests must be.
+func batchEscape(key string) string {
+	return strings.ReplaceAll(url.QueryEscape(key), "+", "%20")
+}
@@ -0,0 +1,92 @@
+package s3fs_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3control"
+	"github.com/aws/aws-sdk-go/service/s3control/s3controliface"
+	"github.com/packrat386/s3fs"
+	"github.com/packrat386/s3fs/s3fstest"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeS3Control records the job it's asked to create and reports it complete.
+type fakeS3Control struct {
+	s3controliface.S3ControlAPI
+	created *s3control.CreateJobInput
+}
+
+func (c *fakeS3Control) CreateJobWithContext(ctx aws.Context, input *s3control.CreateJobInput, opts ...request.Option) (*s3control.CreateJobOutput, error) {
+	c.created = input
+	return &s3control.CreateJobOutput{JobId: aws.String("job-1")}, nil
+}
+
+func (c *fakeS3Control) DescribeJobWithContext(ctx aws.Context, input *s3control.DescribeJobInput, opts ...request.Option) (*s3control.DescribeJobOutput, error) {
+	return &s3control.DescribeJobOutput{Job: &s3control.JobDescriptor{
+		JobId:  input.JobId,
+		Status: aws.String(s3control.JobStatusComplete),
+		ProgressSummary: &s3control.JobProgressSummary{
+			TotalNumberOfTasks:     aws.Int64(2),
+			NumberOfTasksSucceeded: aws.Int64(2),
+			NumberOfTasksFailed:    aws.Int64(0),
+		},
+	}}, nil
+}
+
+func TestSubmitBatchJob(t *testing.T) {
+	store := s3fstest.NewMemStore()
+	store.WriteFile("archive/2020/a b.csv", "a")
+	store.WriteFile("archive/2020/c.csv", "c")
+	store.WriteFile("live/d.csv", "d")
+
+	myFS := s3fs.NewFS(store)
+	client := &fakeS3Control{}
+	ctx := context.Background()
+
+	job, err := myFS.SubmitBatchJob(ctx, client, "archive", s3fs.BatchRestore{Tier: s3fs.RestoreBulk, Days: 7}, s3fs.BatchJobConfig{
+		AccountID:    "111122223333",
+		RoleARN:      "arn:aws:iam::111122223333:role/batch",
+		Bucket:       "my-bucket",
+		ManifestName: "batch/restore.csv",
+		ReportDir:    "batch/reports",
+	})
+	require.Nil(t, err)
+	require.Equal(t, "job-1", job.ID)
+
+	info, manifest := rawObject(t, store, "batch/restore.csv")
+	require.Equal(t, "my-bucket,archive%2F2020%2Fa%20b.csv\nmy-bucket,archive%2F2020%2Fc.csv\n", string(manifest))
+
+	in := client.created
+	require.Equal(t, "arn:aws:s3:::my-bucket/batch/restore.csv", aws.StringValue(in.Manifest.Location.ObjectArn))
+	require.Equal(t, info.ETag[1:len(info.ETag)-1], aws.StringValue(in.Manifest.Location.ETag))
+	require.Equal(t, "BULK", aws.StringValue(in.Operation.S3InitiateRestoreObject.GlacierJobTier))
+	require.Equal(t, int64(7), aws.Int64Value(in.Operation.S3InitiateRestoreObject.ExpirationInDays))
+	require.Equal(t, "batch/reports", aws.StringValue(in.Report.Prefix))
+
+	st, err := job.Wait(ctx)
+	require.Nil(t, err)
+	require.True(t, st.Done())
+	require.Equal(t, int64(2), st.Succeeded)
+}
+
+func TestSubmitBatchJob_Copy(t *testing.T) {
+	store := s3fstest.NewMemStore()
+	store.WriteFile("src/a.csv", "a")
+
+	myFS := s3fs.NewFS(store)
+	client := &fakeS3Control{}
+
+	_, err := myFS.SubmitBatchJob(context.Background(), client, "src", s3fs.BatchCopy{Dst: "backup", StorageClass: "GLACIER"}, s3fs.BatchJobConfig{
+		Bucket:       "my-bucket",
+		ManifestName: "batch/copy.csv",
+	})
+	require.Nil(t, err)
+
+	cp := client.created.Operation.S3PutObjectCopy
+	require.Equal(t, "arn:aws:s3:::my-bucket", aws.StringValue(cp.TargetResource))
+	require.Equal(t, "backup/", aws.StringValue(cp.TargetKeyPrefix))
+	require.Equal(t, "GLACIER", aws.StringValue(cp.StorageClass))
+}
@@ -0,0 +1,67 @@
+package s3fs
+
+import "sync"
+
+// defaultBufferSize is the size of the buffers used to copy bodies unless
+// WithBufferSize says otherwise. It's the size io.Copy uses.
+const defaultBufferSize = 32 * 1024
+
+// BufferPool hands out reusable buffers of one size, so that streaming lots of files
+// doesn't allocate a new copy buffer for each one. It's safe for concurrent use.
+type BufferPool struct {
+	size int
+	pool sync.Pool
+}
+
+// NewBufferPool returns a BufferPool of buffers of size bytes. Pass it to
+// WithBufferPool to share it between FSs.
+func NewBufferPool(size int) *BufferPool {
+	if size <= 0 {
+		size = defaultBufferSize
+	}
+
+	return &BufferPool{size: size}
+}
+
+// Get returns a buffer from the pool, or a new one if it's empty.
+func (p *BufferPool) Get() []byte {
+	if buf, ok := p.pool.Get().(*[]byte); ok {
+		return *buf
+	}
+
+	return make([]byte, p.size)
+}
+
+// Put returns buf to the pool. It must not be used afterwards. Buffers that didn't
+// come from the pool are dropped.
+func (p *BufferPool) Put(buf []byte) {
+	if cap(buf) != p.size {
+		return
+	}
+
+	buf = buf[:p.size]
+	p.pool.Put(&buf)
+}
+
+// WithBufferSize sets the size of the buffers the FS copies bodies with, when
+// downloading files and when serving them with s3fshttp. Bigger buffers mean fewer,
+// larger writes to the destination. It's 32 KiB by default.
+func WithBufferSize(n int) Option {
+	return func(s *S3FS) {
+		s.buffers = NewBufferPool(n)
+	}
+}
+
+// WithBufferPool makes the FS take its copy buffers from pool, e.g. to share one pool
+// between several FSs.
+func WithBufferPool(pool *BufferPool) Option {
+	return func(s *S3FS) {
+		s.buffers = pool
+	}
+}
+
+// Buffers returns the pool the FS takes its copy buffers from, for adapters that
+// copy files somewhere else.
+func (s *S3FS) Buffers() *BufferPool {
+	return s.buffers
+}
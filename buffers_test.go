@@ -0,0 +1,35 @@
+package s3fs_test
+
+import (
+	"testing"
+
+	"github.com/packrat386/s3fs"
+	"github.com/packrat386/s3fs/s3fstest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBufferPool(t *testing.T) {
+	pool := s3fs.NewBufferPool(1024)
+
+	buf := pool.Get()
+	require.Len(t, buf, 1024)
+
+	pool.Put(buf[:10])
+	require.Len(t, pool.Get(), 1024)
+
+	// buffers of the wrong size are dropped
+	pool.Put(make([]byte, 10))
+	require.Len(t, pool.Get(), 1024)
+}
+
+func TestWithBufferSize(t *testing.T) {
+	myFS := s3fs.NewFS(s3fstest.NewMemStore())
+	require.Len(t, myFS.Buffers().Get(), 32*1024)
+
+	myFS = s3fs.NewFS(s3fstest.NewMemStore(), s3fs.WithBufferSize(1<<20))
+	require.Len(t, myFS.Buffers().Get(), 1<<20)
+
+	pool := s3fs.NewBufferPool(4096)
+	myFS = s3fs.NewFS(s3fstest.NewMemStore(), s3fs.WithBufferPool(pool))
+	require.Same(t, pool, myFS.Buffers())
+}
@@ -0,0 +1,407 @@
+package s3fs
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxCachedObjectSize is the largest object WithCache caches. Bigger ones are
+// streamed as usual.
+var maxCachedObjectSize int64 = 16 << 20
+
+// Cache holds the contents of files read through an FS, see WithCache. It must be safe
+// for concurrent use.
+type Cache interface {
+	// Get returns the entry for key, if there is one. The entry must not be modified.
+	Get(key string) (*CacheEntry, bool)
+
+	// Put stores entry for key, replacing any entry it already had.
+	Put(key string, entry *CacheEntry)
+
+	// Delete removes the entry for key, if there is one.
+	Delete(key string)
+}
+
+// CacheEntry is the cached content of a file.
+type CacheEntry struct {
+	Info ObjectInfo
+	Data []byte
+
+	// Validated is when the entry was last known to match the object.
+	Validated time.Time
+}
+
+// WithCache keeps the contents of files of up to 16 MiB in c when they are read.
+// Entries validated less than maxAge ago are served without making any request.
+// Older entries are revalidated with a conditional GET for their ETag: if the object
+// hasn't changed S3 answers 304 Not Modified, the entry is served again and counts
+// as validated from then, and nothing is downloaded. Only changed objects are
+// downloaded again. A maxAge of zero revalidates on every open. Writes through the
+// FS remove what they change from the cache.
+func WithCache(c Cache, maxAge time.Duration) Option {
+	return func(s *S3FS) {
+		s.cache = c
+		s.cacheMaxAge = maxAge
+	}
+}
+
+// openCached opens the file at key, from the cache if it can.
+func openCached(ctx context.Context, s *S3FS, key string) (fs.File, error) {
+	now := s.clock.Now()
+
+	entry, ok := s.cache.Get(key)
+	if ok && now.Sub(entry.Validated) < s.cacheMaxAge {
+		return newCachedFile(s, entry), nil
+	}
+
+	opts := GetOptions{}
+	if ok {
+		opts.IfNoneMatch = entry.Info.ETag
+	}
+
+	stats := TransferStats{}
+	object, err := getObject(ctx, s.store, key, opts, &stats)
+	if ok && errors.Is(err, ErrNotModified) {
+		renewed := *entry
+		renewed.Validated = now
+		s.cache.Put(key, &renewed)
+
+		f := newCachedFile(s, &renewed)
+		f.stats = stats
+
+		return f, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("error getting s3 object: %w", err)
+	}
+
+	if err := s.checkSize(key, object.Info.Size); err != nil {
+		object.Body.Close()
+		return nil, err
+	}
+
+	if object.Info.Size > maxCachedObjectSize {
+		s.cache.Delete(key)
+
+		f := newS3File(s, key, object)
+		f.stats = stats
+
+		return f, nil
+	}
+
+	defer object.Body.Close()
+
+	data, err := io.ReadAll(object.Body)
+	if err != nil {
+		return nil, fmt.Errorf("could not read %s: %w", key, err)
+	}
+
+	// a truncated body mustn't be served from the cache from then on
+	if int64(len(data)) != object.Info.Size {
+		return nil, fmt.Errorf("could not read %s: %w: read %d of %d bytes", key, ErrTruncated, len(data), object.Info.Size)
+	}
+
+	entry = &CacheEntry{Info: object.Info, Data: data, Validated: now}
+	s.cache.Put(key, entry)
+
+	f := newCachedFile(s, entry)
+	f.stats = stats
+	f.stats.BytesRead = int64(len(data))
+
+	return f, nil
+}
+
+// mutated is called after key has been changed through the FS.
+func (s *S3FS) mutated(op, key, source, etag string) {
+	s.journal.record(op, key, source, etag)
+
+	if s.cache != nil {
+		s.cache.Delete(key)
+	}
+}
+
+// cachedFile is an open file served from a CacheEntry.
+type cachedFile struct {
+	*bytes.Reader
+	fileInfo s3FileInfo
+	stale    bool
+	stats    TransferStats
+}
+
+func newCachedFile(s *S3FS, entry *CacheEntry) *cachedFile {
+	info := entry.Info
+
+	return &cachedFile{
+		Reader: bytes.NewReader(entry.Data),
+		fileInfo: s3FileInfo{
+			name:    path.Base(info.Key),
+			mode:    fs.FileMode(0400),
+			size:    int64(len(entry.Data)),
+			modTime: info.LastModified,
+			object:  &info,
+			key:     info.Key,
+			loc:     s.location,
+		},
+	}
+}
+
+func (f *cachedFile) Stat() (fs.FileInfo, error) {
+	return &f.fileInfo, nil
+}
+
+func (f *cachedFile) Close() error {
+	return nil
+}
+
+// memoryCache is a Cache in memory, see NewMemoryCache.
+type memoryCache struct {
+	maxBytes int64
+
+	mu      sync.Mutex
+	bytes   int64
+	entries map[string]*list.Element
+	lru     *list.List // of *memoryCacheItem, most recently used first
+}
+
+type memoryCacheItem struct {
+	key   string
+	entry *CacheEntry
+}
+
+// NewMemoryCache returns a Cache that keeps up to maxBytes of file contents in memory,
+// dropping the least recently used files when it's full.
+func NewMemoryCache(maxBytes int64) Cache {
+	return &memoryCache{
+		maxBytes: maxBytes,
+		entries:  map[string]*list.Element{},
+		lru:      list.New(),
+	}
+}
+
+func (c *memoryCache) Get(key string) (*CacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+
+	c.lru.MoveToFront(e)
+
+	return e.Value.(*memoryCacheItem).entry, true
+}
+
+func (c *memoryCache) Put(key string, entry *CacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.remove(key)
+
+	if int64(len(entry.Data)) > c.maxBytes {
+		return
+	}
+
+	c.entries[key] = c.lru.PushFront(&memoryCacheItem{key: key, entry: entry})
+	c.bytes += int64(len(entry.Data))
+
+	for c.bytes > c.maxBytes {
+		c.remove(c.lru.Back().Value.(*memoryCacheItem).key)
+	}
+}
+
+func (c *memoryCache) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.remove(key)
+}
+
+func (c *memoryCache) remove(key string) {
+	e, ok := c.entries[key]
+	if !ok {
+		return
+	}
+
+	c.lru.Remove(e)
+	delete(c.entries, key)
+	c.bytes -= int64(len(e.Value.(*memoryCacheItem).entry.Data))
+}
+
+// diskCache is a Cache in a local directory, see NewDiskCache.
+type diskCache struct {
+	dir      string
+	maxBytes int64
+
+	mu sync.Mutex
+}
+
+// diskCacheMeta is stored next to the data of a disk cache entry.
+type diskCacheMeta struct {
+	Info      ObjectInfo `json:"info"`
+	Validated time.Time  `json:"validated"`
+}
+
+// NewDiskCache returns a Cache that keeps up to maxBytes of file contents in dir,
+// dropping the least recently validated files when it's full. Entries survive
+// restarts, so a new FS using the same dir starts out warm.
+func NewDiskCache(dir string, maxBytes int64) (Cache, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("could not create cache directory: %w", err)
+	}
+
+	return &diskCache{dir: dir, maxBytes: maxBytes}, nil
+}
+
+func (c *diskCache) paths(key string) (data, meta string) {
+	sum := sha256.Sum256([]byte(key))
+	base := filepath.Join(c.dir, hex.EncodeToString(sum[:]))
+
+	return base + ".data", base + ".json"
+}
+
+func (c *diskCache) Get(key string) (*CacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	dataPath, metaPath := c.paths(key)
+
+	raw, err := os.ReadFile(metaPath)
+	if err != nil {
+		return nil, false
+	}
+
+	meta := diskCacheMeta{}
+	if err := json.Unmarshal(raw, &meta); err != nil || meta.Info.Key != key {
+		return nil, false
+	}
+
+	data, err := os.ReadFile(dataPath)
+	if err != nil {
+		return nil, false
+	}
+
+	return &CacheEntry{Info: meta.Info, Data: data, Validated: meta.Validated}, true
+}
+
+func (c *diskCache) Put(key string, entry *CacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	dataPath, metaPath := c.paths(key)
+
+	info := entry.Info
+	info.Key = key
+
+	meta, err := json.Marshal(diskCacheMeta{Info: info, Validated: entry.Validated})
+	if err != nil {
+		return
+	}
+
+	if writeFileAtomic(dataPath, entry.Data) != nil || writeFileAtomic(metaPath, meta) != nil {
+		os.Remove(dataPath)
+		os.Remove(metaPath)
+		return
+	}
+
+	c.evict()
+}
+
+func (c *diskCache) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	dataPath, metaPath := c.paths(key)
+	os.Remove(metaPath)
+	os.Remove(dataPath)
+}
+
+// evict removes the least recently validated entries until the cache fits in
+// maxBytes.
+func (c *diskCache) evict() {
+	files, err := os.ReadDir(c.dir)
+	if err != nil {
+		return
+	}
+
+	type cached struct {
+		base    string
+		size    int64
+		modTime time.Time
+	}
+
+	entries := []cached{}
+	total := int64(0)
+	for _, f := range files {
+		if !strings.HasSuffix(f.Name(), ".json") {
+			continue
+		}
+
+		base := filepath.Join(c.dir, strings.TrimSuffix(f.Name(), ".json"))
+
+		meta, err := f.Info()
+		if err != nil {
+			continue
+		}
+
+		data, err := os.Stat(base + ".data")
+		if err != nil {
+			continue
+		}
+
+		entries = append(entries, cached{base: base, size: data.Size(), modTime: meta.ModTime()})
+		total += data.Size()
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].modTime.Before(entries[j].modTime)
+	})
+
+	for _, e := range entries {
+		if total <= c.maxBytes {
+			break
+		}
+
+		os.Remove(e.base + ".json")
+		os.Remove(e.base + ".data")
+		total -= e.size
+	}
+}
+
+// writeFileAtomic writes data to name by way of a temporary file, so readers never
+// see it half written.
+func writeFileAtomic(name string, data []byte) error {
+	f, err := os.CreateTemp(filepath.Dir(name), ".tmp-*")
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+
+	return os.Rename(f.Name(), name)
+}
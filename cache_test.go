@@ -0,0 +1,125 @@
+package s3fs_test
+
+import (
+	"io/fs"
+	"testing"
+	"time"
+
+	"github.com/packrat386/s3fs"
+	"github.com/packrat386/s3fs/s3fstest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithCache(t *testing.T) {
+	store := &rangeStore{MemStore: s3fstest.NewMemStore()}
+	store.WriteFile("a.txt", "one")
+
+	myFS := s3fs.NewFS(store, s3fs.WithCache(s3fs.NewMemoryCache(1<<20), time.Hour))
+
+	for i := 0; i < 3; i++ {
+		data, err := fs.ReadFile(myFS, "a.txt")
+		require.Nil(t, err)
+		require.Equal(t, "one", string(data))
+	}
+
+	require.Len(t, store.ranges, 1)
+
+	// writes through the FS invalidate
+	require.Nil(t, myFS.WriteFile("a.txt", []byte("two")))
+
+	data, err := fs.ReadFile(myFS, "a.txt")
+	require.Nil(t, err)
+	require.Equal(t, "two", string(data))
+	require.Len(t, store.ranges, 2)
+}
+
+func TestWithCache_Revalidate(t *testing.T) {
+	store := &rangeStore{MemStore: s3fstest.NewMemStore()}
+	store.WriteFile("a.txt", "one")
+
+	myFS := s3fs.NewFS(store, s3fs.WithCache(s3fs.NewMemoryCache(1<<20), 0))
+
+	data, err := fs.ReadFile(myFS, "a.txt")
+	require.Nil(t, err)
+	require.Equal(t, "one", string(data))
+
+	data, err = fs.ReadFile(myFS, "a.txt")
+	require.Nil(t, err)
+	require.Equal(t, "one", string(data))
+
+	// revalidated by ETag
+	require.Len(t, store.ranges, 2)
+	require.Empty(t, store.ranges[0].IfNoneMatch)
+	require.Equal(t, etagOf(t, store, "a.txt"), store.ranges[1].IfNoneMatch)
+
+	// changed behind the FS's back
+	store.WriteFile("a.txt", "changed")
+
+	data, err = fs.ReadFile(myFS, "a.txt")
+	require.Nil(t, err)
+	require.Equal(t, "changed", string(data))
+}
+
+func TestNewMemoryCache(t *testing.T) {
+	c := s3fs.NewMemoryCache(10)
+
+	c.Put("a", &s3fs.CacheEntry{Data: []byte("aaaa")})
+	c.Put("b", &s3fs.CacheEntry{Data: []byte("bbbb")})
+	_, ok := c.Get("a")
+	require.True(t, ok)
+
+	// b is the least recently used
+	c.Put("c", &s3fs.CacheEntry{Data: []byte("cccc")})
+	_, ok = c.Get("b")
+	require.False(t, ok)
+	_, ok = c.Get("a")
+	require.True(t, ok)
+
+	c.Put("big", &s3fs.CacheEntry{Data: []byte("too big to cache")})
+	_, ok = c.Get("big")
+	require.False(t, ok)
+
+	c.Delete("a")
+	_, ok = c.Get("a")
+	require.False(t, ok)
+}
+
+func TestNewDiskCache(t *testing.T) {
+	dir := t.TempDir()
+
+	store := &rangeStore{MemStore: s3fstest.NewMemStore()}
+	store.WriteFile("a.txt", "one")
+
+	c, err := s3fs.NewDiskCache(dir, 1<<20)
+	require.Nil(t, err)
+
+	myFS := s3fs.NewFS(store, s3fs.WithCache(c, time.Hour))
+	_, err = fs.ReadFile(myFS, "a.txt")
+	require.Nil(t, err)
+
+	// a new cache in the same directory is warm
+	c, err = s3fs.NewDiskCache(dir, 1<<20)
+	require.Nil(t, err)
+
+	myFS = s3fs.NewFS(store, s3fs.WithCache(c, time.Hour))
+	data, err := fs.ReadFile(myFS, "a.txt")
+	require.Nil(t, err)
+	require.Equal(t, "one", string(data))
+	require.Len(t, store.ranges, 1)
+
+	entry, ok := c.Get("a.txt")
+	require.True(t, ok)
+	require.Equal(t, etagOf(t, store, "a.txt"), entry.Info.ETag)
+
+	small, err := s3fs.NewDiskCache(t.TempDir(), 6)
+	require.Nil(t, err)
+
+	small.Put("a", &s3fs.CacheEntry{Info: s3fs.ObjectInfo{Key: "a"}, Data: []byte("aaaa")})
+	time.Sleep(10 * time.Millisecond)
+	small.Put("b", &s3fs.CacheEntry{Info: s3fs.ObjectInfo{Key: "b"}, Data: []byte("bbbb")})
+
+	_, ok = small.Get("a")
+	require.False(t, ok)
+	_, ok = small.Get("b")
+	require.True(t, ok)
+}
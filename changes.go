@@ -0,0 +1,309 @@
+package s3fs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/arn"
+	"github.com/aws/aws-sdk-go/service/sqs"
+)
+
+// ChangeKind is what happened to a file in a ChangeEvent.
+type ChangeKind string
+
+const (
+	ChangeCreated  ChangeKind = "create"
+	ChangeDeleted  ChangeKind = "delete"
+	ChangeRestored ChangeKind = "restore"
+)
+
+// ChangeEvent is a change to a file, from an S3 event notification.
+type ChangeEvent struct {
+	Kind ChangeKind
+
+	// Name is the name of the file in the FS.
+	Name string
+
+	// Size and ETag are only set for ChangeCreated events.
+	Size int64
+	ETag string
+
+	// Time is when S3 says the change happened.
+	Time time.Time
+}
+
+// ChangeSource delivers S3 event notifications to Changes. Each message may be an S3
+// event notification, one wrapped in an SNS notification, or an EventBridge event.
+type ChangeSource interface {
+	// Receive waits for the next batch of messages and returns their bodies.
+	Receive(ctx context.Context) ([][]byte, error)
+}
+
+// changeRetryInterval is how long Changes waits after its source fails before it
+// tries again.
+var changeRetryInterval = 5 * time.Second
+
+// WithChangeSource sets where Changes gets S3 event notifications from, usually an
+// SQS queue subscribed to them (see NewSQSChangeSource).
+func WithChangeSource(src ChangeSource) Option {
+	return func(s *S3FS) {
+		s.changeSource = src
+	}
+}
+
+// ErrNoChangeSource is returned by Changes when the FS wasn't created with
+// WithChangeSource.
+var ErrNoChangeSource = errors.New("no change source configured")
+
+// Changes subscribes to changes to the files of the FS, normalizing S3 event
+// notifications delivered by the FS's ChangeSource, however they were routed, into
+// ChangeEvents. Events for other buckets, for directory markers, and for keys that
+// aren't valid names are dropped. If the source fails, Changes waits a few seconds
+// and tries again. The channel is closed once ctx is done.
+//
+// S3 delivers notifications at least once and not necessarily in order, so consumers
+// should be idempotent and compare Time (or Stat the file) before acting.
+func (s *S3FS) Changes(ctx context.Context) (<-chan ChangeEvent, error) {
+	if s.bucketErr != nil {
+		return nil, s.bucketErr
+	}
+
+	if s.changeSource == nil {
+		return nil, ErrNoChangeSource
+	}
+
+	// scoped FSes only see changes under their prefix, by names relative to it
+	base := s.baseStore()
+	scope, _ := base.(*prefixStore)
+	if scope != nil {
+		base = scope.store
+	}
+
+	bucket := ""
+	if store, ok := base.(*s3Store); ok && !arn.IsARN(store.bucket) {
+		bucket = store.bucket
+	}
+
+	events := make(chan ChangeEvent)
+	go func() {
+		defer close(events)
+
+		for ctx.Err() == nil {
+			msgs, err := s.changeSource.Receive(ctx)
+			if err != nil {
+				select {
+				case <-ctx.Done():
+				case <-s.clock.After(changeRetryInterval):
+				}
+
+				continue
+			}
+
+			for _, msg := range msgs {
+				for _, change := range parseChanges(msg) {
+					if bucket != "" && change.bucket != bucket {
+						continue
+					}
+
+					if strings.HasSuffix(change.key, "/") {
+						continue
+					}
+
+					if scope != nil {
+						key, ok := scope.unscoped(change.key)
+						if !ok {
+							continue
+						}
+
+						change.key = key
+					}
+
+					if _, err := s.key("changes", change.key); err != nil {
+						continue
+					}
+
+					change.event.Name = change.key
+
+					select {
+					case events <- change.event:
+					case <-ctx.Done():
+						return
+					}
+				}
+			}
+		}
+	}()
+
+	return events, nil
+}
+
+// rawChange is a change parsed from a notification, before it is checked against
+// the FS.
+type rawChange struct {
+	bucket string
+	key    string
+	event  ChangeEvent
+}
+
+// s3Notification is an S3 event notification, or the parts of one we need.
+type s3Notification struct {
+	Records []struct {
+		EventName string    `json:"eventName"`
+		EventTime time.Time `json:"eventTime"`
+		S3        struct {
+			Bucket struct {
+				Name string `json:"name"`
+			} `json:"bucket"`
+			Object struct {
+				Key  string `json:"key"`
+				Size int64  `json:"size"`
+				ETag string `json:"eTag"`
+			} `json:"object"`
+		} `json:"s3"`
+	} `json:"Records"`
+
+	// set if it's wrapped in an SNS notification
+	Type    string `json:"Type"`
+	Message string `json:"Message"`
+
+	// set if it's an EventBridge event
+	DetailType string    `json:"detail-type"`
+	Time       time.Time `json:"time"`
+	Detail     struct {
+		Bucket struct {
+			Name string `json:"name"`
+		} `json:"bucket"`
+		Object struct {
+			Key  string `json:"key"`
+			Size int64  `json:"size"`
+			ETag string `json:"etag"`
+		} `json:"object"`
+	} `json:"detail"`
+}
+
+// parseChanges returns the changes in a notification message, ignoring anything it
+// doesn't understand.
+func parseChanges(msg []byte) []rawChange {
+	n := s3Notification{}
+	if err := json.Unmarshal(msg, &n); err != nil {
+		return nil
+	}
+
+	if n.Type == "Notification" && n.Message != "" {
+		return parseChanges([]byte(n.Message))
+	}
+
+	if n.DetailType != "" {
+		kind, ok := map[string]ChangeKind{
+			"Object Created":           ChangeCreated,
+			"Object Deleted":           ChangeDeleted,
+			"Object Restore Completed": ChangeRestored,
+		}[n.DetailType]
+
+		if !ok {
+			return nil
+		}
+
+		return []rawChange{{
+			bucket: n.Detail.Bucket.Name,
+			key:    n.Detail.Object.Key,
+			event:  newChangeEvent(kind, n.Detail.Object.Size, n.Detail.Object.ETag, n.Time),
+		}}
+	}
+
+	changes := []rawChange{}
+	for _, r := range n.Records {
+		var kind ChangeKind
+		switch {
+		case strings.HasPrefix(r.EventName, "ObjectCreated:"):
+			kind = ChangeCreated
+		case strings.HasPrefix(r.EventName, "ObjectRemoved:"), strings.HasPrefix(r.EventName, "LifecycleExpiration:"):
+			kind = ChangeDeleted
+		case r.EventName == "ObjectRestore:Completed":
+			kind = ChangeRestored
+		default:
+			continue
+		}
+
+		// keys in S3 notifications are form encoded
+		key, err := url.QueryUnescape(r.S3.Object.Key)
+		if err != nil {
+			continue
+		}
+
+		changes = append(changes, rawChange{
+			bucket: r.S3.Bucket.Name,
+			key:    key,
+			event:  newChangeEvent(kind, r.S3.Object.Size, r.S3.Object.ETag, r.EventTime),
+		})
+	}
+
+	return changes
+}
+
+func newChangeEvent(kind ChangeKind, size int64, etag string, at time.Time) ChangeEvent {
+	event := ChangeEvent{Kind: kind, Time: at}
+	if kind == ChangeCreated {
+		event.Size = size
+		event.ETag = `"` + strings.Trim(etag, `"`) + `"`
+	}
+
+	return event
+}
+
+// sqsChangeSource is a ChangeSource reading from an SQS queue.
+type sqsChangeSource struct {
+	client   *sqs.SQS
+	queueURL string
+}
+
+// NewSQSChangeSource returns a ChangeSource that long polls the SQS queue at
+// queueURL, which should receive the bucket's event notifications directly, through
+// an SNS topic, or from an EventBridge rule. Messages are deleted from the queue as
+// soon as they have been received.
+func NewSQSChangeSource(client *sqs.SQS, queueURL string) ChangeSource {
+	return &sqsChangeSource{client: client, queueURL: queueURL}
+}
+
+func (q *sqsChangeSource) Receive(ctx context.Context) ([][]byte, error) {
+	out, err := q.client.ReceiveMessageWithContext(ctx, &sqs.ReceiveMessageInput{
+		QueueUrl:            aws.String(q.queueURL),
+		MaxNumberOfMessages: aws.Int64(10),
+		WaitTimeSeconds:     aws.Int64(20),
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("could not receive from %s: %w", q.queueURL, err)
+	}
+
+	if len(out.Messages) == 0 {
+		return nil, nil
+	}
+
+	bodies := make([][]byte, 0, len(out.Messages))
+	entries := make([]*sqs.DeleteMessageBatchRequestEntry, 0, len(out.Messages))
+	for _, m := range out.Messages {
+		bodies = append(bodies, []byte(aws.StringValue(m.Body)))
+		entries = append(entries, &sqs.DeleteMessageBatchRequestEntry{
+			Id:            m.MessageId,
+			ReceiptHandle: m.ReceiptHandle,
+		})
+	}
+
+	_, err = q.client.DeleteMessageBatchWithContext(ctx, &sqs.DeleteMessageBatchInput{
+		QueueUrl: aws.String(q.queueURL),
+		Entries:  entries,
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("could not delete messages from %s: %w", q.queueURL, err)
+	}
+
+	return bodies, nil
+}
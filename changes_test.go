@@ -0,0 +1,85 @@
+package s3fs_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/packrat386/s3fs"
+	"github.com/packrat386/s3fs/s3fstest"
+	"github.com/stretchr/testify/require"
+)
+
+// chanSource is a ChangeSource delivering the messages sent to it.
+type chanSource chan string
+
+func (c chanSource) Receive(ctx context.Context) ([][]byte, error) {
+	select {
+	case msg := <-c:
+		return [][]byte{[]byte(msg)}, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+const s3Event = `{"Records":[
+	{"eventName":"ObjectCreated:Put","eventTime":"2024-01-02T03:04:05.000Z","s3":{"bucket":{"name":"b"},"object":{"key":"dir/a+file.txt","size":5,"eTag":"abc"}}},
+	{"eventName":"ObjectRemoved:Delete","eventTime":"2024-01-02T03:04:06.000Z","s3":{"bucket":{"name":"b"},"object":{"key":"old.txt"}}},
+	{"eventName":"ObjectCreated:Put","eventTime":"2024-01-02T03:04:07.000Z","s3":{"bucket":{"name":"b"},"object":{"key":"dir/","size":0}}},
+	{"eventName":"ObjectRestore:Post","eventTime":"2024-01-02T03:04:08.000Z","s3":{"bucket":{"name":"b"},"object":{"key":"cold.bin"}}}
+]}`
+
+const snsEvent = `{"Type":"Notification","Message":"{\"Records\":[{\"eventName\":\"ObjectRestore:Completed\",\"eventTime\":\"2024-01-02T03:04:09.000Z\",\"s3\":{\"bucket\":{\"name\":\"b\"},\"object\":{\"key\":\"cold.bin\"}}}]}"}`
+
+const eventBridgeEvent = `{"detail-type":"Object Created","time":"2024-01-02T03:04:10Z","detail":{"bucket":{"name":"b"},"object":{"key":"new.txt","size":3,"etag":"def"}}}`
+
+func TestChanges(t *testing.T) {
+	src := make(chanSource, 4)
+	myFS := s3fs.NewFS(s3fstest.NewMemStore(), s3fs.WithChangeSource(src))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	events, err := myFS.Changes(ctx)
+	require.Nil(t, err)
+
+	src <- s3Event
+	src <- `{"Event":"s3:TestEvent"}`
+	src <- snsEvent
+	src <- eventBridgeEvent
+
+	got := []s3fs.ChangeEvent{}
+	for len(got) < 4 {
+		select {
+		case e := <-events:
+			got = append(got, e)
+		case <-time.After(time.Second):
+			t.Fatalf("only got %d events", len(got))
+		}
+	}
+
+	at := func(s string) time.Time {
+		tm, err := time.Parse(time.RFC3339, s)
+		require.Nil(t, err)
+		return tm
+	}
+
+	require.Equal(t, []s3fs.ChangeEvent{
+		{Kind: s3fs.ChangeCreated, Name: "dir/a file.txt", Size: 5, ETag: `"abc"`, Time: at("2024-01-02T03:04:05Z")},
+		{Kind: s3fs.ChangeDeleted, Name: "old.txt", Time: at("2024-01-02T03:04:06Z")},
+		{Kind: s3fs.ChangeRestored, Name: "cold.bin", Time: at("2024-01-02T03:04:09Z")},
+		{Kind: s3fs.ChangeCreated, Name: "new.txt", Size: 3, ETag: `"def"`, Time: at("2024-01-02T03:04:10Z")},
+	}, got)
+
+	cancel()
+	for range events {
+	}
+}
+
+func TestChanges_NoSource(t *testing.T) {
+	myFS := s3fs.NewFS(s3fstest.NewMemStore())
+
+	_, err := myFS.Changes(context.Background())
+	require.True(t, errors.Is(err, s3fs.ErrNoChangeSource))
+}
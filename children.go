@@ -0,0 +1,168 @@
+package s3fs
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+)
+
+// HasChildren reports whether the directory at name has anything in it, without
+// listing all of it. It is false for names that don't exist, or are files.
+func (s *S3FS) HasChildren(ctx context.Context, name string) (bool, error) {
+	found := false
+	err := s.listChildren(ctx, name, ListOptions{MaxKeys: 1}, func(page *ListPage) bool {
+		found = s.pageEntries(page) > 0
+		return !found
+	})
+
+	return found, err
+}
+
+// EntryCount counts the entries of the directory at name, giving up once it has
+// counted at least limit of them, so it's cheap even for huge directories. complete
+// reports whether count is every entry; if it's false the directory has at least
+// count entries. A limit of zero or less counts everything.
+func (s *S3FS) EntryCount(ctx context.Context, name string, limit int) (count int, complete bool, err error) {
+	complete = true
+	err = s.listChildren(ctx, name, ListOptions{}, func(page *ListPage) bool {
+		count += s.pageEntries(page)
+		if limit > 0 && count >= limit {
+			complete = false
+			return false
+		}
+
+		return true
+	})
+
+	if err != nil {
+		return 0, false, err
+	}
+
+	return count, complete, nil
+}
+
+// ReadDirAfter returns up to n entries of the directory at name whose names sort after
+// after, in order, without listing the entries before them. A limit of zero or less
+// returns every one of them. It's meant for directories of lexically sortable names,
+// such as zero padded sequence numbers or timestamps, that are read a little at a
+// time: pass the name of the last entry read as after to pick up where it left off.
+func (s *S3FS) ReadDirAfter(ctx context.Context, name string, after string, n int) ([]fs.DirEntry, error) {
+	dir, err := s.key("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+
+	s.auditRead(OpReadDir, dir)
+
+	opts := ListOptions{}
+	if after != "" {
+		key, err := s.key("readdir", path.Join(name, after))
+		if err != nil {
+			return nil, err
+		}
+
+		opts.StartAfter = key
+	}
+
+	if n > 0 {
+		opts.MaxKeys = n
+	}
+
+	entries := []fs.DirEntry{}
+	err = s.listChildren(ctx, name, opts, func(page *ListPage) bool {
+		for _, obj := range page.Objects {
+			obj := obj
+			if s.skipStorageClasses[obj.StorageClass] || strings.HasSuffix(obj.Key, "/") {
+				continue
+			}
+
+			entries = append(entries, &s3FileInfo{
+				name:    path.Base(obj.Key),
+				mode:    fs.FileMode(0400),
+				size:    obj.Size,
+				modTime: obj.LastModified,
+				object:  &obj,
+				key:     obj.Key,
+				loc:     s.location,
+			})
+		}
+
+		for _, cp := range page.CommonPrefixes {
+			entries = append(entries, &s3FileInfo{
+				name: path.Base(cp),
+				mode: fs.FileMode(0400) | fs.ModeDir,
+				key:  cp,
+				loc:  s.location,
+			})
+		}
+
+		return n <= 0 || len(entries) < n
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	// objects and common prefixes come in separate lists, so put them back in the
+	// order of their keys
+	listed := func(e fs.DirEntry) string {
+		if e.IsDir() {
+			return e.Name() + "/"
+		}
+
+		return e.Name()
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return listed(entries[i]) < listed(entries[j])
+	})
+
+	if n > 0 && len(entries) > n {
+		entries = entries[:n]
+	}
+
+	return entries, nil
+}
+
+// listChildren lists the directory at name with opts, calling fn with each page until
+// it returns false.
+func (s *S3FS) listChildren(ctx context.Context, name string, opts ListOptions, fn func(*ListPage) bool) error {
+	if s.bucketErr != nil {
+		return s.bucketErr
+	}
+
+	key, err := s.key("readdir", name)
+	if err != nil {
+		return err
+	}
+
+	prefix := ""
+	if key != "" {
+		prefix = key + "/"
+	}
+
+	opts.Delimiter = "/"
+	err = s.store.List(ctx, prefix, opts, fn)
+
+	if err != nil {
+		return fmt.Errorf("could not list s3 objects: %w", err)
+	}
+
+	return nil
+}
+
+// pageEntries counts the directory entries in a page of a listing, leaving out any
+// directory marker object and objects hidden by WithSkipStorageClasses.
+func (s *S3FS) pageEntries(page *ListPage) int {
+	n := len(page.CommonPrefixes)
+	for _, obj := range page.Objects {
+		if !s.skipStorageClasses[obj.StorageClass] && !strings.HasSuffix(obj.Key, "/") {
+			n++
+		}
+	}
+
+	return n
+}
@@ -0,0 +1,80 @@
+package s3fs_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/packrat386/s3fs"
+	"github.com/packrat386/s3fs/s3fstest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHasChildren(t *testing.T) {
+	store := s3fstest.NewMemStore()
+	store.WriteFile("full/a.json", `{}`)
+	store.WriteFile("marker/", ``)
+	store.WriteFile("file.json", `{}`)
+
+	myFS := s3fs.NewFS(store)
+	ctx := context.Background()
+
+	for name, expected := range map[string]bool{
+		".":         true,
+		"full":      true,
+		"marker":    false,
+		"file.json": false,
+		"missing":   false,
+	} {
+		has, err := myFS.HasChildren(ctx, name)
+		require.Nil(t, err)
+		require.Equal(t, expected, has, name)
+	}
+}
+
+func TestEntryCount(t *testing.T) {
+	store := s3fstest.NewMemStore()
+	store.PageSize = 10
+	for i := 0; i < 25; i++ {
+		store.WriteFile(fmt.Sprintf("dir/%02d.json", i), `{}`)
+	}
+	store.WriteFile("dir/sub/a.json", `{}`)
+
+	myFS := s3fs.NewFS(store)
+	ctx := context.Background()
+
+	count, complete, err := myFS.EntryCount(ctx, "dir", 0)
+	require.Nil(t, err)
+	require.True(t, complete)
+	require.Equal(t, 26, count)
+
+	count, complete, err = myFS.EntryCount(ctx, "dir", 15)
+	require.Nil(t, err)
+	require.False(t, complete)
+	require.Equal(t, 20, count)
+}
+
+func TestReadDirAfter(t *testing.T) {
+	store := s3fstest.NewMemStore()
+	store.PageSize = 10
+	for i := 0; i < 25; i++ {
+		store.WriteFile(fmt.Sprintf("dir/%02d.json", i), `{}`)
+	}
+	store.WriteFile("dir/15/a.json", `{}`)
+
+	myFS := s3fs.NewFS(store)
+	ctx := context.Background()
+
+	entries, err := myFS.ReadDirAfter(ctx, "dir", "12.json", 4)
+	require.Nil(t, err)
+	require.Equal(t, []string{"13.json", "14.json", "15.json", "15"}, entryNames(entries))
+	require.True(t, entries[3].IsDir())
+
+	entries, err = myFS.ReadDirAfter(ctx, "dir", "22.json", 0)
+	require.Nil(t, err)
+	require.Equal(t, []string{"23.json", "24.json"}, entryNames(entries))
+
+	entries, err = myFS.ReadDirAfter(ctx, "dir", "", 2)
+	require.Nil(t, err)
+	require.Equal(t, []string{"00.json", "01.json"}, entryNames(entries))
+}
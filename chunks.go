@@ -0,0 +1,418 @@
+package s3fs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"strconv"
+	"strings"
+)
+
+const (
+	// defaultChunkPrefix is where chunked files keep their chunks.
+	defaultChunkPrefix = ".s3fs-chunks/"
+
+	// maxChunkSize is the largest chunk WithChunking allows, so that a file of a
+	// single chunk can be copied into place with one CopyObject.
+	maxChunkSize = 5 << 30
+
+	// chunkedMetadata is the metadata key that marks a chunk manifest, set to the
+	// size of the whole file.
+	chunkedMetadata = "s3fs-chunked"
+)
+
+// WithChunking makes files written through the FS that are bigger than chunkSize be
+// stored as numbered chunk objects of chunkSize bytes under ".s3fs-chunks/", plus a
+// small manifest at their name, so they can exceed the 5 TB limit on S3 objects.
+// Files that fit in one chunk are stored as usual. Open transparently stitches the
+// chunks back together, and removing or replacing a chunked file removes its chunks.
+// chunkSize is at most 5 GiB, which is also the default.
+//
+// Listings only see the manifest, so ReadDir reports the size of the manifest rather
+// than of the file. Stat on the opened file reports the real size. The chunks
+// themselves are ordinary objects, so ".s3fs-chunks" shows up as a directory at the
+// root and WalkDir descends into it. Chunked files are always streamed, regardless of
+// WithCache, WithColumnarAccess and WithStreamingConcurrency. Files can't be appended
+// to or written with WriteFileIf while chunking is on.
+func WithChunking(chunkSize int64) Option {
+	return func(s *S3FS) {
+		if chunkSize <= 0 || chunkSize > maxChunkSize {
+			chunkSize = maxChunkSize
+		}
+
+		s.chunkSize = chunkSize
+	}
+}
+
+// chunkManifest lists the chunks of a chunked file, in order.
+type chunkManifest struct {
+	Size   int64      `json:"size"`
+	Chunks []chunkRef `json:"chunks"`
+}
+
+type chunkRef struct {
+	Key  string `json:"key"`
+	Size int64  `json:"size"`
+	ETag string `json:"etag"`
+}
+
+// metadataValue looks up key in metadata ignoring case, since S3 canonicalizes the
+// case of metadata keys.
+func metadataValue(metadata map[string]string, key string) (string, bool) {
+	for k, v := range metadata {
+		if strings.EqualFold(k, key) {
+			return v, true
+		}
+	}
+
+	return "", false
+}
+
+// newChunkPrefix returns a new prefix for the chunks of key, so that new chunks never
+// overwrite the chunks of the version of key being replaced.
+func newChunkPrefix(key string) (string, error) {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return "", fmt.Errorf("could not generate chunk prefix: %w", err)
+	}
+
+	return defaultChunkPrefix + key + "/" + hex.EncodeToString(id) + "/", nil
+}
+
+// putChunked writes body to key, split into chunks if it's bigger than one.
+func (s *S3FS) putChunked(ctx context.Context, key string, body io.Reader, opts PutOptions) (ObjectInfo, error) {
+	old, err := s.chunkManifest(ctx, key)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+
+	prefix, err := newChunkPrefix(key)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	partSize := uploadPartSize(s.chunkSize)
+
+	m := &chunkManifest{}
+	for i := 0; ; i++ {
+		chunkKey := fmt.Sprintf("%s%08d", prefix, i)
+
+		// the first chunk becomes the file itself if it's the only one
+		chunkOpts := PutOptions{PartSize: partSize}
+		if i == 0 {
+			chunkOpts.ContentType = opts.ContentType
+			chunkOpts.Metadata = opts.Metadata
+		}
+
+		h := newPartHasher(partSize)
+		r := &countingReader{r: io.TeeReader(io.LimitReader(body, s.chunkSize), h)}
+
+		info, err := s.store.Put(ctx, chunkKey, r, chunkOpts)
+		if err == nil && !h.matches(info.ETag) {
+			err = fmt.Errorf("%w: got ETag %s", ErrChecksumMismatch, info.ETag)
+		}
+
+		if err != nil {
+			s.store.Delete(context.Background(), chunkKey)
+			s.deleteChunks(m)
+
+			return ObjectInfo{}, fmt.Errorf("could not write chunk %d of %s: %w", i, key, err)
+		}
+
+		if r.n == 0 && i > 0 {
+			s.store.Delete(ctx, chunkKey)
+			break
+		}
+
+		m.Size += r.n
+		m.Chunks = append(m.Chunks, chunkRef{Key: chunkKey, Size: r.n, ETag: info.ETag})
+
+		if r.n < s.chunkSize {
+			break
+		}
+	}
+
+	var info ObjectInfo
+	if len(m.Chunks) == 1 {
+		info, err = s.putSingleChunk(ctx, key, m.Chunks[0].Key)
+	} else {
+		info, err = s.putChunkManifest(ctx, key, m, opts)
+	}
+
+	if err != nil {
+		s.deleteChunks(m)
+		return ObjectInfo{}, err
+	}
+
+	s.mutated("put", key, "", info.ETag)
+	s.deleteChunks(old)
+
+	return info, nil
+}
+
+// putSingleChunk moves the only chunk of a file into place as a plain object.
+func (s *S3FS) putSingleChunk(ctx context.Context, key, chunkKey string) (ObjectInfo, error) {
+	defer s.store.Delete(context.Background(), chunkKey)
+
+	if err := s.store.Copy(ctx, chunkKey, key, CopyOptions{}); err != nil {
+		return ObjectInfo{}, fmt.Errorf("could not move %s into place: %w", key, err)
+	}
+
+	info, err := s.store.Head(ctx, key)
+	if err != nil {
+		return ObjectInfo{}, fmt.Errorf("could not stat %s: %w", key, err)
+	}
+
+	return info, nil
+}
+
+func (s *S3FS) putChunkManifest(ctx context.Context, key string, m *chunkManifest, opts PutOptions) (ObjectInfo, error) {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return ObjectInfo{}, fmt.Errorf("could not encode chunk manifest of %s: %w", key, err)
+	}
+
+	metadata := map[string]string{}
+	for k, v := range opts.Metadata {
+		if !strings.EqualFold(k, chunkedMetadata) {
+			metadata[k] = v
+		}
+	}
+	metadata[chunkedMetadata] = strconv.FormatInt(m.Size, 10)
+
+	info, err := s.store.Put(ctx, key, strings.NewReader(string(data)), PutOptions{
+		ContentType: opts.ContentType,
+		Metadata:    metadata,
+	})
+	if err != nil {
+		return ObjectInfo{}, fmt.Errorf("could not write chunk manifest of %s: %w", key, err)
+	}
+
+	info.Size = m.Size
+	info.Metadata = metadata
+
+	return info, nil
+}
+
+// chunkManifest returns the manifest of the file at key, or nil if it isn't chunked
+// or doesn't exist.
+func (s *S3FS) chunkManifest(ctx context.Context, key string) (*chunkManifest, error) {
+	info, err := s.store.Head(ctx, key)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("could not stat %s: %w", key, err)
+	}
+
+	if _, ok := metadataValue(info.Metadata, chunkedMetadata); !ok {
+		return nil, nil
+	}
+
+	object, err := s.store.Get(ctx, key, GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("could not get chunk manifest of %s: %w", key, err)
+	}
+	defer object.Body.Close()
+
+	return readChunkManifest(key, object.Body)
+}
+
+func readChunkManifest(key string, r io.Reader) (*chunkManifest, error) {
+	m := &chunkManifest{}
+	if err := json.NewDecoder(r).Decode(m); err != nil {
+		return nil, fmt.Errorf("could not read chunk manifest of %s: %w", key, err)
+	}
+
+	return m, nil
+}
+
+// deleteChunks deletes the chunks of m, if there are any. Failures only leave garbage
+// behind, so they are ignored.
+func (s *S3FS) deleteChunks(m *chunkManifest) {
+	if m == nil {
+		return
+	}
+
+	for _, chunk := range m.Chunks {
+		s.store.Delete(context.Background(), chunk.Key)
+	}
+}
+
+// copyObject copies the object at src to dst, giving a chunked file chunks of its own.
+// It copies src as its staged writes leave it, and replaces any staged for dst.
+func (s *S3FS) copyObject(ctx context.Context, src, dst string) error {
+	if s.writeBack != nil {
+		s.writeBack.wait(src)
+		s.writeBack.forget(dst)
+	}
+
+	if s.chunkSize <= 0 {
+		return s.store.Copy(ctx, src, dst, CopyOptions{})
+	}
+
+	m, err := s.chunkManifest(ctx, src)
+	if err != nil {
+		return err
+	}
+
+	old, err := s.chunkManifest(ctx, dst)
+	if err != nil {
+		return err
+	}
+
+	if m == nil {
+		if err := s.store.Copy(ctx, src, dst, CopyOptions{}); err != nil {
+			return err
+		}
+
+		s.deleteChunks(old)
+
+		return nil
+	}
+
+	info, err := s.store.Head(ctx, src)
+	if err != nil {
+		return err
+	}
+
+	prefix, err := newChunkPrefix(dst)
+	if err != nil {
+		return err
+	}
+
+	copied := &chunkManifest{Size: m.Size}
+	for i, chunk := range m.Chunks {
+		chunkKey := fmt.Sprintf("%s%08d", prefix, i)
+		if err := s.store.Copy(ctx, chunk.Key, chunkKey, CopyOptions{}); err != nil {
+			s.deleteChunks(copied)
+			return err
+		}
+
+		copied.Chunks = append(copied.Chunks, chunkRef{Key: chunkKey, Size: chunk.Size, ETag: chunk.ETag})
+	}
+
+	if _, err := s.putChunkManifest(ctx, dst, copied, PutOptions{ContentType: info.ContentType, Metadata: info.Metadata}); err != nil {
+		s.deleteChunks(copied)
+		return err
+	}
+
+	s.deleteChunks(old)
+
+	return nil
+}
+
+// openChunked opens the file at key, stitching its chunks together if it's chunked.
+func openChunked(ctx context.Context, s *S3FS, key string, opts GetOptions) (*s3File, error) {
+	stats := TransferStats{}
+	object, err := getObject(ctx, s.store, key, opts, &stats)
+	if err != nil {
+		return nil, fmt.Errorf("error getting s3 object: %w", err)
+	}
+
+	if err := s.checkSize(key, object.Info.Size); err != nil {
+		object.Body.Close()
+		return nil, err
+	}
+
+	if _, ok := metadataValue(object.Info.Metadata, chunkedMetadata); ok {
+		m, err := readChunkManifest(key, object.Body)
+		object.Body.Close()
+
+		if err != nil {
+			return nil, err
+		}
+
+		if err := s.checkSize(key, m.Size); err != nil {
+			return nil, err
+		}
+
+		info := object.Info
+		info.Size = m.Size
+
+		body := &chunkedBody{ctx: ctx, store: s.store, key: key, chunks: m.Chunks}
+		f := newS3File(s, key, &Object{Body: body, Info: info})
+		f.stats = stats
+		body.stats = &f.stats
+
+		return f, nil
+	}
+
+	f := newS3File(s, key, object)
+	f.stats = stats
+
+	return f, nil
+}
+
+// chunkedBody reads the chunks of a file one after the other.
+type chunkedBody struct {
+	ctx    context.Context
+	store  ObjectStore
+	key    string
+	chunks []chunkRef
+	stats  *TransferStats
+
+	cur io.ReadCloser
+	err error
+}
+
+func (b *chunkedBody) Read(buf []byte) (int, error) {
+	for b.err == nil {
+		if b.cur == nil {
+			if len(b.chunks) == 0 {
+				b.err = io.EOF
+				break
+			}
+
+			chunk := b.chunks[0]
+			b.chunks = b.chunks[1:]
+
+			object, err := getObject(b.ctx, b.store, chunk.Key, GetOptions{}, b.stats)
+			if err != nil {
+				b.err = fmt.Errorf("could not get chunk of %s: %w", b.key, err)
+				break
+			}
+
+			if object.Info.ETag != chunk.ETag {
+				object.Body.Close()
+				b.err = fmt.Errorf("chunk %s of %s changed", chunk.Key, b.key)
+				break
+			}
+
+			b.cur = object.Body
+		}
+
+		n, err := b.cur.Read(buf)
+		if err == io.EOF {
+			b.cur.Close()
+			b.cur = nil
+			err = nil
+		}
+
+		if err != nil {
+			b.err = err
+		}
+
+		if n > 0 || b.err != nil {
+			return n, b.err
+		}
+	}
+
+	return 0, b.err
+}
+
+func (b *chunkedBody) Close() error {
+	b.chunks = nil
+	b.err = fs.ErrClosed
+
+	if b.cur != nil {
+		return b.cur.Close()
+	}
+
+	return nil
+}
@@ -0,0 +1,114 @@
+package s3fs_test
+
+import (
+	"context"
+	"io/fs"
+	"strings"
+	"testing"
+
+	"github.com/packrat386/s3fs"
+	"github.com/packrat386/s3fs/s3fstest"
+	"github.com/stretchr/testify/require"
+)
+
+// keysUnder lists every key in store under prefix.
+func keysUnder(t *testing.T, store s3fs.ObjectStore, prefix string) []string {
+	keys := []string{}
+	err := store.List(context.Background(), prefix, s3fs.ListOptions{}, func(page *s3fs.ListPage) bool {
+		for _, obj := range page.Objects {
+			keys = append(keys, obj.Key)
+		}
+
+		return true
+	})
+	require.Nil(t, err)
+
+	return keys
+}
+
+func TestWithChunking(t *testing.T) {
+	store := s3fstest.NewMemStore()
+	myFS := s3fs.NewFS(store, s3fs.WithChunking(10))
+
+	content := "0123456789abcdefghijklmnopqrstuvwxyz"
+	require.Nil(t, myFS.WriteFile("big.txt", []byte(content)))
+	require.Len(t, keysUnder(t, store, ".s3fs-chunks/big.txt/"), 4)
+
+	data, err := fs.ReadFile(myFS, "big.txt")
+	require.Nil(t, err)
+	require.Equal(t, content, string(data))
+
+	info, err := fs.Stat(myFS, "big.txt")
+	require.Nil(t, err)
+	require.Equal(t, int64(len(content)), info.Size())
+
+	// replacing a chunked file removes its chunks
+	require.Nil(t, myFS.WriteFile("big.txt", []byte(strings.Repeat("x", 25))))
+	require.Len(t, keysUnder(t, store, ".s3fs-chunks/big.txt/"), 3)
+
+	data, err = fs.ReadFile(myFS, "big.txt")
+	require.Nil(t, err)
+	require.Equal(t, strings.Repeat("x", 25), string(data))
+
+	// copies get their own chunks
+	require.Nil(t, myFS.Copy("big.txt", "copy.txt"))
+	require.Nil(t, myFS.Remove("big.txt"))
+	require.Empty(t, keysUnder(t, store, ".s3fs-chunks/big.txt/"))
+
+	data, err = fs.ReadFile(myFS, "copy.txt")
+	require.Nil(t, err)
+	require.Equal(t, strings.Repeat("x", 25), string(data))
+}
+
+func TestWithChunking_SingleChunk(t *testing.T) {
+	store := s3fstest.NewMemStore()
+	myFS := s3fs.NewFS(store, s3fs.WithChunking(10))
+
+	for _, content := range []string{"", "small", "0123456789"} {
+		require.Nil(t, myFS.UploadFrom(context.Background(), "small.txt", strings.NewReader(content), int64(len(content))))
+		require.Empty(t, keysUnder(t, store, ".s3fs-chunks/"))
+
+		data, err := fs.ReadFile(myFS, "small.txt")
+		require.Nil(t, err)
+		require.Equal(t, content, string(data))
+	}
+}
+
+func TestWithChunking_Append(t *testing.T) {
+	store := s3fstest.NewMemStore()
+	myFS := s3fs.NewFS(store, s3fs.WithChunking(10))
+
+	content := "0123456789abcdefghijklmnopqrstuvwxyz"
+	require.Nil(t, myFS.WriteFile("big.txt", []byte(content)))
+
+	_, err := myFS.Append("big.txt")
+	require.Error(t, err)
+
+	data, err := fs.ReadFile(myFS, "big.txt")
+	require.Nil(t, err)
+	require.Equal(t, content, string(data))
+}
+
+func TestWithChunking_WriteFileIf(t *testing.T) {
+	store := s3fstest.NewMemStore()
+	myFS := s3fs.NewFS(store, s3fs.WithChunking(10))
+
+	content := "0123456789abcdefghijklmnopqrstuvwxyz"
+	require.Nil(t, myFS.WriteFile("big.txt", []byte(content)))
+
+	info, err := fs.Stat(myFS, "big.txt")
+	require.Nil(t, err)
+
+	err = myFS.WriteFileIf("big.txt", []byte("small"), info.Sys().(s3fs.ObjectInfo).ETag)
+	require.Error(t, err)
+	require.Len(t, keysUnder(t, store, ".s3fs-chunks/big.txt/"), 4)
+
+	data, err := fs.ReadFile(myFS, "big.txt")
+	require.Nil(t, err)
+	require.Equal(t, content, string(data))
+
+	// the chunks are listed like any other objects
+	entries, err := fs.ReadDir(myFS, ".")
+	require.Nil(t, err)
+	require.Equal(t, []string{".s3fs-chunks", "big.txt"}, entryNames(entries))
+}
@@ -0,0 +1,39 @@
+package s3fs
+
+import "time"
+
+// Clock is where the FS gets the time from, and how it waits, for cache expiry,
+// polling, retry backoff and hedged reads. s3fstest.FakeClock is a Clock that tests
+// can move forward by hand.
+type Clock interface {
+	Now() time.Time
+
+	// After returns a channel that receives the time once d has passed.
+	After(d time.Duration) <-chan time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+// WithClock makes the FS use c instead of the system clock, so that tests can check
+// cache expiry, WaitRestored, Changes retries, write-back backoff, which uploads are
+// stale and when presigned URLs expire without waiting on real time. The locks, log and templates packages use
+// the FS's clock too.
+func WithClock(c Clock) Option {
+	return func(s *S3FS) {
+		s.clock = c
+	}
+}
+
+// Clock returns the clock the FS uses, so that code built on the FS, like locks and
+// pollers, keeps the same time as it does.
+func (s *S3FS) Clock() Clock {
+	return s.clock
+}
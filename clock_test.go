@@ -0,0 +1,54 @@
+package s3fs_test
+
+import (
+	"context"
+	"io/fs"
+	"testing"
+	"time"
+
+	"github.com/packrat386/s3fs"
+	"github.com/packrat386/s3fs/s3fstest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithClock_CacheExpiry(t *testing.T) {
+	store := &rangeStore{MemStore: s3fstest.NewMemStore()}
+	store.WriteFile("a.txt", "one")
+
+	clock := s3fstest.NewFakeClock(time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC))
+	myFS := s3fs.NewFS(store, s3fs.WithCache(s3fs.NewMemoryCache(1<<20), time.Minute), s3fs.WithClock(clock))
+
+	_, err := fs.ReadFile(myFS, "a.txt")
+	require.Nil(t, err)
+
+	clock.Advance(59 * time.Second)
+	_, err = fs.ReadFile(myFS, "a.txt")
+	require.Nil(t, err)
+	require.Len(t, store.ranges, 1)
+
+	clock.Advance(time.Second)
+	_, err = fs.ReadFile(myFS, "a.txt")
+	require.Nil(t, err)
+	require.Len(t, store.ranges, 2)
+	require.NotEmpty(t, store.ranges[1].IfNoneMatch)
+}
+
+func TestWithClock_WaitRestored(t *testing.T) {
+	store := &glacierStore{MemStore: s3fstest.NewMemStore(), restore: map[string]string{}}
+	store.WriteFile("old.json", "{}")
+
+	clock := s3fstest.NewFakeClock(time.Now())
+	myFS := s3fs.NewFS(store, s3fs.WithClock(clock))
+	require.Nil(t, myFS.Restore("old.json", s3fs.RestoreBulk, 1))
+
+	done := make(chan error)
+	go func() {
+		done <- myFS.WaitRestored(context.Background(), "old.json")
+	}()
+
+	clock.BlockUntil(1)
+	store.finishRestore("old.json")
+	clock.Advance(time.Hour)
+
+	require.Nil(t, <-done)
+}
@@ -0,0 +1,40 @@
+package s3fs
+
+import (
+	"context"
+	"io/fs"
+	"sync/atomic"
+)
+
+// Close shuts the FS down: it waits for pending write-back uploads (see Drain), stops
+// background work, and closes idle HTTP connections of clients the FS created itself.
+// Files that are already open can still be read and closed, but everything else fails
+// with fs.ErrClosed afterwards. Close returns the error from draining write-back
+// uploads, if there was one; calling it again returns the same error.
+func (s *S3FS) Close() error {
+	s.closeOnce.Do(func() {
+		atomic.StoreInt32(&s.closed, 1)
+
+		if s.writeBack != nil {
+			s.closeErr = s.writeBack.drain(context.Background())
+			s.writeBack.stop()
+		}
+
+		s.stopBackground()
+
+		for _, fn := range s.closers {
+			fn()
+		}
+	})
+
+	return s.closeErr
+}
+
+// checkOpen returns fs.ErrClosed if the FS has been closed.
+func (s *S3FS) checkOpen() error {
+	if atomic.LoadInt32(&s.closed) == 1 {
+		return fs.ErrClosed
+	}
+
+	return nil
+}
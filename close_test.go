@@ -0,0 +1,47 @@
+package s3fs_test
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"testing"
+
+	"github.com/packrat386/s3fs"
+	"github.com/packrat386/s3fs/s3fstest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClose(t *testing.T) {
+	store := &flakyStore{MemStore: s3fstest.NewMemStore()}
+	myFS := s3fs.NewFS(store, s3fs.WithWriteBack(t.TempDir()))
+
+	store.gate.Lock()
+	require.Nil(t, myFS.WriteFile("a.txt", []byte("one")))
+
+	f, err := myFS.Open("a.txt")
+	require.Nil(t, err)
+
+	closed := make(chan error)
+	go func() {
+		closed <- myFS.Close()
+	}()
+
+	// Close waits for the pending upload
+	store.gate.Unlock()
+	require.Nil(t, <-closed)
+
+	_, err = store.Head(context.Background(), "a.txt")
+	require.Nil(t, err)
+
+	// files that were already open still work
+	buf := make([]byte, 3)
+	_, err = f.Read(buf)
+	require.Nil(t, err)
+	require.Nil(t, f.Close())
+
+	_, err = myFS.Open("a.txt")
+	require.True(t, errors.Is(err, fs.ErrClosed))
+	require.True(t, errors.Is(myFS.WriteFile("b.txt", nil), fs.ErrClosed))
+
+	require.Nil(t, myFS.Close())
+}
@@ -0,0 +1,250 @@
+package s3fs
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"sync"
+)
+
+const (
+	// columnarBlockSize is the least a columnar file fetches at once, so that the
+	// many small reads of column chunk headers and pages share requests.
+	columnarBlockSize = 64 << 10
+
+	// columnarFooterSize is how much of the end of a file is fetched when it is
+	// opened. Parquet and ORC readers start by reading the footer, which is
+	// usually well within this.
+	columnarFooterSize = 64 << 10
+
+	// columnarCachedRanges is how many fetched ranges a columnar file keeps.
+	columnarCachedRanges = 16
+)
+
+// WithColumnarAccess tunes opened files for columnar formats like Parquet, whose
+// readers jump around a file with many small ReadAt calls instead of reading it
+// front to back. Files don't stream the object anymore; instead they implement
+// io.ReaderAt and io.Seeker and fetch ranges on demand. Small reads are coalesced into
+// 64 KiB ranges, the last 16 ranges fetched are cached, and the last 64 KiB of the
+// file, where the footer is, is fetched when the file is opened.
+func WithColumnarAccess() Option {
+	return func(s *S3FS) {
+		s.columnar = true
+	}
+}
+
+// cachedRange is a range of a columnar file that has been fetched.
+type cachedRange struct {
+	off  int64
+	data []byte
+}
+
+// columnarFile is an open file of an FS with WithColumnarAccess. It is safe for
+// concurrent use.
+type columnarFile struct {
+	store    ObjectStore
+	key      string
+	fileInfo s3FileInfo
+
+	mu     sync.Mutex
+	off    int64
+	cache  []cachedRange // least recently used first
+	closed bool
+	stats  TransferStats
+}
+
+func openColumnar(s *S3FS, key string) (fs.File, error) {
+	ctx := context.Background()
+
+	info, err := s.store.Head(ctx, key)
+	if err != nil {
+		return nil, fmt.Errorf("error getting s3 object: %w", err)
+	}
+
+	if err := s.checkSize(key, info.Size); err != nil {
+		return nil, err
+	}
+
+	f := &columnarFile{
+		store: s.store,
+		key:   key,
+		fileInfo: s3FileInfo{
+			name:    path.Base(key),
+			mode:    fs.FileMode(0400),
+			size:    info.Size,
+			modTime: info.LastModified,
+			object:  &info,
+			key:     key,
+			loc:     s.location,
+		},
+	}
+
+	footer := info.Size - columnarFooterSize
+	if footer < 0 {
+		footer = 0
+	}
+
+	if footer < info.Size {
+		if _, err := f.fetch(ctx, footer, info.Size-footer); err != nil {
+			return nil, err
+		}
+	}
+
+	return f, nil
+}
+
+func (f *columnarFile) Stat() (fs.FileInfo, error) {
+	return &f.fileInfo, nil
+}
+
+func (f *columnarFile) Read(buf []byte) (int, error) {
+	f.mu.Lock()
+	off := f.off
+	f.mu.Unlock()
+
+	n, err := f.ReadAt(buf, off)
+
+	f.mu.Lock()
+	f.off = off + int64(n)
+	f.mu.Unlock()
+
+	if err == io.EOF && n > 0 {
+		err = nil
+	}
+
+	return n, err
+}
+
+func (f *columnarFile) Seek(offset int64, whence int) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += f.off
+	case io.SeekEnd:
+		offset += f.fileInfo.size
+	default:
+		return 0, fmt.Errorf("invalid whence: %d", whence)
+	}
+
+	if offset < 0 {
+		return 0, fmt.Errorf("negative offset: %d", offset)
+	}
+
+	f.off = offset
+
+	return offset, nil
+}
+
+// ReadAt reads len(buf) bytes starting at off, from the cache if it can and with as
+// few requests as it can otherwise.
+func (f *columnarFile) ReadAt(buf []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, fmt.Errorf("negative offset: %d", off)
+	}
+
+	n := 0
+	for n < len(buf) {
+		pos := off + int64(n)
+		if pos >= f.fileInfo.size {
+			return n, io.EOF
+		}
+
+		data, ok := f.cached(pos)
+		if !ok {
+			length := int64(len(buf) - n)
+			if length < columnarBlockSize {
+				length = columnarBlockSize
+			}
+
+			if pos+length > f.fileInfo.size {
+				length = f.fileInfo.size - pos
+			}
+
+			var err error
+			if data, err = f.fetch(context.Background(), pos, length); err != nil {
+				return n, err
+			}
+		}
+
+		n += copy(buf[n:], data)
+	}
+
+	return n, nil
+}
+
+// cached returns the cached data from pos to the end of the range holding it, if any.
+func (f *columnarFile) cached(pos int64) ([]byte, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for i, r := range f.cache {
+		if pos >= r.off && pos < r.off+int64(len(r.data)) {
+			// most recently used goes last
+			f.cache = append(append(f.cache[:i:i], f.cache[i+1:]...), r)
+
+			return r.data[pos-r.off:], true
+		}
+	}
+
+	return nil, false
+}
+
+// fetch gets length bytes starting at off and caches them.
+func (f *columnarFile) fetch(ctx context.Context, off, length int64) ([]byte, error) {
+	f.mu.Lock()
+	closed := f.closed
+	f.mu.Unlock()
+
+	if closed {
+		return nil, fs.ErrClosed
+	}
+
+	stats := TransferStats{}
+	object, err := getObject(ctx, f.store, f.key, GetOptions{Offset: off, Length: length}, &stats)
+
+	f.mu.Lock()
+	f.stats.Requests += stats.Requests
+	f.stats.Retries += stats.Retries
+	f.stats.Latency += stats.Latency
+	f.mu.Unlock()
+
+	if err != nil {
+		return nil, fmt.Errorf("could not get %s at offset %d: %w", f.key, off, err)
+	}
+	defer object.Body.Close()
+
+	if object.Info.ETag != f.fileInfo.object.ETag {
+		return nil, fmt.Errorf("%s changed since it was opened", f.key)
+	}
+
+	data, err := io.ReadAll(object.Body)
+	if err != nil {
+		return nil, fmt.Errorf("could not read %s at offset %d: %w", f.key, off, err)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.stats.BytesRead += int64(len(data))
+	f.cache = append(f.cache, cachedRange{off: off, data: data})
+	if len(f.cache) > columnarCachedRanges {
+		f.cache = f.cache[1:]
+	}
+
+	return data, nil
+}
+
+func (f *columnarFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.closed = true
+	f.cache = nil
+
+	return nil
+}
@@ -0,0 +1,112 @@
+package s3fs_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/fs"
+	"sync"
+	"testing"
+
+	"github.com/packrat386/s3fs"
+	"github.com/packrat386/s3fs/s3fstest"
+	"github.com/stretchr/testify/require"
+)
+
+// rangeStore records the ranges requested from a MemStore.
+type rangeStore struct {
+	*s3fstest.MemStore
+
+	mu     sync.Mutex
+	ranges []s3fs.GetOptions
+}
+
+func (r *rangeStore) Get(ctx context.Context, key string, opts s3fs.GetOptions) (*s3fs.Object, error) {
+	r.mu.Lock()
+	r.ranges = append(r.ranges, opts)
+	r.mu.Unlock()
+
+	return r.MemStore.Get(ctx, key, opts)
+}
+
+func TestWithColumnarAccess(t *testing.T) {
+	data := make([]byte, 200<<10)
+	for i := range data {
+		data[i] = byte(i % 251)
+	}
+
+	store := &rangeStore{MemStore: s3fstest.NewMemStore()}
+	store.WriteFile("data.parquet", string(data))
+
+	myFS := s3fs.NewFS(store, s3fs.WithColumnarAccess())
+
+	f, err := myFS.Open("data.parquet")
+	require.Nil(t, err)
+	defer f.Close()
+
+	// the footer is fetched up front
+	require.Equal(t, []s3fs.GetOptions{{Offset: 136 << 10, Length: 64 << 10}}, store.ranges)
+
+	ra, ok := f.(io.ReaderAt)
+	require.True(t, ok)
+
+	buf := make([]byte, 8)
+	n, err := ra.ReadAt(buf, int64(len(data)-8))
+	require.Nil(t, err)
+	require.Equal(t, 8, n)
+	require.Equal(t, data[len(data)-8:], buf)
+	require.Len(t, store.ranges, 1)
+
+	// small reads are coalesced into one block
+	_, err = ra.ReadAt(buf, 0)
+	require.Nil(t, err)
+	_, err = ra.ReadAt(buf, 1000)
+	require.Nil(t, err)
+	require.Equal(t, data[1000:1008], buf)
+	require.Len(t, store.ranges, 2)
+	require.Equal(t, s3fs.GetOptions{Offset: 0, Length: 64 << 10}, store.ranges[1])
+
+	// reads past the end
+	n, err = ra.ReadAt(buf, int64(len(data)-4))
+	require.Equal(t, io.EOF, err)
+	require.Equal(t, 4, n)
+
+	seeker, ok := f.(io.Seeker)
+	require.True(t, ok)
+
+	off, err := seeker.Seek(-16, io.SeekEnd)
+	require.Nil(t, err)
+	require.Equal(t, int64(len(data)-16), off)
+
+	rest, err := io.ReadAll(f)
+	require.Nil(t, err)
+	require.Equal(t, data[len(data)-16:], rest)
+
+	_, err = seeker.Seek(0, io.SeekStart)
+	require.Nil(t, err)
+
+	all, err := io.ReadAll(f)
+	require.Nil(t, err)
+	require.True(t, bytes.Equal(data, all))
+
+	require.Nil(t, f.Close())
+	_, err = ra.ReadAt(buf, 100<<10)
+	require.ErrorIs(t, err, fs.ErrClosed)
+}
+
+func TestWithColumnarAccess_Small(t *testing.T) {
+	store := &rangeStore{MemStore: s3fstest.NewMemStore()}
+	store.WriteFile("small.txt", "hello")
+	store.WriteFile("empty.txt", "")
+
+	myFS := s3fs.NewFS(store, s3fs.WithColumnarAccess())
+
+	data, err := fs.ReadFile(myFS, "small.txt")
+	require.Nil(t, err)
+	require.Equal(t, "hello", string(data))
+	require.Len(t, store.ranges, 1)
+
+	data, err = fs.ReadFile(myFS, "empty.txt")
+	require.Nil(t, err)
+	require.Empty(t, data)
+}
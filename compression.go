@@ -0,0 +1,228 @@
+package s3fs
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"strconv"
+)
+
+const (
+	// compressionMetadata marks an object compressed by the FS, set to its
+	// Content-Encoding.
+	compressionMetadata = "s3fs-compression"
+
+	// originalSizeMetadata is the size of a compressed file before compression, when
+	// it was known as the file was written.
+	originalSizeMetadata = "s3fs-original-size"
+)
+
+// Compression is a compression format for WithWriteCompression.
+type Compression interface {
+	// Encoding is the Content-Encoding compressed objects are stored with, e.g. "gzip".
+	Encoding() string
+
+	// NewWriter returns a writer that compresses what is written to it into w. It is
+	// closed once everything has been written.
+	NewWriter(w io.Writer) (io.WriteCloser, error)
+
+	// NewReader returns a reader that decompresses r.
+	NewReader(r io.Reader) (io.ReadCloser, error)
+}
+
+// Gzip is the gzip Compression, at the default compression level.
+var Gzip Compression = gzipCompression{}
+
+type gzipCompression struct{}
+
+func (gzipCompression) Encoding() string {
+	return "gzip"
+}
+
+func (gzipCompression) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	return gzip.NewWriter(w), nil
+}
+
+func (gzipCompression) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return gzip.NewReader(r)
+}
+
+// WithWriteCompression makes the FS compress files with c as they are written, and
+// decompress them as they are read. Compressed objects are stored with c's
+// Content-Encoding, and with their original size in their metadata when it's known up
+// front, as it is for WriteFile, small files written with Create and UploadFrom with a
+// size. Gzip is built in; other formats such as zstd can be used by implementing
+// Compression on top of a library for them.
+//
+// Only objects the FS compressed itself are decompressed, so objects uploaded with a
+// Content-Encoding by something else are still read as they are stored. Listings
+// report the compressed size; Stat on an opened file reports the original size, or -1
+// if it wasn't known. Compressed files are always streamed, regardless of WithCache,
+// WithColumnarAccess and WithStreamingConcurrency, and can't be appended to or written
+// with WriteFileDedup. With WithEncryption files are compressed before they are
+// encrypted.
+func WithWriteCompression(c Compression) Option {
+	return func(s *S3FS) {
+		s.compression = c
+	}
+}
+
+// compressed reports whether info describes an object compressed by the FS.
+func compressed(info ObjectInfo) bool {
+	_, ok := metadataValue(info.Metadata, compressionMetadata)
+
+	return ok
+}
+
+// encode applies the transformations configured for writes to body, hashing it,
+// compressing it and then encrypting it, and returns opts with the metadata that
+// describes them.
+func (s *S3FS) encode(ctx context.Context, body io.Reader, opts PutOptions) (io.Reader, PutOptions, error) {
+	var err error
+
+	if s.contentHashes {
+		if opts, err = hashContent(body, opts); err != nil {
+			return nil, opts, err
+		}
+	}
+
+	if s.compression != nil {
+		if body, opts, err = compress(s.compression, body, opts); err != nil {
+			return nil, opts, err
+		}
+	}
+
+	if s.encryption != nil {
+		if body, opts, err = s.encryption.seal(ctx, body, opts); err != nil {
+			return nil, opts, err
+		}
+	}
+
+	return body, opts, nil
+}
+
+// compress returns body compressed with c, and opts with the metadata that marks the
+// object as compressed.
+func compress(c Compression, body io.Reader, opts PutOptions) (io.Reader, PutOptions, error) {
+	metadata := map[string]string{}
+	for k, v := range opts.Metadata {
+		metadata[k] = v
+	}
+	metadata[compressionMetadata] = c.Encoding()
+
+	if l, ok := body.(interface{ Len() int }); ok {
+		if _, set := metadataValue(metadata, originalSizeMetadata); !set {
+			metadata[originalSizeMetadata] = strconv.Itoa(l.Len())
+		}
+	}
+
+	opts.Metadata = metadata
+	opts.ContentEncoding = c.Encoding()
+
+	r := &compressingReader{body: body, chunk: make([]byte, 32<<10)}
+	zw, err := c.NewWriter(&r.out)
+	if err != nil {
+		return nil, opts, fmt.Errorf("could not start %s compression: %w", c.Encoding(), err)
+	}
+	r.zw = zw
+
+	return r, opts, nil
+}
+
+// compressingReader compresses body as it is read.
+type compressingReader struct {
+	body  io.Reader
+	zw    io.WriteCloser
+	chunk []byte
+
+	out  bytes.Buffer
+	done bool
+}
+
+func (r *compressingReader) Read(buf []byte) (int, error) {
+	for r.out.Len() == 0 {
+		if r.done {
+			return 0, io.EOF
+		}
+
+		n, err := r.body.Read(r.chunk)
+		if n > 0 {
+			if _, werr := r.zw.Write(r.chunk[:n]); werr != nil {
+				return 0, werr
+			}
+		}
+
+		if err == io.EOF {
+			if err := r.zw.Close(); err != nil {
+				return 0, err
+			}
+
+			r.done = true
+		} else if err != nil {
+			return 0, err
+		}
+	}
+
+	return r.out.Read(buf)
+}
+
+// openDecoded opens the file at key, undoing the transformations applied to it when it
+// was written as it's read.
+func openDecoded(ctx context.Context, s *S3FS, key string, opts GetOptions) (fs.File, error) {
+	f, err := openChunked(ctx, s, key, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	info := *f.fileInfo.object
+	var body io.Reader = f.body
+
+	if s.encryption != nil {
+		plain, size, err := s.encryption.open(ctx, info, body)
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("could not decrypt %s: %w", key, err)
+		}
+
+		body = plain
+		info.Size = size
+	}
+
+	if s.compression != nil && compressed(info) {
+		encoding, _ := metadataValue(info.Metadata, compressionMetadata)
+		if encoding != s.compression.Encoding() {
+			f.Close()
+			return nil, fmt.Errorf("could not decompress %s: it is compressed with %s", key, encoding)
+		}
+
+		zr, err := s.compression.NewReader(body)
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("could not decompress %s: %w", key, err)
+		}
+
+		body = zr
+		info.Size = -1
+		if v, ok := metadataValue(info.Metadata, originalSizeMetadata); ok {
+			if size, err := strconv.ParseInt(v, 10, 64); err == nil {
+				info.Size = size
+			}
+		}
+
+		if info.Size < 0 && s.maxOpenSize > 0 {
+			body = &sizeLimitedReader{r: body, name: key, max: s.maxOpenSize}
+		}
+	}
+
+	f.body = struct {
+		io.Reader
+		io.Closer
+	}{body, f.body}
+	f.fileInfo.size = info.Size
+	f.fileInfo.object = &info
+
+	return f, nil
+}
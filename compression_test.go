@@ -0,0 +1,106 @@
+package s3fs_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"io/fs"
+	"strings"
+	"testing"
+
+	"github.com/packrat386/s3fs"
+	"github.com/packrat386/s3fs/s3fstest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithWriteCompression(t *testing.T) {
+	store := s3fstest.NewMemStore()
+	myFS := s3fs.NewFS(store, s3fs.WithWriteCompression(s3fs.Gzip))
+
+	content := strings.Repeat("compress me ", 1000)
+	require.Nil(t, myFS.WriteFile("file.txt", []byte(content)))
+
+	info, raw := rawObject(t, store, "file.txt")
+	require.Equal(t, "gzip", info.ContentEncoding)
+	require.Equal(t, "12000", info.Metadata["s3fs-original-size"])
+	require.Less(t, len(raw), len(content))
+
+	zr, err := gzip.NewReader(bytes.NewReader(raw))
+	require.Nil(t, err)
+	plain, err := io.ReadAll(zr)
+	require.Nil(t, err)
+	require.Equal(t, content, string(plain))
+
+	data, err := fs.ReadFile(myFS, "file.txt")
+	require.Nil(t, err)
+	require.Equal(t, content, string(data))
+
+	stat, err := fs.Stat(myFS, "file.txt")
+	require.Nil(t, err)
+	require.Equal(t, int64(len(content)), stat.Size())
+
+	f, err := myFS.OpenIf(context.Background(), "file.txt", s3fs.ReadConditions{})
+	require.Nil(t, err)
+	data, err = io.ReadAll(f)
+	require.Nil(t, err)
+	require.Nil(t, f.Close())
+	require.Equal(t, content, string(data))
+
+	require.Nil(t, myFS.UploadFrom(context.Background(), "uploaded.txt", strings.NewReader(content), int64(len(content))))
+
+	data, err = fs.ReadFile(myFS, "uploaded.txt")
+	require.Nil(t, err)
+	require.Equal(t, content, string(data))
+
+	_, err = myFS.Append("uploaded.txt")
+	require.Error(t, err)
+}
+
+func TestWithWriteCompression_Streamed(t *testing.T) {
+	store := s3fstest.NewMemStore()
+	myFS := s3fs.NewFS(store, s3fs.WithWriteCompression(s3fs.Gzip))
+
+	content := strings.Repeat("0123456789", 600000)
+
+	w, err := myFS.Create("big.txt")
+	require.Nil(t, err)
+	_, err = io.Copy(w, strings.NewReader(content))
+	require.Nil(t, err)
+	require.Nil(t, w.Close())
+
+	data, err := fs.ReadFile(myFS, "big.txt")
+	require.Nil(t, err)
+	require.Equal(t, content, string(data))
+
+	stat, err := fs.Stat(myFS, "big.txt")
+	require.Nil(t, err)
+	require.Equal(t, int64(-1), stat.Size())
+}
+
+func TestWithWriteCompression_Uncompressed(t *testing.T) {
+	store := s3fstest.NewMemStore()
+	_, err := store.Put(context.Background(), "site.js.gz", strings.NewReader("raw"), s3fs.PutOptions{ContentEncoding: "gzip"})
+	require.Nil(t, err)
+
+	myFS := s3fs.NewFS(store, s3fs.WithWriteCompression(s3fs.Gzip))
+
+	data, err := fs.ReadFile(myFS, "site.js.gz")
+	require.Nil(t, err)
+	require.Equal(t, "raw", string(data))
+}
+
+func TestWithWriteCompression_Encrypted(t *testing.T) {
+	store := s3fstest.NewMemStore()
+	myFS := s3fs.NewFS(store, s3fs.WithWriteCompression(s3fs.Gzip), s3fs.WithEncryption(newFakeKMS(t), "alias/test"))
+
+	content := strings.Repeat("x", 10000)
+	require.Nil(t, myFS.WriteFile("secret.txt", []byte(content)))
+
+	_, raw := rawObject(t, store, "secret.txt")
+	require.Less(t, len(raw), len(content))
+
+	data, err := fs.ReadFile(myFS, "secret.txt")
+	require.Nil(t, err)
+	require.Equal(t, content, string(data))
+}
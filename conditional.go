@@ -0,0 +1,143 @@
+package s3fs
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"path"
+	"time"
+)
+
+// ReadConditions make OpenIf skip reading objects that haven't changed, like the
+// If-None-Match and If-Modified-Since headers of an HTTP request.
+type ReadConditions struct {
+	// IfNoneMatch, if set, skips the read if the object has this ETag.
+	IfNoneMatch string
+
+	// IfModifiedSince, if set, skips the read if the object hasn't been modified since.
+	IfModifiedSince time.Time
+}
+
+// StatFile returns the FileInfo of the file at name with a single HEAD request. Unlike
+// Open it doesn't list, so it doesn't know about directories: for a name that is only
+// a directory it returns an error wrapping fs.ErrNotExist.
+func (s *S3FS) StatFile(ctx context.Context, name string) (fs.FileInfo, error) {
+	if s.bucketErr != nil {
+		return nil, s.bucketErr
+	}
+
+	key, err := s.key("stat", name)
+	if err != nil {
+		return nil, err
+	}
+
+	if key == "" {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+	}
+
+	s.auditRead(OpStat, key)
+
+	if s.offline {
+		f, err := openOffline(s, key)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+
+		if _, ok := f.(*s3Directory); ok {
+			return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+		}
+
+		return f.Stat()
+	}
+
+	if s.writeBack != nil {
+		if f, ok, err := s.writeBack.open(key); ok {
+			if err != nil {
+				return nil, err
+			}
+			defer f.Close()
+
+			return f.Stat()
+		}
+	}
+
+	info, err := s.store.Head(ctx, key)
+	if err != nil {
+		return nil, fmt.Errorf("could not stat %s: %w", key, err)
+	}
+
+	return &s3FileInfo{
+		name:    path.Base(key),
+		mode:    fs.FileMode(0400),
+		size:    info.Size,
+		modTime: info.LastModified,
+		object:  &info,
+		key:     key,
+		loc:     s.location,
+	}, nil
+}
+
+// OpenIf opens the file at name with a single GET request, unless cond says there's
+// no need to read it, in which case it returns an error wrapping ErrNotModified
+// without transferring anything. Like StatFile, it doesn't list, so for a name that
+// is only a directory it returns an error wrapping fs.ErrNotExist.
+func (s *S3FS) OpenIf(ctx context.Context, name string, cond ReadConditions) (fs.File, error) {
+	if s.bucketErr != nil {
+		return nil, s.bucketErr
+	}
+
+	if err := s.checkOpen(); err != nil {
+		return nil, err
+	}
+
+	key, err := s.key("open", name)
+	if err != nil {
+		return nil, err
+	}
+
+	if key == "" {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+
+	s.auditRead(OpOpen, key)
+
+	if s.offline {
+		f, err := openOffline(s, key)
+		if _, ok := f.(*s3Directory); ok {
+			f.Close()
+			return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+		}
+
+		return s.limitSize(name, f, err)
+	}
+
+	if s.writeBack != nil {
+		if f, ok, err := s.writeBack.open(key); ok {
+			return s.limitSize(name, f, err)
+		}
+	}
+
+	opts := GetOptions{
+		IfNoneMatch:     cond.IfNoneMatch,
+		IfModifiedSince: cond.IfModifiedSince,
+	}
+
+	var f fs.File
+	switch {
+	case s.encryption != nil || s.compression != nil:
+		f, err = openDecoded(ctx, s, key, opts)
+	case s.chunkSize > 0:
+		// openChunked returns an *s3File, which mustn't end up in f if it's nil
+		chunked, chunkedErr := openChunked(ctx, s, key, opts)
+		if chunkedErr != nil {
+			return nil, chunkedErr
+		}
+
+		f = chunked
+	default:
+		f, err = openObject(ctx, s, key, opts)
+	}
+
+	return s.limitSize(name, f, err)
+}
@@ -0,0 +1,67 @@
+package s3fs_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"testing"
+	"time"
+
+	"github.com/packrat386/s3fs"
+	"github.com/packrat386/s3fs/s3fstest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatFile(t *testing.T) {
+	store := s3fstest.NewMemStore()
+	store.WriteFile("dir/a.txt", "abc")
+
+	myFS := s3fs.NewFS(store)
+
+	info, err := myFS.StatFile(context.Background(), "dir/a.txt")
+	require.Nil(t, err)
+	require.Equal(t, "a.txt", info.Name())
+	require.Equal(t, int64(3), info.Size())
+	require.NotEmpty(t, info.Sys().(s3fs.ObjectInfo).ETag)
+
+	_, err = myFS.StatFile(context.Background(), "dir")
+	require.True(t, errors.Is(err, fs.ErrNotExist))
+
+	_, err = myFS.StatFile(context.Background(), ".")
+	require.True(t, errors.Is(err, fs.ErrNotExist))
+}
+
+func TestOpenIf(t *testing.T) {
+	store := s3fstest.NewMemStore()
+	store.WriteFile("a.txt", "abc")
+
+	myFS := s3fs.NewFS(store)
+	ctx := context.Background()
+
+	f, err := myFS.OpenIf(ctx, "a.txt", s3fs.ReadConditions{})
+	require.Nil(t, err)
+
+	data, err := io.ReadAll(f)
+	require.Nil(t, err)
+	require.Equal(t, "abc", string(data))
+
+	info, err := f.Stat()
+	require.Nil(t, err)
+	require.Nil(t, f.Close())
+
+	etag := info.Sys().(s3fs.ObjectInfo).ETag
+
+	_, err = myFS.OpenIf(ctx, "a.txt", s3fs.ReadConditions{IfNoneMatch: etag})
+	require.True(t, errors.Is(err, s3fs.ErrNotModified))
+
+	_, err = myFS.OpenIf(ctx, "a.txt", s3fs.ReadConditions{IfModifiedSince: time.Now().Add(time.Minute)})
+	require.True(t, errors.Is(err, s3fs.ErrNotModified))
+
+	f, err = myFS.OpenIf(ctx, "a.txt", s3fs.ReadConditions{IfModifiedSince: time.Now().Add(-time.Hour)})
+	require.Nil(t, err)
+	require.Nil(t, f.Close())
+
+	_, err = myFS.OpenIf(ctx, "missing.txt", s3fs.ReadConditions{})
+	require.True(t, errors.Is(err, fs.ErrNotExist))
+}
@@ -0,0 +1,176 @@
+package s3fs
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// Config describes how to build the S3 client for NewS3FSFromConfig. Zero values
+// fall back to whatever the SDK would pick up from the environment.
+type Config struct {
+	// Bucket is the bucket name, access point alias, or access point ARN to read from.
+	Bucket string
+
+	// Region overrides the region from the environment.
+	Region string
+
+	// Endpoint is a custom endpoint URL, e.g. for MinIO or LocalStack.
+	Endpoint string
+
+	// Credentials overrides the default credential chain.
+	Credentials *credentials.Credentials
+
+	// UsePathStyle addresses the bucket as part of the path rather than the host,
+	// which most S3 compatible servers require.
+	UsePathStyle bool
+
+	// UseAccelerate uses the S3 transfer acceleration endpoints. The bucket must
+	// have acceleration enabled.
+	UseAccelerate bool
+
+	// UseDualStack uses the IPv4/IPv6 dualstack endpoints.
+	UseDualStack bool
+}
+
+// NewS3FSFromConfig builds an S3 client from cfg and returns an FS backed by
+// cfg.Bucket, configured by opts.
+func NewS3FSFromConfig(cfg Config, opts ...Option) (*S3FS, error) {
+	if cfg.Bucket == "" {
+		return nil, fmt.Errorf("bucket is required")
+	}
+
+	if cfg.UseAccelerate && cfg.UsePathStyle {
+		return nil, fmt.Errorf("transfer acceleration cannot be used with path style addressing")
+	}
+
+	// the client is created once the options have said how, but options like
+	// WithPrefixCredentials need the store to exist already
+	store := newS3Store(nil, cfg.Bucket)
+	s := newFS(store, opts...)
+
+	awsCfg := aws.NewConfig()
+
+	if cfg.Region != "" {
+		awsCfg = awsCfg.WithRegion(cfg.Region)
+	}
+
+	if cfg.Endpoint != "" {
+		awsCfg = awsCfg.WithEndpoint(cfg.Endpoint)
+	}
+
+	if cfg.Credentials != nil {
+		awsCfg = awsCfg.WithCredentials(cfg.Credentials)
+	}
+
+	httpClient := s.httpClient
+	if httpClient == nil {
+		// our own client, so that Close can close its connections without affecting
+		// anyone else using http.DefaultClient
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		s.transportTuning.apply(transport)
+
+		httpClient = &http.Client{Transport: transport}
+		s.closers = append(s.closers, httpClient.CloseIdleConnections)
+	}
+
+	awsCfg = awsCfg.
+		WithHTTPClient(httpClient).
+		WithS3ForcePathStyle(cfg.UsePathStyle).
+		WithS3UseAccelerate(cfg.UseAccelerate).
+		WithUseDualStack(cfg.UseDualStack)
+
+	sess, err := session.NewSession(awsCfg)
+	if err != nil {
+		s.Close()
+		return nil, fmt.Errorf("could not create aws session: %w", err)
+	}
+
+	store.client = s3.New(sess)
+	s.bucketErr = checkBucket(cfg.Bucket)
+	s.start()
+
+	return s, nil
+}
+
+// WithHTTPClient makes NewS3FSFromConfig send requests with client instead of a
+// client of its own. The FS doesn't close client's connections when it is closed,
+// since others may be using it. It has no effect on FSes created from an existing
+// S3 client, which already has an HTTP client.
+func WithHTTPClient(client *http.Client) Option {
+	return func(s *S3FS) {
+		s.httpClient = client
+	}
+}
+
+// transportTuning is how WithTransportTuning changes the default transport.
+type transportTuning struct {
+	maxIdleConns int
+	idleTimeout  time.Duration
+	tlsConfig    *tls.Config
+}
+
+// WithTransportTuning tunes the connection pool of the HTTP client that
+// NewS3FSFromConfig creates. maxIdleConns is how many idle connections to S3 are kept
+// for reuse; Go's default of 2 per host makes highly concurrent workloads open and
+// tear down connections all the time. idleTimeout is how long they are kept, and
+// tlsConfig, if not nil, is used for TLS connections, e.g. to trust a private CA in
+// front of an S3 compatible server. Zero values keep Go's defaults. Like
+// WithHTTPClient, it has no effect on FSes created from an existing S3 client, and it
+// is ignored if WithHTTPClient is given too.
+func WithTransportTuning(maxIdleConns int, idleTimeout time.Duration, tlsConfig *tls.Config) Option {
+	return func(s *S3FS) {
+		s.transportTuning = &transportTuning{
+			maxIdleConns: maxIdleConns,
+			idleTimeout:  idleTimeout,
+			tlsConfig:    tlsConfig,
+		}
+	}
+}
+
+func (t *transportTuning) apply(transport *http.Transport) {
+	if t == nil {
+		return
+	}
+
+	// every request goes to the same host, so the per host limit is the one that
+	// matters
+	if t.maxIdleConns > 0 {
+		transport.MaxIdleConns = t.maxIdleConns
+		transport.MaxIdleConnsPerHost = t.maxIdleConns
+	}
+
+	if t.idleTimeout > 0 {
+		transport.IdleConnTimeout = t.idleTimeout
+	}
+
+	if t.tlsConfig != nil {
+		transport.TLSClientConfig = t.tlsConfig
+	}
+}
+
+// NewS3FSAssumeRole returns an fs.FS backed by bucket, read with credentials for
+// roleARN assumed via STS from sess. The credentials refresh themselves a minute
+// before they expire, so the FS can be long lived. opts are applied to the underlying
+// stscreds.AssumeRoleProvider, e.g. to set an ExternalID or session duration.
+func NewS3FSAssumeRole(sess *session.Session, roleARN string, bucket string, opts ...func(*stscreds.AssumeRoleProvider)) *S3FS {
+	opts = append(
+		[]func(*stscreds.AssumeRoleProvider){
+			func(p *stscreds.AssumeRoleProvider) {
+				p.ExpiryWindow = time.Minute
+			},
+		},
+		opts...,
+	)
+
+	creds := stscreds.NewCredentials(sess, roleARN, opts...)
+
+	return NewS3FS(s3.New(sess, aws.NewConfig().WithCredentials(creds)), bucket)
+}
@@ -0,0 +1,92 @@
+package s3fs
+
+import (
+	"crypto/tls"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewS3FSFromConfig(t *testing.T) {
+	fsys, err := NewS3FSFromConfig(Config{
+		Bucket:       "my-bucket",
+		Region:       "us-west-2",
+		Endpoint:     "http://localhost:9000",
+		UsePathStyle: true,
+	})
+	require.Nil(t, err)
+
+	client := fsys.store.(*s3Store).client
+	require.Equal(t, "us-west-2", *client.Config.Region)
+	require.Equal(t, "http://localhost:9000", *client.Config.Endpoint)
+	require.True(t, *client.Config.S3ForcePathStyle)
+	require.Equal(t, "my-bucket", fsys.store.(*s3Store).bucket)
+}
+
+func TestNewS3FSFromConfig_HTTPClient(t *testing.T) {
+	client := &http.Client{Timeout: time.Minute}
+
+	fsys, err := NewS3FSFromConfig(Config{Bucket: "my-bucket", Region: "us-west-2"}, WithHTTPClient(client))
+	require.Nil(t, err)
+
+	require.Same(t, client, fsys.store.(*s3Store).client.Config.HTTPClient)
+	require.Empty(t, fsys.closers)
+}
+
+func TestNewS3FSFromConfig_TransportTuning(t *testing.T) {
+	tlsConfig := &tls.Config{ServerName: "minio.internal"}
+
+	fsys, err := NewS3FSFromConfig(
+		Config{Bucket: "my-bucket", Region: "us-west-2"},
+		WithTransportTuning(256, 30*time.Second, tlsConfig),
+		WithConcurrency(64),
+	)
+	require.Nil(t, err)
+	require.Equal(t, 64, fsys.maxConcurrency)
+
+	transport := fsys.store.(*s3Store).client.Config.HTTPClient.Transport.(*http.Transport)
+	require.Equal(t, 256, transport.MaxIdleConns)
+	require.Equal(t, 256, transport.MaxIdleConnsPerHost)
+	require.Equal(t, 30*time.Second, transport.IdleConnTimeout)
+	require.Same(t, tlsConfig, transport.TLSClientConfig)
+
+	// the default transport is left alone
+	require.NotEqual(t, 256, http.DefaultTransport.(*http.Transport).MaxIdleConnsPerHost)
+}
+
+func TestNewS3FSFromConfig_Invalid(t *testing.T) {
+	_, err := NewS3FSFromConfig(Config{})
+	require.NotNil(t, err)
+	require.Contains(t, err.Error(), "bucket is required")
+
+	_, err = NewS3FSFromConfig(Config{
+		Bucket:        "my-bucket",
+		UseAccelerate: true,
+		UsePathStyle:  true,
+	})
+	require.NotNil(t, err)
+	require.Contains(t, err.Error(), "transfer acceleration cannot be used with path style")
+}
+
+func TestNewS3FSAssumeRole(t *testing.T) {
+	sess, err := session.NewSession(aws.NewConfig().WithRegion("us-west-2"))
+	require.Nil(t, err)
+
+	fsys := NewS3FSAssumeRole(
+		sess,
+		"arn:aws:iam::123456789012:role/partner-read",
+		"partner-bucket",
+		func(p *stscreds.AssumeRoleProvider) {
+			p.Duration = 30 * time.Minute
+		},
+	)
+
+	s := fsys.store.(*s3Store)
+	require.Equal(t, "partner-bucket", s.bucket)
+	require.NotEqual(t, sess.Config.Credentials, s.client.Config.Credentials)
+}
@@ -0,0 +1,108 @@
+package s3fs
+
+import (
+	"fmt"
+	"io/fs"
+)
+
+// ConflictPolicy decides what a name that is both a file and a directory refers to,
+// which S3 allows when there are keys like "some/file" and "some/file/or_is_it".
+type ConflictPolicy int
+
+const (
+	// ConflictError fails to open or stat such names, and fails to open directories
+	// with a key named after them with a trailing slash. It is the default.
+	ConflictError ConflictPolicy = iota
+
+	// PreferFile treats such names as files, hiding the directory. Files in it can
+	// still be opened by name, since checking every parent of every name opened would
+	// take a listing each.
+	PreferFile
+
+	// PreferDir treats such names as directories, hiding the file.
+	PreferDir
+)
+
+// WithConflictPolicy sets what names that are both a file and a directory refer to.
+// With PreferFile or PreferDir, Open, Stat, Copy and directory listings all resolve
+// them the same way, so that such a bucket can be walked, and keys ending in a slash,
+// which some tools create as directory markers, are left out of listings instead of
+// failing them. Directories read with WithLazyListing can only hide a file for
+// PreferDir if its directory is listed before the file is read.
+func WithConflictPolicy(policy ConflictPolicy) Option {
+	return func(s *S3FS) {
+		s.conflictPolicy = policy
+	}
+}
+
+// resolveConflict reports whether key, which is both a file and a directory, should
+// be treated as a file, or returns an error if the FS doesn't resolve conflicts.
+func (s *S3FS) resolveConflict(key string) (bool, error) {
+	switch s.conflictPolicy {
+	case PreferFile:
+		return true, nil
+	case PreferDir:
+		return false, nil
+	}
+
+	return false, fmt.Errorf("directory name matches file name: %s", key)
+}
+
+// dirConflicts finds the entries of a directory listing that are both a file and a
+// directory, so the ones the policy hides can be dropped. A nil *dirConflicts drops
+// nothing.
+type dirConflicts struct {
+	preferFile bool
+	files      map[string]bool
+	dirs       map[string]bool
+}
+
+func (s *S3FS) newDirConflicts() *dirConflicts {
+	if s.conflictPolicy == ConflictError {
+		return nil
+	}
+
+	return &dirConflicts{
+		preferFile: s.conflictPolicy == PreferFile,
+		files:      map[string]bool{},
+		dirs:       map[string]bool{},
+	}
+}
+
+// add records a listed entry.
+func (c *dirConflicts) add(e fs.DirEntry) {
+	if c == nil {
+		return
+	}
+
+	if e.IsDir() {
+		c.dirs[e.Name()] = true
+	} else {
+		c.files[e.Name()] = true
+	}
+}
+
+// drops reports whether e is hidden by an entry of the other kind with its name.
+func (c *dirConflicts) drops(e fs.DirEntry) bool {
+	if c == nil || !c.files[e.Name()] || !c.dirs[e.Name()] {
+		return false
+	}
+
+	return e.IsDir() == c.preferFile
+}
+
+// filter returns entries without the ones it drops.
+func (c *dirConflicts) filter(entries []fs.DirEntry) []fs.DirEntry {
+	if c == nil {
+		return entries
+	}
+
+	out := entries[:0]
+	for _, e := range entries {
+		if !c.drops(e) {
+			out = append(out, e)
+		}
+	}
+
+	return out
+}
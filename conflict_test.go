@@ -0,0 +1,116 @@
+package s3fs_test
+
+import (
+	"io/fs"
+	"testing"
+	"testing/fstest"
+
+	"github.com/packrat386/s3fs"
+	"github.com/packrat386/s3fs/s3fstest"
+	"github.com/stretchr/testify/require"
+)
+
+func conflictStore() *s3fstest.MemStore {
+	store := s3fstest.NewMemStore()
+	store.WriteFile("foo", `{"data":"foo"}`)
+	store.WriteFile("foo-1", `{"data":"foo-1"}`)
+	store.WriteFile("foo/bar", `{"data":"bar"}`)
+	store.WriteFile("marked/", "")
+	store.WriteFile("marked/a", `{"data":"a"}`)
+
+	return store
+}
+
+func TestWithConflictPolicy_Error(t *testing.T) {
+	myFS := s3fs.NewFS(conflictStore())
+
+	_, err := myFS.Open("foo")
+	require.NotNil(t, err)
+	require.Contains(t, err.Error(), "directory name matches file name")
+
+	_, err = myFS.Stat("foo")
+	require.NotNil(t, err)
+
+	_, err = myFS.Open("marked")
+	require.NotNil(t, err)
+}
+
+func TestWithConflictPolicy_PreferFile(t *testing.T) {
+	for name, opts := range map[string][]s3fs.Option{
+		"listing": nil,
+		"spill":   {s3fs.WithListingSpill(t.TempDir(), 1)},
+		"lazy":    {s3fs.WithLazyListing()},
+	} {
+		t.Run(name, func(t *testing.T) {
+			store := conflictStore()
+			store.PageSize = 1
+
+			myFS := s3fs.NewFS(store, append(opts, s3fs.WithConflictPolicy(s3fs.PreferFile))...)
+
+			require.Nil(t, fstest.TestFS(myFS, "foo", "foo-1", "marked/a"))
+
+			data, err := fs.ReadFile(myFS, "foo")
+			require.Nil(t, err)
+			require.Equal(t, `{"data":"foo"}`, string(data))
+
+			info, err := myFS.Stat("foo")
+			require.Nil(t, err)
+			require.False(t, info.IsDir())
+
+			entries, err := fs.ReadDir(myFS, ".")
+			require.Nil(t, err)
+			require.Equal(t, []string{"foo", "foo-1", "marked"}, entryNames(entries))
+			require.False(t, entries[0].IsDir())
+			require.True(t, entries[2].IsDir())
+
+			// hidden from listings, but still there by name
+			data, err = fs.ReadFile(myFS, "foo/bar")
+			require.Nil(t, err)
+			require.Equal(t, `{"data":"bar"}`, string(data))
+		})
+	}
+}
+
+func TestWithConflictPolicy_PreferDir(t *testing.T) {
+	for name, opts := range map[string][]s3fs.Option{
+		"listing": nil,
+		"spill":   {s3fs.WithListingSpill(t.TempDir(), 1)},
+	} {
+		t.Run(name, func(t *testing.T) {
+			myFS := s3fs.NewFS(conflictStore(), append(opts, s3fs.WithConflictPolicy(s3fs.PreferDir))...)
+
+			require.Nil(t, fstest.TestFS(myFS, "foo/bar", "foo-1", "marked/a"))
+
+			info, err := myFS.Stat("foo")
+			require.Nil(t, err)
+			require.True(t, info.IsDir())
+
+			entries, err := fs.ReadDir(myFS, ".")
+			require.Nil(t, err)
+			require.Equal(t, []string{"foo", "foo-1", "marked"}, entryNames(entries))
+			require.True(t, entries[0].IsDir())
+			require.True(t, entries[2].IsDir())
+
+			entries, err = fs.ReadDir(myFS, "marked")
+			require.Nil(t, err)
+			require.Equal(t, []string{"a"}, entryNames(entries))
+		})
+	}
+}
+
+func TestWithConflictPolicy_Copy(t *testing.T) {
+	store := conflictStore()
+
+	require.Nil(t, s3fs.NewFS(store, s3fs.WithConflictPolicy(s3fs.PreferFile)).Copy("foo", "copied"))
+	require.Nil(t, s3fs.NewFS(store, s3fs.WithConflictPolicy(s3fs.PreferDir)).Copy("foo", "dir-copy"))
+
+	myFS := s3fs.NewFS(store)
+
+	data, err := fs.ReadFile(myFS, "copied")
+	require.Nil(t, err)
+	require.Equal(t, `{"data":"foo"}`, string(data))
+
+	data, err = fs.ReadFile(myFS, "dir-copy/bar")
+	require.Nil(t, err)
+	require.Equal(t, `{"data":"bar"}`, string(data))
+}
@@ -0,0 +1,143 @@
+package s3fs
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"strings"
+	"sync"
+)
+
+// Copy copies the file or directory at src to dst without downloading anything; the
+// store copies the data itself. Directories are copied recursively, with up to
+// WithConcurrency objects being copied at once. Copying onto an existing file
+// replaces it.
+func (s *S3FS) Copy(src, dst string) error {
+	ctx := context.Background()
+
+	srcKey, err := s.writableKey("copy", src)
+	if err != nil {
+		return err
+	}
+
+	dstKey, err := s.writableKey("copy", dst)
+	if err != nil {
+		return err
+	}
+
+	// copy the staged data, once it has been uploaded
+	if s.writeBack != nil {
+		s.writeBack.wait(srcKey)
+	}
+
+	fileMatch, dirMatch, err := s.lookup(ctx, srcKey)
+	if err != nil {
+		return fmt.Errorf("could not list s3 objects: %w", err)
+	}
+
+	if fileMatch && dirMatch {
+		preferFile, err := s.resolveConflict(srcKey)
+		if err != nil {
+			return err
+		}
+
+		fileMatch, dirMatch = preferFile, !preferFile
+	}
+
+	if fileMatch {
+		var size int64
+		if s.quota != nil {
+			info, err := s.store.Head(ctx, srcKey)
+			if err != nil {
+				return fmt.Errorf("could not stat %s: %w", srcKey, err)
+			}
+
+			size = info.Size
+		}
+
+		if err := s.quota.reserve(1, size); err != nil {
+			return fmt.Errorf("could not copy %s to %s: %w", srcKey, dstKey, err)
+		}
+
+		if err := s.copyObject(ctx, srcKey, dstKey); err != nil {
+			s.quota.release(1, size)
+			return fmt.Errorf("could not copy %s to %s: %w", srcKey, dstKey, err)
+		}
+
+		s.mutated("copy", dstKey, srcKey, "")
+
+		return nil
+	}
+
+	if dirMatch {
+		return s.copyDir(ctx, srcKey+"/", dstKey+"/")
+	}
+
+	return fs.ErrNotExist
+}
+
+func (s *S3FS) copyDir(ctx context.Context, srcPrefix, dstPrefix string) error {
+	if strings.HasPrefix(dstPrefix, srcPrefix) {
+		return fmt.Errorf("cannot copy %s into itself", strings.TrimSuffix(srcPrefix, "/"))
+	}
+
+	objects := []ObjectInfo{}
+	var total int64
+	err := s.store.List(ctx, srcPrefix, ListOptions{}, func(page *ListPage) bool {
+		for _, obj := range page.Objects {
+			objects = append(objects, obj)
+			total += obj.Size
+		}
+
+		return true
+	})
+
+	if err != nil {
+		return fmt.Errorf("error listing s3 dir: %w", err)
+	}
+
+	// the whole directory has to fit, so we don't stop with half of it copied
+	if err := s.quota.reserve(int64(len(objects)), total); err != nil {
+		return fmt.Errorf("could not copy %s to %s: %w", strings.TrimSuffix(srcPrefix, "/"), strings.TrimSuffix(dstPrefix, "/"), err)
+	}
+
+	work := make(chan ObjectInfo)
+	mu := sync.Mutex{}
+	wg := sync.WaitGroup{}
+	var firstErr error
+
+	for i := 0; i < s.concurrency(); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for obj := range work {
+				key := obj.Key
+				dst := dstPrefix + strings.TrimPrefix(key, srcPrefix)
+
+				if err := s.copyObject(ctx, key, dst); err != nil {
+					s.quota.release(1, obj.Size)
+
+					mu.Lock()
+					if firstErr == nil {
+						firstErr = fmt.Errorf("could not copy %s to %s: %w", key, dst, err)
+					}
+					mu.Unlock()
+
+					continue
+				}
+
+				s.mutated("copy", dst, key, "")
+			}
+		}()
+	}
+
+	for _, obj := range objects {
+		work <- obj
+	}
+
+	close(work)
+	wg.Wait()
+
+	return firstErr
+}
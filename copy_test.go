@@ -0,0 +1,43 @@
+package s3fs_test
+
+import (
+	"errors"
+	"io/fs"
+	"testing"
+
+	"github.com/packrat386/s3fs"
+	"github.com/packrat386/s3fs/s3fstest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCopy(t *testing.T) {
+	store := s3fstest.NewMemStore()
+	store.WriteFile("foo.txt", "foo")
+	store.WriteFile("dir/a.txt", "a")
+	store.WriteFile("dir/sub/b.txt", "b")
+
+	myFS := s3fs.NewFS(store, s3fs.WithConcurrency(2))
+
+	require.Nil(t, myFS.Copy("foo.txt", "bar.txt"))
+
+	data, err := fs.ReadFile(myFS, "bar.txt")
+	require.Nil(t, err)
+	require.Equal(t, "foo", string(data))
+
+	require.Nil(t, myFS.Copy("dir", "other/dir"))
+
+	data, err = fs.ReadFile(myFS, "other/dir/a.txt")
+	require.Nil(t, err)
+	require.Equal(t, "a", string(data))
+
+	data, err = fs.ReadFile(myFS, "other/dir/sub/b.txt")
+	require.Nil(t, err)
+	require.Equal(t, "b", string(data))
+
+	err = myFS.Copy("dir", "dir/inside")
+	require.NotNil(t, err)
+	require.Contains(t, err.Error(), "into itself")
+
+	err = myFS.Copy("nope", "still-nope")
+	require.True(t, errors.Is(err, fs.ErrNotExist))
+}
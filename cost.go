@@ -0,0 +1,178 @@
+package s3fs
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync/atomic"
+)
+
+// CostReport counts the requests an FS created with WithDryRun would have made. Its
+// fields are updated atomically, so read them once the job is done.
+type CostReport struct {
+	// Lists counts pages of listings, which are a request each.
+	Lists int64
+	Heads int64
+	Gets  int64
+
+	// GetBytes is how many bytes the Gets would have downloaded.
+	GetBytes int64
+
+	Puts int64
+
+	// PutBytes is how many bytes the Puts would have uploaded.
+	PutBytes int64
+
+	Copies  int64
+	Deletes int64
+}
+
+// CostPrices are the prices Estimate uses, in dollars.
+type CostPrices struct {
+	// ListPutPerThousand is the price of 1000 LIST, PUT and COPY requests.
+	ListPutPerThousand float64
+
+	// GetHeadPerThousand is the price of 1000 GET and HEAD requests.
+	GetHeadPerThousand float64
+
+	// TransferPerGB is the price of downloading a GB.
+	TransferPerGB float64
+}
+
+// S3StandardPrices are the prices of S3 Standard in us-east-1 at the time of
+// writing, with downloads going out to the internet. Downloads to EC2 in the same
+// region are free, so set TransferPerGB to zero for jobs that run there.
+var S3StandardPrices = CostPrices{
+	ListPutPerThousand: 0.005,
+	GetHeadPerThousand: 0.0004,
+	TransferPerGB:      0.09,
+}
+
+// Estimate returns what the counted requests would cost at prices. DELETEs are free.
+func (r *CostReport) Estimate(prices CostPrices) float64 {
+	listPuts := float64(atomic.LoadInt64(&r.Lists) + atomic.LoadInt64(&r.Puts) + atomic.LoadInt64(&r.Copies))
+	getHeads := float64(atomic.LoadInt64(&r.Gets) + atomic.LoadInt64(&r.Heads))
+	gb := float64(atomic.LoadInt64(&r.GetBytes)) / (1 << 30)
+
+	return listPuts/1000*prices.ListPutPerThousand + getHeads/1000*prices.GetHeadPerThousand + gb*prices.TransferPerGB
+}
+
+func (r *CostReport) String() string {
+	return fmt.Sprintf(
+		"%d LIST, %d HEAD, %d GET (%d bytes), %d PUT (%d bytes), %d COPY, %d DELETE",
+		atomic.LoadInt64(&r.Lists), atomic.LoadInt64(&r.Heads),
+		atomic.LoadInt64(&r.Gets), atomic.LoadInt64(&r.GetBytes),
+		atomic.LoadInt64(&r.Puts), atomic.LoadInt64(&r.PutBytes),
+		atomic.LoadInt64(&r.Copies), atomic.LoadInt64(&r.Deletes),
+	)
+}
+
+// WithDryRun makes the FS count the requests it makes in report instead of
+// downloading or changing anything, to find out what a job would cost before running
+// it for real. Listings and HEADs are still made, since the job needs them to decide
+// what to read, but files read as zeros of their real size instead of being
+// downloaded (so it's no use with WithEncryption or WithWriteCompression, whose files
+// can't be decoded from zeros), and writes, copies and deletes are counted and
+// dropped. Store features
+// beyond the ObjectStore interface, such as multipart uploads and restores, aren't
+// available in a dry run.
+func WithDryRun(report *CostReport) Option {
+	return func(s *S3FS) {
+		s.dryRun = report
+	}
+}
+
+// dryRunStore counts requests to store, only making the ones that don't download or
+// change anything.
+type dryRunStore struct {
+	store  ObjectStore
+	report *CostReport
+}
+
+func (d *dryRunStore) List(ctx context.Context, prefix string, opts ListOptions, fn func(*ListPage) bool) error {
+	return d.store.List(ctx, prefix, opts, func(page *ListPage) bool {
+		atomic.AddInt64(&d.report.Lists, 1)
+		return fn(page)
+	})
+}
+
+func (d *dryRunStore) Head(ctx context.Context, key string) (ObjectInfo, error) {
+	atomic.AddInt64(&d.report.Heads, 1)
+
+	return d.store.Head(ctx, key)
+}
+
+func (d *dryRunStore) Get(ctx context.Context, key string, opts GetOptions) (*Object, error) {
+	atomic.AddInt64(&d.report.Gets, 1)
+
+	info, err := d.store.Head(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.IfNoneMatch != "" && opts.IfNoneMatch == info.ETag {
+		return nil, fmt.Errorf("%w: %s", ErrNotModified, key)
+	}
+
+	if !opts.IfModifiedSince.IsZero() && !info.LastModified.After(opts.IfModifiedSince) {
+		return nil, fmt.Errorf("%w: %s", ErrNotModified, key)
+	}
+
+	length := info.Size - opts.Offset
+	if opts.Length > 0 && opts.Length < length {
+		length = opts.Length
+	}
+
+	if length < 0 {
+		length = 0
+	}
+
+	atomic.AddInt64(&d.report.GetBytes, length)
+
+	return &Object{
+		Body: io.NopCloser(io.LimitReader(zeros{}, length)),
+		Info: info,
+	}, nil
+}
+
+func (d *dryRunStore) Put(ctx context.Context, key string, body io.Reader, opts PutOptions) (ObjectInfo, error) {
+	atomic.AddInt64(&d.report.Puts, 1)
+
+	n, err := io.Copy(io.Discard, body)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+
+	atomic.AddInt64(&d.report.PutBytes, n)
+
+	if opts.PartUploaded != nil {
+		opts.PartUploaded(1, n)
+	}
+
+	return ObjectInfo{Key: key, Size: n, ContentType: opts.ContentType, Metadata: opts.Metadata}, nil
+}
+
+func (d *dryRunStore) Delete(ctx context.Context, key string) error {
+	atomic.AddInt64(&d.report.Deletes, 1)
+
+	return nil
+}
+
+func (d *dryRunStore) Copy(ctx context.Context, src, dst string, opts CopyOptions) error {
+	atomic.AddInt64(&d.report.Copies, 1)
+
+	_, err := d.store.Head(ctx, src)
+
+	return err
+}
+
+// zeros reads as an endless stream of zero bytes.
+type zeros struct{}
+
+func (zeros) Read(buf []byte) (int, error) {
+	for i := range buf {
+		buf[i] = 0
+	}
+
+	return len(buf), nil
+}
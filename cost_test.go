@@ -0,0 +1,53 @@
+package s3fs_test
+
+import (
+	"io/fs"
+	"strings"
+	"testing"
+
+	"github.com/packrat386/s3fs"
+	"github.com/packrat386/s3fs/s3fstest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithDryRun(t *testing.T) {
+	store := &rangeStore{MemStore: s3fstest.NewMemStore()}
+	store.WriteFile("data/a.csv", strings.Repeat("a", 1000))
+	store.WriteFile("data/b.csv", strings.Repeat("b", 3000))
+
+	report := &s3fs.CostReport{}
+	myFS := s3fs.NewFS(store, s3fs.WithDryRun(report))
+
+	err := fs.WalkDir(myFS, "data", func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+
+		data, err := fs.ReadFile(myFS, p)
+		if err != nil {
+			return err
+		}
+
+		require.Equal(t, strings.Repeat("\x00", len(data)), string(data))
+
+		return myFS.WriteFile("out/"+d.Name(), data)
+	})
+	require.Nil(t, err)
+
+	// nothing was downloaded or written
+	require.Empty(t, store.ranges)
+	_, err = fs.Stat(myFS, "out/a.csv")
+	require.ErrorIs(t, err, fs.ErrNotExist)
+
+	require.Equal(t, int64(2), report.Gets)
+	require.Equal(t, int64(4000), report.GetBytes)
+	require.Equal(t, int64(2), report.Puts)
+	require.Equal(t, int64(4000), report.PutBytes)
+	require.Greater(t, report.Lists, int64(0))
+
+	cost := report.Estimate(s3fs.S3StandardPrices)
+	require.Greater(t, cost, 0.0)
+	require.Less(t, cost, 0.001)
+
+	require.Contains(t, report.String(), "GET (")
+}
@@ -0,0 +1,111 @@
+package s3fs
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+)
+
+// defaultBlobPrefix is where WriteFileDedup keeps blobs unless WithContentAddressing
+// says otherwise.
+const defaultBlobPrefix = ".s3fs-blobs/sha256/"
+
+// WithContentAddressing sets the prefix under which WriteFileDedup stores each
+// distinct content once, keyed by its SHA-256. The default is ".s3fs-blobs/sha256/".
+func WithContentAddressing(blobPrefix string) Option {
+	return func(s *S3FS) {
+		s.blobPrefix = blobPrefix
+	}
+}
+
+// WriteFileDedup writes data to name like WriteFile, but avoids uploading content the
+// store already has. If name already holds data it does nothing. Otherwise the data
+// is stored once under the content addressing prefix (see WithContentAddressing),
+// keyed by its SHA-256, and copied from there to name server side, so writing the same
+// content again, to any name, only costs a copy. uploaded reports whether data had to
+// be uploaded.
+func (s *S3FS) WriteFileDedup(name string, data []byte) (uploaded bool, err error) {
+	key, err := s.writableKey("write", name)
+	if err != nil {
+		return false, err
+	}
+
+	if s.encryption != nil {
+		return false, fmt.Errorf("could not write %s: encrypted files can't be deduplicated", key)
+	}
+
+	if s.compression != nil {
+		return false, fmt.Errorf("could not write %s: compressed files can't be deduplicated", key)
+	}
+
+	ctx := context.Background()
+
+	// this replaces anything still staged
+	if s.writeBack != nil {
+		s.writeBack.forget(key)
+	}
+
+	sum := md5.Sum(data)
+	if info, err := s.store.Head(ctx, key); err == nil && info.ETag == `"`+hex.EncodeToString(sum[:])+`"` {
+		return false, nil
+	}
+
+	prefix := s.blobPrefix
+	if prefix == "" {
+		prefix = defaultBlobPrefix
+	}
+
+	digest := sha256.Sum256(data)
+	blobKey := prefix + hex.EncodeToString(digest[:])
+
+	_, err = s.store.Head(ctx, blobKey)
+	if errors.Is(err, fs.ErrNotExist) {
+		uploaded = true
+		err = s.putBlob(ctx, blobKey, data)
+	}
+
+	if err != nil {
+		return uploaded, fmt.Errorf("could not store blob for %s: %w", key, err)
+	}
+
+	if err := s.quota.reserve(1, int64(len(data))); err != nil {
+		return uploaded, fmt.Errorf("could not write %s: %w", key, err)
+	}
+
+	if err := s.store.Copy(ctx, blobKey, key, CopyOptions{}); err != nil {
+		s.quota.release(1, int64(len(data)))
+
+		err = fmt.Errorf("could not copy blob to %s: %w", key, err)
+		if s.uploadHooks.Aborted != nil {
+			s.uploadHooks.Aborted(key, 0, err)
+		}
+
+		return uploaded, err
+	}
+
+	s.mutated("copy", key, blobKey, `"`+hex.EncodeToString(sum[:])+`"`)
+
+	if s.uploadHooks.Completed != nil {
+		s.uploadHooks.Completed(key, int64(len(data)))
+	}
+
+	return uploaded, nil
+}
+
+func (s *S3FS) putBlob(ctx context.Context, blobKey string, data []byte) error {
+	if err := s.quota.reserve(1, int64(len(data))); err != nil {
+		return err
+	}
+
+	if _, err := s.store.Put(ctx, blobKey, bytes.NewReader(data), PutOptions{}); err != nil {
+		s.quota.release(1, int64(len(data)))
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,47 @@
+package s3fs_test
+
+import (
+	"context"
+	"io/fs"
+	"testing"
+
+	"github.com/packrat386/s3fs"
+	"github.com/packrat386/s3fs/s3fstest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteFileDedup(t *testing.T) {
+	store := s3fstest.NewMemStore()
+	myFS := s3fs.NewFS(store, s3fs.WithContentAddressing("blobs/"))
+
+	uploaded, err := myFS.WriteFileDedup("a/artifact.bin", []byte("blob"))
+	require.Nil(t, err)
+	require.True(t, uploaded)
+
+	uploaded, err = myFS.WriteFileDedup("b/artifact.bin", []byte("blob"))
+	require.Nil(t, err)
+	require.False(t, uploaded)
+
+	// rewriting the same content is free
+	uploaded, err = myFS.WriteFileDedup("b/artifact.bin", []byte("blob"))
+	require.Nil(t, err)
+	require.False(t, uploaded)
+
+	for _, name := range []string{"a/artifact.bin", "b/artifact.bin"} {
+		data, err := fs.ReadFile(myFS, name)
+		require.Nil(t, err)
+		require.Equal(t, "blob", string(data))
+	}
+
+	entries, err := fs.ReadDir(myFS, "blobs")
+	require.Nil(t, err)
+	require.Equal(t, 1, len(entries))
+
+	uploaded, err = myFS.WriteFileDedup("a/artifact.bin", []byte("other"))
+	require.Nil(t, err)
+	require.True(t, uploaded)
+
+	info, err := store.Head(context.Background(), "a/artifact.bin")
+	require.Nil(t, err)
+	require.Equal(t, int64(5), info.Size)
+}
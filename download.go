@@ -0,0 +1,392 @@
+package s3fs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// DownloadHandler is called by DownloadMany with each opened file. The file is
+// closed after the handler returns.
+type DownloadHandler func(name string, f fs.File) error
+
+// DownloadManyError is returned by DownloadMany when any of the names could not be
+// opened or their handler returned an error.
+type DownloadManyError struct {
+	Errors map[string]error
+}
+
+func (e *DownloadManyError) Error() string {
+	names := make([]string, 0, len(e.Errors))
+	for name := range e.Errors {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	msgs := make([]string, 0, len(names))
+	for _, name := range names {
+		msgs = append(msgs, fmt.Sprintf("%s: %s", name, e.Errors[name]))
+	}
+
+	return fmt.Sprintf("%d downloads failed: %s", len(names), strings.Join(msgs, "; "))
+}
+
+// DownloadMany opens each of names in parallel (see WithConcurrency) and calls handler
+// with the opened file. A failure on one name doesn't stop the others; all failures
+// are collected in a *DownloadManyError. If ctx is cancelled, names that haven't
+// been started yet fail with the context's error.
+func (s *S3FS) DownloadMany(ctx context.Context, names []string, handler DownloadHandler) error {
+	work := make(chan string)
+	errs := map[string]error{}
+	done := 0
+	mu := sync.Mutex{}
+	wg := sync.WaitGroup{}
+
+	finish := func(name string, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if err != nil {
+			errs[name] = err
+		}
+
+		done++
+		if s.downloadProgress != nil {
+			s.downloadProgress(done, len(names))
+		}
+	}
+
+	for i := 0; i < s.concurrency(); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for name := range work {
+				finish(name, s.download(ctx, name, handler))
+			}
+		}()
+	}
+
+	for _, name := range names {
+		if ctx.Err() != nil {
+			finish(name, ctx.Err())
+			continue
+		}
+
+		work <- name
+	}
+
+	close(work)
+	wg.Wait()
+
+	if len(errs) > 0 {
+		return &DownloadManyError{Errors: errs}
+	}
+
+	return nil
+}
+
+func (s *S3FS) download(ctx context.Context, name string, handler DownloadHandler) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	f, err := s.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return handler(name, f)
+}
+
+// downloadPartSize is the size of the ranges DownloadTo fetches concurrently.
+const downloadPartSize = 8 << 20
+
+// downloadState is saved next to a partial download so that it can be resumed.
+type downloadState struct {
+	ETag string `json:"etag"`
+	Size int64  `json:"size"`
+	Done int64  `json:"done"`
+}
+
+// DownloadTo downloads the file at name to localPath, fetching ranges of it in
+// parallel (see WithConcurrency). For FSes backed by S3 each range is fetched with the
+// SDK's s3manager.Downloader, which retries a range whose body fails partway through.
+// Data is written to localPath + ".partial" and only renamed into place once complete.
+// If a previous DownloadTo of the same object was interrupted, it picks up where that
+// one left off as long as the object hasn't changed since.
+//
+// Files that aren't stored as they read, i.e. chunked, encrypted or compressed files,
+// are downloaded with one request through the same decoding as Open instead, and can't
+// be resumed.
+func (s *S3FS) DownloadTo(ctx context.Context, name string, localPath string) error {
+	if s.bucketErr != nil {
+		return s.bucketErr
+	}
+
+	key, err := s.key("download", name)
+	if err != nil {
+		return err
+	}
+
+	info, err := s.store.Head(ctx, key)
+	if err != nil {
+		return fmt.Errorf("could not stat %s: %w", key, err)
+	}
+
+	partialPath := localPath + ".partial"
+	statePath := partialPath + ".json"
+
+	var f *os.File
+	if storedAsSent(info) {
+		f, err = s.downloadRanges(ctx, key, info, partialPath, statePath)
+	} else {
+		os.Remove(statePath)
+		f, err = s.downloadDecoded(ctx, key, partialPath)
+	}
+
+	if f != nil {
+		defer f.Close()
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if err := f.Sync(); err != nil {
+		return fmt.Errorf("could not sync partial download: %w", err)
+	}
+
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("could not close partial download: %w", err)
+	}
+
+	if err := os.Rename(partialPath, localPath); err != nil {
+		return fmt.Errorf("could not move download into place: %w", err)
+	}
+
+	os.Remove(statePath)
+
+	return nil
+}
+
+// downloadRanges downloads the object at key, described by info, to partialPath in
+// parallel ranges, resuming from the state saved at statePath if it's of the same
+// object. It returns the file it opened at partialPath, if it did.
+func (s *S3FS) downloadRanges(ctx context.Context, key string, info ObjectInfo, partialPath, statePath string) (*os.File, error) {
+	state := downloadState{}
+	if data, err := os.ReadFile(statePath); err == nil {
+		json.Unmarshal(data, &state)
+	}
+
+	flags := os.O_RDWR | os.O_CREATE
+	if state.ETag != info.ETag || state.Size != info.Size {
+		state = downloadState{ETag: info.ETag, Size: info.Size}
+		flags |= os.O_TRUNC
+	}
+
+	f, err := os.OpenFile(partialPath, flags, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("could not open partial download: %w", err)
+	}
+
+	if err := f.Truncate(info.Size); err != nil {
+		return f, fmt.Errorf("could not size partial download: %w", err)
+	}
+
+	return f, s.downloadParts(ctx, key, f, &state, statePath)
+}
+
+// downloadDecoded downloads the file at key to partialPath by reading it like Open
+// does, which stitches chunks together, decrypts and decompresses. It returns the file
+// it opened at partialPath, if it did.
+func (s *S3FS) downloadDecoded(ctx context.Context, key string, partialPath string) (*os.File, error) {
+	var src fs.File
+	var err error
+	if s.encryption != nil || s.compression != nil {
+		src, err = openDecoded(ctx, s, key, GetOptions{})
+	} else {
+		src, err = openChunked(ctx, s, key, GetOptions{})
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+
+	f, err := os.OpenFile(partialPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("could not open partial download: %w", err)
+	}
+
+	buf := s.buffers.Get()
+	defer s.buffers.Put(buf)
+
+	if _, err := io.CopyBuffer(f, src, buf); err != nil {
+		return f, fmt.Errorf("could not download %s: %w", key, err)
+	}
+
+	return f, nil
+}
+
+// downloadParts fills f with the parts of key after state.Done. As parts finish,
+// state.Done is advanced over the contiguous completed prefix and saved to statePath.
+func (s *S3FS) downloadParts(ctx context.Context, key string, f *os.File, state *downloadState, statePath string) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	offsets := []int64{}
+	for off := state.Done; off < state.Size; off += downloadPartSize {
+		offsets = append(offsets, off)
+	}
+
+	work := make(chan int)
+	finished := make([]bool, len(offsets))
+	next := 0
+	mu := sync.Mutex{}
+	wg := sync.WaitGroup{}
+	var firstErr error
+
+	fail := func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if firstErr == nil {
+			firstErr = err
+			cancel()
+		}
+	}
+
+	for i := 0; i < s.concurrency(); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for i := range work {
+				if err := s.downloadPart(ctx, key, f, state.ETag, offsets[i], state.Size); err != nil {
+					fail(err)
+					continue
+				}
+
+				mu.Lock()
+				finished[i] = true
+				for next < len(finished) && finished[next] {
+					next++
+				}
+
+				if next < len(offsets) {
+					state.Done = offsets[next]
+				} else {
+					state.Done = state.Size
+				}
+
+				data, _ := json.Marshal(state)
+				os.WriteFile(statePath, data, 0644)
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for i := range offsets {
+		if ctx.Err() != nil {
+			break
+		}
+
+		work <- i
+	}
+
+	close(work)
+	wg.Wait()
+
+	if firstErr != nil {
+		return firstErr
+	}
+
+	return ctx.Err()
+}
+
+// rangeDownloader is implemented by stores with a transfer manager of their own, which
+// DownloadTo fetches ranges with instead of Get.
+type rangeDownloader interface {
+	// downloadRange writes length bytes of key from offset to w at the same offset, as
+	// long as key still has the ETag etag, and returns how many bytes it wrote.
+	downloadRange(ctx context.Context, key string, w io.WriterAt, offset, length int64, etag string) (int64, error)
+}
+
+func (s *S3FS) downloadPart(ctx context.Context, key string, f *os.File, etag string, offset, size int64) error {
+	length := size - offset
+	if length > downloadPartSize {
+		length = downloadPartSize
+	}
+
+	var n int64
+	var err error
+	if d, ok := s.baseStore().(rangeDownloader); ok {
+		n, err = d.downloadRange(ctx, key, f, offset, length, etag)
+	} else {
+		n, err = s.getRange(ctx, key, f, etag, offset, length)
+	}
+
+	if errors.Is(err, ErrPreconditionFailed) {
+		return fmt.Errorf("%s changed during download", key)
+	}
+
+	if err != nil {
+		return fmt.Errorf("could not download %s at offset %d: %w", key, offset, err)
+	}
+
+	if n != length {
+		return fmt.Errorf("could not download %s at offset %d: %w: read %d of %d bytes", key, offset, ErrTruncated, n, length)
+	}
+
+	return nil
+}
+
+// getRange gets length bytes of key from offset and writes them to f at the same
+// offset.
+func (s *S3FS) getRange(ctx context.Context, key string, f *os.File, etag string, offset, length int64) (int64, error) {
+	object, err := s.store.Get(ctx, key, GetOptions{Offset: offset, Length: length})
+	if err != nil {
+		return 0, err
+	}
+	defer object.Body.Close()
+
+	if object.Info.ETag != etag {
+		return 0, ErrPreconditionFailed
+	}
+
+	buf := s.buffers.Get()
+	defer s.buffers.Put(buf)
+
+	return io.CopyBuffer(&offsetWriter{w: f, off: offset}, object.Body, buf)
+}
+
+type offsetWriter struct {
+	w   io.WriterAt
+	off int64
+}
+
+func (o *offsetWriter) Write(buf []byte) (int, error) {
+	n, err := o.w.WriteAt(buf, o.off)
+	o.off += int64(n)
+	return n, err
+}
+
+// shiftedWriterAt writes to w at off past the offsets it's given.
+type shiftedWriterAt struct {
+	w   io.WriterAt
+	off int64
+}
+
+func (s *shiftedWriterAt) WriteAt(buf []byte, off int64) (int, error) {
+	return s.w.WriteAt(buf, s.off+off)
+}
@@ -0,0 +1,181 @@
+package s3fs_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/packrat386/s3fs"
+	"github.com/packrat386/s3fs/s3fstest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDownloadMany(t *testing.T) {
+	store := s3fstest.NewMemStore()
+	store.WriteFile("a.txt", "a")
+	store.WriteFile("b.txt", "b")
+	store.WriteFile("c.txt", "c")
+
+	progress := []int{}
+	myFS := s3fs.NewFS(
+		store,
+		s3fs.WithConcurrency(2),
+		s3fs.WithDownloadManyProgress(func(done, total int) {
+			require.Equal(t, 5, total)
+			progress = append(progress, done)
+		}),
+	)
+
+	mu := sync.Mutex{}
+	got := map[string]string{}
+
+	err := myFS.DownloadMany(
+		context.Background(),
+		[]string{"a.txt", "b.txt", "c.txt", "missing.txt", "bad.txt"},
+		func(name string, f fs.File) error {
+			data, err := io.ReadAll(f)
+			if err != nil {
+				return err
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			got[name] = string(data)
+			return nil
+		},
+	)
+
+	require.Equal(t, map[string]string{"a.txt": "a", "b.txt": "b", "c.txt": "c"}, got)
+	require.Equal(t, []int{1, 2, 3, 4, 5}, progress)
+
+	var dmErr *s3fs.DownloadManyError
+	require.True(t, errors.As(err, &dmErr))
+	require.Equal(t, 2, len(dmErr.Errors))
+	require.True(t, errors.Is(dmErr.Errors["missing.txt"], fs.ErrNotExist))
+	require.True(t, errors.Is(dmErr.Errors["bad.txt"], fs.ErrNotExist))
+}
+
+func TestDownloadMany_Cancelled(t *testing.T) {
+	store := s3fstest.NewMemStore()
+	store.WriteFile("a.txt", "a")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := s3fs.NewFS(store).DownloadMany(ctx, []string{"a.txt"}, func(name string, f fs.File) error {
+		t.Fatal("handler should not be called")
+		return nil
+	})
+
+	var dmErr *s3fs.DownloadManyError
+	require.True(t, errors.As(err, &dmErr))
+	require.True(t, errors.Is(dmErr.Errors["a.txt"], context.Canceled))
+}
+
+func TestDownloadTo(t *testing.T) {
+	body := bytes.Repeat([]byte("0123456789abcdef"), 1<<20+3)
+
+	store := s3fstest.NewMemStore()
+	store.WriteFile("big.bin", string(body))
+
+	dir := t.TempDir()
+	dst := filepath.Join(dir, "big.bin")
+
+	err := s3fs.NewFS(store, s3fs.WithConcurrency(2)).DownloadTo(context.Background(), "big.bin", dst)
+	require.Nil(t, err)
+
+	data, err := os.ReadFile(dst)
+	require.Nil(t, err)
+	require.True(t, bytes.Equal(body, data))
+
+	_, err = os.Stat(dst + ".partial")
+	require.True(t, errors.Is(err, fs.ErrNotExist))
+	_, err = os.Stat(dst + ".partial.json")
+	require.True(t, errors.Is(err, fs.ErrNotExist))
+}
+
+func TestDownloadTo_Resume(t *testing.T) {
+	body := bytes.Repeat([]byte("0123456789abcdef"), 1<<20+3)
+
+	store := s3fstest.NewMemStore()
+	store.WriteFile("big.bin", string(body))
+
+	info, err := store.Head(context.Background(), "big.bin")
+	require.Nil(t, err)
+
+	dir := t.TempDir()
+	dst := filepath.Join(dir, "big.bin")
+
+	// pretend the first 8 MiB made it last time, and poison the rest so we can
+	// tell it was fetched again
+	partial := append([]byte{}, body[:8<<20]...)
+	partial = append(partial, bytes.Repeat([]byte("x"), len(body)-len(partial))...)
+	require.Nil(t, os.WriteFile(dst+".partial", partial, 0644))
+
+	state, err := json.Marshal(map[string]interface{}{"etag": info.ETag, "size": info.Size, "done": 8 << 20})
+	require.Nil(t, err)
+	require.Nil(t, os.WriteFile(dst+".partial.json", state, 0644))
+
+	err = s3fs.NewFS(store).DownloadTo(context.Background(), "big.bin", dst)
+	require.Nil(t, err)
+
+	data, err := os.ReadFile(dst)
+	require.Nil(t, err)
+	require.True(t, bytes.Equal(body, data))
+}
+
+func TestDownloadTo_StaleResume(t *testing.T) {
+	store := s3fstest.NewMemStore()
+	store.WriteFile("small.txt", "brand new contents")
+
+	dir := t.TempDir()
+	dst := filepath.Join(dir, "small.txt")
+
+	require.Nil(t, os.WriteFile(dst+".partial", []byte("old contents that are longer"), 0644))
+	require.Nil(t, os.WriteFile(dst+".partial.json", []byte(`{"etag":"\"old\"","size":28,"done":28}`), 0644))
+
+	err := s3fs.NewFS(store).DownloadTo(context.Background(), "small.txt", dst)
+	require.Nil(t, err)
+
+	data, err := os.ReadFile(dst)
+	require.Nil(t, err)
+	require.Equal(t, "brand new contents", string(data))
+}
+
+func TestDownloadTo_Decoded(t *testing.T) {
+	content := strings.Repeat("0123456789", 1000)
+
+	store := s3fstest.NewMemStore()
+	myFS := s3fs.NewFS(
+		store,
+		s3fs.WithWriteCompression(s3fs.Gzip),
+		s3fs.WithEncryption(newFakeKMS(t), "alias/test"),
+		s3fs.WithChunking(1000),
+	)
+
+	require.Nil(t, myFS.WriteFile("big.txt", []byte(content)))
+
+	dir := t.TempDir()
+	dst := filepath.Join(dir, "big.txt")
+
+	// a stale partial download of the stored bytes is discarded
+	require.Nil(t, os.WriteFile(dst+".partial.json", []byte(`{"etag":"\"old\"","size":28,"done":28}`), 0644))
+
+	err := myFS.DownloadTo(context.Background(), "big.txt", dst)
+	require.Nil(t, err)
+
+	data, err := os.ReadFile(dst)
+	require.Nil(t, err)
+	require.Equal(t, content, string(data))
+
+	_, err = os.Stat(dst + ".partial.json")
+	require.True(t, errors.Is(err, fs.ErrNotExist))
+}
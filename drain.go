@@ -0,0 +1,36 @@
+package s3fs
+
+import "io"
+
+// defaultCloseDrain is how many unread bytes Close reads and discards unless
+// WithCloseDrain says otherwise.
+const defaultCloseDrain = 64 << 10
+
+// WithCloseDrain sets how many unread bytes of a file Close reads and throws away so
+// its connection can be reused. Closing a response body before reading all of it
+// makes Go tear down the connection, so workloads that only read the start of many
+// objects, like file headers, would otherwise open a new connection for every file.
+// Remainders bigger than n aren't drained, since reading them would cost more than a
+// new connection; nor are bodies that already failed, whose connections are gone
+// anyway. It defaults to 64 KiB, and n <= 0 turns it off.
+func WithCloseDrain(n int64) Option {
+	return func(s *S3FS) {
+		s.closeDrain = n
+	}
+}
+
+// drain reads what's left of f's body if that's at most f.closeDrain bytes.
+func (f *s3File) drain() {
+	if f.closeDrain <= 0 || f.bodyErr != nil {
+		return
+	}
+
+	remaining := f.fileInfo.size - f.read
+	if remaining <= 0 || remaining > f.closeDrain {
+		return
+	}
+
+	// the size is only a hint for encoded files, so never read more than allowed.
+	// Errors don't matter, they just mean the connection can't be reused.
+	io.CopyN(io.Discard, f.body, f.closeDrain)
+}
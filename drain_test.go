@@ -0,0 +1,77 @@
+package s3fs_test
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/packrat386/s3fs"
+	"github.com/packrat386/s3fs/s3fstest"
+	"github.com/stretchr/testify/require"
+)
+
+// drainStore records how much of each body was left unread when it was closed.
+type drainStore struct {
+	*s3fstest.MemStore
+
+	unread map[string]int
+}
+
+type drainBody struct {
+	io.Reader
+	closed func(unread int)
+}
+
+func (b *drainBody) Close() error {
+	n, _ := io.Copy(io.Discard, b.Reader)
+	b.closed(int(n))
+
+	return nil
+}
+
+func (s *drainStore) Get(ctx context.Context, key string, opts s3fs.GetOptions) (*s3fs.Object, error) {
+	object, err := s.MemStore.Get(ctx, key, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	object.Body = &drainBody{
+		Reader: object.Body,
+		closed: func(unread int) { s.unread[key] = unread },
+	}
+
+	return object, nil
+}
+
+func TestWithCloseDrain(t *testing.T) {
+	store := &drainStore{MemStore: s3fstest.NewMemStore(), unread: map[string]int{}}
+	store.WriteFile("small.bin", strings.Repeat("x", 1000))
+	store.WriteFile("big.bin", strings.Repeat("x", 200<<10))
+
+	readHeader := func(fsys *s3fs.S3FS, name string) {
+		f, err := fsys.Open(name)
+		require.Nil(t, err)
+
+		_, err = f.Read(make([]byte, 16))
+		require.Nil(t, err)
+		require.Nil(t, f.Close())
+	}
+
+	myFS := s3fs.NewFS(store)
+
+	readHeader(myFS, "small.bin")
+	require.Equal(t, 0, store.unread["small.bin"])
+
+	// too much left to be worth reading
+	readHeader(myFS, "big.bin")
+	require.Equal(t, 200<<10-16, store.unread["big.bin"])
+
+	bigger := s3fs.NewFS(store, s3fs.WithCloseDrain(1<<20))
+	readHeader(bigger, "big.bin")
+	require.Equal(t, 0, store.unread["big.bin"])
+
+	off := s3fs.NewFS(store, s3fs.WithCloseDrain(0))
+	readHeader(off, "small.bin")
+	require.Equal(t, 1000-16, store.unread["small.bin"])
+}
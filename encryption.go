@@ -0,0 +1,498 @@
+package s3fs
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/kms"
+	"github.com/aws/aws-sdk-go/service/kms/kmsiface"
+)
+
+// ErrNotEncrypted is returned when reading a file through an FS with WithEncryption
+// that wasn't written encrypted.
+var ErrNotEncrypted = errors.New("file is not encrypted")
+
+const (
+	// encryptionMetadata marks an encrypted object, set to the format it's in.
+	encryptionMetadata = "s3fs-encryption"
+
+	// wrappedKeyMetadata is the data key of an encrypted object, as encrypted by KMS
+	// and base64 encoded.
+	wrappedKeyMetadata = "s3fs-wrapped-key"
+
+	encryptionFormat = "aws-encryption-sdk"
+
+	// the message format of the AWS Encryption SDK, version 1, with the algorithm
+	// suite AES_256_GCM_IV12_TAG16_HKDF_SHA256, which isn't signed and which every
+	// version of the SDK can decrypt with its default commitment policy
+	esdkVersion     = 0x01
+	esdkType        = 0x80
+	esdkAlgorithm   = 0x0178
+	esdkFramed      = 0x02
+	esdkIVLen       = 12
+	esdkTagLen      = 16
+	esdkFrameLength = 4096
+	esdkFinalFrame  = 0xFFFFFFFF
+	esdkKMSProvider = "aws-kms"
+
+	esdkFrameAAD      = "AWSKMSEncryptionClient Frame"
+	esdkFinalFrameAAD = "AWSKMSEncryptionClient Final Frame"
+)
+
+// WithEncryption makes the FS encrypt files client side as they are written and
+// decrypt them as they are read, for data that can't be trusted to server side
+// encryption alone. Each file is encrypted with AES-256-GCM under a new data key
+// generated by KMS with the key keyID, and the data key, encrypted by KMS, is stored
+// in the object's metadata as well as in the object itself. Objects are in the
+// message format of the AWS Encryption SDK, so any of its implementations can decrypt
+// them with a KMS keyring for keyID.
+//
+// Reading a file that isn't encrypted fails with an error wrapping ErrNotEncrypted.
+// Listings report the size of the encrypted objects, which are slightly bigger than
+// the files; Stat on an opened file reports the real size. Encrypted files are always
+// streamed, regardless of WithCache, WithColumnarAccess and WithStreamingConcurrency,
+// and can't be appended to or written with WriteFileDedup.
+func WithEncryption(client kmsiface.KMSAPI, keyID string) Option {
+	return func(s *S3FS) {
+		s.encryption = &envelope{kms: client, keyID: keyID}
+	}
+}
+
+// envelope encrypts and decrypts with data keys from KMS.
+type envelope struct {
+	kms   kmsiface.KMSAPI
+	keyID string
+}
+
+// encrypted reports whether info describes an encrypted object.
+func encrypted(info ObjectInfo) bool {
+	format, _ := metadataValue(info.Metadata, encryptionMetadata)
+
+	return format == encryptionFormat
+}
+
+// seal returns body encrypted under a new data key, and opts with the metadata that
+// marks the object as encrypted.
+func (e *envelope) seal(ctx context.Context, body io.Reader, opts PutOptions) (io.Reader, PutOptions, error) {
+	out, err := e.kms.GenerateDataKeyWithContext(ctx, &kms.GenerateDataKeyInput{
+		KeyId:   aws.String(e.keyID),
+		KeySpec: aws.String(kms.DataKeySpecAes256),
+	})
+	if err != nil {
+		return nil, opts, fmt.Errorf("could not generate data key: %w", err)
+	}
+
+	messageID := make([]byte, 16)
+	if _, err := rand.Read(messageID); err != nil {
+		return nil, opts, fmt.Errorf("could not generate message ID: %w", err)
+	}
+
+	aead, err := esdkCipher(out.Plaintext, messageID)
+	if err != nil {
+		return nil, opts, err
+	}
+
+	header := &bytes.Buffer{}
+	header.Write([]byte{esdkVersion, esdkType})
+	binary.Write(header, binary.BigEndian, uint16(esdkAlgorithm))
+	header.Write(messageID)
+	binary.Write(header, binary.BigEndian, uint16(0)) // no encryption context
+	binary.Write(header, binary.BigEndian, uint16(1))
+	writeField(header, []byte(esdkKMSProvider))
+	writeField(header, []byte(aws.StringValue(out.KeyId)))
+	writeField(header, out.CiphertextBlob)
+	header.WriteByte(esdkFramed)
+	header.Write([]byte{0, 0, 0, 0})
+	header.WriteByte(esdkIVLen)
+	binary.Write(header, binary.BigEndian, uint32(esdkFrameLength))
+
+	iv := make([]byte, esdkIVLen)
+	auth := aead.Seal(nil, iv, nil, header.Bytes())
+	header.Write(iv)
+	header.Write(auth)
+
+	metadata := map[string]string{}
+	for k, v := range opts.Metadata {
+		metadata[k] = v
+	}
+	metadata[encryptionMetadata] = encryptionFormat
+	metadata[wrappedKeyMetadata] = base64.StdEncoding.EncodeToString(out.CiphertextBlob)
+	opts.Metadata = metadata
+
+	r := &sealingReader{
+		body:      body,
+		aead:      aead,
+		messageID: messageID,
+		out:       header.Bytes(),
+		plain:     make([]byte, esdkFrameLength),
+	}
+
+	return r, opts, nil
+}
+
+// open decrypts the object described by info whose body is r.
+func (e *envelope) open(ctx context.Context, info ObjectInfo, r io.Reader) (io.Reader, int64, error) {
+	if !encrypted(info) {
+		return nil, 0, fmt.Errorf("%w: %s", ErrNotEncrypted, info.Key)
+	}
+
+	br := bufio.NewReader(r)
+	header := &bytes.Buffer{}
+	hr := io.TeeReader(br, header)
+
+	fixed := make([]byte, 4+16)
+	if _, err := io.ReadFull(hr, fixed); err != nil {
+		return nil, 0, fmt.Errorf("could not read encryption header: %w", err)
+	}
+
+	if fixed[0] != esdkVersion || fixed[1] != esdkType || binary.BigEndian.Uint16(fixed[2:]) != esdkAlgorithm {
+		return nil, 0, fmt.Errorf("unsupported encryption format %x", fixed[:4])
+	}
+
+	messageID := fixed[4:]
+
+	aad, err := readField(hr)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	encryptionContext, err := parseEncryptionContext(aad)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var edkCount uint16
+	if err := binary.Read(hr, binary.BigEndian, &edkCount); err != nil {
+		return nil, 0, fmt.Errorf("could not read encryption header: %w", err)
+	}
+
+	var wrapped []byte
+	for i := 0; i < int(edkCount); i++ {
+		provider, err := readField(hr)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		if _, err := readField(hr); err != nil {
+			return nil, 0, err
+		}
+
+		ciphertext, err := readField(hr)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		if string(provider) == esdkKMSProvider && wrapped == nil {
+			wrapped = ciphertext
+		}
+	}
+
+	if wrapped == nil {
+		return nil, 0, fmt.Errorf("no KMS encrypted data key")
+	}
+
+	tail := make([]byte, 1+4+1+4)
+	if _, err := io.ReadFull(hr, tail); err != nil {
+		return nil, 0, fmt.Errorf("could not read encryption header: %w", err)
+	}
+
+	if tail[0] != esdkFramed || tail[5] != esdkIVLen {
+		return nil, 0, fmt.Errorf("unsupported encryption content type %d", tail[0])
+	}
+
+	frameLength := int64(binary.BigEndian.Uint32(tail[6:]))
+	headerLength := int64(header.Len())
+
+	auth := make([]byte, esdkIVLen+esdkTagLen)
+	if _, err := io.ReadFull(br, auth); err != nil {
+		return nil, 0, fmt.Errorf("could not read encryption header: %w", err)
+	}
+
+	out, err := e.kms.DecryptWithContext(ctx, &kms.DecryptInput{
+		CiphertextBlob:    wrapped,
+		EncryptionContext: aws.StringMap(encryptionContext),
+	})
+	if err != nil {
+		return nil, 0, fmt.Errorf("could not decrypt data key: %w", err)
+	}
+
+	aead, err := esdkCipher(out.Plaintext, messageID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if _, err := aead.Open(nil, auth[:esdkIVLen], auth[esdkIVLen:], header.Bytes()); err != nil {
+		return nil, 0, fmt.Errorf("could not authenticate encryption header: %w", err)
+	}
+
+	// every frame but the last is full, and the last is shorter than a full frame
+	frameOverhead := int64(4 + esdkIVLen + esdkTagLen)
+	finalOverhead := int64(4+4+4) + int64(esdkIVLen+esdkTagLen)
+	body := info.Size - headerLength - int64(len(auth)) - finalOverhead
+	frames := body / (frameLength + frameOverhead)
+	size := frames*frameLength + body - frames*(frameLength+frameOverhead)
+
+	return &openingReader{
+		r:           br,
+		aead:        aead,
+		messageID:   messageID,
+		frameLength: frameLength,
+	}, size, nil
+}
+
+// esdkCipher derives the key of the message messageID from dataKey with HKDF-SHA256,
+// as the algorithm suite requires, and returns an AES-GCM cipher with it.
+func esdkCipher(dataKey, messageID []byte) (cipher.AEAD, error) {
+	info := make([]byte, 2, 2+len(messageID)+1)
+	binary.BigEndian.PutUint16(info, esdkAlgorithm)
+	info = append(append(info, messageID...), 1)
+
+	extract := hmac.New(sha256.New, make([]byte, sha256.Size))
+	extract.Write(dataKey)
+
+	expand := hmac.New(sha256.New, extract.Sum(nil))
+	expand.Write(info)
+
+	block, err := aes.NewCipher(expand.Sum(nil))
+	if err != nil {
+		return nil, fmt.Errorf("could not create cipher: %w", err)
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// frameAAD is the additional authenticated data of frame seq holding length bytes.
+func frameAAD(messageID []byte, final bool, seq uint32, length int) []byte {
+	aad := &bytes.Buffer{}
+	aad.Write(messageID)
+	if final {
+		aad.WriteString(esdkFinalFrameAAD)
+	} else {
+		aad.WriteString(esdkFrameAAD)
+	}
+	binary.Write(aad, binary.BigEndian, seq)
+	binary.Write(aad, binary.BigEndian, uint64(length))
+
+	return aad.Bytes()
+}
+
+func frameIV(seq uint32) []byte {
+	iv := make([]byte, esdkIVLen)
+	binary.BigEndian.PutUint32(iv[esdkIVLen-4:], seq)
+
+	return iv
+}
+
+// writeField writes data preceded by its 2 byte length.
+func writeField(w *bytes.Buffer, data []byte) {
+	binary.Write(w, binary.BigEndian, uint16(len(data)))
+	w.Write(data)
+}
+
+// readField reads data preceded by its 2 byte length.
+func readField(r io.Reader) ([]byte, error) {
+	var n uint16
+	if err := binary.Read(r, binary.BigEndian, &n); err != nil {
+		return nil, fmt.Errorf("could not read encryption header: %w", err)
+	}
+
+	data := make([]byte, n)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, fmt.Errorf("could not read encryption header: %w", err)
+	}
+
+	return data, nil
+}
+
+// parseEncryptionContext parses the serialized encryption context of a message.
+func parseEncryptionContext(aad []byte) (map[string]string, error) {
+	if len(aad) == 0 {
+		return nil, nil
+	}
+
+	r := bytes.NewReader(aad)
+
+	var count uint16
+	if err := binary.Read(r, binary.BigEndian, &count); err != nil {
+		return nil, fmt.Errorf("could not read encryption context: %w", err)
+	}
+
+	encryptionContext := map[string]string{}
+	for i := 0; i < int(count); i++ {
+		k, err := readField(r)
+		if err != nil {
+			return nil, err
+		}
+
+		v, err := readField(r)
+		if err != nil {
+			return nil, err
+		}
+
+		encryptionContext[string(k)] = string(v)
+	}
+
+	return encryptionContext, nil
+}
+
+// sealingReader reads the header of a message and then body as encrypted frames.
+type sealingReader struct {
+	body      io.Reader
+	aead      cipher.AEAD
+	messageID []byte
+	seq       uint32
+	done      bool
+
+	plain []byte
+	out   []byte
+}
+
+func (r *sealingReader) Read(buf []byte) (int, error) {
+	for len(r.out) == 0 {
+		if r.done {
+			return 0, io.EOF
+		}
+
+		if err := r.nextFrame(); err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(buf, r.out)
+	r.out = r.out[n:]
+
+	return n, nil
+}
+
+func (r *sealingReader) nextFrame() error {
+	n, err := io.ReadFull(r.body, r.plain)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return err
+	}
+
+	r.seq++
+	final := err != nil
+
+	frame := &bytes.Buffer{}
+	if final {
+		binary.Write(frame, binary.BigEndian, uint32(esdkFinalFrame))
+	}
+	binary.Write(frame, binary.BigEndian, r.seq)
+
+	iv := frameIV(r.seq)
+	frame.Write(iv)
+
+	if final {
+		binary.Write(frame, binary.BigEndian, uint32(n))
+	}
+
+	frame.Write(r.aead.Seal(nil, iv, r.plain[:n], frameAAD(r.messageID, final, r.seq, n)))
+
+	r.out = frame.Bytes()
+	r.done = final
+
+	return nil
+}
+
+// openingReader decrypts the frames of a message after its header.
+type openingReader struct {
+	r           io.Reader
+	aead        cipher.AEAD
+	messageID   []byte
+	frameLength int64
+	seq         uint32
+	done        bool
+
+	out []byte
+}
+
+func (r *openingReader) Read(buf []byte) (int, error) {
+	for len(r.out) == 0 {
+		if r.done {
+			return 0, io.EOF
+		}
+
+		if err := r.nextFrame(); err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(buf, r.out)
+	r.out = r.out[n:]
+
+	return n, nil
+}
+
+func (r *openingReader) nextFrame() error {
+	var seq uint32
+	if err := binary.Read(r.r, binary.BigEndian, &seq); err != nil {
+		return fmt.Errorf("could not read encrypted frame: %w", unexpected(err))
+	}
+
+	final := seq == esdkFinalFrame
+	if final {
+		if err := binary.Read(r.r, binary.BigEndian, &seq); err != nil {
+			return fmt.Errorf("could not read encrypted frame: %w", unexpected(err))
+		}
+	}
+
+	if seq != r.seq+1 {
+		return fmt.Errorf("encrypted frame %d is out of order", seq)
+	}
+	r.seq = seq
+
+	iv := make([]byte, esdkIVLen)
+	if _, err := io.ReadFull(r.r, iv); err != nil {
+		return fmt.Errorf("could not read encrypted frame: %w", unexpected(err))
+	}
+
+	length := r.frameLength
+	if final {
+		var n uint32
+		if err := binary.Read(r.r, binary.BigEndian, &n); err != nil {
+			return fmt.Errorf("could not read encrypted frame: %w", unexpected(err))
+		}
+
+		if int64(n) > r.frameLength {
+			return fmt.Errorf("encrypted frame %d is too long", seq)
+		}
+
+		length = int64(n)
+	}
+
+	sealed := make([]byte, length+esdkTagLen)
+	if _, err := io.ReadFull(r.r, sealed); err != nil {
+		return fmt.Errorf("could not read encrypted frame: %w", unexpected(err))
+	}
+
+	plain, err := r.aead.Open(sealed[:0], iv, sealed, frameAAD(r.messageID, final, seq, int(length)))
+	if err != nil {
+		return fmt.Errorf("could not decrypt frame %d: %w", seq, err)
+	}
+
+	r.out = plain
+	r.done = final
+
+	return nil
+}
+
+// unexpected turns io.EOF into io.ErrUnexpectedEOF, for reads that can't end there.
+func unexpected(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+
+	return err
+}
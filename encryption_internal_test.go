@@ -0,0 +1,34 @@
+package s3fs
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestESDKCipher(t *testing.T) {
+	dataKey := bytes.Repeat([]byte{42}, 32)
+	messageID := []byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15}
+
+	// HKDF-SHA256 (RFC 5869) of dataKey with no salt and the info 0x0178 || messageID,
+	// as the message format specification derives the key of suite 0x0178
+	derived, err := hex.DecodeString("f806734186952935c09ffc585d2399902d14c1b6ca109ea658305b47f11245ad")
+	require.Nil(t, err)
+
+	block, err := aes.NewCipher(derived)
+	require.Nil(t, err)
+
+	want, err := cipher.NewGCM(block)
+	require.Nil(t, err)
+
+	got, err := esdkCipher(dataKey, messageID)
+	require.Nil(t, err)
+
+	iv := frameIV(1)
+	aad := frameAAD(messageID, true, 1, 5)
+	require.Equal(t, want.Seal(nil, iv, []byte("hello"), aad), got.Seal(nil, iv, []byte("hello"), aad))
+}
@@ -0,0 +1,147 @@
+package s3fs_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"errors"
+	"io"
+	"io/fs"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/kms"
+	"github.com/aws/aws-sdk-go/service/kms/kmsiface"
+	"github.com/packrat386/s3fs"
+	"github.com/packrat386/s3fs/s3fstest"
+	"github.com/stretchr/testify/require"
+)
+
+const testKeyARN = "arn:aws:kms:us-east-1:111122223333:key/test"
+
+// fakeKMS wraps data keys with a master key of its own.
+type fakeKMS struct {
+	kmsiface.KMSAPI
+	master cipher.AEAD
+}
+
+func newFakeKMS(t *testing.T) *fakeKMS {
+	block, err := aes.NewCipher(bytes.Repeat([]byte{7}, 32))
+	require.Nil(t, err)
+
+	master, err := cipher.NewGCM(block)
+	require.Nil(t, err)
+
+	return &fakeKMS{master: master}
+}
+
+func (k *fakeKMS) GenerateDataKeyWithContext(ctx aws.Context, input *kms.GenerateDataKeyInput, opts ...request.Option) (*kms.GenerateDataKeyOutput, error) {
+	key := bytes.Repeat([]byte{42}, 32)
+	nonce := make([]byte, k.master.NonceSize())
+
+	return &kms.GenerateDataKeyOutput{
+		KeyId:          aws.String(testKeyARN),
+		Plaintext:      key,
+		CiphertextBlob: k.master.Seal(nonce, nonce, key, nil),
+	}, nil
+}
+
+func (k *fakeKMS) DecryptWithContext(ctx aws.Context, input *kms.DecryptInput, opts ...request.Option) (*kms.DecryptOutput, error) {
+	n := k.master.NonceSize()
+	if len(input.CiphertextBlob) < n {
+		return nil, errors.New("invalid ciphertext")
+	}
+
+	key, err := k.master.Open(nil, input.CiphertextBlob[:n], input.CiphertextBlob[n:], nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &kms.DecryptOutput{KeyId: aws.String(testKeyARN), Plaintext: key}, nil
+}
+
+func rawObject(t *testing.T, store s3fs.ObjectStore, key string) (*s3fs.ObjectInfo, []byte) {
+	object, err := store.Get(context.Background(), key, s3fs.GetOptions{})
+	require.Nil(t, err)
+	defer object.Body.Close()
+
+	data, err := io.ReadAll(object.Body)
+	require.Nil(t, err)
+
+	return &object.Info, data
+}
+
+func TestWithEncryption(t *testing.T) {
+	store := s3fstest.NewMemStore()
+	myFS := s3fs.NewFS(store, s3fs.WithEncryption(newFakeKMS(t), "alias/test"))
+
+	for _, size := range []int{0, 10, 4096, 4097, 10000} {
+		content := strings.Repeat("x", size)
+		require.Nil(t, myFS.WriteFile("secret.txt", []byte(content)))
+
+		info, raw := rawObject(t, store, "secret.txt")
+		require.NotContains(t, string(raw), "xxxx")
+		// version 1, customer authenticated encrypted data, AES_256_GCM_IV12_TAG16_HKDF_SHA256
+		require.Equal(t, []byte{0x01, 0x80, 0x01, 0x78}, raw[:4])
+		require.Equal(t, "aws-encryption-sdk", info.Metadata["s3fs-encryption"])
+		require.NotEmpty(t, info.Metadata["s3fs-wrapped-key"])
+
+		data, err := fs.ReadFile(myFS, "secret.txt")
+		require.Nil(t, err)
+		require.Equal(t, content, string(data))
+
+		stat, err := fs.Stat(myFS, "secret.txt")
+		require.Nil(t, err)
+		require.Equal(t, int64(size), stat.Size())
+	}
+
+	require.Nil(t, myFS.UploadFrom(context.Background(), "uploaded.txt", strings.NewReader("uploaded"), 8))
+
+	data, err := fs.ReadFile(myFS, "uploaded.txt")
+	require.Nil(t, err)
+	require.Equal(t, "uploaded", string(data))
+
+	_, err = myFS.Append("uploaded.txt")
+	require.Error(t, err)
+}
+
+func TestWithEncryption_Tampered(t *testing.T) {
+	store := s3fstest.NewMemStore()
+	myFS := s3fs.NewFS(store, s3fs.WithEncryption(newFakeKMS(t), "alias/test"))
+
+	require.Nil(t, myFS.WriteFile("secret.txt", []byte(strings.Repeat("x", 5000))))
+
+	info, raw := rawObject(t, store, "secret.txt")
+	raw[len(raw)-20] ^= 1
+
+	_, err := store.Put(context.Background(), "secret.txt", bytes.NewReader(raw), s3fs.PutOptions{Metadata: info.Metadata})
+	require.Nil(t, err)
+
+	_, err = fs.ReadFile(myFS, "secret.txt")
+	require.Error(t, err)
+}
+
+func TestWithEncryption_NotEncrypted(t *testing.T) {
+	store := s3fstest.NewMemStore()
+	store.WriteFile("plain.txt", "plain")
+
+	myFS := s3fs.NewFS(store, s3fs.WithEncryption(newFakeKMS(t), "alias/test"))
+
+	_, err := myFS.Open("plain.txt")
+	require.True(t, errors.Is(err, s3fs.ErrNotEncrypted))
+}
+
+func TestWithEncryption_Chunked(t *testing.T) {
+	store := s3fstest.NewMemStore()
+	myFS := s3fs.NewFS(store, s3fs.WithEncryption(newFakeKMS(t), "alias/test"), s3fs.WithChunking(1000))
+
+	content := strings.Repeat("0123456789", 1000)
+	require.Nil(t, myFS.WriteFile("big.txt", []byte(content)))
+
+	data, err := fs.ReadFile(myFS, "big.txt")
+	require.Nil(t, err)
+	require.Equal(t, content, string(data))
+}
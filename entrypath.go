@@ -0,0 +1,57 @@
+package s3fs
+
+import "strings"
+
+// EntryPath is implemented by the fs.FileInfo and fs.DirEntry values of the FS, so
+// code walking it, e.g. with WalkParallel or fs.WalkDir, can tell where an entry is
+// without rebuilding its path from the names of its parents.
+type EntryPath interface {
+	// Path returns the name of the entry relative to the root of the FS, as Open
+	// takes it.
+	Path() string
+
+	// Key returns the S3 key of a file, or the prefix of the keys in a directory,
+	// ending with a slash. For FSes created with NewScopedS3FS it includes the
+	// scope's prefix, so it's the exact key in the bucket.
+	Key() string
+}
+
+func (fi *s3FileInfo) Path() string {
+	if fi.key == "" {
+		return "."
+	}
+
+	return strings.TrimSuffix(fi.key, "/")
+}
+
+func (fi *s3FileInfo) Key() string {
+	if fi.loc == nil {
+		return fi.key
+	}
+
+	return fi.loc.prefix + fi.key
+}
+
+// location is where the keys of an FS are: the bucket, if it's on S3, and the
+// prefix of its scope, if it was created with NewScopedS3FS or NewScopedFS.
+type location struct {
+	bucket string
+	prefix string
+}
+
+// newLocation returns the location of the keys of store, which the FS was created
+// with.
+func newLocation(store ObjectStore) *location {
+	loc := &location{}
+
+	if p, ok := store.(*prefixStore); ok {
+		loc.prefix = p.prefix
+		store = p.store
+	}
+
+	if s3, ok := store.(*s3Store); ok {
+		loc.bucket = s3.bucket
+	}
+
+	return loc
+}
@@ -0,0 +1,84 @@
+package s3fs_test
+
+import (
+	"context"
+	"io/fs"
+	"sync"
+	"testing"
+
+	"github.com/packrat386/s3fs"
+	"github.com/packrat386/s3fs/s3fstest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEntryPath(t *testing.T) {
+	store := s3fstest.NewMemStore()
+	store.WriteFile("a.txt", "a")
+	store.WriteFile("dir/b.txt", "b")
+	store.WriteFile("dir/sub/c.txt", "c")
+
+	for _, opts := range [][]s3fs.Option{nil, {s3fs.WithLazyListing()}, {s3fs.WithListingSpill(t.TempDir(), 1)}} {
+		myFS := s3fs.NewFS(store, opts...)
+
+		keys := map[string]string{}
+		err := fs.WalkDir(myFS, ".", func(name string, d fs.DirEntry, err error) error {
+			require.Nil(t, err)
+
+			if name == "." {
+				return nil
+			}
+
+			require.Equal(t, name, d.(s3fs.EntryPath).Path())
+			keys[name] = d.(s3fs.EntryPath).Key()
+
+			return nil
+		})
+		require.Nil(t, err)
+
+		require.Equal(t, map[string]string{
+			"a.txt":         "a.txt",
+			"dir":           "dir/",
+			"dir/b.txt":     "dir/b.txt",
+			"dir/sub":       "dir/sub/",
+			"dir/sub/c.txt": "dir/sub/c.txt",
+		}, keys)
+	}
+
+	myFS := s3fs.NewFS(store)
+
+	info, err := fs.Stat(myFS, ".")
+	require.Nil(t, err)
+	require.Equal(t, ".", info.(s3fs.EntryPath).Path())
+	require.Equal(t, "", info.(s3fs.EntryPath).Key())
+
+	mu := sync.Mutex{}
+	walked := map[string]string{}
+	err = myFS.WalkParallel(context.Background(), ".", 2, func(name string, info fs.FileInfo) error {
+		mu.Lock()
+		defer mu.Unlock()
+
+		walked[info.(s3fs.EntryPath).Path()] = info.(s3fs.EntryPath).Key()
+		return nil
+	})
+	require.Nil(t, err)
+	require.Equal(t, map[string]string{"a.txt": "a.txt", "dir/b.txt": "dir/b.txt", "dir/sub/c.txt": "dir/sub/c.txt"}, walked)
+}
+
+func TestEntryPath_Scoped(t *testing.T) {
+	store := s3fstest.NewMemStore()
+	store.WriteFile("tenants/a/dir/b.txt", "b")
+
+	myFS, err := s3fs.NewScopedFS(store, "tenants/a")
+	require.Nil(t, err)
+
+	entries, err := fs.ReadDir(myFS, ".")
+	require.Nil(t, err)
+	require.Len(t, entries, 1)
+	require.Equal(t, "dir", entries[0].(s3fs.EntryPath).Path())
+	require.Equal(t, "tenants/a/dir/", entries[0].(s3fs.EntryPath).Key())
+
+	info, err := fs.Stat(myFS, "dir/b.txt")
+	require.Nil(t, err)
+	require.Equal(t, "dir/b.txt", info.(s3fs.EntryPath).Path())
+	require.Equal(t, "tenants/a/dir/b.txt", info.(s3fs.EntryPath).Key())
+}
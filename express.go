@@ -0,0 +1,318 @@
+package s3fs
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/request"
+	v4 "github.com/aws/aws-sdk-go/aws/signer/v4"
+)
+
+// expressSessionRefresh is how long before a session expires that a new one is
+// created, so that no request is signed with a session that expires in flight.
+const expressSessionRefresh = time.Minute
+
+// isDirectoryBucket reports whether bucket is an S3 Express One Zone directory bucket,
+// which are named like "bucket--usw2-az1--x-s3".
+func isDirectoryBucket(bucket string) bool {
+	return strings.HasSuffix(bucket, "--x-s3")
+}
+
+// directoryBucket sends requests to an S3 Express One Zone directory bucket. Those are
+// authorized with short lived sessions from CreateSession, which this version of the
+// SDK knows nothing about, so it creates and refreshes the sessions itself and signs
+// requests with them. Directory buckets are served from the endpoint of their
+// availability zone rather than the regional one.
+type directoryBucket struct {
+	bucket string
+
+	mu       sync.Mutex
+	sessions map[*credentials.Credentials]*expressSession
+}
+
+// expressSession is a session created by CreateSession.
+type expressSession struct {
+	creds   *credentials.Credentials
+	token   string
+	expires time.Time
+}
+
+// newDirectoryBucket returns the directory bucket bucket names, or nil if it doesn't
+// name one.
+func newDirectoryBucket(bucket string) *directoryBucket {
+	if !isDirectoryBucket(bucket) {
+		return nil
+	}
+
+	return &directoryBucket{
+		bucket:   bucket,
+		sessions: map[*credentials.Credentials]*expressSession{},
+	}
+}
+
+// host returns the endpoint of the bucket's zone, like
+// "bucket--usw2-az1--x-s3.s3express-usw2-az1.us-west-2.amazonaws.com".
+func (d *directoryBucket) host(region string) string {
+	name := strings.TrimSuffix(d.bucket, "--x-s3")
+	zone := name[strings.LastIndex(name, "--")+2:]
+
+	return fmt.Sprintf("%s.s3express-%s.%s.amazonaws.com", d.bucket, zone, region)
+}
+
+// option routes a request to the bucket's zone and signs it with a session.
+func (d *directoryBucket) option(r *request.Request) {
+	r.Config.S3ForcePathStyle = aws.Bool(true)
+	r.Config.S3UseAccelerate = aws.Bool(false)
+
+	r.Handlers.Build.PushBack(func(r *request.Request) {
+		moveToHost(r, d.host(aws.StringValue(r.Config.Region)), d.bucket)
+	})
+	r.Handlers.Sign.Swap(v4.SignRequestHandler.Name, request.NamedHandler{
+		Name: "s3fs.ExpressSignHandler",
+		Fn:   d.sign,
+	})
+}
+
+// sign signs the request with a session for its credentials, creating one if there
+// is none or it's about to expire. Presigned requests can't outlive that session.
+func (d *directoryBucket) sign(r *request.Request) {
+	if r.Config.Credentials == credentials.AnonymousCredentials {
+		return
+	}
+
+	region := aws.StringValue(r.Config.Region)
+
+	session, err := d.session(r)
+	if err != nil {
+		r.Error = err
+		return
+	}
+
+	signer := v4.NewSigner(session.creds, func(s *v4.Signer) {
+		s.DisableURIPathEscaping = true
+		s.DisableRequestBodyOverwrite = true
+		s.UnsignedPayload = r.ExpireTime > 0
+	})
+
+	// the v4 signer only sets the payload hash header for the s3 signing name
+	if r.ExpireTime == 0 && r.HTTPRequest.Header.Get("X-Amz-Content-Sha256") == "" {
+		hash, err := hashPayload(r.GetBody(), false)
+		if err != nil {
+			r.Error = err
+			return
+		}
+
+		r.HTTPRequest.Header.Set("X-Amz-Content-Sha256", hash)
+	}
+
+	r.HTTPRequest.Header.Set("X-Amz-S3session-Token", session.token)
+
+	now := time.Now()
+	if r.ExpireTime > 0 {
+		_, err = signer.Presign(r.HTTPRequest, r.GetBody(), "s3express", region, r.ExpireTime, now)
+	} else {
+		_, err = signer.Sign(r.HTTPRequest, r.GetBody(), "s3express", region, now)
+	}
+
+	if err != nil {
+		r.Error = err
+		return
+	}
+
+	r.LastSignedAt = now
+}
+
+// session returns a session for the credentials of r that doesn't expire for at
+// least expressSessionRefresh.
+func (d *directoryBucket) session(r *request.Request) (*expressSession, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	creds := r.Config.Credentials
+	if s, ok := d.sessions[creds]; ok && time.Until(s.expires) > expressSessionRefresh {
+		return s, nil
+	}
+
+	s, err := d.createSession(r.Context(), r.Config)
+	if err != nil {
+		return nil, fmt.Errorf("could not create session for directory bucket: %w", err)
+	}
+
+	d.sessions[creds] = s
+
+	return s, nil
+}
+
+// createSessionResult is the response to CreateSession.
+type createSessionResult struct {
+	Credentials struct {
+		AccessKeyID     string    `xml:"AccessKeyId"`
+		SecretAccessKey string    `xml:"SecretAccessKey"`
+		SessionToken    string    `xml:"SessionToken"`
+		Expiration      time.Time `xml:"Expiration"`
+	} `xml:"Credentials"`
+}
+
+// s3ErrorResponse is the body of an S3 error response.
+type s3ErrorResponse struct {
+	Code      string `xml:"Code"`
+	Message   string `xml:"Message"`
+	RequestID string `xml:"RequestId"`
+}
+
+// createSession creates a read and write session for the bucket with the credentials
+// and HTTP client of cfg.
+func (d *directoryBucket) createSession(ctx context.Context, cfg aws.Config) (*expressSession, error) {
+	region := aws.StringValue(cfg.Region)
+
+	scheme := "https"
+	if aws.BoolValue(cfg.DisableSSL) {
+		scheme = "http"
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, scheme+"://"+d.host(region)+"/?session", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("X-Amz-Create-Session-Mode", "ReadWrite")
+	req.Header.Set("X-Amz-Content-Sha256", emptyPayloadHash)
+
+	signer := v4.NewSigner(cfg.Credentials, func(s *v4.Signer) {
+		s.DisableURIPathEscaping = true
+	})
+	if _, err := signer.Sign(req, nil, "s3express", region, time.Now()); err != nil {
+		return nil, err
+	}
+
+	httpClient := cfg.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		failure := s3ErrorResponse{}
+		xml.Unmarshal(body, &failure)
+
+		return nil, awserr.NewRequestFailure(awserr.New(failure.Code, failure.Message, nil), resp.StatusCode, failure.RequestID)
+	}
+
+	result := createSessionResult{}
+	if err := xml.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("invalid CreateSession response: %w", err)
+	}
+
+	return &expressSession{
+		creds:   credentials.NewStaticCredentials(result.Credentials.AccessKeyID, result.Credentials.SecretAccessKey, ""),
+		token:   result.Credentials.SessionToken,
+		expires: result.Credentials.Expiration,
+	}, nil
+}
+
+// emptyPayloadHash is the SHA-256 of an empty body.
+const emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// listDirectoryBucket lists a directory bucket the way List lists any other. Directory
+// buckets only list prefixes that end with a slash, don't support StartAfter, and list
+// keys in no particular order. So it lists the whole directory that prefix is in and
+// filters and sorts that before handing it to fn a page at a time, with the last name
+// on each page as the continuation token.
+func (s *s3Store) listDirectoryBucket(ctx context.Context, prefix string, opts ListOptions, fn func(*ListPage) bool) error {
+	dir := prefix[:strings.LastIndex(prefix, "/")+1]
+
+	after := opts.StartAfter
+	if opts.ContinuationToken > after {
+		after = opts.ContinuationToken
+	}
+
+	all := ListPage{}
+	err := s.listPages(ctx, dir, ListOptions{Delimiter: opts.Delimiter}, func(page *ListPage) bool {
+		for _, obj := range page.Objects {
+			if strings.HasPrefix(obj.Key, prefix) && obj.Key > after {
+				all.Objects = append(all.Objects, obj)
+			}
+		}
+
+		for _, cp := range page.CommonPrefixes {
+			if strings.HasPrefix(cp, prefix) && cp > after {
+				all.CommonPrefixes = append(all.CommonPrefixes, cp)
+			}
+		}
+
+		return true
+	})
+	if err != nil {
+		return err
+	}
+
+	sort.Slice(all.Objects, func(i, j int) bool { return all.Objects[i].Key < all.Objects[j].Key })
+	sort.Strings(all.CommonPrefixes)
+
+	pageSize := opts.MaxKeys
+	if pageSize <= 0 {
+		pageSize = 1000
+	}
+
+	for {
+		page := &ListPage{}
+		last := ""
+
+		for len(page.Objects)+len(page.CommonPrefixes) < pageSize && (len(all.Objects) > 0 || len(all.CommonPrefixes) > 0) {
+			if len(all.CommonPrefixes) == 0 || (len(all.Objects) > 0 && all.Objects[0].Key < all.CommonPrefixes[0]) {
+				last = all.Objects[0].Key
+				page.Objects = append(page.Objects, all.Objects[0])
+				all.Objects = all.Objects[1:]
+			} else {
+				last = all.CommonPrefixes[0]
+				page.CommonPrefixes = append(page.CommonPrefixes, all.CommonPrefixes[0])
+				all.CommonPrefixes = all.CommonPrefixes[1:]
+			}
+		}
+
+		more := len(all.Objects) > 0 || len(all.CommonPrefixes) > 0
+		if more {
+			page.NextContinuationToken = last
+		}
+
+		if !fn(page) || !more {
+			return nil
+		}
+	}
+}
+
+// moveToHost sends a request that was built for bucket in path style to host, with
+// the bucket taken out of its path.
+func moveToHost(r *request.Request, host, bucket string) {
+	u := r.HTTPRequest.URL
+	u.Host = host
+
+	prefix := "/" + bucket
+	u.Path = strings.TrimPrefix(u.Path, prefix)
+	u.RawPath = strings.TrimPrefix(u.RawPath, prefix)
+
+	if u.Path == "" {
+		u.Path = "/"
+		u.RawPath = ""
+	}
+}
@@ -0,0 +1,205 @@
+package s3fs
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testDirectoryBucket = "my-bucket--use1-az4--x-s3"
+
+// expressServer answers requests for a directory bucket holding files, giving out
+// sessions that expire after the durations in lifetimes, one at a time.
+type expressServer struct {
+	mu        sync.Mutex
+	files     map[string]string
+	lifetimes []time.Duration
+	sessions  int
+	requests  []*http.Request
+}
+
+func (e *expressServer) RoundTrip(r *http.Request) (*http.Response, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.requests = append(e.requests, r)
+
+	body := ""
+	switch {
+	case r.URL.Query().Has("session"):
+		lifetime := e.lifetimes[0]
+		if len(e.lifetimes) > 1 {
+			e.lifetimes = e.lifetimes[1:]
+		}
+
+		e.sessions++
+		body = fmt.Sprintf(
+			`<CreateSessionResult><Credentials><SessionToken>TOKEN-%d</SessionToken><SecretAccessKey>SESSIONSECRET</SecretAccessKey><AccessKeyId>SESSIONKEY</AccessKeyId><Expiration>%s</Expiration></Credentials></CreateSessionResult>`,
+			e.sessions,
+			time.Now().Add(lifetime).UTC().Format(time.RFC3339),
+		)
+	case r.URL.Query().Get("list-type") != "":
+		prefix := r.URL.Query().Get("prefix")
+		if prefix != "" && !strings.HasSuffix(prefix, "/") {
+			return e.respond(r, http.StatusBadRequest, `<Error><Code>InvalidRequest</Code></Error>`), nil
+		}
+
+		if r.URL.Query().Has("start-after") {
+			return e.respond(r, http.StatusBadRequest, `<Error><Code>InvalidRequest</Code></Error>`), nil
+		}
+
+		// directory buckets list in no particular order, so list backwards
+		contents := ""
+		prefixes := map[string]bool{}
+		for _, name := range []string{"dir/sub/c.txt", "dir/b.txt", "dir/a.txt", "top.txt"} {
+			if !strings.HasPrefix(name, prefix) {
+				continue
+			}
+
+			rest := strings.TrimPrefix(name, prefix)
+			if i := strings.Index(rest, "/"); i >= 0 && r.URL.Query().Get("delimiter") == "/" {
+				if !prefixes[rest[:i+1]] {
+					contents += "<CommonPrefixes><Prefix>" + prefix + rest[:i+1] + "</Prefix></CommonPrefixes>"
+				}
+				prefixes[rest[:i+1]] = true
+				continue
+			}
+
+			contents += fmt.Sprintf("<Contents><Key>%s</Key><Size>%d</Size></Contents>", name, len(e.files[name]))
+		}
+
+		body = "<ListBucketResult>" + contents + "</ListBucketResult>"
+	default:
+		data, ok := e.files[strings.TrimPrefix(r.URL.Path, "/")]
+		if !ok {
+			return e.respond(r, http.StatusNotFound, `<Error><Code>NoSuchKey</Code></Error>`), nil
+		}
+
+		body = data
+	}
+
+	return e.respond(r, http.StatusOK, body), nil
+}
+
+func (e *expressServer) respond(r *http.Request, status int, body string) *http.Response {
+	return &http.Response{
+		StatusCode: status,
+		Header:     http.Header{"Content-Length": []string{strconv.Itoa(len(body))}},
+		Body:       io.NopCloser(strings.NewReader(body)),
+		Request:    r,
+	}
+}
+
+func newExpressServer(lifetimes ...time.Duration) *expressServer {
+	return &expressServer{
+		files: map[string]string{
+			"dir/a.txt":     "a",
+			"dir/b.txt":     "b",
+			"dir/sub/c.txt": "c",
+			"top.txt":       "top",
+		},
+		lifetimes: lifetimes,
+	}
+}
+
+func TestDirectoryBucket(t *testing.T) {
+	server := newExpressServer(5 * time.Minute)
+	myFS := NewS3FS(newTestClient(server, ""), testDirectoryBucket)
+
+	data, err := fs.ReadFile(myFS, "dir/a.txt")
+	require.Nil(t, err)
+	require.Equal(t, "a", string(data))
+
+	entries, err := fs.ReadDir(myFS, "dir")
+	require.Nil(t, err)
+	require.Equal(t, []string{"a.txt", "b.txt", "sub"}, entryNames(entries))
+
+	require.Equal(t, 1, server.sessions)
+
+	session := server.requests[0]
+	require.Equal(t, "/", session.URL.Path)
+	require.True(t, strings.Contains(session.Header.Get("Authorization"), "Credential=AKID/"), session.Header.Get("Authorization"))
+	require.True(t, strings.Contains(session.Header.Get("Authorization"), "/us-east-1/s3express/aws4_request"))
+
+	for _, r := range server.requests[1:] {
+		require.Equal(t, "my-bucket--use1-az4--x-s3.s3express-use1-az4.us-east-1.amazonaws.com", r.URL.Host)
+		require.Equal(t, "TOKEN-1", r.Header.Get("X-Amz-S3session-Token"))
+		require.Empty(t, r.Header.Get("X-Amz-Security-Token"))
+		require.True(t, strings.Contains(r.Header.Get("Authorization"), "Credential=SESSIONKEY/"))
+		require.True(t, strings.Contains(r.Header.Get("Authorization"), "/us-east-1/s3express/aws4_request"))
+	}
+}
+
+func TestDirectoryBucket_RefreshesSessions(t *testing.T) {
+	// the first session expires too soon to use for long, so the second request gets
+	// a new one, which is good for the rest
+	server := newExpressServer(30*time.Second, 5*time.Minute)
+	myFS := NewS3FS(newTestClient(server, ""), testDirectoryBucket)
+
+	for i := 0; i < 3; i++ {
+		_, err := fs.ReadFile(myFS, "top.txt")
+		require.Nil(t, err)
+	}
+
+	tokens := []string{}
+	for _, r := range server.requests {
+		if token := r.Header.Get("X-Amz-S3session-Token"); token != "" {
+			tokens = append(tokens, token)
+		}
+	}
+
+	require.Equal(t, "TOKEN-1", tokens[0])
+	for _, token := range tokens[1:] {
+		require.Equal(t, "TOKEN-2", token)
+	}
+
+	require.Equal(t, 2, server.sessions)
+}
+
+func TestDirectoryBucket_ListsPages(t *testing.T) {
+	server := newExpressServer(5 * time.Minute)
+	store := newS3Store(newTestClient(server, ""), testDirectoryBucket)
+
+	pages := [][]string{}
+	token := ""
+	for {
+		names := []string{}
+		next := ""
+		err := store.List(context.Background(), "dir/", ListOptions{MaxKeys: 2, ContinuationToken: token}, func(page *ListPage) bool {
+			for _, obj := range page.Objects {
+				names = append(names, obj.Key)
+			}
+
+			next = page.NextContinuationToken
+			return false
+		})
+		require.Nil(t, err)
+
+		pages = append(pages, names)
+		if next == "" {
+			break
+		}
+
+		token = next
+	}
+
+	require.Equal(t, [][]string{{"dir/a.txt", "dir/b.txt"}, {"dir/sub/c.txt"}}, pages)
+}
+
+func entryNames(entries []fs.DirEntry) []string {
+	names := []string{}
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+
+	return names
+}
@@ -0,0 +1,75 @@
+package s3fs
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+)
+
+// StaleFile is implemented by the files opened by the FS. Type assert an fs.File to
+// it to see whether OpenWithFallback served it from the cache because the store
+// didn't respond in time.
+type StaleFile interface {
+	Stale() bool
+}
+
+// OpenWithFallback opens the file at name like OpenIf, with a single GET request, but
+// if the store doesn't respond before ctx's deadline, it serves the last version of
+// the file in the cache set with WithCache instead of failing. Files served that way
+// report true from Stale. This suits readers of configuration data who would rather
+// have a slightly old file than none at all.
+//
+// Any other error, and a deadline without a cached version, is returned as usual.
+func (s *S3FS) OpenWithFallback(ctx context.Context, name string) (fs.File, error) {
+	if s.bucketErr != nil {
+		return nil, s.bucketErr
+	}
+
+	if err := s.checkOpen(); err != nil {
+		return nil, err
+	}
+
+	key, err := s.key("open", name)
+	if err != nil {
+		return nil, err
+	}
+
+	if key == "" {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+
+	if s.cache == nil || s.offline {
+		return s.OpenIf(ctx, name, ReadConditions{})
+	}
+
+	s.auditRead(OpOpen, key)
+
+	if s.writeBack != nil {
+		if f, ok, err := s.writeBack.open(key); ok {
+			return s.limitSize(name, f, err)
+		}
+	}
+
+	f, err := openCached(ctx, s, key)
+	if err == nil || (ctx.Err() == nil && !errors.Is(err, context.DeadlineExceeded)) {
+		return s.limitSize(name, f, err)
+	}
+
+	entry, ok := s.cache.Get(key)
+	if !ok {
+		return nil, err
+	}
+
+	stale := newCachedFile(s, entry)
+	stale.stale = true
+
+	return s.limitSize(name, stale, nil)
+}
+
+func (f *cachedFile) Stale() bool {
+	return f.stale
+}
+
+func (f *s3File) Stale() bool {
+	return false
+}
@@ -0,0 +1,77 @@
+package s3fs_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/packrat386/s3fs"
+	"github.com/packrat386/s3fs/s3fstest"
+	"github.com/stretchr/testify/require"
+)
+
+// slowStore doesn't respond to Gets until ctx is done while slow is set.
+type slowStore struct {
+	*s3fstest.MemStore
+	slow bool
+}
+
+func (s *slowStore) Get(ctx context.Context, key string, opts s3fs.GetOptions) (*s3fs.Object, error) {
+	if s.slow {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+
+	return s.MemStore.Get(ctx, key, opts)
+}
+
+func TestOpenWithFallback(t *testing.T) {
+	store := &slowStore{MemStore: s3fstest.NewMemStore()}
+	store.WriteFile("config.json", `{"v":1}`)
+
+	myFS := s3fs.NewFS(store, s3fs.WithCache(s3fs.NewMemoryCache(1<<20), 0))
+
+	readFallback := func(name string) (string, bool, error) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+
+		f, err := myFS.OpenWithFallback(ctx, name)
+		if err != nil {
+			return "", false, err
+		}
+		defer f.Close()
+
+		data, err := io.ReadAll(f)
+		require.Nil(t, err)
+
+		return string(data), f.(s3fs.StaleFile).Stale(), nil
+	}
+
+	data, stale, err := readFallback("config.json")
+	require.Nil(t, err)
+	require.Equal(t, `{"v":1}`, data)
+	require.False(t, stale)
+
+	store.WriteFile("config.json", `{"v":2}`)
+	store.slow = true
+
+	data, stale, err = readFallback("config.json")
+	require.Nil(t, err)
+	require.Equal(t, `{"v":1}`, data)
+	require.True(t, stale)
+
+	// nothing cached to fall back to
+	store.WriteFile("other.json", `{}`)
+
+	_, _, err = readFallback("other.json")
+	require.True(t, errors.Is(err, context.DeadlineExceeded))
+
+	store.slow = false
+
+	data, stale, err = readFallback("config.json")
+	require.Nil(t, err)
+	require.Equal(t, `{"v":2}`, data)
+	require.False(t, stale)
+}
@@ -0,0 +1,157 @@
+package s3fs_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+
+	"github.com/packrat386/s3fs"
+	"github.com/packrat386/s3fs/s3fstest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewFS(t *testing.T) {
+	store := s3fstest.NewMemStore()
+	store.PageSize = 2
+
+	store.WriteFile("top.json", `{"data":"top"}`)
+	store.WriteFile("deep/down/top.json", `{"data":"liar"}`)
+	store.WriteFile("dir-a/one.json", `{"data":"one"}`)
+	store.WriteFile("dir-a/two.json", `{"data":"two"}`)
+	store.WriteFile("dir-a/three.json", `{"data":"three"}`)
+	store.WriteFile("dir-b/foo.json", `{"data":"foo"}`)
+
+	myFS := s3fs.NewFS(store)
+
+	if err := fstest.TestFS(myFS, "top.json", "deep/down/top.json", "dir-a/one.json", "dir-b/foo.json"); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := myFS.Open("dir-b/foo.json")
+	require.Nil(t, err)
+
+	out := map[string]string{}
+	err = json.NewDecoder(f).Decode(&out)
+	require.Nil(t, err)
+	require.Equal(t, "foo", out["data"])
+
+	entries, err := fs.ReadDir(myFS, "dir-a")
+	require.Nil(t, err)
+	require.Equal(t, 3, len(entries))
+}
+
+func TestNewFS_FileAndDir(t *testing.T) {
+	store := s3fstest.NewMemStore()
+	store.WriteFile("foo", `{"data":"foo"}`)
+	store.WriteFile("foo/bar", `{"data":"bar"}`)
+
+	_, err := s3fs.NewFS(store).Open("foo")
+	require.NotNil(t, err)
+	require.Contains(t, err.Error(), "directory name matches file name")
+}
+
+func TestNewFS_Progress(t *testing.T) {
+	store := s3fstest.NewMemStore()
+	store.WriteFile("big.txt", strings.Repeat("x", 10000))
+
+	var lastRead, lastTotal int64
+	calls := 0
+
+	myFS := s3fs.NewFS(store, s3fs.WithProgress(func(path string, readBytes, totalBytes int64) {
+		require.Equal(t, "big.txt", path)
+		require.True(t, readBytes > lastRead)
+		lastRead = readBytes
+		lastTotal = totalBytes
+		calls++
+	}))
+
+	data, err := fs.ReadFile(myFS, "big.txt")
+	require.Nil(t, err)
+	require.Equal(t, 10000, len(data))
+	require.Equal(t, int64(10000), lastRead)
+	require.Equal(t, int64(10000), lastTotal)
+	require.True(t, calls > 0)
+}
+
+func TestNewFS_DirectoryHandle(t *testing.T) {
+	store := s3fstest.NewMemStore()
+	for i := 0; i < 50; i++ {
+		store.WriteFile(fmt.Sprintf("dir/%02d.json", i), `{}`)
+	}
+
+	myFS := s3fs.NewFS(store)
+
+	f, err := myFS.Open("dir")
+	require.Nil(t, err)
+	defer f.Close()
+
+	dir := f.(fs.ReadDirFile)
+
+	// every entry is returned exactly once, however many goroutines are reading
+	mu := sync.Mutex{}
+	seen := map[string]int{}
+	wg := sync.WaitGroup{}
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for {
+				entries, err := dir.ReadDir(3)
+				if err == io.EOF {
+					return
+				}
+
+				mu.Lock()
+				for _, e := range entries {
+					seen[e.Name()]++
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	require.Equal(t, 50, len(seen))
+	for _, n := range seen {
+		require.Equal(t, 1, n)
+	}
+
+	// and it can be read again after rewinding
+	_, err = f.(io.Seeker).Seek(0, io.SeekStart)
+	require.Nil(t, err)
+
+	entries, err := dir.ReadDir(-1)
+	require.Nil(t, err)
+	require.Equal(t, 50, len(entries))
+
+	_, err = f.(io.Seeker).Seek(1, io.SeekStart)
+	require.NotNil(t, err)
+}
+
+func TestNewFS_EmptyRoot(t *testing.T) {
+	myFS := s3fs.NewFS(s3fstest.NewMemStore())
+
+	if err := fstest.TestFS(myFS); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := fs.Stat(myFS, ".")
+	require.Nil(t, err)
+	require.True(t, info.IsDir())
+	require.Equal(t, ".", info.Name())
+
+	entries, err := fs.ReadDir(myFS, ".")
+	require.Nil(t, err)
+	require.Equal(t, 0, len(entries))
+
+	// other directories still have to have something in them to exist
+	_, err = myFS.Open("missing")
+	require.True(t, errors.Is(err, fs.ErrNotExist))
+}
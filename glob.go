@@ -0,0 +1,144 @@
+package s3fs
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+// Glob returns the names of all files and directories matching pattern, with the
+// syntax of path.Match, making FS an fs.GlobFS. Directories are only listed as far as
+// the literal part of each pattern element allows, so a pattern like
+// "logs/2024-*/app.log" lists "logs/" with the prefix "logs/2024-" and nothing else.
+//
+// The result is sorted and has no duplicates, even when a name is both an object and
+// a directory, so tools that build from globbed inputs get them in the same order
+// every time.
+func (s *S3FS) Glob(pattern string) ([]string, error) {
+	if s.bucketErr != nil {
+		return nil, s.bucketErr
+	}
+
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, err
+	}
+
+	matches := map[string]bool{}
+	if err := s.glob(context.Background(), "", strings.Split(pattern, "/"), false, matches); err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(matches))
+	for name := range matches {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names, nil
+}
+
+// glob adds the names under the directory dir ("" for the root) that match the
+// pattern elements to matches. If extended is set, "**" elements match any number of
+// directories.
+func (s *S3FS) glob(ctx context.Context, dir string, elems []string, extended bool, matches map[string]bool) error {
+	elem, rest := elems[0], elems[1:]
+
+	if extended && elem == "**" {
+		return s.globRecursive(ctx, dir, elems, matches)
+	}
+
+	if !hasMeta(elem) {
+		name := path.Join(dir, elem)
+		if len(rest) > 0 {
+			return s.glob(ctx, name, rest, extended, matches)
+		}
+
+		return s.globLiteral(ctx, name, matches)
+	}
+
+	prefix := ""
+	if dir != "" {
+		prefix = dir + "/"
+	}
+
+	children := map[string]bool{}
+	err := s.store.List(ctx, prefix+literalPrefix(elem), ListOptions{Delimiter: "/"}, func(page *ListPage) bool {
+		if len(rest) == 0 {
+			for _, obj := range page.Objects {
+				if !strings.HasSuffix(obj.Key, "/") && !s.skipStorageClasses[obj.StorageClass] {
+					children[strings.TrimPrefix(obj.Key, prefix)] = true
+				}
+			}
+		}
+
+		for _, cp := range page.CommonPrefixes {
+			children[strings.TrimSuffix(strings.TrimPrefix(cp, prefix), "/")] = true
+		}
+
+		return true
+	})
+
+	if err != nil {
+		return fmt.Errorf("could not list s3 objects: %w", err)
+	}
+
+	for child := range children {
+		if ok, _ := path.Match(elem, child); !ok {
+			continue
+		}
+
+		if len(rest) == 0 {
+			matches[path.Join(dir, child)] = true
+			continue
+		}
+
+		if err := s.glob(ctx, path.Join(dir, child), rest, extended, matches); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// globLiteral adds name to matches if it exists.
+func (s *S3FS) globLiteral(ctx context.Context, name string, matches map[string]bool) error {
+	key, err := s.key("glob", name)
+	if err != nil {
+		// names that can't exist don't match
+		return nil
+	}
+
+	if key == "" {
+		matches["."] = true
+		return nil
+	}
+
+	fileMatch, dirMatch, err := s.lookup(ctx, key)
+	if err != nil {
+		return fmt.Errorf("could not list s3 objects: %w", err)
+	}
+
+	if fileMatch || dirMatch {
+		matches[name] = true
+	}
+
+	return nil
+}
+
+// hasMeta reports whether elem contains any of the special characters of path.Match.
+func hasMeta(elem string) bool {
+	return strings.ContainsAny(elem, `*?[\`)
+}
+
+// literalPrefix returns the part of the pattern elem before its first special
+// character, which every match must start with.
+func literalPrefix(elem string) string {
+	if i := strings.IndexAny(elem, `*?[\`); i >= 0 {
+		return elem[:i]
+	}
+
+	return elem
+}
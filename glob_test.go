@@ -0,0 +1,115 @@
+package s3fs_test
+
+import (
+	"io/fs"
+	"path"
+	"testing"
+
+	"github.com/packrat386/s3fs"
+	"github.com/packrat386/s3fs/s3fstest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGlob(t *testing.T) {
+	store := s3fstest.NewMemStore()
+	store.WriteFile("logs/2023-12/app.log", "")
+	store.WriteFile("logs/2024-01/app.log", "")
+	store.WriteFile("logs/2024-01/db.log", "")
+	store.WriteFile("logs/2024-02/app.log", "")
+	store.WriteFile("readme.md", "")
+	store.WriteFile("notes.md", "")
+
+	myFS := s3fs.NewFS(store)
+
+	for pattern, want := range map[string][]string{
+		"logs/2024-*/app.log": {"logs/2024-01/app.log", "logs/2024-02/app.log"},
+		"logs/*/*.log":        {"logs/2023-12/app.log", "logs/2024-01/app.log", "logs/2024-01/db.log", "logs/2024-02/app.log"},
+		"*.md":                {"notes.md", "readme.md"},
+		"*":                   {"logs", "notes.md", "readme.md"},
+		"logs/202[34]-1?":     {"logs/2023-12"},
+		"logs/2024-01":        {"logs/2024-01"},
+		"readme.md":           {"readme.md"},
+		".":                   {"."},
+		"missing/*":           {},
+		"missing.md":          {},
+	} {
+		got, err := myFS.Glob(pattern)
+		require.Nil(t, err, pattern)
+		require.Equal(t, want, got, pattern)
+
+		// fs.Glob uses the GlobFS implementation, and agrees with the generic one
+		generic, err := fs.Glob(readDirOnly{myFS}, pattern)
+		require.Nil(t, err, pattern)
+		if len(generic) == 0 {
+			generic = []string{}
+		}
+		require.Equal(t, generic, got, pattern)
+	}
+
+	_, err := myFS.Glob("[")
+	require.Equal(t, path.ErrBadPattern, err)
+}
+
+func TestGlob_Deterministic(t *testing.T) {
+	store := s3fstest.NewMemStore()
+	store.PageSize = 1
+	store.WriteFile("b", "file")
+	store.WriteFile("b/c", "file in directory of the same name")
+	store.WriteFile("a", "")
+	store.WriteFile("c/d", "")
+	store.WriteFile("d/", "")
+
+	myFS := s3fs.NewFS(store)
+
+	for i := 0; i < 10; i++ {
+		got, err := myFS.Glob("*")
+		require.Nil(t, err)
+		require.Equal(t, []string{"a", "b", "c", "d"}, got)
+	}
+}
+
+// readDirOnly hides every method of an FS but Open, so fs.Glob has to use ReadDir.
+type readDirOnly struct {
+	fsys fs.FS
+}
+
+func (r readDirOnly) Open(name string) (fs.File, error) {
+	return r.fsys.Open(name)
+}
+
+func TestGlobEx(t *testing.T) {
+	store := s3fstest.NewMemStore()
+	store.WriteFile("assets/a.png", "")
+	store.WriteFile("assets/b.svg", "")
+	store.WriteFile("assets/x/c.png", "")
+	store.WriteFile("assets/x/y/d.png", "")
+	store.WriteFile("assets/x/y/e.gif", "")
+	store.WriteFile("img/icons/i.png", "")
+	store.WriteFile("img/logos/l.svg", "")
+	store.WriteFile("img/other/o.png", "")
+	store.WriteFile("docs/", "")
+
+	myFS := s3fs.NewFS(store)
+
+	for pattern, want := range map[string][]string{
+		"assets/**/*.png":                  {"assets/a.png", "assets/x/c.png", "assets/x/y/d.png"},
+		"**/*.svg":                         {"assets/b.svg", "img/logos/l.svg"},
+		"assets/**":                        {"assets/a.png", "assets/b.svg", "assets/x", "assets/x/c.png", "assets/x/y", "assets/x/y/d.png", "assets/x/y/e.gif"},
+		"**/y":                             {"assets/x/y"},
+		"img/{icons,logos}/*.{png,svg}":    {"img/icons/i.png", "img/logos/l.svg"},
+		"{assets,img/{icons,other}}/*.png": {"assets/a.png", "img/icons/i.png", "img/other/o.png"},
+		"assets/[!a]*":                     {"assets/b.svg", "assets/x"},
+		"assets/[^a]*":                     {"assets/b.svg", "assets/x"},
+		"*":                                {"assets", "docs", "img"},
+		"assets/a.png":                     {"assets/a.png"},
+	} {
+		got, err := myFS.GlobEx(pattern)
+		require.Nil(t, err, pattern)
+		require.Equal(t, want, got, pattern)
+	}
+
+	for _, pattern := range []string{"{a,b", "a}", "[", "**/["} {
+		_, err := myFS.GlobEx(pattern)
+		require.Equal(t, path.ErrBadPattern, err, pattern)
+	}
+}
@@ -0,0 +1,209 @@
+package s3fs
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+// GlobEx is Glob with the extensions common in build tools and asset configs:
+//
+//   - "**" as a whole path element matches any number of directories, including
+//     none, so "assets/**/*.png" matches "assets/a.png" and "assets/x/y/b.png"
+//   - "{a,b}" matches either alternative, and may be nested, so
+//     "img/{icons,logos}/*.{png,svg}" is four patterns in one
+//   - "[!a-z]" is accepted as a negated character class, as well as "[^a-z]"
+//
+// Like Glob, it lists only as far as the literal parts of the pattern allow. Each
+// "**" costs one flat listing of the directory it appears in, which needs a request
+// per 1000 objects under it no matter how deep they are. The result is sorted and
+// has no duplicates.
+func (s *S3FS) GlobEx(pattern string) ([]string, error) {
+	if s.bucketErr != nil {
+		return nil, s.bucketErr
+	}
+
+	patterns, err := expandBraces(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	matches := map[string]bool{}
+	for _, p := range patterns {
+		p = strings.ReplaceAll(p, "[!", "[^")
+
+		elems := strings.Split(p, "/")
+		for _, elem := range elems {
+			if _, err := path.Match(elem, ""); err != nil {
+				return nil, err
+			}
+		}
+
+		if err := s.glob(context.Background(), "", elems, true, matches); err != nil {
+			return nil, err
+		}
+	}
+
+	names := make([]string, 0, len(matches))
+	for name := range matches {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names, nil
+}
+
+// globRecursive adds the names under dir matching elems, which start with "**", to
+// matches. It lists everything under dir at once and matches the relative paths of
+// the files, and of the directories they imply, in memory.
+func (s *S3FS) globRecursive(ctx context.Context, dir string, elems []string, matches map[string]bool) error {
+	prefix := ""
+	if dir != "" {
+		prefix = dir + "/"
+	}
+
+	names := map[string]bool{}
+	err := s.store.List(ctx, prefix, ListOptions{}, func(page *ListPage) bool {
+		for _, obj := range page.Objects {
+			rel := strings.TrimPrefix(obj.Key, prefix)
+			marker := strings.HasSuffix(rel, "/")
+			rel = strings.TrimSuffix(rel, "/")
+
+			if rel == "" {
+				continue
+			}
+
+			if !marker && !s.skipStorageClasses[obj.StorageClass] {
+				names[rel] = true
+			}
+
+			// and every directory it's in
+			for d := path.Dir(rel); d != "."; d = path.Dir(d) {
+				names[d] = true
+			}
+
+			if marker {
+				names[rel] = true
+			}
+		}
+
+		return true
+	})
+
+	if err != nil {
+		return fmt.Errorf("could not list s3 objects: %w", err)
+	}
+
+	for name := range names {
+		if matchElems(elems, strings.Split(name, "/")) {
+			matches[path.Join(dir, name)] = true
+		}
+	}
+
+	return nil
+}
+
+// matchElems reports whether the path elements segs match the pattern elements
+// elems, where a "**" element matches any number of path elements.
+func matchElems(elems, segs []string) bool {
+	if len(elems) == 0 {
+		return len(segs) == 0
+	}
+
+	if elems[0] == "**" {
+		for i := 0; i <= len(segs); i++ {
+			if matchElems(elems[1:], segs[i:]) {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	if len(segs) == 0 {
+		return false
+	}
+
+	if ok, _ := path.Match(elems[0], segs[0]); !ok {
+		return false
+	}
+
+	return matchElems(elems[1:], segs[1:])
+}
+
+// expandBraces expands the "{a,b}" alternatives in pattern into the patterns they
+// stand for. Backslash escaped braces and commas are left alone.
+func expandBraces(pattern string) ([]string, error) {
+	open := -1
+	depth := 0
+
+	for i := 0; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '\\':
+			i++
+		case '{':
+			if depth == 0 {
+				open = i
+			}
+			depth++
+		case '}':
+			depth--
+			if depth < 0 {
+				return nil, path.ErrBadPattern
+			}
+
+			if depth > 0 {
+				continue
+			}
+
+			head, tail := pattern[:open], pattern[i+1:]
+
+			expanded := []string{}
+			for _, alt := range splitAlternatives(pattern[open+1 : i]) {
+				more, err := expandBraces(head + alt + tail)
+				if err != nil {
+					return nil, err
+				}
+
+				expanded = append(expanded, more...)
+			}
+
+			return expanded, nil
+		}
+	}
+
+	if depth != 0 {
+		return nil, path.ErrBadPattern
+	}
+
+	return []string{pattern}, nil
+}
+
+// splitAlternatives splits the inside of a brace group at the commas that aren't
+// escaped or inside a nested group.
+func splitAlternatives(group string) []string {
+	alts := []string{}
+	depth := 0
+	start := 0
+
+	for i := 0; i < len(group); i++ {
+		switch group[i] {
+		case '\\':
+			i++
+		case '{':
+			depth++
+		case '}':
+			depth--
+		case ',':
+			if depth == 0 {
+				alts = append(alts, group[start:i])
+				start = i + 1
+			}
+		}
+	}
+
+	return append(alts, group[start:])
+}
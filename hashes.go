@@ -0,0 +1,73 @@
+package s3fs
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+)
+
+// sha256Metadata is the metadata key holding the hex SHA-256 of a file's content.
+const sha256Metadata = "sha256"
+
+// WithContentHashes makes the FS compute the SHA-256 of files as they are written and
+// store it in their "sha256" metadata (x-amz-meta-sha256), where ObjectInfo.SHA256
+// reads it back, so consumers can verify content without hashing it again. The hash is
+// of the content as written, before any compression or encryption.
+//
+// The metadata has to be sent before the content, so only content that can be hashed
+// up front gets a hash: WriteFile, WriteFileIf, files written with Create that are
+// small enough to be buffered, and UploadFrom from an io.ReadSeeker such as an
+// *os.File. Files streamed through Create, appended to or written back from
+// WithWriteBack's staging directory don't.
+func WithContentHashes() Option {
+	return func(s *S3FS) {
+		s.contentHashes = true
+	}
+}
+
+// SHA256 returns the hex SHA-256 of the object's content stored by WithContentHashes,
+// or "" if it doesn't have one. Listings from S3 don't include metadata, so it's only
+// set for ObjectInfos from Stat and opened files.
+func (o ObjectInfo) SHA256() string {
+	sum, _ := metadataValue(o.Metadata, sha256Metadata)
+
+	return sum
+}
+
+// hashContent adds the SHA-256 of body to the metadata in opts if body can be read
+// twice, rewinding it afterwards.
+func hashContent(body io.Reader, opts PutOptions) (PutOptions, error) {
+	if _, ok := metadataValue(opts.Metadata, sha256Metadata); ok {
+		return opts, nil
+	}
+
+	rs, ok := body.(io.ReadSeeker)
+	if !ok {
+		return opts, nil
+	}
+
+	start, err := rs.Seek(0, io.SeekCurrent)
+	if err != nil {
+		// not really seekable, e.g. a pipe
+		return opts, nil
+	}
+
+	h := sha256.New()
+	if _, err := io.Copy(h, rs); err != nil {
+		return opts, fmt.Errorf("could not hash content: %w", err)
+	}
+
+	if _, err := rs.Seek(start, io.SeekStart); err != nil {
+		return opts, fmt.Errorf("could not rewind content after hashing it: %w", err)
+	}
+
+	metadata := map[string]string{}
+	for k, v := range opts.Metadata {
+		metadata[k] = v
+	}
+	metadata[sha256Metadata] = hex.EncodeToString(h.Sum(nil))
+	opts.Metadata = metadata
+
+	return opts, nil
+}
@@ -0,0 +1,64 @@
+package s3fs_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/packrat386/s3fs"
+	"github.com/packrat386/s3fs/s3fstest"
+	"github.com/stretchr/testify/require"
+)
+
+func sha256Hex(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestWithContentHashes(t *testing.T) {
+	store := s3fstest.NewMemStore()
+	myFS := s3fs.NewFS(store, s3fs.WithContentHashes())
+
+	require.Nil(t, myFS.WriteFile("file.txt", []byte("hello")))
+
+	stat, err := fs.Stat(myFS, "file.txt")
+	require.Nil(t, err)
+	require.Equal(t, sha256Hex("hello"), stat.Sys().(s3fs.ObjectInfo).SHA256())
+
+	path := filepath.Join(t.TempDir(), "upload.txt")
+	require.Nil(t, os.WriteFile(path, []byte("from disk"), 0600))
+
+	f, err := os.Open(path)
+	require.Nil(t, err)
+	defer f.Close()
+
+	require.Nil(t, myFS.UploadFrom(context.Background(), "uploaded.txt", f, 9))
+
+	info, data := rawObject(t, store, "uploaded.txt")
+	require.Equal(t, "from disk", string(data))
+	require.Equal(t, sha256Hex("from disk"), info.SHA256())
+
+	// a plain reader can't be hashed before it's sent
+	require.Nil(t, myFS.UploadFrom(context.Background(), "streamed.txt", io.MultiReader(strings.NewReader("streamed")), 8))
+
+	info, _ = rawObject(t, store, "streamed.txt")
+	require.Equal(t, "", info.SHA256())
+}
+
+func TestWithContentHashes_Compressed(t *testing.T) {
+	store := s3fstest.NewMemStore()
+	myFS := s3fs.NewFS(store, s3fs.WithContentHashes(), s3fs.WithWriteCompression(s3fs.Gzip))
+
+	content := strings.Repeat("hash me ", 100)
+	require.Nil(t, myFS.WriteFile("file.txt", []byte(content)))
+
+	stat, err := fs.Stat(myFS, "file.txt")
+	require.Nil(t, err)
+	require.Equal(t, sha256Hex(content), stat.Sys().(s3fs.ObjectInfo).SHA256())
+}
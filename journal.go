@@ -0,0 +1,116 @@
+package s3fs
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// JournalRecord describes a change made through the FS, as written to its journal.
+type JournalRecord struct {
+	Time time.Time `json:"time"`
+
+	// Actor is who made the change, as given to WithJournal or WithBucketJournal.
+	Actor string `json:"actor"`
+
+	// Op is "put", "append", "copy", or "delete".
+	Op string `json:"op"`
+
+	Name string `json:"name"`
+
+	// Source is the name that was copied, for copies.
+	Source string `json:"source,omitempty"`
+
+	// ETag is the ETag of the written object, if it is known.
+	ETag string `json:"etag,omitempty"`
+}
+
+// WithJournal writes a JournalRecord, as a line of JSON, to w every time a file is
+// written, copied, or removed through the FS, giving a cheap audit trail of its
+// changes. actor is recorded as who made the changes; if it is empty the hostname and
+// pid of the process are used. Records are written after the change has been made,
+// and failures to write them are logged rather than failing the change.
+func WithJournal(w io.Writer, actor string) Option {
+	return func(s *S3FS) {
+		s.journal = newJournal(actor, func(data []byte) error {
+			_, err := w.Write(data)
+			return err
+		})
+	}
+}
+
+// WithBucketJournal is like WithJournal, but stores each record in the bucket, as its
+// own object under prefix. Keys start with the time of the change, so listing the
+// prefix gives the records in order.
+func WithBucketJournal(prefix string, actor string) Option {
+	return func(s *S3FS) {
+		if prefix != "" && !strings.HasSuffix(prefix, "/") {
+			prefix += "/"
+		}
+
+		store := s.store
+		s.journal = newJournal(actor, func(data []byte) error {
+			suffix := make([]byte, 4)
+			rand.Read(suffix)
+
+			key := prefix + time.Now().UTC().Format("2006/01/02/150405.000000000") + "-" + hex.EncodeToString(suffix) + ".json"
+			_, err := store.Put(context.Background(), key, bytes.NewReader(data), PutOptions{ContentType: "application/json"})
+
+			return err
+		})
+	}
+}
+
+// journal writes JournalRecords. A nil journal doesn't write anything.
+type journal struct {
+	actor string
+
+	mu    sync.Mutex
+	write func(data []byte) error
+}
+
+func newJournal(actor string, write func([]byte) error) *journal {
+	if actor == "" {
+		host, _ := os.Hostname()
+		actor = fmt.Sprintf("%s:%d", host, os.Getpid())
+	}
+
+	return &journal{actor: actor, write: write}
+}
+
+// record writes a record of op on name.
+func (j *journal) record(op, name, source, etag string) {
+	if j == nil {
+		return
+	}
+
+	data, err := json.Marshal(JournalRecord{
+		Time:   time.Now().UTC(),
+		Actor:  j.actor,
+		Op:     op,
+		Name:   name,
+		Source: source,
+		ETag:   etag,
+	})
+
+	if err != nil {
+		log.Printf("s3fs: could not encode journal record for %s of %s: %s", op, name, err)
+		return
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if err := j.write(append(data, '\n')); err != nil {
+		log.Printf("s3fs: could not journal %s of %s: %s", op, name, err)
+	}
+}
@@ -0,0 +1,91 @@
+package s3fs_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/fs"
+	"strings"
+	"testing"
+
+	"github.com/packrat386/s3fs"
+	"github.com/packrat386/s3fs/s3fstest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithJournal(t *testing.T) {
+	buf := &bytes.Buffer{}
+	store := s3fstest.NewMemStore()
+	myFS := s3fs.NewFS(store, s3fs.WithJournal(buf, "alice"))
+
+	require.Nil(t, myFS.WriteFile("a.txt", []byte("a")))
+	require.Nil(t, myFS.Copy("a.txt", "b.txt"))
+
+	w, err := myFS.Append("b.txt")
+	require.Nil(t, err)
+	w.Write([]byte("b"))
+	require.Nil(t, w.Close())
+
+	require.Nil(t, myFS.Remove("a.txt"))
+
+	records := []s3fs.JournalRecord{}
+	scanner := bufio.NewScanner(buf)
+	for scanner.Scan() {
+		r := s3fs.JournalRecord{}
+		require.Nil(t, json.Unmarshal(scanner.Bytes(), &r))
+		require.Equal(t, "alice", r.Actor)
+		require.False(t, r.Time.IsZero())
+
+		records = append(records, r)
+	}
+
+	info, err := store.Head(context.Background(), "b.txt")
+	require.Nil(t, err)
+
+	require.Len(t, records, 4)
+	require.Equal(t, "put", records[0].Op)
+	require.Equal(t, "a.txt", records[0].Name)
+	require.NotEmpty(t, records[0].ETag)
+	require.Equal(t, s3fs.JournalRecord{Time: records[1].Time, Actor: "alice", Op: "copy", Name: "b.txt", Source: "a.txt"}, records[1])
+	require.Equal(t, s3fs.JournalRecord{Time: records[2].Time, Actor: "alice", Op: "append", Name: "b.txt", ETag: info.ETag}, records[2])
+	require.Equal(t, s3fs.JournalRecord{Time: records[3].Time, Actor: "alice", Op: "delete", Name: "a.txt"}, records[3])
+}
+
+func TestWithBucketJournal(t *testing.T) {
+	store := s3fstest.NewMemStore()
+	myFS := s3fs.NewFS(store, s3fs.WithBucketJournal("audit", ""))
+
+	require.Nil(t, myFS.WriteFile("a.txt", []byte("a")))
+	require.Nil(t, myFS.WriteFileIf("a.txt", []byte("b"), etagOf(t, store, "a.txt")))
+	require.Nil(t, myFS.Remove("a.txt"))
+
+	ops := []string{}
+	err := fs.WalkDir(myFS, "audit", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+
+		require.True(t, strings.HasSuffix(name, ".json"))
+
+		data, err := fs.ReadFile(myFS, name)
+		require.Nil(t, err)
+
+		r := s3fs.JournalRecord{}
+		require.Nil(t, json.Unmarshal(data, &r))
+		require.Contains(t, r.Actor, ":")
+		ops = append(ops, r.Op+" "+r.Name)
+
+		return nil
+	})
+
+	require.Nil(t, err)
+	require.Equal(t, []string{"put a.txt", "put a.txt", "delete a.txt"}, ops)
+}
+
+func etagOf(t *testing.T, store s3fs.ObjectStore, key string) string {
+	info, err := store.Head(context.Background(), key)
+	require.Nil(t, err)
+
+	return info.ETag
+}
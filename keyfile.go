@@ -0,0 +1,41 @@
+package s3fs
+
+// KeyFile is implemented by the files and directories the FS opens, for handing the
+// object to other AWS services, like Athena queries or Lambda triggers, without
+// guessing how names map to keys.
+type KeyFile interface {
+	// Key returns the bucket and the exact key of the file, or the prefix of the keys
+	// in a directory, ending with a slash. The key includes the prefix of FSes created
+	// with NewScopedS3FS. The bucket is as the FS was created with, which may be an
+	// access point, and is empty for stores other than S3.
+	Key() (bucket, key string)
+}
+
+// bucketKey returns the bucket and exact key of fi.
+func (fi *s3FileInfo) bucketKey() (string, string) {
+	if fi.loc == nil {
+		return "", fi.key
+	}
+
+	return fi.loc.bucket, fi.Key()
+}
+
+func (f *s3File) Key() (bucket, key string) {
+	return f.fileInfo.bucketKey()
+}
+
+func (d *s3Directory) Key() (bucket, key string) {
+	return d.fileInfo.bucketKey()
+}
+
+func (d *lazyDir) Key() (bucket, key string) {
+	return d.fileInfo.bucketKey()
+}
+
+func (f *cachedFile) Key() (bucket, key string) {
+	return f.fileInfo.bucketKey()
+}
+
+func (f *columnarFile) Key() (bucket, key string) {
+	return f.fileInfo.bucketKey()
+}
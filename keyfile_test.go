@@ -0,0 +1,41 @@
+package s3fs_test
+
+import (
+	"testing"
+
+	"github.com/packrat386/s3fs"
+	"github.com/packrat386/s3fs/s3fstest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyFile(t *testing.T) {
+	store := s3fstest.NewMemStore()
+	store.WriteFile("tenants/a/logs/app.log", "log")
+
+	scoped, err := s3fs.NewScopedFS(store, "tenants/a")
+	require.Nil(t, err)
+
+	for _, tc := range []struct {
+		fsys  *s3fs.S3FS
+		name  string
+		key   string
+		isDir bool
+	}{
+		{s3fs.NewFS(store), "tenants/a/logs/app.log", "tenants/a/logs/app.log", false},
+		{s3fs.NewFS(store), "tenants/a/logs", "tenants/a/logs/", true},
+		{s3fs.NewFS(store), ".", "", true},
+		{s3fs.NewFS(store, s3fs.WithLazyListing()), "tenants", "tenants/", true},
+		{s3fs.NewFS(store, s3fs.WithCache(s3fs.NewMemoryCache(1<<20), 0)), "tenants/a/logs/app.log", "tenants/a/logs/app.log", false},
+		{scoped, "logs/app.log", "tenants/a/logs/app.log", false},
+		{scoped, "logs", "tenants/a/logs/", true},
+	} {
+		f, err := tc.fsys.Open(tc.name)
+		require.Nil(t, err, tc.name)
+
+		bucket, key := f.(s3fs.KeyFile).Key()
+		require.Equal(t, "", bucket)
+		require.Equal(t, tc.key, key, tc.name)
+
+		require.Nil(t, f.Close())
+	}
+}
@@ -0,0 +1,110 @@
+// Package kv is a key-value view of an s3fs.S3FS, for code that just stores and
+// fetches small objects and has no use for fs.File handles.
+//
+// Keys are paths in the FS, so they follow the same rules as names passed to it: they
+// are slash separated, with no leading slash and no "." or ".." elements. Values are
+// read and written whole, so they should be small enough to hold in memory.
+package kv
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+
+	"github.com/packrat386/s3fs"
+)
+
+// Store gets and puts values in an FS.
+type Store struct {
+	fsys *s3fs.S3FS
+}
+
+// NewStore returns a Store keeping its values in fsys. Everything the FS was created
+// with, such as a root prefix, encryption or a cache, applies to them.
+func NewStore(fsys *s3fs.S3FS) *Store {
+	return &Store{fsys: fsys}
+}
+
+// Get returns the value of key. If there is none it returns an error wrapping
+// fs.ErrNotExist.
+func (s *Store) Get(ctx context.Context, key string) ([]byte, error) {
+	f, err := s.fsys.OpenIf(ctx, key, s3fs.ReadConditions{})
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	value, err := io.ReadAll(f)
+	if err != nil {
+		return nil, fmt.Errorf("could not read %s: %w", key, err)
+	}
+
+	return value, nil
+}
+
+// Put sets the value of key, replacing any value it had.
+func (s *Store) Put(ctx context.Context, key string, value []byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return s.fsys.WriteFile(key, value)
+}
+
+// Delete removes key. Deleting a key that has no value is not an error.
+func (s *Store) Delete(ctx context.Context, key string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return s.fsys.Remove(key)
+}
+
+// ListPrefix returns the keys that start with prefix, in lexical order. prefix
+// doesn't have to end at a slash: "users/a" matches both "users/alice" and
+// "users/a/profile". Only the directories that can hold matching keys are listed.
+func (s *Store) ListPrefix(ctx context.Context, prefix string) ([]string, error) {
+	root := path.Dir(prefix)
+	if strings.HasSuffix(prefix, "/") {
+		root = strings.TrimSuffix(prefix, "/")
+	}
+
+	keys := []string{}
+	err := fs.WalkDir(s.fsys, root, func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if d.IsDir() {
+			if name == root || strings.HasPrefix(name+"/", prefix) || strings.HasPrefix(prefix, name+"/") {
+				return nil
+			}
+
+			return fs.SkipDir
+		}
+
+		if strings.HasPrefix(name, prefix) {
+			keys = append(keys, name)
+		}
+
+		return nil
+	})
+
+	if errors.Is(err, fs.ErrNotExist) {
+		return keys, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("could not list %s: %w", prefix, err)
+	}
+
+	return keys, nil
+}
@@ -0,0 +1,71 @@
+package kv
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"testing"
+
+	"github.com/packrat386/s3fs"
+	"github.com/packrat386/s3fs/s3fstest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore(t *testing.T) {
+	ctx := context.Background()
+	store := NewStore(s3fs.NewFS(s3fstest.NewMemStore()))
+
+	_, err := store.Get(ctx, "users/alice")
+	require.True(t, errors.Is(err, fs.ErrNotExist))
+
+	require.Nil(t, store.Put(ctx, "users/alice", []byte(`{"age":30}`)))
+
+	value, err := store.Get(ctx, "users/alice")
+	require.Nil(t, err)
+	require.Equal(t, `{"age":30}`, string(value))
+
+	require.Nil(t, store.Delete(ctx, "users/alice"))
+	require.Nil(t, store.Delete(ctx, "users/alice"))
+
+	_, err = store.Get(ctx, "users/alice")
+	require.True(t, errors.Is(err, fs.ErrNotExist))
+}
+
+func TestStore_PutCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	mem := s3fstest.NewMemStore()
+	store := NewStore(s3fs.NewFS(mem))
+
+	require.True(t, errors.Is(store.Put(ctx, "users/alice", []byte(`{"age":30}`)), context.Canceled))
+
+	_, err := mem.Head(context.Background(), "users/alice")
+	require.True(t, errors.Is(err, fs.ErrNotExist))
+}
+
+func TestStore_ListPrefix(t *testing.T) {
+	ctx := context.Background()
+	mem := s3fstest.NewMemStore()
+	for _, key := range []string{"users/alice", "users/al/profile", "users/bob", "groups/admins", "top"} {
+		mem.WriteFile(key, "x")
+	}
+
+	store := NewStore(s3fs.NewFS(mem))
+
+	keys, err := store.ListPrefix(ctx, "users/al")
+	require.Nil(t, err)
+	require.Equal(t, []string{"users/al/profile", "users/alice"}, keys)
+
+	keys, err = store.ListPrefix(ctx, "users/")
+	require.Nil(t, err)
+	require.Equal(t, []string{"users/al/profile", "users/alice", "users/bob"}, keys)
+
+	keys, err = store.ListPrefix(ctx, "")
+	require.Nil(t, err)
+	require.Len(t, keys, 5)
+
+	keys, err = store.ListPrefix(ctx, "nothing/here")
+	require.Nil(t, err)
+	require.Empty(t, keys)
+}
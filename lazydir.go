@@ -0,0 +1,325 @@
+package s3fs
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// WithLazyListing makes directories list their entries as they are read instead of
+// all at once when they are opened. Opening a directory fetches its first page of
+// entries, and each ReadDir(n) only fetches as many more pages as it needs, carrying
+// on from where the last call left off, so reading the start of a huge directory
+// doesn't list all of it. ReadDir(-1) and fs.ReadDir still list everything.
+// WithListingSpill has no effect on lazily listed directories, since they never hold
+// more than a page or so of entries.
+func WithLazyListing() Option {
+	return func(s *S3FS) {
+		s.lazyListing = true
+	}
+}
+
+// lazyDir is a directory listed one page at a time as it is read.
+type lazyDir struct {
+	s        *S3FS
+	prefix   string
+	fileInfo s3FileInfo
+
+	mu      sync.Mutex
+	pending []lazyEntry
+	token   string
+	done    bool
+
+	// conflicts drops pending entries hidden by the ConflictPolicy
+	conflicts *dirConflicts
+}
+
+// lazyEntry is a listed entry that hasn't been read yet, with where it was listed.
+type lazyEntry struct {
+	entry fs.DirEntry
+
+	// token is the continuation token of the page the entry was on, and index its
+	// position on that page
+	token string
+	index int
+}
+
+func openLazyDir(s *S3FS, name string) (fs.File, error) {
+	d := &lazyDir{
+		s:         s,
+		prefix:    name,
+		conflicts: s.newDirConflicts(),
+		fileInfo: s3FileInfo{
+			name: path.Base(name),
+			mode: fs.FileMode(0400) | fs.ModeDir,
+			size: 0,
+			key:  name,
+			loc:  s.location,
+		},
+	}
+
+	listed, err := d.fetch()
+	if err != nil {
+		return nil, err
+	}
+
+	// the root always exists, even in an empty bucket
+	if !listed && name != "" {
+		return nil, fs.ErrNotExist
+	}
+
+	return d, nil
+}
+
+// ResumableDir is a directory that can be read in pieces over several handles, e.g.
+// to serve an infinitely scrolling list of a huge directory without keeping it open
+// between requests.
+type ResumableDir interface {
+	fs.ReadDirFile
+
+	// ContinuationToken returns a token that OpenDirFrom can open the directory again
+	// with, so that the first ReadDir returns the entry that the next ReadDir on this
+	// handle would have. more is false once the whole directory has been read.
+	ContinuationToken() (token string, more bool)
+}
+
+// OpenDirFrom opens the directory name to carry on reading from where the handle
+// token came from left off, listing only from there on. An empty token opens the
+// directory from the start. Tokens are S3's continuation tokens, followed by "#n" if
+// the handle stopped n entries into a page, and are only good for a listing of the
+// same directory. Like S3's, they don't expire, but entries added or removed since the
+// token was handed out may be skipped or repeated. Directories opened with
+// WithLazyListing are ResumableDirs too.
+func (s *S3FS) OpenDirFrom(name, token string) (ResumableDir, error) {
+	if s.bucketErr != nil {
+		return nil, s.bucketErr
+	}
+
+	if err := s.checkOpen(); err != nil {
+		return nil, err
+	}
+
+	key, err := s.key("open", name)
+	if err != nil {
+		return nil, err
+	}
+
+	s.auditRead(OpReadDir, key)
+
+	if key != "" {
+		key += "/"
+	}
+
+	if token == "" {
+		f, err := openLazyDir(s, key)
+		if err != nil {
+			return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+		}
+
+		return f.(*lazyDir), nil
+	}
+
+	raw, skip := token, 0
+	if i := strings.LastIndex(token, "#"); i >= 0 {
+		raw = token[:i]
+		if skip, err = strconv.Atoi(token[i+1:]); err != nil || skip < 0 {
+			return nil, &fs.PathError{Op: "open", Path: name, Err: fmt.Errorf("%w: bad continuation token", fs.ErrInvalid)}
+		}
+	}
+
+	d := &lazyDir{
+		s:         s,
+		prefix:    key,
+		token:     raw,
+		conflicts: s.newDirConflicts(),
+		fileInfo: s3FileInfo{
+			name: path.Base(key),
+			mode: fs.FileMode(0400) | fs.ModeDir,
+			size: 0,
+			key:  key,
+			loc:  s.location,
+		},
+	}
+
+	if _, err := d.fetch(); err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+
+	// by index rather than count, as entries hidden by the ConflictPolicy are gone
+	for len(d.pending) > 0 && d.pending[0].index < skip {
+		d.pending = d.pending[1:]
+	}
+
+	return d, nil
+}
+
+// fetch lists the next page of the directory, reporting whether anything was listed.
+func (d *lazyDir) fetch() (bool, error) {
+	duplicateName := false
+	listed := false
+	token := ""
+	index := 0
+
+	add := func(entry fs.DirEntry) {
+		d.conflicts.add(entry)
+		d.pending = append(d.pending, lazyEntry{entry: entry, token: d.token, index: index})
+		index++
+	}
+
+	err := d.s.store.List(
+		context.Background(),
+		d.prefix,
+		ListOptions{Delimiter: "/", ContinuationToken: d.token},
+		func(page *ListPage) bool {
+			for _, obj := range page.Objects {
+				obj := obj
+				if obj.Key == d.prefix {
+					// a directory marker, which shows the directory exists
+					if d.conflicts != nil {
+						listed = true
+						continue
+					}
+
+					duplicateName = true
+					return false
+				}
+
+				listed = true
+				if d.s.skipStorageClasses[obj.StorageClass] {
+					continue
+				}
+
+				add(&s3FileInfo{
+					name:    path.Base(obj.Key),
+					mode:    fs.FileMode(0400),
+					size:    obj.Size,
+					modTime: obj.LastModified,
+					object:  &obj,
+					key:     obj.Key,
+					loc:     d.s.location,
+				})
+			}
+
+			for _, cp := range page.CommonPrefixes {
+				listed = true
+				add(&s3FileInfo{
+					name: path.Base(cp),
+					mode: fs.FileMode(0400) | fs.ModeDir,
+					size: 0,
+					key:  cp,
+					loc:  d.s.location,
+				})
+			}
+
+			token = page.NextContinuationToken
+
+			return false
+		},
+	)
+
+	if err != nil {
+		return false, fmt.Errorf("error listing s3 dir: %w", err)
+	}
+
+	if duplicateName {
+		return false, fmt.Errorf("directory name matches file name: %s", d.prefix)
+	}
+
+	if d.conflicts != nil {
+		pending := d.pending[:0]
+		for _, p := range d.pending {
+			if !d.conflicts.drops(p.entry) {
+				pending = append(pending, p)
+			}
+		}
+		d.pending = pending
+	}
+
+	d.token = token
+	d.done = token == ""
+
+	return listed, nil
+}
+
+func (d *lazyDir) ContinuationToken() (string, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if len(d.pending) > 0 {
+		next := d.pending[0]
+		if next.index == 0 {
+			return next.token, true
+		}
+
+		return next.token + "#" + strconv.Itoa(next.index), true
+	}
+
+	return d.token, !d.done
+}
+
+func (d *lazyDir) Stat() (fs.FileInfo, error) {
+	return &d.fileInfo, nil
+}
+
+func (d *lazyDir) Read(buf []byte) (int, error) {
+	return 0, fmt.Errorf("cannot read a directory")
+}
+
+func (d *lazyDir) Close() error {
+	return nil
+}
+
+// Seek rewinds the directory so the next ReadDir starts from the first entry again,
+// listing it from the beginning. The only offset it supports is 0 from the start.
+func (d *lazyDir) Seek(offset int64, whence int) (int64, error) {
+	if offset != 0 || whence != io.SeekStart {
+		return 0, fmt.Errorf("can only seek a directory to its start")
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.pending = nil
+	d.token = ""
+	d.done = false
+
+	return 0, nil
+}
+
+func (d *lazyDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for (n <= 0 || len(d.pending) < n) && !d.done {
+		if _, err := d.fetch(); err != nil {
+			return d.take(n), err
+		}
+	}
+
+	out := d.take(n)
+	if n > 0 && len(out) == 0 {
+		return nil, io.EOF
+	}
+
+	return out, nil
+}
+
+// take removes up to n pending entries, or all of them if n <= 0, and returns them.
+func (d *lazyDir) take(n int) []fs.DirEntry {
+	if n <= 0 || n > len(d.pending) {
+		n = len(d.pending)
+	}
+
+	out := make([]fs.DirEntry, n)
+	for i := range out {
+		out[i] = d.pending[i].entry
+	}
+	d.pending = d.pending[n:]
+
+	return out
+}
@@ -0,0 +1,185 @@
+package s3fs_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"sync"
+	"testing"
+	"testing/fstest"
+
+	"github.com/packrat386/s3fs"
+	"github.com/packrat386/s3fs/s3fstest"
+	"github.com/stretchr/testify/require"
+)
+
+// tokenStore records the continuation token of every List call.
+type tokenStore struct {
+	*s3fstest.MemStore
+
+	mu     sync.Mutex
+	tokens []string
+}
+
+func (s *tokenStore) List(ctx context.Context, prefix string, opts s3fs.ListOptions, fn func(*s3fs.ListPage) bool) error {
+	s.mu.Lock()
+	s.tokens = append(s.tokens, opts.ContinuationToken)
+	s.mu.Unlock()
+
+	return s.MemStore.List(ctx, prefix, opts, fn)
+}
+
+func (s *tokenStore) reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.tokens = nil
+}
+
+func TestWithLazyListing(t *testing.T) {
+	store := &tokenStore{MemStore: s3fstest.NewMemStore()}
+	store.PageSize = 3
+	for i := 0; i < 10; i++ {
+		store.WriteFile(fmt.Sprintf("big/%02d.json", i), `{}`)
+	}
+	store.WriteFile("big/sub/deep.json", `{}`)
+	store.WriteFile("big/sub/deeper.json", `{}`)
+	store.WriteFile("small.txt", "small")
+
+	myFS := s3fs.NewFS(store, s3fs.WithLazyListing())
+
+	if err := fstest.TestFS(myFS, "big/00.json", "big/sub/deep.json", "small.txt"); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := myFS.Open("big")
+	require.Nil(t, err)
+	defer f.Close()
+
+	store.reset()
+
+	dir := f.(fs.ReadDirFile)
+	names := []string{}
+	for {
+		entries, err := dir.ReadDir(2)
+		if err == io.EOF {
+			require.Empty(t, entries)
+			break
+		}
+
+		require.Nil(t, err)
+		require.NotEmpty(t, entries)
+		require.LessOrEqual(t, len(entries), 2)
+
+		for _, e := range entries {
+			names = append(names, e.Name())
+		}
+	}
+
+	require.Equal(t, []string{
+		"00.json", "01.json", "02.json", "03.json", "04.json",
+		"05.json", "06.json", "07.json", "08.json", "09.json", "sub",
+	}, names)
+
+	// the first page was listed on open, and every page after it was listed once,
+	// carrying on from the page before
+	require.Len(t, store.tokens, 3)
+	for _, token := range store.tokens {
+		require.NotEmpty(t, token)
+	}
+
+	entries, err := dir.ReadDir(-1)
+	require.Nil(t, err)
+	require.Empty(t, entries)
+
+	_, err = dir.ReadDir(1)
+	require.Equal(t, io.EOF, err)
+
+	// rewinding lists from the start again
+	_, err = f.(io.Seeker).Seek(0, io.SeekStart)
+	require.Nil(t, err)
+
+	entries, err = dir.ReadDir(-1)
+	require.Nil(t, err)
+	require.Len(t, entries, 11)
+
+	_, err = myFS.Open("missing")
+	require.ErrorIs(t, err, fs.ErrNotExist)
+
+	empty := s3fs.NewFS(s3fstest.NewMemStore(), s3fs.WithLazyListing())
+	entries, err = fs.ReadDir(empty, ".")
+	require.Nil(t, err)
+	require.Empty(t, entries)
+}
+
+func TestOpenDirFrom(t *testing.T) {
+	store := &tokenStore{MemStore: s3fstest.NewMemStore()}
+	store.PageSize = 3
+	for i := 0; i < 10; i++ {
+		store.WriteFile(fmt.Sprintf("big/%02d.json", i), `{}`)
+	}
+	store.WriteFile("big/sub/deep.json", `{}`)
+
+	myFS := s3fs.NewFS(store)
+
+	all, err := fs.ReadDir(myFS, "big")
+	require.Nil(t, err)
+
+	// a new handle for every two entries, as a paged UI would
+	names := []string{}
+	token := ""
+	for more := true; more; {
+		dir, err := myFS.OpenDirFrom("big", token)
+		require.Nil(t, err)
+
+		entries, err := dir.ReadDir(2)
+		require.Nil(t, err)
+		for _, e := range entries {
+			names = append(names, e.Name())
+		}
+
+		token, more = dir.ContinuationToken()
+		require.Nil(t, dir.Close())
+	}
+
+	require.Len(t, names, len(all))
+	for i, e := range all {
+		require.Equal(t, e.Name(), names[i])
+	}
+
+	// resuming only lists from the page it left off on
+	dir, err := myFS.OpenDirFrom("big", "")
+	require.Nil(t, err)
+	_, err = dir.ReadDir(7)
+	require.Nil(t, err)
+	token, more := dir.ContinuationToken()
+	require.True(t, more)
+
+	store.reset()
+	resumed, err := myFS.OpenDirFrom("big", token)
+	require.Nil(t, err)
+
+	entries, err := resumed.ReadDir(-1)
+	require.Nil(t, err)
+	require.Len(t, entries, 4)
+	require.Equal(t, "07.json", entries[0].Name())
+	require.Len(t, store.tokens, 2)
+	require.NotEmpty(t, store.tokens[0])
+
+	_, more = resumed.ContinuationToken()
+	require.False(t, more)
+
+	_, err = myFS.OpenDirFrom("missing", "")
+	require.ErrorIs(t, err, fs.ErrNotExist)
+
+	_, err = myFS.OpenDirFrom("big", "token#x")
+	require.ErrorIs(t, err, fs.ErrInvalid)
+
+	// lazily listed directories are resumable too
+	lazy := s3fs.NewFS(store, s3fs.WithLazyListing())
+	f, err := lazy.Open("big")
+	require.Nil(t, err)
+	_, ok := f.(s3fs.ResumableDir)
+	require.True(t, ok)
+}
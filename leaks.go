@@ -0,0 +1,104 @@
+package s3fs
+
+import (
+	"log"
+	"runtime"
+	"runtime/debug"
+	"sync"
+)
+
+// LeakedFile describes a file that was opened through the FS and never closed.
+type LeakedFile struct {
+	Name string
+
+	// Stack is the stack trace of the call to Open.
+	Stack string
+}
+
+// WithLeakDetection records a stack trace every time a file is opened and reports files
+// that are garbage collected without being closed, along with any still open when
+// the FS is closed. Unclosed files hold on to their HTTP connections, so leaking them
+// slowly exhausts the connection pool. Leaked files are closed when they are found.
+//
+// onLeak is called with each leaked file; if it is nil they are logged with the
+// standard logger. Recording stack traces is slow, so this is meant for debugging.
+func WithLeakDetection(onLeak func(LeakedFile)) Option {
+	return func(s *S3FS) {
+		if onLeak == nil {
+			onLeak = func(leak LeakedFile) {
+				log.Printf("s3fs: %s was never closed, opened at:\n%s", leak.Name, leak.Stack)
+			}
+		}
+
+		s.leaks = &leakDetector{
+			onLeak: onLeak,
+			open:   map[int64]LeakedFile{},
+		}
+		s.closers = append(s.closers, s.leaks.reportOpen)
+	}
+}
+
+type leakDetector struct {
+	onLeak func(LeakedFile)
+
+	mu     sync.Mutex
+	nextID int64
+
+	// open is the files that haven't been closed yet. It mustn't refer to the files
+	// themselves, or they would never be garbage collected.
+	open map[int64]LeakedFile
+}
+
+// track starts watching f, which has just been opened.
+func (d *leakDetector) track(f *s3File) {
+	if d == nil {
+		return
+	}
+
+	d.mu.Lock()
+	d.nextID++
+	id := d.nextID
+	d.open[id] = LeakedFile{Name: f.name, Stack: string(debug.Stack())}
+	d.mu.Unlock()
+
+	f.leakID = id
+	f.leaks = d
+
+	runtime.SetFinalizer(f, func(f *s3File) {
+		if leak, ok := d.forget(f.leakID); ok {
+			f.body.Close()
+			d.onLeak(leak)
+		}
+	})
+}
+
+// closed stops watching f, which has been closed.
+func (d *leakDetector) closed(f *s3File) {
+	d.forget(f.leakID)
+	runtime.SetFinalizer(f, nil)
+}
+
+func (d *leakDetector) forget(id int64) (LeakedFile, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	leak, ok := d.open[id]
+	delete(d.open, id)
+
+	return leak, ok
+}
+
+// reportOpen reports every file that is still open, and stops watching them.
+func (d *leakDetector) reportOpen() {
+	d.mu.Lock()
+	leaks := make([]LeakedFile, 0, len(d.open))
+	for _, leak := range d.open {
+		leaks = append(leaks, leak)
+	}
+	d.open = map[int64]LeakedFile{}
+	d.mu.Unlock()
+
+	for _, leak := range leaks {
+		d.onLeak(leak)
+	}
+}
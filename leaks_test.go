@@ -0,0 +1,57 @@
+package s3fs_test
+
+import (
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/packrat386/s3fs"
+	"github.com/packrat386/s3fs/s3fstest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithLeakDetection(t *testing.T) {
+	store := s3fstest.NewMemStore()
+	store.WriteFile("leaked.json", `{}`)
+	store.WriteFile("closed.json", `{}`)
+	store.WriteFile("open.json", `{}`)
+
+	leaks := make(chan s3fs.LeakedFile, 10)
+	myFS := s3fs.NewFS(store, s3fs.WithLeakDetection(func(leak s3fs.LeakedFile) {
+		leaks <- leak
+	}))
+
+	openAndForget(t, myFS, "leaked.json")
+
+	f, err := myFS.Open("closed.json")
+	require.Nil(t, err)
+	require.Nil(t, f.Close())
+
+	// finalizers run in the background some time after a GC
+	var leak s3fs.LeakedFile
+	for i := 0; leak.Name == "" && i < 500; i++ {
+		runtime.GC()
+
+		select {
+		case leak = <-leaks:
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+
+	require.Equal(t, "leaked.json", leak.Name)
+	require.Contains(t, leak.Stack, "openAndForget")
+
+	open, err := myFS.Open("open.json")
+	require.Nil(t, err)
+
+	require.Nil(t, myFS.Close())
+	require.Equal(t, "open.json", (<-leaks).Name)
+	require.Equal(t, 0, len(leaks))
+
+	require.Nil(t, open.Close())
+}
+
+func openAndForget(t *testing.T, myFS *s3fs.S3FS, name string) {
+	_, err := myFS.Open(name)
+	require.Nil(t, err)
+}
@@ -0,0 +1,104 @@
+package s3fs
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Lifecycle is what S3 reports about an object's lifecycle: when a lifecycle rule
+// will expire it, whether it is being restored from an archive, and how far along it
+// is in being replicated.
+type Lifecycle struct {
+	// ExpiresAt is when a lifecycle rule will expire the object, or zero if none will.
+	ExpiresAt time.Time
+
+	// ExpirationRule is the ID of the rule that will expire the object.
+	ExpirationRule string
+
+	// Restoring is whether a restore of the archived object is in progress.
+	Restoring bool
+
+	// RestoredUntil is when the restored copy of an archived object will be removed
+	// again, or zero if it isn't restored.
+	RestoredUntil time.Time
+
+	// ReplicationStatus is the object's replication status, e.g. "PENDING",
+	// "COMPLETED", "FAILED" or "REPLICA", or empty if it isn't replicated.
+	ReplicationStatus string
+}
+
+// LifecycleFile is implemented by the files opened by the FS. Type assert an fs.File
+// to it to see its Lifecycle, e.g. to skip objects that are about to expire or are
+// still being restored.
+type LifecycleFile interface {
+	Lifecycle() Lifecycle
+}
+
+// Lifecycle parses the lifecycle headers in the ObjectInfo. Only Head and Get fill
+// them in, so this is always empty for ObjectInfos from List.
+func (o ObjectInfo) Lifecycle() Lifecycle {
+	l := Lifecycle{
+		ReplicationStatus: o.ReplicationStatus,
+	}
+
+	// expiry-date="Fri, 23 Dec 2012 00:00:00 GMT", rule-id="picture-deletion-rule"
+	expiration := parseHeaderParams(o.Expiration)
+	l.ExpiresAt, _ = http.ParseTime(expiration["expiry-date"])
+	l.ExpirationRule = expiration["rule-id"]
+
+	// ongoing-request="false", expiry-date="Fri, 21 Dec 2012 00:00:00 GMT"
+	restore := parseHeaderParams(o.Restore)
+	l.Restoring = restore["ongoing-request"] == "true"
+	l.RestoredUntil, _ = http.ParseTime(restore["expiry-date"])
+
+	return l
+}
+
+func (f *s3File) Lifecycle() Lifecycle {
+	if f.fileInfo.object == nil {
+		return Lifecycle{}
+	}
+
+	return f.fileInfo.object.Lifecycle()
+}
+
+// parseHeaderParams parses a header made of comma separated key="value" pairs, where
+// the values may contain commas.
+func parseHeaderParams(header string) map[string]string {
+	params := map[string]string{}
+
+	for header != "" {
+		eq := strings.Index(header, "=")
+		if eq < 0 {
+			break
+		}
+
+		key := strings.TrimSpace(header[:eq])
+		header = header[eq+1:]
+
+		var value string
+		if strings.HasPrefix(header, `"`) {
+			end := strings.Index(header[1:], `"`)
+			if end < 0 {
+				break
+			}
+
+			value = header[1 : end+1]
+			header = header[end+2:]
+		} else {
+			end := strings.Index(header, ",")
+			if end < 0 {
+				end = len(header)
+			}
+
+			value = header[:end]
+			header = header[end:]
+		}
+
+		params[key] = value
+		header = strings.TrimPrefix(strings.TrimSpace(header), ",")
+	}
+
+	return params
+}
@@ -0,0 +1,43 @@
+package s3fs_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/packrat386/s3fs"
+	"github.com/packrat386/s3fs/s3fstest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestObjectInfo_Lifecycle(t *testing.T) {
+	info := s3fs.ObjectInfo{
+		Expiration:        `expiry-date="Fri, 23 Dec 2012 00:00:00 GMT", rule-id="picture-deletion-rule"`,
+		Restore:           `ongoing-request="false", expiry-date="Fri, 21 Dec 2012 00:00:00 GMT"`,
+		ReplicationStatus: "COMPLETED",
+	}
+
+	l := info.Lifecycle()
+	require.Equal(t, time.Date(2012, 12, 23, 0, 0, 0, 0, time.UTC), l.ExpiresAt)
+	require.Equal(t, "picture-deletion-rule", l.ExpirationRule)
+	require.False(t, l.Restoring)
+	require.Equal(t, time.Date(2012, 12, 21, 0, 0, 0, 0, time.UTC), l.RestoredUntil)
+	require.Equal(t, "COMPLETED", l.ReplicationStatus)
+
+	l = s3fs.ObjectInfo{Restore: `ongoing-request="true"`}.Lifecycle()
+	require.True(t, l.Restoring)
+	require.True(t, l.RestoredUntil.IsZero())
+	require.True(t, l.ExpiresAt.IsZero())
+}
+
+func TestLifecycleFile(t *testing.T) {
+	store := s3fstest.NewMemStore()
+	store.WriteFile("foo.json", `{"data":"foo"}`)
+
+	f, err := s3fs.NewFS(store).Open("foo.json")
+	require.Nil(t, err)
+	defer f.Close()
+
+	lf, ok := f.(s3fs.LifecycleFile)
+	require.True(t, ok)
+	require.Equal(t, s3fs.Lifecycle{}, lf.Lifecycle())
+}
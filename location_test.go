@@ -0,0 +1,27 @@
+package s3fs
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewLocation(t *testing.T) {
+	sess, err := session.NewSession(aws.NewConfig().WithRegion("us-west-2"))
+	require.Nil(t, err)
+
+	fsys := NewS3FS(s3.New(sess), "my-bucket")
+	require.Equal(t, &location{bucket: "my-bucket"}, fsys.location)
+
+	scoped, err := NewScopedS3FS(s3.New(sess), "my-bucket", "tenants/a")
+	require.Nil(t, err)
+	require.Equal(t, &location{bucket: "my-bucket", prefix: "tenants/a/"}, scoped.location)
+
+	info := &s3FileInfo{key: "logs/app.log", loc: scoped.location}
+	bucket, key := info.bucketKey()
+	require.Equal(t, "my-bucket", bucket)
+	require.Equal(t, "tenants/a/logs/app.log", key)
+}
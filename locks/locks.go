@@ -0,0 +1,259 @@
+// Package locks provides coarse advisory locks stored as objects in an s3fs.S3FS.
+//
+// A lock is an object holding who owns it and when it expires. It is taken with a
+// conditional write that only succeeds if the object doesn't exist or is exactly the
+// expired lock we read, so only one process can win. Held locks are renewed in the
+// background until released.
+//
+// Expiry is judged by the clocks of the processes involved, so ttl should be much
+// longer than any clock skew between them.
+package locks
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/packrat386/s3fs"
+)
+
+// ErrLocked is returned by AcquireLock when someone else holds an unexpired lock.
+var ErrLocked = errors.New("lock is held")
+
+// ErrLockLost is returned by Renew and Release when the lock has expired and been
+// taken by someone else.
+var ErrLockLost = errors.New("lock was lost")
+
+// Locker acquires locks stored in an FS.
+type Locker struct {
+	fsys  *s3fs.S3FS
+	owner string
+}
+
+// NewLocker returns a Locker storing locks in fsys. Locks record the hostname and pid
+// of the process that holds them to make debugging easier.
+func NewLocker(fsys *s3fs.S3FS) *Locker {
+	host, _ := os.Hostname()
+
+	return &Locker{
+		fsys:  fsys,
+		owner: fmt.Sprintf("%s:%d", host, os.Getpid()),
+	}
+}
+
+// record is what is stored in a lock object.
+type record struct {
+	Owner   string    `json:"owner"`
+	Token   string    `json:"token"`
+	Expires time.Time `json:"expires"`
+}
+
+// Lock is a held lock. It is renewed every ttl/3 until Release is called.
+type Lock struct {
+	locker *Locker
+	name   string
+	ttl    time.Duration
+	token  string
+
+	mu   sync.Mutex
+	etag string
+	err  error
+
+	stopOnce sync.Once
+	stop     chan struct{}
+	done     chan struct{}
+	lost     chan struct{}
+}
+
+// AcquireLock takes the lock at name for ttl. It returns an error wrapping ErrLocked
+// if someone else holds it.
+func (l *Locker) AcquireLock(name string, ttl time.Duration) (*Lock, error) {
+	token, err := newToken()
+	if err != nil {
+		return nil, err
+	}
+
+	lk := &Lock{
+		locker: l,
+		name:   name,
+		ttl:    ttl,
+		token:  token,
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+		lost:   make(chan struct{}),
+	}
+
+	current, etag, err := l.read(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		etag = ""
+	} else if err != nil {
+		return nil, err
+	} else if l.fsys.Clock().Now().Before(current.Expires) {
+		return nil, fmt.Errorf("%w: %s is held by %s until %s", ErrLocked, name, current.Owner, current.Expires)
+	}
+
+	if err := lk.write(etag, l.fsys.Clock().Now().Add(ttl)); err != nil {
+		if errors.Is(err, s3fs.ErrPreconditionFailed) {
+			return nil, fmt.Errorf("%w: %s was taken by someone else", ErrLocked, name)
+		}
+
+		return nil, err
+	}
+
+	go lk.heartbeat()
+
+	return lk, nil
+}
+
+// Renew extends the lock for another ttl from now.
+func (lk *Lock) Renew() error {
+	lk.mu.Lock()
+	defer lk.mu.Unlock()
+
+	if lk.err != nil {
+		return lk.err
+	}
+
+	err := lk.write(lk.etag, lk.locker.fsys.Clock().Now().Add(lk.ttl))
+	if errors.Is(err, s3fs.ErrPreconditionFailed) {
+		lk.err = fmt.Errorf("%w: %s", ErrLockLost, lk.name)
+		close(lk.lost)
+		return lk.err
+	}
+
+	return err
+}
+
+// Release stops renewing the lock and marks it expired so someone else can take it.
+func (lk *Lock) Release() error {
+	lk.stopOnce.Do(func() {
+		close(lk.stop)
+	})
+	<-lk.done
+
+	lk.mu.Lock()
+	defer lk.mu.Unlock()
+
+	if lk.err == errReleased {
+		return nil
+	}
+
+	if lk.err != nil {
+		return lk.err
+	}
+
+	err := lk.write(lk.etag, time.Time{})
+	if errors.Is(err, s3fs.ErrPreconditionFailed) {
+		return fmt.Errorf("%w: %s", ErrLockLost, lk.name)
+	}
+
+	if err == nil {
+		lk.err = errReleased
+	}
+
+	return err
+}
+
+var errReleased = errors.New("lock was released")
+
+// Lost is closed if a renewal finds the lock has been taken by someone else.
+func (lk *Lock) Lost() <-chan struct{} {
+	return lk.lost
+}
+
+func (lk *Lock) heartbeat() {
+	defer close(lk.done)
+
+	clock := lk.locker.fsys.Clock()
+
+	for {
+		select {
+		case <-lk.stop:
+			return
+		case <-clock.After(lk.ttl / 3):
+			if errors.Is(lk.Renew(), ErrLockLost) {
+				return
+			}
+		}
+	}
+}
+
+// write replaces the lock object, which must currently have etag (or not exist if etag
+// is empty), with a record of ours. It must be called with lk.mu held, or before the
+// heartbeat starts.
+func (lk *Lock) write(etag string, expires time.Time) error {
+	data, err := json.Marshal(record{
+		Owner:   lk.locker.owner,
+		Token:   lk.token,
+		Expires: expires.UTC(),
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := lk.locker.fsys.WriteFileIf(lk.name, data, etag); err != nil {
+		return err
+	}
+
+	// read back the ETag of what we wrote. If the token isn't ours someone else
+	// thought our lock expired and took it in between.
+	current, newETag, err := lk.locker.read(lk.name)
+	if err != nil {
+		return err
+	}
+
+	if current.Token != lk.token {
+		return fmt.Errorf("%w: %s was taken by %s", s3fs.ErrPreconditionFailed, lk.name, current.Owner)
+	}
+
+	lk.etag = newETag
+
+	return nil
+}
+
+// read returns the lock record at name and its ETag.
+func (l *Locker) read(name string) (record, string, error) {
+	f, err := l.fsys.Open(name)
+	if err != nil {
+		return record{}, "", err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return record{}, "", err
+	}
+
+	object, ok := info.Sys().(s3fs.ObjectInfo)
+	if !ok {
+		return record{}, "", fmt.Errorf("%s is not a lock", name)
+	}
+
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return record{}, "", err
+	}
+
+	rec := record{}
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return record{}, "", fmt.Errorf("%s is not a lock: %w", name, err)
+	}
+
+	return rec, object.ETag, nil
+}
+
+func newToken() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("could not generate lock token: %w", err)
+	}
+
+	return hex.EncodeToString(buf), nil
+}
@@ -0,0 +1,98 @@
+package locks
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/packrat386/s3fs"
+	"github.com/packrat386/s3fs/s3fstest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAcquireLock(t *testing.T) {
+	fsys := s3fs.NewFS(s3fstest.NewMemStore())
+	locker := NewLocker(fsys)
+
+	lk, err := locker.AcquireLock("locks/job", time.Minute)
+	require.Nil(t, err)
+
+	_, err = locker.AcquireLock("locks/job", time.Minute)
+	require.True(t, errors.Is(err, ErrLocked))
+
+	require.Nil(t, lk.Renew())
+	require.Nil(t, lk.Release())
+	require.Nil(t, lk.Release())
+
+	lk2, err := locker.AcquireLock("locks/job", time.Minute)
+	require.Nil(t, err)
+	require.Nil(t, lk2.Release())
+}
+
+func TestAcquireLock_Expired(t *testing.T) {
+	store := s3fstest.NewMemStore()
+	fsys := s3fs.NewFS(store)
+	locker := NewLocker(fsys)
+
+	store.WriteFile("locks/job", `{"owner":"crashed","token":"abc","expires":"2001-01-01T00:00:00Z"}`)
+
+	lk, err := locker.AcquireLock("locks/job", time.Minute)
+	require.Nil(t, err)
+
+	// someone else decides we're dead and takes over
+	store.WriteFile("locks/job", `{"owner":"thief","token":"def","expires":"2999-01-01T00:00:00Z"}`)
+
+	err = lk.Renew()
+	require.True(t, errors.Is(err, ErrLockLost))
+
+	select {
+	case <-lk.Lost():
+	default:
+		t.Fatal("lost channel should be closed")
+	}
+
+	err = lk.Release()
+	require.True(t, errors.Is(err, ErrLockLost))
+}
+
+func TestAcquireLock_Heartbeat(t *testing.T) {
+	fsys := s3fs.NewFS(s3fstest.NewMemStore())
+	locker := NewLocker(fsys)
+
+	lk, err := locker.AcquireLock("locks/job", 60*time.Millisecond)
+	require.Nil(t, err)
+
+	time.Sleep(200 * time.Millisecond)
+
+	// still ours thanks to renewals
+	_, err = locker.AcquireLock("locks/job", time.Minute)
+	require.True(t, errors.Is(err, ErrLocked))
+
+	require.Nil(t, lk.Release())
+}
+
+func TestAcquireLock_HeartbeatClock(t *testing.T) {
+	clock := s3fstest.NewFakeClock(time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC))
+	store := s3fstest.NewMemStore()
+	fsys := s3fs.NewFS(store, s3fs.WithClock(clock))
+	locker := NewLocker(fsys)
+
+	lk, err := locker.AcquireLock("locks/job", time.Minute)
+	require.Nil(t, err)
+
+	// renewed every 20 seconds by the FS's clock, well past the first expiry
+	for i := 0; i < 6; i++ {
+		clock.BlockUntil(1)
+		clock.Advance(20 * time.Second)
+	}
+	clock.BlockUntil(1)
+
+	current, _, err := locker.read("locks/job")
+	require.Nil(t, err)
+	require.Equal(t, clock.Now().Add(time.Minute), current.Expires)
+
+	_, err = locker.AcquireLock("locks/job", time.Minute)
+	require.True(t, errors.Is(err, ErrLocked))
+
+	require.Nil(t, lk.Release())
+}
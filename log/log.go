@@ -0,0 +1,209 @@
+// Package log is a cheap append-only event log stored as objects in an s3fs.S3FS, for
+// systems that write rarely enough that an object per append is affordable.
+//
+// Each append writes a segment: an object in the log's directory named after its
+// sequence number, zero padded so that names sort in sequence order. Segments are
+// written with a conditional write that fails if the name is taken, so concurrent
+// writers never overwrite each other; the loser moves on to the next number. Readers
+// pick up new segments by listing only the names after the last one they read.
+package log
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/packrat386/s3fs"
+)
+
+// segmentDigits is enough digits for any uint64, so names always sort in order.
+const segmentDigits = 20
+
+// Segment is one append to a Log.
+type Segment struct {
+	// Seq is the sequence number of the segment. The first segment is 1.
+	Seq uint64
+
+	Data []byte
+}
+
+// Log appends segments to a directory of an FS and reads them back.
+type Log struct {
+	fsys *s3fs.S3FS
+	dir  string
+
+	mu   sync.Mutex
+	last uint64
+}
+
+// NewLog returns the Log kept in the directory dir of fsys. The directory doesn't need
+// to exist yet.
+func NewLog(fsys *s3fs.S3FS, dir string) *Log {
+	return &Log{fsys: fsys, dir: dir}
+}
+
+func segmentName(seq uint64) string {
+	return fmt.Sprintf("%0*d", segmentDigits, seq)
+}
+
+// parseSegmentName returns the sequence number of a segment name, or false if name
+// isn't one.
+func parseSegmentName(name string) (uint64, bool) {
+	if len(name) != segmentDigits {
+		return 0, false
+	}
+
+	seq, err := strconv.ParseUint(name, 10, 64)
+
+	return seq, err == nil
+}
+
+// Append writes data as the next segment of the log and returns its sequence number.
+func (l *Log) Append(ctx context.Context, data []byte) (uint64, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if err := l.catchUp(ctx); err != nil {
+		return 0, err
+	}
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+
+		seq := l.last + 1
+		err := l.fsys.WriteFileIf(path.Join(l.dir, segmentName(seq)), data, "")
+		if err == nil {
+			l.last = seq
+			return seq, nil
+		}
+
+		if !errors.Is(err, s3fs.ErrPreconditionFailed) {
+			return 0, fmt.Errorf("could not append to %s: %w", l.dir, err)
+		}
+
+		// someone else took seq
+		if err := l.catchUp(ctx); err != nil {
+			return 0, err
+		}
+	}
+}
+
+// catchUp advances l.last past the segments written since it was last seen.
+func (l *Log) catchUp(ctx context.Context) error {
+	for {
+		after := ""
+		if l.last > 0 {
+			after = segmentName(l.last)
+		}
+
+		entries, err := l.fsys.ReadDirAfter(ctx, l.dir, after, 0)
+		if err != nil {
+			return fmt.Errorf("could not list %s: %w", l.dir, err)
+		}
+
+		advanced := false
+		for _, entry := range entries {
+			if seq, ok := parseSegmentName(entry.Name()); ok && seq > l.last {
+				l.last = seq
+				advanced = true
+			}
+		}
+
+		if !advanced {
+			return nil
+		}
+	}
+}
+
+// Iterator reads the segments of a Log in order.
+type Iterator struct {
+	log     *Log
+	after   uint64
+	pending []uint64
+}
+
+// Iterator returns an Iterator over the segments after the one numbered after. An
+// after of zero reads the whole log.
+func (l *Log) Iterator(after uint64) *Iterator {
+	return &Iterator{log: l, after: after}
+}
+
+// iteratorBatch is how many segment names an Iterator lists at a time.
+const iteratorBatch = 1000
+
+// Next returns the next segment. Once it has returned every segment written so far it
+// returns io.EOF; calling it again later picks up segments appended since.
+func (it *Iterator) Next(ctx context.Context) (Segment, error) {
+	if len(it.pending) == 0 {
+		after := ""
+		if it.after > 0 {
+			after = segmentName(it.after)
+		}
+
+		entries, err := it.log.fsys.ReadDirAfter(ctx, it.log.dir, after, iteratorBatch)
+		if err != nil {
+			return Segment{}, fmt.Errorf("could not list %s: %w", it.log.dir, err)
+		}
+
+		for _, entry := range entries {
+			if seq, ok := parseSegmentName(entry.Name()); ok && !entry.IsDir() {
+				it.pending = append(it.pending, seq)
+			}
+		}
+
+		if len(it.pending) == 0 {
+			return Segment{}, io.EOF
+		}
+	}
+
+	seq := it.pending[0]
+	name := path.Join(it.log.dir, segmentName(seq))
+
+	f, err := it.log.fsys.OpenIf(ctx, name, s3fs.ReadConditions{})
+	if err != nil {
+		return Segment{}, fmt.Errorf("could not open segment %d of %s: %w", seq, it.log.dir, err)
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return Segment{}, fmt.Errorf("could not read segment %d of %s: %w", seq, it.log.dir, err)
+	}
+
+	it.pending = it.pending[1:]
+	it.after = seq
+
+	return Segment{Seq: seq, Data: data}, nil
+}
+
+// Follow calls fn with every segment after the Iterator's position as it is appended,
+// checking for new ones every interval, until ctx is done or fn returns an error.
+func (it *Iterator) Follow(ctx context.Context, interval time.Duration, fn func(Segment) error) error {
+	for {
+		segment, err := it.Next(ctx)
+		if err == nil {
+			if err := fn(segment); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		if err != io.EOF {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-it.log.fsys.Clock().After(interval):
+		}
+	}
+}
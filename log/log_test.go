@@ -0,0 +1,79 @@
+package log
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/packrat386/s3fs"
+	"github.com/packrat386/s3fs/s3fstest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLog(t *testing.T) {
+	ctx := context.Background()
+	fsys := s3fs.NewFS(s3fstest.NewMemStore())
+	l := NewLog(fsys, "events")
+
+	for i, event := range []string{"one", "two", "three"} {
+		seq, err := l.Append(ctx, []byte(event))
+		require.Nil(t, err)
+		require.Equal(t, uint64(i+1), seq)
+	}
+
+	it := l.Iterator(1)
+
+	segment, err := it.Next(ctx)
+	require.Nil(t, err)
+	require.Equal(t, Segment{Seq: 2, Data: []byte("two")}, segment)
+
+	segment, err = it.Next(ctx)
+	require.Nil(t, err)
+	require.Equal(t, Segment{Seq: 3, Data: []byte("three")}, segment)
+
+	_, err = it.Next(ctx)
+	require.Equal(t, io.EOF, err)
+
+	// a second writer that doesn't know about the first one's segments
+	seq, err := NewLog(fsys, "events").Append(ctx, []byte("four"))
+	require.Nil(t, err)
+	require.Equal(t, uint64(4), seq)
+
+	segment, err = it.Next(ctx)
+	require.Nil(t, err)
+	require.Equal(t, Segment{Seq: 4, Data: []byte("four")}, segment)
+
+	// the first writer finds 4 taken and moves on
+	seq, err = l.Append(ctx, []byte("five"))
+	require.Nil(t, err)
+	require.Equal(t, uint64(5), seq)
+}
+
+func TestIterator_Follow(t *testing.T) {
+	fsys := s3fs.NewFS(s3fstest.NewMemStore())
+	l := NewLog(fsys, "events")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	go func() {
+		for _, event := range []string{"a", "b", "c"} {
+			l.Append(context.Background(), []byte(event))
+			time.Sleep(5 * time.Millisecond)
+		}
+	}()
+
+	got := []string{}
+	err := l.Iterator(0).Follow(ctx, time.Millisecond, func(s Segment) error {
+		got = append(got, string(s.Data))
+		if len(got) == 3 {
+			cancel()
+		}
+
+		return nil
+	})
+
+	require.Equal(t, context.Canceled, err)
+	require.Equal(t, []string{"a", "b", "c"}, got)
+}
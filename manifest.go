@@ -0,0 +1,263 @@
+package s3fs
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// manifestHeader starts every manifest file, followed by the prefix.
+const manifestHeader = "# s3fs manifest v1 prefix="
+
+// Manifest records the size, ETag, and SHA-256 (if known) of every file under a
+// prefix at one point in time. Its text form (see WriteTo) is canonical, so the same
+// manifest always serializes to the same bytes and can be signed.
+type Manifest struct {
+	// Prefix is the directory the manifest covers, "." for the whole bucket.
+	Prefix string
+
+	// Entries are sorted by Path.
+	Entries []ManifestEntry
+}
+
+// ManifestEntry is a single file in a Manifest.
+type ManifestEntry struct {
+	Path string
+	Size int64
+	ETag string
+
+	// SHA256 is the hex SHA-256 of the content, if it is known, and empty
+	// otherwise.
+	SHA256 string
+}
+
+// ETags maps each path in the manifest to its ETag, for NewPinnedFS.
+func (m *Manifest) ETags() map[string]string {
+	etags := make(map[string]string, len(m.Entries))
+	for _, e := range m.Entries {
+		etags[e.Path] = e.ETag
+	}
+
+	return etags
+}
+
+// WriteTo writes the manifest as text: a header line naming the prefix, then a line
+// of size, ETag, SHA-256 ("-" if unknown), and quoted path, separated by tabs, for
+// each entry.
+func (m *Manifest) WriteTo(w io.Writer) (int64, error) {
+	bw := bufio.NewWriter(w)
+	cw := &countingWriter{w: bw}
+
+	fmt.Fprintf(cw, "%s%s\n", manifestHeader, strconv.Quote(m.Prefix))
+	for _, e := range m.Entries {
+		sha := e.SHA256
+		if sha == "" {
+			sha = "-"
+		}
+
+		fmt.Fprintf(cw, "%d\t%s\t%s\t%s\n", e.Size, e.ETag, sha, strconv.Quote(e.Path))
+	}
+
+	if cw.err != nil {
+		return cw.n, cw.err
+	}
+
+	return cw.n, bw.Flush()
+}
+
+// ParseManifest reads a manifest written by WriteTo.
+func ParseManifest(r io.Reader) (*Manifest, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	if !scanner.Scan() || !strings.HasPrefix(scanner.Text(), manifestHeader) {
+		return nil, fmt.Errorf("not a manifest")
+	}
+
+	prefix, err := strconv.Unquote(strings.TrimPrefix(scanner.Text(), manifestHeader))
+	if err != nil {
+		return nil, fmt.Errorf("invalid manifest prefix: %w", err)
+	}
+
+	m := &Manifest{Prefix: prefix}
+	for line := 2; scanner.Scan(); line++ {
+		fields := strings.SplitN(scanner.Text(), "\t", 4)
+		if len(fields) != 4 {
+			return nil, fmt.Errorf("invalid manifest line %d", line)
+		}
+
+		e := ManifestEntry{ETag: fields[1]}
+
+		if e.Size, err = strconv.ParseInt(fields[0], 10, 64); err != nil {
+			return nil, fmt.Errorf("invalid size on manifest line %d: %w", line, err)
+		}
+
+		if fields[2] != "-" {
+			e.SHA256 = fields[2]
+		}
+
+		if e.Path, err = strconv.Unquote(fields[3]); err != nil {
+			return nil, fmt.Errorf("invalid path on manifest line %d: %w", line, err)
+		}
+
+		m.Entries = append(m.Entries, e)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("could not read manifest: %w", err)
+	}
+
+	return m, nil
+}
+
+// GenerateManifest lists every file under the directory prefix ("." for the whole
+// bucket) and records it in a Manifest. It only lists, so it's cheap even for large
+// prefixes. SHA-256s are only recorded if the store's listings include the "sha256"
+// metadata of objects; S3's don't, so manifests of S3 identify content by ETag.
+func (s *S3FS) GenerateManifest(ctx context.Context, prefix string) (*Manifest, error) {
+	if s.bucketErr != nil {
+		return nil, s.bucketErr
+	}
+
+	key, err := s.key("manifest", prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	listPrefix := ""
+	if key != "" {
+		listPrefix = key + "/"
+	}
+
+	m := &Manifest{Prefix: prefix}
+	err = s.store.List(ctx, listPrefix, ListOptions{}, func(page *ListPage) bool {
+		for _, obj := range page.Objects {
+			if strings.HasSuffix(obj.Key, "/") {
+				// directory markers aren't files
+				continue
+			}
+
+			m.Entries = append(m.Entries, ManifestEntry{
+				Path:   obj.Key,
+				Size:   obj.Size,
+				ETag:   obj.ETag,
+				SHA256: obj.Metadata["sha256"],
+			})
+		}
+
+		return true
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("could not list s3 objects: %w", err)
+	}
+
+	sort.Slice(m.Entries, func(i, j int) bool {
+		return m.Entries[i].Path < m.Entries[j].Path
+	})
+
+	return m, nil
+}
+
+// Fingerprint returns a hash of the path, size and ETag of every file under the
+// directory prefix ("." for the whole bucket). It's computed from listings alone, so
+// it's cheap to check on a schedule: if the fingerprint is the same as last time,
+// nothing under prefix was added, removed or changed. It is the hex SHA-256 of the
+// sorted entries, so it's stable across runs and processes.
+func (s *S3FS) Fingerprint(ctx context.Context, prefix string) (string, error) {
+	m, err := s.GenerateManifest(ctx, prefix)
+	if err != nil {
+		return "", err
+	}
+
+	h := sha256.New()
+	for _, e := range m.Entries {
+		fmt.Fprintf(h, "%s\t%d\t%s\n", strconv.Quote(e.Path), e.Size, e.ETag)
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// ManifestMismatchError is returned by VerifyManifest when the files under the
+// manifest's prefix don't match it.
+type ManifestMismatchError struct {
+	// Missing files are in the manifest but no longer exist.
+	Missing []string
+
+	// Changed files have a different size or ETag than in the manifest.
+	Changed []string
+
+	// Added files exist but aren't in the manifest.
+	Added []string
+}
+
+func (e *ManifestMismatchError) Error() string {
+	return fmt.Sprintf(
+		"files don't match manifest: %d missing, %d changed, %d added",
+		len(e.Missing), len(e.Changed), len(e.Added),
+	)
+}
+
+// VerifyManifest checks that the files under the manifest's prefix are exactly the
+// ones in the manifest, unchanged. If they aren't it returns a
+// *ManifestMismatchError listing the differences.
+func (s *S3FS) VerifyManifest(ctx context.Context, m *Manifest) error {
+	current, err := s.GenerateManifest(ctx, m.Prefix)
+	if err != nil {
+		return err
+	}
+
+	expected := make(map[string]ManifestEntry, len(m.Entries))
+	for _, e := range m.Entries {
+		expected[e.Path] = e
+	}
+
+	mismatch := &ManifestMismatchError{}
+	for _, e := range current.Entries {
+		want, ok := expected[e.Path]
+		delete(expected, e.Path)
+
+		switch {
+		case !ok:
+			mismatch.Added = append(mismatch.Added, e.Path)
+		case want.Size != e.Size || want.ETag != e.ETag:
+			mismatch.Changed = append(mismatch.Changed, e.Path)
+		}
+	}
+
+	for name := range expected {
+		mismatch.Missing = append(mismatch.Missing, name)
+	}
+	sort.Strings(mismatch.Missing)
+
+	if len(mismatch.Missing) > 0 || len(mismatch.Changed) > 0 || len(mismatch.Added) > 0 {
+		return mismatch
+	}
+
+	return nil
+}
+
+// countingWriter counts what is written to w, and remembers the first error.
+type countingWriter struct {
+	w   io.Writer
+	n   int64
+	err error
+}
+
+func (c *countingWriter) Write(buf []byte) (int, error) {
+	if c.err != nil {
+		return 0, c.err
+	}
+
+	n, err := c.w.Write(buf)
+	c.n += int64(n)
+	c.err = err
+
+	return n, err
+}
@@ -0,0 +1,92 @@
+package s3fs_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/packrat386/s3fs"
+	"github.com/packrat386/s3fs/s3fstest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateManifest(t *testing.T) {
+	store := s3fstest.NewMemStore()
+	store.WriteFile("site/index.html", "<html>")
+	store.WriteFile("site/css/main.css", "body {}")
+	store.WriteFile("site/weird\tname.txt", "tab")
+	store.WriteFile("other.txt", "other")
+	store.Put(context.Background(), "site/hashed.txt", bytes.NewReader([]byte("h")), s3fs.PutOptions{
+		Metadata: map[string]string{"sha256": "aa"},
+	})
+
+	myFS := s3fs.NewFS(store)
+	ctx := context.Background()
+
+	m, err := myFS.GenerateManifest(ctx, "site")
+	require.Nil(t, err)
+	require.Equal(t, 4, len(m.Entries))
+	require.Equal(t, "site/css/main.css", m.Entries[0].Path)
+	require.Equal(t, int64(7), m.Entries[0].Size)
+	require.Equal(t, "aa", m.Entries[1].SHA256)
+
+	// the text form round trips, and is the same every time
+	buf := &bytes.Buffer{}
+	n, err := m.WriteTo(buf)
+	require.Nil(t, err)
+	require.Equal(t, int64(buf.Len()), n)
+
+	parsed, err := s3fs.ParseManifest(bytes.NewReader(buf.Bytes()))
+	require.Nil(t, err)
+	require.Equal(t, m, parsed)
+
+	again := &bytes.Buffer{}
+	parsed.WriteTo(again)
+	require.Equal(t, buf.String(), again.String())
+
+	require.Nil(t, myFS.VerifyManifest(ctx, parsed))
+
+	require.Nil(t, myFS.WriteFile("site/index.html", []byte("<html>changed")))
+	require.Nil(t, myFS.WriteFile("site/new.html", []byte("<html>")))
+	require.Nil(t, myFS.Remove("site/css/main.css"))
+
+	mismatch := &s3fs.ManifestMismatchError{}
+	require.True(t, errors.As(myFS.VerifyManifest(ctx, parsed), &mismatch))
+	require.Equal(t, []string{"site/css/main.css"}, mismatch.Missing)
+	require.Equal(t, []string{"site/index.html"}, mismatch.Changed)
+	require.Equal(t, []string{"site/new.html"}, mismatch.Added)
+
+	_, err = s3fs.NewPinnedFS(myFS, m.ETags()).Open("site/index.html")
+	require.True(t, errors.Is(err, s3fs.ErrContentChanged))
+}
+
+func TestFingerprint(t *testing.T) {
+	store := s3fstest.NewMemStore()
+	store.WriteFile("data/a.csv", "1,2,3")
+	store.WriteFile("data/b.csv", "4,5,6")
+	store.WriteFile("elsewhere.txt", "x")
+
+	myFS := s3fs.NewFS(store)
+	ctx := context.Background()
+
+	first, err := myFS.Fingerprint(ctx, "data")
+	require.Nil(t, err)
+	require.Len(t, first, 64)
+
+	same, err := myFS.Fingerprint(ctx, "data")
+	require.Nil(t, err)
+	require.Equal(t, first, same)
+
+	store.WriteFile("elsewhere.txt", "y")
+
+	same, err = myFS.Fingerprint(ctx, "data")
+	require.Nil(t, err)
+	require.Equal(t, first, same)
+
+	store.WriteFile("data/b.csv", "4,5,7")
+
+	changed, err := myFS.Fingerprint(ctx, "data")
+	require.Nil(t, err)
+	require.NotEqual(t, first, changed)
+}
@@ -0,0 +1,85 @@
+package s3fs
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+)
+
+// ErrTooLarge is returned when opening a file bigger than WithMaxOpenSize allows.
+var ErrTooLarge = errors.New("file too large")
+
+// WithMaxOpenSize makes opening files of more than n bytes fail with an error
+// wrapping ErrTooLarge, so a bad name in user input can't make a memory constrained
+// service stream a huge object. The size comes from the response to the GET, whose
+// body is closed without being read, so a refused file costs a request but no
+// transfer. For encrypted and compressed files that is the size of the stored
+// object, and reading a compressed file whose original size wasn't recorded fails
+// with ErrTooLarge once more than n bytes have been read. Directories and Stat
+// aren't affected.
+func WithMaxOpenSize(n int64) Option {
+	return func(s *S3FS) {
+		s.maxOpenSize = n
+	}
+}
+
+// checkSize returns an error if the object at key, of size bytes, is bigger than
+// WithMaxOpenSize allows. The open paths call it before reading any of the body.
+func (s *S3FS) checkSize(key string, size int64) error {
+	if s.maxOpenSize <= 0 || size <= s.maxOpenSize {
+		return nil
+	}
+
+	return tooLarge(key, size, s.maxOpenSize)
+}
+
+func tooLarge(name string, size, max int64) error {
+	return &fs.PathError{
+		Op:   "open",
+		Path: name,
+		Err:  fmt.Errorf("%w: %d bytes is more than %d", ErrTooLarge, size, max),
+	}
+}
+
+// sizeLimitedReader fails with ErrTooLarge once more than max bytes are read from r,
+// for files whose size isn't known until they have been read.
+type sizeLimitedReader struct {
+	r    io.Reader
+	name string
+	max  int64
+	n    int64
+}
+
+func (r *sizeLimitedReader) Read(buf []byte) (int, error) {
+	n, err := r.r.Read(buf)
+	r.n += int64(n)
+
+	if r.n > r.max {
+		return 0, tooLarge(r.name, r.n, r.max)
+	}
+
+	return n, err
+}
+
+// limitSize returns the result of opening name, unless it's a file bigger than
+// WithMaxOpenSize allows, which is closed. The open paths have already checked the
+// size of the stored object, this checks the size of the file read from it.
+func (s *S3FS) limitSize(name string, f fs.File, err error) (fs.File, error) {
+	if err != nil || s.maxOpenSize <= 0 {
+		return f, err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	if !info.IsDir() && info.Size() > s.maxOpenSize {
+		f.Close()
+		return nil, tooLarge(name, info.Size(), s.maxOpenSize)
+	}
+
+	return f, nil
+}
@@ -0,0 +1,121 @@
+package s3fs_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"io/fs"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/packrat386/s3fs"
+	"github.com/packrat386/s3fs/s3fstest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithMaxOpenSize(t *testing.T) {
+	store := s3fstest.NewMemStore()
+	store.WriteFile("small.txt", "hello")
+	store.WriteFile("big.bin", strings.Repeat("x", 1000))
+	store.WriteFile("dir/big.bin", strings.Repeat("x", 1000))
+
+	myFS := s3fs.NewFS(store, s3fs.WithMaxOpenSize(100))
+
+	data, err := fs.ReadFile(myFS, "small.txt")
+	require.Nil(t, err)
+	require.Equal(t, "hello", string(data))
+
+	_, err = myFS.Open("big.bin")
+	require.ErrorIs(t, err, s3fs.ErrTooLarge)
+
+	_, err = myFS.OpenIf(context.Background(), "big.bin", s3fs.ReadConditions{})
+	require.ErrorIs(t, err, s3fs.ErrTooLarge)
+
+	// directories and stats aren't limited
+	entries, err := fs.ReadDir(myFS, "dir")
+	require.Nil(t, err)
+	require.Len(t, entries, 1)
+
+	info, err := myFS.StatFile(context.Background(), "big.bin")
+	require.Nil(t, err)
+	require.Equal(t, int64(1000), info.Size())
+
+	unlimited := s3fs.NewFS(store)
+	data, err = fs.ReadFile(unlimited, "big.bin")
+	require.Nil(t, err)
+	require.Len(t, data, 1000)
+}
+
+// countingStore counts the bytes read from the bodies of the objects it gets.
+type countingStore struct {
+	*s3fstest.MemStore
+	read int64
+}
+
+func (c *countingStore) Get(ctx context.Context, key string, opts s3fs.GetOptions) (*s3fs.Object, error) {
+	object, err := c.MemStore.Get(ctx, key, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	object.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.TeeReader(object.Body, writerFunc(func(p []byte) (int, error) {
+		atomic.AddInt64(&c.read, int64(len(p)))
+		return len(p), nil
+	})), object.Body}
+
+	return object, nil
+}
+
+type writerFunc func([]byte) (int, error)
+
+func (f writerFunc) Write(p []byte) (int, error) {
+	return f(p)
+}
+
+func TestWithMaxOpenSize_NoTransfer(t *testing.T) {
+	for name, opt := range map[string]s3fs.Option{
+		"plain":     s3fs.WithMaxOpenSize(100),
+		"cache":     s3fs.WithCache(s3fs.NewMemoryCache(1<<20), time.Minute),
+		"streaming": s3fs.WithStreamingConcurrency(4, 10),
+		"columnar":  s3fs.WithColumnarAccess(),
+		"chunked":   s3fs.WithChunking(10),
+	} {
+		t.Run(name, func(t *testing.T) {
+			store := &countingStore{MemStore: s3fstest.NewMemStore()}
+			store.WriteFile("big.bin", strings.Repeat("x", 1000))
+
+			myFS := s3fs.NewFS(store, opt, s3fs.WithMaxOpenSize(100))
+
+			_, err := myFS.Open("big.bin")
+			require.ErrorIs(t, err, s3fs.ErrTooLarge)
+			require.Equal(t, int64(0), atomic.LoadInt64(&store.read))
+		})
+	}
+}
+
+func TestWithMaxOpenSize_UnknownSize(t *testing.T) {
+	compressed := &bytes.Buffer{}
+	zw := gzip.NewWriter(compressed)
+	_, err := zw.Write([]byte(strings.Repeat("x", 1000)))
+	require.Nil(t, err)
+	require.Nil(t, zw.Close())
+
+	// compressed without recording the original size, as streamed writes are
+	store := s3fstest.NewMemStore()
+	_, err = store.Put(context.Background(), "big.txt", compressed, s3fs.PutOptions{
+		ContentEncoding: "gzip",
+		Metadata:        map[string]string{"s3fs-compression": "gzip"},
+	})
+	require.Nil(t, err)
+
+	myFS := s3fs.NewFS(store, s3fs.WithWriteCompression(s3fs.Gzip), s3fs.WithMaxOpenSize(100))
+
+	_, err = fs.ReadFile(myFS, "big.txt")
+	require.ErrorIs(t, err, s3fs.ErrTooLarge)
+}
@@ -0,0 +1,119 @@
+package s3fs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+)
+
+// ErrMetadataOnly is returned by reads of files opened through a MetadataOnlyFS.
+var ErrMetadataOnly = errors.New("file contents can't be read through a metadata-only FS")
+
+// MetadataOnlyFS is a view of an FS that can list directories and stat files but
+// never downloads a file, for code like indexers that should only ever cost LISTs
+// and HEADs. Files it opens can be Stat'd, but reading them fails with an error
+// wrapping ErrMetadataOnly, and like a ReadOnlyFS neither it nor its files can be
+// type asserted to anything that could get at the contents. Create one with the
+// MetadataOnly method of an FS.
+type MetadataOnlyFS struct {
+	fsys *S3FS
+}
+
+// MetadataOnly returns a view of the FS that can't read the contents of files.
+func (s *S3FS) MetadataOnly() *MetadataOnlyFS {
+	return &MetadataOnlyFS{fsys: s}
+}
+
+// Open opens name without downloading anything: files are HEADed and directories
+// listed.
+func (m *MetadataOnlyFS) Open(name string) (fs.File, error) {
+	s := m.fsys
+	if s.bucketErr != nil {
+		return nil, s.bucketErr
+	}
+
+	if err := s.checkOpen(); err != nil {
+		return nil, err
+	}
+
+	key, err := s.key("open", name)
+	if err != nil {
+		return nil, err
+	}
+
+	// offline files are read from disk, so they cost nothing either way
+	if s.offline {
+		f, err := openOffline(s, key)
+		if err != nil {
+			return nil, err
+		}
+
+		return metadataOnly(name, f)
+	}
+
+	info, err := s.StatFile(context.Background(), name)
+	if err == nil {
+		return &metadataFile{name: name, info: info}, nil
+	}
+
+	if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	if key != "" {
+		_, dirMatch, err := s.lookup(context.Background(), key)
+		if err != nil {
+			return nil, fmt.Errorf("could not list s3 objects: %w", err)
+		}
+
+		if !dirMatch {
+			return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+		}
+
+		key += "/"
+	}
+
+	s.auditRead(OpOpen, key)
+
+	f, err := openDir(s, key)
+	if err != nil {
+		return nil, err
+	}
+
+	return metadataOnly(name, f)
+}
+
+// metadataOnly hides everything about f but its metadata and, if it's a directory,
+// its entries.
+func metadataOnly(name string, f fs.File) (fs.File, error) {
+	if d, ok := f.(fs.ReadDirFile); ok {
+		return readOnlyDir{d}, nil
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	return &metadataFile{name: name, info: info}, nil
+}
+
+// metadataFile is a file opened through a MetadataOnlyFS.
+type metadataFile struct {
+	name string
+	info fs.FileInfo
+}
+
+func (f *metadataFile) Stat() (fs.FileInfo, error) {
+	return f.info, nil
+}
+
+func (f *metadataFile) Read(buf []byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: f.name, Err: ErrMetadataOnly}
+}
+
+func (f *metadataFile) Close() error {
+	return nil
+}
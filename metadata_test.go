@@ -0,0 +1,62 @@
+package s3fs_test
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"testing"
+
+	"github.com/packrat386/s3fs"
+	"github.com/packrat386/s3fs/s3fstest"
+	"github.com/stretchr/testify/require"
+)
+
+// getlessStore fails every Get.
+type getlessStore struct {
+	*s3fstest.MemStore
+}
+
+func (getlessStore) Get(ctx context.Context, key string, opts s3fs.GetOptions) (*s3fs.Object, error) {
+	return nil, errors.New("unexpected GET of " + key)
+}
+
+func TestMetadataOnly(t *testing.T) {
+	store := s3fstest.NewMemStore()
+	store.WriteFile("docs/a.txt", "aaa")
+	store.WriteFile("docs/sub/b.txt", "b")
+
+	meta := s3fs.NewFS(getlessStore{store}).MetadataOnly()
+
+	info, err := fs.Stat(meta, "docs/a.txt")
+	require.Nil(t, err)
+	require.Equal(t, int64(3), info.Size())
+	require.False(t, info.IsDir())
+
+	info, err = fs.Stat(meta, "docs")
+	require.Nil(t, err)
+	require.True(t, info.IsDir())
+
+	entries, err := fs.ReadDir(meta, "docs")
+	require.Nil(t, err)
+	require.Len(t, entries, 2)
+
+	walked := []string{}
+	err = fs.WalkDir(meta, ".", func(name string, d fs.DirEntry, err error) error {
+		walked = append(walked, name)
+		return err
+	})
+	require.Nil(t, err)
+	require.Equal(t, []string{".", "docs", "docs/a.txt", "docs/sub", "docs/sub/b.txt"}, walked)
+
+	_, err = fs.ReadFile(meta, "docs/a.txt")
+	require.ErrorIs(t, err, s3fs.ErrMetadataOnly)
+
+	f, err := meta.Open("docs/a.txt")
+	require.Nil(t, err)
+	_, ok := f.(s3fs.StatsFile)
+	require.False(t, ok)
+	require.Nil(t, f.Close())
+
+	_, err = meta.Open("missing")
+	require.ErrorIs(t, err, fs.ErrNotExist)
+}
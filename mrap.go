@@ -0,0 +1,329 @@
+package s3fs
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/arn"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/request"
+	v4 "github.com/aws/aws-sdk-go/aws/signer/v4"
+)
+
+// sigV4AAlgorithm is the name of SigV4A, the asymmetric variant of SigV4 that signs
+// requests for every region at once, which multi-region access points require.
+const sigV4AAlgorithm = "AWS4-ECDSA-P256-SHA256"
+
+// multiRegionAccessPoint sends requests to a multi-region access point. This version
+// of the SDK can neither resolve their ARNs, which have no region, nor sign requests
+// with SigV4A, so requests are built for the access point's alias as a bucket and
+// then sent to its global endpoint, signed by sign.
+type multiRegionAccessPoint struct {
+	// arn is the access point's ARN, and alias its alias, like "mfzwi23gnjvgw.mrap"
+	arn   string
+	alias string
+	host  string
+
+	// signingKey is derived from keyCreds, the last credentials used
+	mu         sync.Mutex
+	keyCreds   credentials.Value
+	signingKey *ecdsa.PrivateKey
+}
+
+// isMultiRegionAccessPoint reports whether a is the ARN of a multi-region access
+// point, which unlike other access points has no region.
+func isMultiRegionAccessPoint(a arn.ARN) bool {
+	return a.Service == "s3" && a.Region == "" && strings.HasPrefix(a.Resource, "accesspoint")
+}
+
+// newMultiRegionAccessPoint returns the multi-region access point bucket is the ARN
+// of, or nil if it isn't one.
+func newMultiRegionAccessPoint(bucket string) *multiRegionAccessPoint {
+	a, err := arn.Parse(bucket)
+	if err != nil || !isMultiRegionAccessPoint(a) {
+		return nil
+	}
+
+	alias := strings.TrimPrefix(strings.TrimPrefix(a.Resource, "accesspoint"), "/")
+	alias = strings.TrimPrefix(alias, ":")
+
+	return &multiRegionAccessPoint{
+		arn:   bucket,
+		alias: alias,
+		host:  alias + ".accesspoint.s3-global.amazonaws.com",
+	}
+}
+
+// option routes a request to the access point and signs it with SigV4A.
+func (m *multiRegionAccessPoint) option(r *request.Request) {
+	// path style keeps the alias, which has a dot in it, out of the host the SDK
+	// builds, so it's in a known place to take it out of the path
+	r.Config.S3ForcePathStyle = aws.Bool(true)
+	r.Config.S3UseAccelerate = aws.Bool(false)
+
+	r.Handlers.Build.PushBack(func(r *request.Request) {
+		moveToHost(r, m.host, m.alias)
+	})
+	r.Handlers.Sign.Swap(v4.SignRequestHandler.Name, request.NamedHandler{
+		Name: "s3fs.SigV4AHandler",
+		Fn:   m.sign,
+	})
+}
+
+// sign signs the request with SigV4A for all regions, in the Authorization header or,
+// when it's being presigned, in the query.
+func (m *multiRegionAccessPoint) sign(r *request.Request) {
+	if r.Config.Credentials == credentials.AnonymousCredentials {
+		return
+	}
+
+	creds, err := r.Config.Credentials.GetWithContext(r.Context())
+	if err != nil {
+		r.Error = fmt.Errorf("could not get credentials: %w", err)
+		return
+	}
+
+	key, err := m.key(creds)
+	if err != nil {
+		r.Error = err
+		return
+	}
+
+	now := time.Now().UTC()
+	if err := signV4A(r.HTTPRequest, r.GetBody(), key, creds, now, r.ExpireTime); err != nil {
+		r.Error = err
+		return
+	}
+
+	r.LastSignedAt = now
+}
+
+func (m *multiRegionAccessPoint) key(creds credentials.Value) (*ecdsa.PrivateKey, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.signingKey != nil && m.keyCreds.AccessKeyID == creds.AccessKeyID && m.keyCreds.SecretAccessKey == creds.SecretAccessKey {
+		return m.signingKey, nil
+	}
+
+	key, err := deriveSigV4AKey(creds.AccessKeyID, creds.SecretAccessKey)
+	if err != nil {
+		return nil, err
+	}
+
+	m.keyCreds = creds
+	m.signingKey = key
+
+	return key, nil
+}
+
+// deriveSigV4AKey derives the P-256 key that SigV4A signs with from an access key
+// pair, with the counter mode HMAC-SHA256 key derivation of NIST SP 800-108.
+func deriveSigV4AKey(accessKeyID, secretAccessKey string) (*ecdsa.PrivateKey, error) {
+	curve := elliptic.P256()
+	nMinusTwo := new(big.Int).Sub(curve.Params().N, big.NewInt(2))
+
+	for counter := 1; counter <= 0xff; counter++ {
+		context := append([]byte(accessKeyID), byte(counter))
+
+		fixed := bytes.Buffer{}
+		fixed.WriteString(sigV4AAlgorithm)
+		fixed.WriteByte(0)
+		fixed.Write(context)
+		binary.Write(&fixed, binary.BigEndian, int32(256))
+
+		mac := hmac.New(sha256.New, []byte("AWS4A"+secretAccessKey))
+		binary.Write(mac, binary.BigEndian, int32(1))
+		mac.Write(fixed.Bytes())
+
+		candidate := new(big.Int).SetBytes(mac.Sum(nil))
+		if candidate.Cmp(nMinusTwo) >= 0 {
+			continue
+		}
+
+		key := &ecdsa.PrivateKey{D: candidate.Add(candidate, big.NewInt(1))}
+		key.PublicKey.Curve = curve
+		key.PublicKey.X, key.PublicKey.Y = curve.ScalarBaseMult(key.D.Bytes())
+
+		return key, nil
+	}
+
+	return nil, fmt.Errorf("could not derive SigV4A key")
+}
+
+// signV4A signs req, whose body is body, with key for every region. If expires isn't
+// zero, req is presigned for that long instead.
+func signV4A(req *http.Request, body io.ReadSeeker, key *ecdsa.PrivateKey, creds credentials.Value, now time.Time, expires time.Duration) error {
+	amzDate := now.Format("20060102T150405Z")
+	scope := now.Format("20060102") + "/s3/aws4_request"
+	presign := expires > 0
+
+	query := req.URL.Query()
+	if presign {
+		query.Set("X-Amz-Algorithm", sigV4AAlgorithm)
+		query.Set("X-Amz-Credential", creds.AccessKeyID+"/"+scope)
+		query.Set("X-Amz-Date", amzDate)
+		query.Set("X-Amz-Expires", strconv.FormatInt(int64(expires/time.Second), 10))
+		query.Set("X-Amz-Region-Set", "*")
+		if creds.SessionToken != "" {
+			query.Set("X-Amz-Security-Token", creds.SessionToken)
+		}
+	} else {
+		req.Header.Set("X-Amz-Date", amzDate)
+		req.Header.Set("X-Amz-Region-Set", "*")
+		if creds.SessionToken != "" {
+			req.Header.Set("X-Amz-Security-Token", creds.SessionToken)
+		}
+	}
+
+	payloadHash := req.Header.Get("X-Amz-Content-Sha256")
+	if payloadHash == "" {
+		var err error
+		payloadHash, err = hashPayload(body, presign)
+		if err != nil {
+			return err
+		}
+
+		if !presign {
+			req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+		}
+	}
+
+	signedHeaders, canonicalHeaders := canonicalHeaders(req)
+	if presign {
+		query.Set("X-Amz-SignedHeaders", signedHeaders)
+	}
+
+	req.URL.RawQuery = strings.Replace(query.Encode(), "+", "%20", -1)
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders + "\n",
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	signature, err := sigV4ASignature(key, amzDate, scope, canonicalRequest)
+	if err != nil {
+		return err
+	}
+
+	if presign {
+		req.URL.RawQuery += "&X-Amz-Signature=" + signature
+		return nil
+	}
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		sigV4AAlgorithm, creds.AccessKeyID, scope, signedHeaders, signature,
+	))
+
+	return nil
+}
+
+// sigV4AStringToSign is what SigV4A signs a digest of for canonicalRequest.
+func sigV4AStringToSign(amzDate, scope, canonicalRequest string) string {
+	hash := sha256.Sum256([]byte(canonicalRequest))
+
+	return strings.Join([]string{sigV4AAlgorithm, amzDate, scope, hex.EncodeToString(hash[:])}, "\n")
+}
+
+func sigV4ASignature(key *ecdsa.PrivateKey, amzDate, scope, canonicalRequest string) (string, error) {
+	digest := sha256.Sum256([]byte(sigV4AStringToSign(amzDate, scope, canonicalRequest)))
+
+	signature, err := ecdsa.SignASN1(rand.Reader, key, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("could not sign request: %w", err)
+	}
+
+	return hex.EncodeToString(signature), nil
+}
+
+// hashPayload returns the hex SHA-256 of body, leaving it where it was, or
+// UNSIGNED-PAYLOAD for presigned requests and bodies that can't be read twice.
+func hashPayload(body io.ReadSeeker, presign bool) (string, error) {
+	if presign || (body != nil && !aws.IsReaderSeekable(body)) {
+		return "UNSIGNED-PAYLOAD", nil
+	}
+
+	hash := sha256.New()
+	if body != nil {
+		start, err := body.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return "", err
+		}
+
+		if _, err := io.Copy(hash, body); err != nil {
+			return "", err
+		}
+
+		if _, err := body.Seek(start, io.SeekStart); err != nil {
+			return "", err
+		}
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// unsignedHeaders are headers that proxies may change, which SigV4 leaves out.
+var unsignedHeaders = map[string]bool{
+	"authorization":   true,
+	"user-agent":      true,
+	"x-amzn-trace-id": true,
+}
+
+// canonicalHeaders returns the names of the headers of req that are signed, and the
+// canonical form of them.
+func canonicalHeaders(req *http.Request) (string, string) {
+	values := map[string][]string{}
+	for k, v := range req.Header {
+		k = strings.ToLower(k)
+		if !unsignedHeaders[k] {
+			values[k] = append(values[k], v...)
+		}
+	}
+
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+
+	values["host"] = []string{host}
+
+	names := make([]string, 0, len(values))
+	for k := range values {
+		names = append(names, k)
+	}
+
+	sort.Strings(names)
+
+	lines := make([]string, len(names))
+	for i, k := range names {
+		vs := make([]string, len(values[k]))
+		for j, v := range values[k] {
+			vs[j] = strings.Join(strings.Fields(v), " ")
+		}
+
+		lines[i] = k + ":" + strings.Join(vs, ",")
+	}
+
+	return strings.Join(names, ";"), strings.Join(lines, "\n")
+}
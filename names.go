@@ -0,0 +1,122 @@
+package s3fs
+
+import (
+	"io/fs"
+	"strings"
+)
+
+// NamePolicy turns a name passed to the FS into the key it refers to, or reports that
+// the name isn't acceptable. The root of the bucket is the empty key.
+type NamePolicy func(name string) (key string, ok bool)
+
+// StrictNames accepts exactly the names that fs.ValidPath does. It is the default.
+func StrictNames(name string) (string, bool) {
+	if !fs.ValidPath(name) {
+		return "", false
+	}
+
+	if name == "." {
+		return "", true
+	}
+
+	return name, true
+}
+
+// RelaxedNames accepts everything StrictNames does, plus names with a trailing slash,
+// which refer to keys that end in a slash. Such keys are often left behind by tools
+// that create "folders" as empty objects, and can't be opened otherwise.
+func RelaxedNames(name string) (string, bool) {
+	if trimmed := strings.TrimSuffix(name, "/"); trimmed != name {
+		key, ok := StrictNames(trimmed)
+		if !ok || key == "" {
+			return "", false
+		}
+
+		return key + "/", true
+	}
+
+	return StrictNames(name)
+}
+
+// WindowsNames accepts everything StrictNames does, plus relative Windows paths with
+// backslashes between their elements, such as `configs\app.yaml`, which refer to the
+// same keys as the names with forward slashes. It suits programs that pass through
+// paths typed by users on Windows. Paths with a drive letter, such as `C:\app.yaml`,
+// or that start with a backslash aren't relative to anything in the bucket, so they
+// are rejected.
+func WindowsNames(name string) (string, bool) {
+	if len(name) >= 2 && name[1] == ':' && isDriveLetter(name[0]) {
+		return "", false
+	}
+
+	return StrictNames(strings.ReplaceAll(name, `\`, "/"))
+}
+
+func isDriveLetter(c byte) bool {
+	return ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z')
+}
+
+// LeadingSlash returns a NamePolicy that strips a single leading slash from names
+// before passing them to policy, or StrictNames if policy is nil, so that callers can
+// pass absolute looking names like "/configs/app.yaml". "/" is the root. Names are
+// still held to policy once the slash is gone, so "//configs" is rejected. This
+// breaks the io/fs rule that such names are invalid, so it is only used when asked
+// for with WithNamePolicy, and WithStrictPaths rejects them regardless.
+func LeadingSlash(policy NamePolicy) NamePolicy {
+	if policy == nil {
+		policy = StrictNames
+	}
+
+	return func(name string) (string, bool) {
+		if trimmed := strings.TrimPrefix(name, "/"); trimmed != name {
+			if trimmed == "" {
+				trimmed = "."
+			}
+
+			return policy(trimmed)
+		}
+
+		return policy(name)
+	}
+}
+
+// WithNamePolicy sets how names passed to the FS are validated and turned into keys.
+// Names that policy rejects fail with an *fs.PathError wrapping fs.ErrInvalid.
+func WithNamePolicy(policy NamePolicy) Option {
+	return func(s *S3FS) {
+		s.namePolicy = policy
+	}
+}
+
+// WithStrictPaths makes the FS check every name passed to it with fs.ValidPath before
+// the NamePolicy sees it, so names that the io/fs contract forbids, such as ones with
+// leading or trailing slashes or empty, "." or ".." elements, fail the same way from
+// every method, with an *fs.PathError wrapping fs.ErrInvalid, whatever the policy or
+// the store would have made of them. This includes the prefixes taken by methods like
+// GenerateManifest and WalkParallel, and the names of a scoped FS, which would
+// otherwise fail with fs.ErrPermission.
+func WithStrictPaths() Option {
+	return func(s *S3FS) {
+		s.strictPaths = true
+	}
+}
+
+// key returns the key that name refers to, or an *fs.PathError for op if name isn't
+// valid.
+func (s *S3FS) key(op, name string) (string, error) {
+	if s.strictPaths && !fs.ValidPath(name) {
+		return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+
+	policy := s.namePolicy
+	if policy == nil {
+		policy = StrictNames
+	}
+
+	key, ok := policy(name)
+	if !ok {
+		return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+
+	return normalize(s.unicodeForm, key), nil
+}
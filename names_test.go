@@ -0,0 +1,136 @@
+package s3fs_test
+
+import (
+	"errors"
+	"io/fs"
+	"testing"
+
+	"github.com/packrat386/s3fs"
+	"github.com/packrat386/s3fs/s3fstest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNamePolicy(t *testing.T) {
+	for name, expected := range map[string]string{
+		".":       "",
+		"a/b.txt": "a/b.txt",
+		"weird/":  "",
+		"/a":      "",
+		"a//b":    "",
+		"./a":     "",
+		"a/../b":  "",
+		"":        "",
+	} {
+		key, ok := s3fs.StrictNames(name)
+		require.Equal(t, expected, key, name)
+		require.Equal(t, name == "." || expected != "", ok, name)
+	}
+
+	for name, expected := range map[string]string{
+		".":       "",
+		"a/b.txt": "a/b.txt",
+		"weird/":  "weird/",
+		"a/b/":    "a/b/",
+		"/":       "",
+		"./":      "",
+		"a//":     "",
+	} {
+		key, ok := s3fs.RelaxedNames(name)
+		require.Equal(t, expected, key, name)
+		require.Equal(t, name == "." || expected != "", ok, name)
+	}
+
+	for name, expected := range map[string]string{
+		".":                   "",
+		"a/b.txt":             "a/b.txt",
+		`configs\app.yaml`:    "configs/app.yaml",
+		`a\b/c.txt`:           "a/b/c.txt",
+		`C:\configs\app.yaml`: "",
+		`c:app.yaml`:          "",
+		`\configs\app.yaml`:   "",
+		`\\server\share`:      "",
+		`a\..\b`:              "",
+		`dir\`:                "",
+	} {
+		key, ok := s3fs.WindowsNames(name)
+		require.Equal(t, expected, key, name)
+		require.Equal(t, name == "." || expected != "", ok, name)
+	}
+
+	for name, expected := range map[string]string{
+		".":                 "",
+		"/":                 "",
+		"a/b.txt":           "a/b.txt",
+		"/configs/app.yaml": "configs/app.yaml",
+		"//configs":         "",
+		"/./configs":        "",
+		"/configs/":         "",
+	} {
+		key, ok := s3fs.LeadingSlash(nil)(name)
+		require.Equal(t, expected, key, name)
+		require.Equal(t, name == "." || name == "/" || expected != "", ok, name)
+	}
+
+	key, ok := s3fs.LeadingSlash(s3fs.RelaxedNames)("/configs/")
+	require.True(t, ok)
+	require.Equal(t, "configs/", key)
+}
+
+func TestWithNamePolicy(t *testing.T) {
+	store := s3fstest.NewMemStore()
+	store.WriteFile("weird/", `{"data":"weird"}`)
+
+	_, err := s3fs.NewFS(store).Open("weird/")
+	pathErr := &fs.PathError{}
+	require.True(t, errors.As(err, &pathErr))
+	require.Equal(t, "open", pathErr.Op)
+	require.Equal(t, "weird/", pathErr.Path)
+	require.True(t, errors.Is(err, fs.ErrInvalid))
+
+	err = s3fs.NewFS(store).WriteFile(".", nil)
+	require.True(t, errors.Is(err, fs.ErrInvalid))
+
+	data, err := fs.ReadFile(s3fs.NewFS(store, s3fs.WithNamePolicy(s3fs.RelaxedNames)), "weird/")
+	require.Nil(t, err)
+	require.Equal(t, `{"data":"weird"}`, string(data))
+}
+
+func TestWindowsNames(t *testing.T) {
+	store := s3fstest.NewMemStore()
+	store.WriteFile("configs/app.yaml", `{"data":"app"}`)
+
+	myFS := s3fs.NewFS(store, s3fs.WithNamePolicy(s3fs.WindowsNames))
+
+	data, err := fs.ReadFile(myFS, `configs\app.yaml`)
+	require.Nil(t, err)
+	require.Equal(t, `{"data":"app"}`, string(data))
+
+	info, err := fs.Stat(myFS, `configs\app.yaml`)
+	require.Nil(t, err)
+	require.Equal(t, "app.yaml", info.Name())
+
+	_, err = myFS.Open(`C:\configs\app.yaml`)
+	require.True(t, errors.Is(err, fs.ErrInvalid))
+}
+
+func TestLeadingSlash(t *testing.T) {
+	store := s3fstest.NewMemStore()
+	store.WriteFile("configs/app.yaml", `{"data":"app"}`)
+
+	_, err := s3fs.NewFS(store).Open("/configs/app.yaml")
+	require.True(t, errors.Is(err, fs.ErrInvalid))
+
+	myFS := s3fs.NewFS(store, s3fs.WithNamePolicy(s3fs.LeadingSlash(nil)))
+
+	data, err := fs.ReadFile(myFS, "/configs/app.yaml")
+	require.Nil(t, err)
+	require.Equal(t, `{"data":"app"}`, string(data))
+
+	entries, err := fs.ReadDir(myFS, "/")
+	require.Nil(t, err)
+	require.Len(t, entries, 1)
+
+	strict := s3fs.NewFS(store, s3fs.WithNamePolicy(s3fs.LeadingSlash(nil)), s3fs.WithStrictPaths())
+	_, err = strict.Open("/configs/app.yaml")
+	require.True(t, errors.Is(err, fs.ErrInvalid))
+}
@@ -0,0 +1,207 @@
+package s3fs
+
+import (
+	"context"
+	"sort"
+	"unicode/utf8"
+)
+
+// UnicodeForm is a Unicode normalization form, for WithUnicodeNormalization.
+type UnicodeForm int
+
+const (
+	// NFC composes letters and their accents into single characters where possible, as
+	// most systems write them.
+	NFC UnicodeForm = iota + 1
+
+	// NFD decomposes letters into the letter followed by its accents, as macOS writes
+	// file names.
+	NFD
+)
+
+// WithUnicodeNormalization makes the FS normalize names to form before looking them
+// up, and the keys it lists to form before returning them as names, so that a name
+// with accents finds its key however either of them was encoded. Buckets that files
+// were uploaded to from macOS often have keys in NFD, which a name typed elsewhere in
+// NFC doesn't match byte for byte; WithUnicodeNormalization(NFD) finds them, and the
+// names ReadDir returns can be opened again. Files written through the FS get keys in
+// form too.
+//
+// The form should be the one the keys in the bucket are in, since a key in the other
+// form can be listed but not opened. Normalization follows the canonical
+// decompositions of Unicode 14.0, so it covers every script, including kana with
+// voicing marks and Indic scripts; compatibility forms like full width letters are
+// left as they are.
+func WithUnicodeNormalization(form UnicodeForm) Option {
+	return func(s *S3FS) {
+		s.unicodeForm = form
+	}
+}
+
+// normalizedStore normalizes the keys store lists.
+type normalizedStore struct {
+	ObjectStore
+
+	form UnicodeForm
+}
+
+func (n *normalizedStore) List(ctx context.Context, prefix string, opts ListOptions, fn func(*ListPage) bool) error {
+	return n.ObjectStore.List(ctx, prefix, opts, func(page *ListPage) bool {
+		out := &ListPage{NextContinuationToken: page.NextContinuationToken}
+
+		for _, obj := range page.Objects {
+			obj.Key = normalize(n.form, obj.Key)
+			out.Objects = append(out.Objects, obj)
+		}
+
+		for _, cp := range page.CommonPrefixes {
+			out.CommonPrefixes = append(out.CommonPrefixes, normalize(n.form, cp))
+		}
+
+		return fn(out)
+	})
+}
+
+const (
+	hangulBase  = 0xAC00
+	hangulLBase = 0x1100
+	hangulVBase = 0x1161
+	hangulTBase = 0x11A7
+	hangulV     = 21
+	hangulT     = 28
+	hangulN     = hangulV * hangulT
+	hangulCount = 19 * hangulN
+)
+
+// compositions maps each pair of characters to the character they compose into.
+var compositions = func() map[[2]rune]rune {
+	out := map[[2]rune]rune{}
+	for r, d := range decompositions {
+		if len(d) == 2 && !compositionExclusions[r] {
+			out[[2]rune{d[0], d[1]}] = r
+		}
+	}
+
+	return out
+}()
+
+// normalize returns s in form.
+func normalize(form UnicodeForm, s string) string {
+	if form == 0 || isASCII(s) {
+		return s
+	}
+
+	runes := []rune{}
+	for _, r := range s {
+		runes = decompose(runes, r)
+	}
+
+	orderMarks(runes)
+
+	if form == NFC {
+		runes = compose(runes)
+	}
+
+	return string(runes)
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+
+	return true
+}
+
+// decompose appends the full canonical decomposition of r to dst.
+func decompose(dst []rune, r rune) []rune {
+	if r >= hangulBase && r < hangulBase+hangulCount {
+		s := r - hangulBase
+		dst = append(dst, hangulLBase+s/hangulN, hangulVBase+(s%hangulN)/hangulT)
+		if t := s % hangulT; t != 0 {
+			dst = append(dst, hangulTBase+t)
+		}
+
+		return dst
+	}
+
+	d, ok := decompositions[r]
+	if !ok {
+		return append(dst, r)
+	}
+
+	for _, r := range d {
+		dst = decompose(dst, r)
+	}
+
+	return dst
+}
+
+// orderMarks puts each run of combining marks in runes into canonical order.
+func orderMarks(runes []rune) {
+	for start := 0; start < len(runes); start++ {
+		if combiningClasses[runes[start]] == 0 {
+			continue
+		}
+
+		end := start + 1
+		for end < len(runes) && combiningClasses[runes[end]] != 0 {
+			end++
+		}
+
+		marks := runes[start:end]
+		sort.SliceStable(marks, func(i, j int) bool {
+			return combiningClasses[marks[i]] < combiningClasses[marks[j]]
+		})
+
+		start = end
+	}
+}
+
+// compose canonically composes decomposed runes.
+func compose(runes []rune) []rune {
+	out := make([]rune, 0, len(runes))
+	starter := -1
+	var last uint8
+
+	for _, r := range runes {
+		class := combiningClasses[r]
+
+		// a mark is blocked from the starter by any mark between them of the same or a
+		// higher class, and anything is blocked by a starter
+		blocked := starter < 0 || (len(out)-1 != starter && (last == 0 || last >= class))
+
+		if !blocked {
+			if c, ok := composePair(out[starter], r); ok {
+				out[starter] = c
+				continue
+			}
+		}
+
+		if class == 0 {
+			starter = len(out)
+		}
+
+		last = class
+		out = append(out, r)
+	}
+
+	return out
+}
+
+// composePair returns the character a and b compose into, if any.
+func composePair(a, b rune) (rune, bool) {
+	if a >= hangulLBase && a < hangulLBase+19 && b >= hangulVBase && b < hangulVBase+hangulV {
+		return hangulBase + ((a-hangulLBase)*hangulV+b-hangulVBase)*hangulT, true
+	}
+
+	if a >= hangulBase && a < hangulBase+hangulCount && (a-hangulBase)%hangulT == 0 && b > hangulTBase && b < hangulTBase+hangulT {
+		return a + b - hangulTBase, true
+	}
+
+	c, ok := compositions[[2]rune{a, b}]
+
+	return c, ok
+}
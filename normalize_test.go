@@ -0,0 +1,42 @@
+package s3fs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalize(t *testing.T) {
+	cases := []struct {
+		nfc, nfd string
+	}{
+		{"café", "café"},
+		{"Ấ", "Ấ"},
+		{"ṩ", "ṩ"},
+		{"ậ", "ậ"},
+		{"Ǖ", "Ǖ"},
+		{"한글", "한글"},
+		{"\u304C", "\u304B\u3099"},
+		{"\u30D1\u30D1", "\u30CF\u309A\u30CF\u309A"},
+		{"\u0929", "\u0928\u093C"},
+		{"\u1F82", "\u03B1\u0313\u0300\u0345"},
+		{"\U0001109A", "\U00011099\U000110BA"},
+		{"plain/ascii.txt", "plain/ascii.txt"},
+	}
+
+	for _, c := range cases {
+		require.Equal(t, c.nfd, normalize(NFD, c.nfc), c.nfc)
+		require.Equal(t, c.nfd, normalize(NFD, c.nfd), c.nfc)
+		require.Equal(t, c.nfc, normalize(NFC, c.nfd), c.nfc)
+		require.Equal(t, c.nfc, normalize(NFC, c.nfc), c.nfc)
+	}
+
+	// marks out of canonical order are reordered
+	require.Equal(t, "ậ", normalize(NFC, "ậ"))
+
+	// singletons decompose but never compose back
+	require.Equal(t, "ʹ", normalize(NFC, "ʹ"))
+
+	// nor do characters excluded from composition, like Devanagari QA
+	require.Equal(t, "\u0915\u093C", normalize(NFC, "\u0958"))
+}
@@ -0,0 +1,93 @@
+package s3fs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"time"
+)
+
+// ObjectLock is the S3 Object Lock state of an object.
+type ObjectLock struct {
+	// Mode is the retention mode, "GOVERNANCE" or "COMPLIANCE", or empty if the object
+	// has no retention period.
+	Mode string
+
+	// RetainUntil is when the retention period ends.
+	RetainUntil time.Time
+
+	// LegalHold is whether the object is under a legal hold.
+	LegalHold bool
+}
+
+// Locked reports whether the object can't be deleted at time now.
+func (l ObjectLock) Locked(now time.Time) bool {
+	return l.LegalHold || (l.Mode != "" && now.Before(l.RetainUntil))
+}
+
+// ObjectLockedError is returned by attempts to delete a file protected by Object Lock.
+type ObjectLockedError struct {
+	Key  string
+	Lock ObjectLock
+}
+
+func (e *ObjectLockedError) Error() string {
+	if e.Lock.LegalHold {
+		return fmt.Sprintf("%s is locked: under legal hold", e.Key)
+	}
+
+	return fmt.Sprintf("%s is locked: %s retention until %s", e.Key, e.Lock.Mode, e.Lock.RetainUntil.Format(time.RFC3339))
+}
+
+// objectLockStore is implemented by stores that support Object Lock.
+type objectLockStore interface {
+	ObjectLock(ctx context.Context, key string) (ObjectLock, error)
+}
+
+// ObjectLock returns the Object Lock retention and legal hold status of the file at
+// name. Files in stores or buckets without Object Lock are never locked.
+func (s *S3FS) ObjectLock(name string) (ObjectLock, error) {
+	if s.bucketErr != nil {
+		return ObjectLock{}, s.bucketErr
+	}
+
+	key, err := s.key("objectlock", name)
+	if err != nil {
+		return ObjectLock{}, err
+	}
+
+	return s.objectLock(context.Background(), key)
+}
+
+func (s *S3FS) objectLock(ctx context.Context, key string) (ObjectLock, error) {
+	ls, ok := s.baseStore().(objectLockStore)
+	if !ok {
+		return ObjectLock{}, nil
+	}
+
+	lock, err := ls.ObjectLock(ctx, key)
+	if errors.Is(err, fs.ErrNotExist) {
+		return ObjectLock{}, nil
+	}
+
+	if err != nil {
+		return ObjectLock{}, fmt.Errorf("could not get object lock of %s: %w", key, err)
+	}
+
+	return lock, nil
+}
+
+// checkNotLocked returns an *ObjectLockedError if key is protected by Object Lock.
+func (s *S3FS) checkNotLocked(ctx context.Context, key string) error {
+	lock, err := s.objectLock(ctx, key)
+	if err != nil {
+		return err
+	}
+
+	if lock.Locked(s.clock.Now()) {
+		return &ObjectLockedError{Key: key, Lock: lock}
+	}
+
+	return nil
+}
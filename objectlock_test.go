@@ -0,0 +1,58 @@
+package s3fs_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/packrat386/s3fs"
+	"github.com/packrat386/s3fs/s3fstest"
+	"github.com/stretchr/testify/require"
+)
+
+// lockedStore reports the object locks in locks.
+type lockedStore struct {
+	*s3fstest.MemStore
+
+	locks map[string]s3fs.ObjectLock
+}
+
+func (l lockedStore) ObjectLock(ctx context.Context, key string) (s3fs.ObjectLock, error) {
+	return l.locks[key], nil
+}
+
+func TestObjectLock(t *testing.T) {
+	store := lockedStore{
+		MemStore: s3fstest.NewMemStore(),
+		locks: map[string]s3fs.ObjectLock{
+			"held.json":     {LegalHold: true},
+			"retained.json": {Mode: "COMPLIANCE", RetainUntil: time.Now().Add(time.Hour)},
+			"expired.json":  {Mode: "GOVERNANCE", RetainUntil: time.Now().Add(-time.Hour)},
+		},
+	}
+
+	for key := range store.locks {
+		store.WriteFile(key, `{}`)
+	}
+	store.WriteFile("free.json", `{}`)
+
+	myFS := s3fs.NewFS(store)
+
+	lock, err := myFS.ObjectLock("retained.json")
+	require.Nil(t, err)
+	require.Equal(t, "COMPLIANCE", lock.Mode)
+	require.True(t, lock.Locked(time.Now()))
+
+	lockErr := &s3fs.ObjectLockedError{}
+	require.True(t, errors.As(myFS.Remove("held.json"), &lockErr))
+	require.Equal(t, "held.json", lockErr.Key)
+	require.True(t, lockErr.Lock.LegalHold)
+
+	require.True(t, errors.As(myFS.Remove("retained.json"), &lockErr))
+	require.Nil(t, myFS.Remove("expired.json"))
+	require.Nil(t, myFS.Remove("free.json"))
+
+	_, err = store.Head(context.Background(), "retained.json")
+	require.Nil(t, err)
+}
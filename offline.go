@@ -0,0 +1,113 @@
+package s3fs
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"path"
+)
+
+// ErrOffline is returned by an FS in offline mode for anything that isn't cached.
+var ErrOffline = errors.New("not available offline")
+
+// WithOfflineMode makes the FS serve files, directories, and Stat calls only from the
+// cache set with WithCache, without making any requests. Files and directory
+// listings are cached whenever they are read while online, so an FS can be switched
+// to offline mode by creating a new one with the same (disk) cache. Anything that
+// isn't cached fails with an error wrapping ErrOffline.
+func WithOfflineMode() Option {
+	return func(s *S3FS) {
+		s.offline = true
+	}
+}
+
+// listingCacheKey is the cache key of the listing of the directory prefix. Keys of
+// valid names never start with a slash, so these can't collide with any of them.
+func listingCacheKey(prefix string) string {
+	return "//listing/" + prefix
+}
+
+// cacheListing stores the entries of the directory prefix in the cache, for offline
+// mode.
+func (s *S3FS) cacheListing(prefix string, entries []fs.DirEntry) {
+	if s.cache == nil {
+		return
+	}
+
+	buf := &bytes.Buffer{}
+	enc := json.NewEncoder(buf)
+	for _, e := range entries {
+		fi := e.(*s3FileInfo)
+		enc.Encode(spilledEntry{
+			Name:    fi.name,
+			Dir:     fi.IsDir(),
+			Size:    fi.size,
+			ModTime: fi.modTime,
+			Object:  fi.object,
+		})
+	}
+
+	s.cache.Put(listingCacheKey(prefix), &CacheEntry{Data: buf.Bytes(), Validated: s.clock.Now()})
+}
+
+// openOffline opens name from the cache.
+func openOffline(s *S3FS, name string) (fs.File, error) {
+	if s.cache == nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: ErrOffline}
+	}
+
+	if name != "" {
+		if entry, ok := s.cache.Get(name); ok {
+			return newCachedFile(s, entry), nil
+		}
+	}
+
+	prefix := name
+	if prefix != "" {
+		prefix += "/"
+	}
+
+	entry, ok := s.cache.Get(listingCacheKey(prefix))
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: ErrOffline}
+	}
+
+	entries := []fs.DirEntry{}
+	scanner := bufio.NewScanner(bytes.NewReader(entry.Data))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		e := spilledEntry{}
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, &fs.PathError{Op: "open", Path: name, Err: ErrOffline}
+		}
+
+		fi := &s3FileInfo{
+			name:    e.Name,
+			mode:    fs.FileMode(0400),
+			size:    e.Size,
+			modTime: e.ModTime,
+			object:  e.Object,
+			key:     prefix + e.Name,
+			loc:     s.location,
+		}
+
+		if e.Dir {
+			fi.mode |= fs.ModeDir
+			fi.key += "/"
+		}
+
+		entries = append(entries, fi)
+	}
+
+	return &s3Directory{
+		entries: entries,
+		fileInfo: s3FileInfo{
+			name: path.Base(prefix),
+			mode: fs.FileMode(0400) | fs.ModeDir,
+			key:  prefix,
+			loc:  s.location,
+		},
+	}, nil
+}
@@ -0,0 +1,100 @@
+package s3fs_test
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"testing"
+	"time"
+
+	"github.com/packrat386/s3fs"
+	"github.com/packrat386/s3fs/s3fstest"
+	"github.com/stretchr/testify/require"
+)
+
+// unreachableStore fails every read, like a store without a network connection.
+type unreachableStore struct {
+	*s3fstest.MemStore
+}
+
+var errUnreachable = errors.New("network is unreachable")
+
+func (unreachableStore) List(ctx context.Context, prefix string, opts s3fs.ListOptions, fn func(*s3fs.ListPage) bool) error {
+	return errUnreachable
+}
+
+func (unreachableStore) Head(ctx context.Context, key string) (s3fs.ObjectInfo, error) {
+	return s3fs.ObjectInfo{}, errUnreachable
+}
+
+func (unreachableStore) Get(ctx context.Context, key string, opts s3fs.GetOptions) (*s3fs.Object, error) {
+	return nil, errUnreachable
+}
+
+func TestWithOfflineMode(t *testing.T) {
+	dir := t.TempDir()
+
+	store := s3fstest.NewMemStore()
+	store.WriteFile("a.txt", "a")
+	store.WriteFile("docs/b.txt", "b")
+	store.WriteFile("docs/c.txt", "c")
+
+	cache, err := s3fs.NewDiskCache(dir, 1<<20)
+	require.Nil(t, err)
+
+	online := s3fs.NewFS(store, s3fs.WithCache(cache, time.Hour))
+
+	_, err = fs.ReadDir(online, ".")
+	require.Nil(t, err)
+	_, err = fs.ReadDir(online, "docs")
+	require.Nil(t, err)
+	_, err = fs.ReadFile(online, "docs/b.txt")
+	require.Nil(t, err)
+
+	cache, err = s3fs.NewDiskCache(dir, 1<<20)
+	require.Nil(t, err)
+
+	offline := s3fs.NewFS(
+		unreachableStore{s3fstest.NewMemStore()},
+		s3fs.WithCache(cache, time.Hour),
+		s3fs.WithOfflineMode(),
+	)
+
+	entries, err := fs.ReadDir(offline, ".")
+	require.Nil(t, err)
+	require.Equal(t, []string{"a.txt", "docs"}, entryNames(entries))
+
+	entries, err = fs.ReadDir(offline, "docs")
+	require.Nil(t, err)
+	require.Equal(t, []string{"b.txt", "c.txt"}, entryNames(entries))
+
+	data, err := fs.ReadFile(offline, "docs/b.txt")
+	require.Nil(t, err)
+	require.Equal(t, "b", string(data))
+
+	info, err := fs.Stat(offline, "docs")
+	require.Nil(t, err)
+	require.True(t, info.IsDir())
+
+	info, err = offline.StatFile(context.Background(), "docs/b.txt")
+	require.Nil(t, err)
+	require.Equal(t, int64(1), info.Size())
+
+	_, err = fs.ReadFile(offline, "docs/c.txt")
+	require.True(t, errors.Is(err, s3fs.ErrOffline))
+
+	_, err = fs.ReadDir(offline, "other")
+	require.True(t, errors.Is(err, s3fs.ErrOffline))
+
+	_, err = s3fs.NewFS(unreachableStore{s3fstest.NewMemStore()}, s3fs.WithOfflineMode()).Open("a.txt")
+	require.True(t, errors.Is(err, s3fs.ErrOffline))
+}
+
+func entryNames(entries []fs.DirEntry) []string {
+	names := []string{}
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+
+	return names
+}
@@ -0,0 +1,136 @@
+package s3fs
+
+import (
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/request"
+)
+
+// Option configures optional behavior of the FS returned by NewS3FS.
+type Option func(*S3FS)
+
+// defaultConcurrency is how many requests bulk operations make at once unless
+// WithConcurrency says otherwise.
+const defaultConcurrency = 8
+
+// WithConcurrency sets the maximum number of requests that bulk operations like
+// DownloadMany make at once.
+func WithConcurrency(n int) Option {
+	return func(s *S3FS) {
+		s.maxConcurrency = n
+	}
+}
+
+// WithDownloadManyProgress sets a callback that DownloadMany calls each time it
+// finishes with a name, successfully or not.
+func WithDownloadManyProgress(fn func(done, total int)) Option {
+	return func(s *S3FS) {
+		s.downloadProgress = fn
+	}
+}
+
+// ProgressFunc is told how many bytes of the file at path have been read so far and
+// how big the file is.
+type ProgressFunc func(path string, readBytes, totalBytes int64)
+
+// WithProgress sets a callback that is called every time bytes are read from an
+// opened file, e.g. to render a progress bar for large downloads.
+func WithProgress(fn ProgressFunc) Option {
+	return func(s *S3FS) {
+		s.progress = fn
+	}
+}
+
+// WithUploadHooks sets callbacks for the lifecycle of files written through the FS.
+func WithUploadHooks(hooks UploadHooks) Option {
+	return func(s *S3FS) {
+		s.uploadHooks = hooks
+	}
+}
+
+// WithSkipStorageClasses leaves objects in any of the given storage classes (e.g.
+// "GLACIER" and "DEEP_ARCHIVE") out of directory listings, so ReadDir and WalkDir
+// don't trip over objects that can't be read without a restore. They can still be
+// opened by name.
+func WithSkipStorageClasses(classes ...string) Option {
+	return func(s *S3FS) {
+		if s.skipStorageClasses == nil {
+			s.skipStorageClasses = map[string]bool{}
+		}
+
+		for _, class := range classes {
+			s.skipStorageClasses[class] = true
+		}
+	}
+}
+
+func (s *S3FS) concurrency() int {
+	if s.maxConcurrency <= 0 {
+		return defaultConcurrency
+	}
+
+	return s.maxConcurrency
+}
+
+// WithPrefixCredentials makes all requests for names under prefix use creds instead
+// of the client's credentials. This lets a multi-tenant service read each tenant's
+// prefix with that tenant's scoped role. If more than one prefix matches a name, the
+// longest one wins. It has no effect on FSs that aren't backed by S3.
+func WithPrefixCredentials(prefix string, creds *credentials.Credentials) Option {
+	return func(fsys *S3FS) {
+		s, ok := fsys.store.(*s3Store)
+		if !ok {
+			return
+		}
+
+		if s.prefixCreds == nil {
+			s.prefixCreds = map[string]*credentials.Credentials{}
+		}
+
+		s.prefixCreds[strings.Trim(prefix, "/")] = creds
+	}
+}
+
+// requestOptions returns the per request options that apply to name.
+func (s *s3Store) requestOptions(name string) []request.Option {
+	opts := []request.Option{}
+	if s.multiRegion != nil {
+		opts = append(opts, s.multiRegion.option)
+	}
+
+	if s.directory != nil {
+		opts = append(opts, s.directory.option)
+	}
+
+	var creds *credentials.Credentials
+	longest := -1
+
+	for prefix, c := range s.prefixCreds {
+		if len(prefix) <= longest || !hasPathPrefix(name, prefix) {
+			continue
+		}
+
+		creds = c
+		longest = len(prefix)
+	}
+
+	if creds != nil {
+		opts = append(opts, func(r *request.Request) {
+			r.Config.Credentials = creds
+		})
+	}
+
+	if s.scheduler != nil {
+		opts = append(opts, s.scheduler.option)
+	}
+
+	return opts
+}
+
+// hasPathPrefix reports whether name is prefix or is inside the directory prefix.
+func hasPathPrefix(name, prefix string) bool {
+	name = strings.TrimSuffix(name, "/")
+
+	return prefix == "" || name == prefix || strings.HasPrefix(name, prefix+"/")
+}
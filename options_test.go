@@ -0,0 +1,43 @@
+package s3fs
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithPrefixCredentials(t *testing.T) {
+	acme := credentials.NewStaticCredentials("acme", "secret", "")
+	acmeReports := credentials.NewStaticCredentials("acme-reports", "secret", "")
+	globex := credentials.NewStaticCredentials("globex", "secret", "")
+
+	s := NewS3FS(
+		nil,
+		"my-bucket",
+		WithPrefixCredentials("tenants/acme/", acme),
+		WithPrefixCredentials("tenants/acme/reports", acmeReports),
+		WithPrefixCredentials("tenants/globex", globex),
+	).store.(*s3Store)
+
+	credsFor := func(name string) *credentials.Credentials {
+		opts := s.requestOptions(name)
+		if len(opts) == 0 {
+			return nil
+		}
+
+		r := &request.Request{}
+		r.ApplyOptions(opts...)
+		return r.Config.Credentials
+	}
+
+	require.Equal(t, acme, credsFor("tenants/acme"))
+	require.Equal(t, acme, credsFor("tenants/acme/"))
+	require.Equal(t, acme, credsFor("tenants/acme/data.json"))
+	require.Equal(t, acmeReports, credsFor("tenants/acme/reports/q1.csv"))
+	require.Equal(t, globex, credsFor("tenants/globex/data.json"))
+	require.Nil(t, credsFor("tenants/acmecorp/data.json"))
+	require.Nil(t, credsFor("tenants"))
+	require.Nil(t, credsFor(""))
+}
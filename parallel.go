@@ -0,0 +1,203 @@
+package s3fs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"sync"
+)
+
+// WithStreamingConcurrency makes opened files fetch n ranges of partSize bytes at once
+// and reassemble them in order as they are read, because a single connection tops out
+// well below what the network can do. Files that fit in one range are streamed as
+// usual. Ranges are fetched no more than n ahead of the reader, so at most about n+1
+// of them are held in memory per file. If partSize isn't positive, ranges are 8 MiB.
+// This takes precedence over WithCache.
+func WithStreamingConcurrency(n int, partSize int64) Option {
+	return func(s *S3FS) {
+		if partSize <= 0 {
+			partSize = downloadPartSize
+		}
+
+		s.streamParts = n
+		s.streamPartSize = partSize
+	}
+}
+
+// openParallel opens the file at key, fetching the ranges after the first one
+// concurrently if there are any.
+func openParallel(ctx context.Context, s *S3FS, key string) (fs.File, error) {
+	stats := TransferStats{}
+	object, err := getObject(ctx, s.store, key, GetOptions{Length: s.streamPartSize}, &stats)
+	if errors.Is(err, ErrInvalidRange) {
+		// S3 won't give any range of an empty object, so just get the whole thing
+		object, err = getObject(ctx, s.store, key, GetOptions{}, &stats)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("error getting s3 object: %w", err)
+	}
+
+	if err := s.checkSize(key, object.Info.Size); err != nil {
+		object.Body.Close()
+		return nil, err
+	}
+
+	if object.Info.Size > s.streamPartSize {
+		body := newParallelBody(s, key, object, stats)
+		object = &Object{Body: body, Info: object.Info}
+	}
+
+	f := newS3File(s, key, object)
+	f.stats = stats
+
+	return f, nil
+}
+
+type partResult struct {
+	data []byte
+	err  error
+}
+
+// parallelBody is the body of an object fetched in concurrent ranges. Each range has
+// a channel its result is sent on, queued in order in parts. Along with the range the
+// reader is waiting for, parts has room for as many ranges as may be fetched at once.
+type parallelBody struct {
+	store    ObjectStore
+	key      string
+	etag     string
+	partSize int64
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	parts  chan chan partResult
+
+	cur []byte
+	err error
+
+	mu    sync.Mutex
+	stats TransferStats
+}
+
+// newParallelBody starts fetching the rest of the object whose first range is first.
+func newParallelBody(s *S3FS, key string, first *Object, stats TransferStats) *parallelBody {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	b := &parallelBody{
+		store:    s.store,
+		key:      key,
+		etag:     first.Info.ETag,
+		partSize: s.streamPartSize,
+		ctx:      ctx,
+		cancel:   cancel,
+		parts:    make(chan chan partResult, s.streamParts-1),
+		stats:    stats,
+	}
+
+	go b.fetchAll(first, first.Info.Size)
+
+	return b
+}
+
+func (b *parallelBody) fetchAll(first *Object, size int64) {
+	defer close(b.parts)
+
+	result := make(chan partResult, 1)
+	b.parts <- result
+
+	go func() {
+		defer first.Body.Close()
+
+		data, err := io.ReadAll(first.Body)
+		b.received(TransferStats{BytesRead: int64(len(data))})
+		result <- partResult{data: data, err: err}
+	}()
+
+	for off := b.partSize; off < size; off += b.partSize {
+		result := make(chan partResult, 1)
+
+		select {
+		case b.parts <- result:
+		case <-b.ctx.Done():
+			return
+		}
+
+		go func(off int64) {
+			data, err := b.fetch(off)
+			result <- partResult{data: data, err: err}
+		}(off)
+	}
+}
+
+func (b *parallelBody) fetch(off int64) ([]byte, error) {
+	stats := TransferStats{}
+	object, err := getObject(b.ctx, b.store, b.key, GetOptions{Offset: off, Length: b.partSize}, &stats)
+	b.received(stats)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not get %s at offset %d: %w", b.key, off, err)
+	}
+	defer object.Body.Close()
+
+	if object.Info.ETag != b.etag {
+		return nil, fmt.Errorf("%s changed since it was opened", b.key)
+	}
+
+	data, err := io.ReadAll(object.Body)
+	b.received(TransferStats{BytesRead: int64(len(data))})
+
+	if err != nil {
+		return nil, fmt.Errorf("could not read %s at offset %d: %w", b.key, off, err)
+	}
+
+	return data, nil
+}
+
+// received adds stats to the body's.
+func (b *parallelBody) received(stats TransferStats) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.stats.BytesRead += stats.BytesRead
+	b.stats.Requests += stats.Requests
+	b.stats.Retries += stats.Retries
+	b.stats.Latency += stats.Latency
+}
+
+func (b *parallelBody) transferStats() TransferStats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.stats
+}
+
+func (b *parallelBody) Read(buf []byte) (int, error) {
+	for len(b.cur) == 0 {
+		if b.err != nil {
+			return 0, b.err
+		}
+
+		result, ok := <-b.parts
+		if !ok {
+			b.err = io.EOF
+			continue
+		}
+
+		r := <-result
+		b.cur, b.err = r.data, r.err
+	}
+
+	n := copy(buf, b.cur)
+	b.cur = b.cur[n:]
+
+	return n, nil
+}
+
+func (b *parallelBody) Close() error {
+	b.cancel()
+	b.cur, b.err = nil, fs.ErrClosed
+
+	return nil
+}
@@ -0,0 +1,123 @@
+package s3fs_test
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/packrat386/s3fs"
+	"github.com/packrat386/s3fs/s3fstest"
+	"github.com/stretchr/testify/require"
+)
+
+// concurrencyStore records the most Gets it was serving at once.
+type concurrencyStore struct {
+	*s3fstest.MemStore
+
+	mu       sync.Mutex
+	inFlight int
+	max      int
+}
+
+func (s *concurrencyStore) Get(ctx context.Context, key string, opts s3fs.GetOptions) (*s3fs.Object, error) {
+	s.mu.Lock()
+	s.inFlight++
+	if s.inFlight > s.max {
+		s.max = s.inFlight
+	}
+	s.mu.Unlock()
+
+	time.Sleep(5 * time.Millisecond)
+
+	s.mu.Lock()
+	s.inFlight--
+	s.mu.Unlock()
+
+	return s.MemStore.Get(ctx, key, opts)
+}
+
+func TestWithStreamingConcurrency(t *testing.T) {
+	store := &concurrencyStore{MemStore: s3fstest.NewMemStore()}
+
+	content := strings.Repeat("0123456789", 10000)
+	store.WriteFile("big.bin", content)
+	store.WriteFile("small.bin", "small")
+
+	myFS := s3fs.NewFS(store, s3fs.WithStreamingConcurrency(4, 10000))
+
+	f, err := myFS.Open("big.bin")
+	require.Nil(t, err)
+	defer f.Close()
+
+	data, err := io.ReadAll(f)
+	require.Nil(t, err)
+	require.Equal(t, content, string(data))
+
+	require.Greater(t, store.max, 1)
+	require.LessOrEqual(t, store.max, 4)
+
+	stats := f.(s3fs.StatsFile).TransferStats()
+	require.Equal(t, 10, stats.Requests)
+	require.Equal(t, int64(len(content)), stats.BytesRead)
+
+	small, err := myFS.Open("small.bin")
+	require.Nil(t, err)
+	defer small.Close()
+
+	data, err = io.ReadAll(small)
+	require.Nil(t, err)
+	require.Equal(t, "small", string(data))
+}
+
+func TestWithStreamingConcurrency_Changed(t *testing.T) {
+	store := s3fstest.NewMemStore()
+	store.WriteFile("big.bin", strings.Repeat("a", 100000))
+
+	myFS := s3fs.NewFS(store, s3fs.WithStreamingConcurrency(2, 10000))
+
+	f, err := myFS.Open("big.bin")
+	require.Nil(t, err)
+	defer f.Close()
+
+	_, err = io.ReadFull(f, make([]byte, 10000))
+	require.Nil(t, err)
+
+	store.WriteFile("big.bin", strings.Repeat("b", 100000))
+
+	_, err = io.ReadAll(f)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "changed since it was opened")
+}
+
+func TestWithStreamingConcurrency_EarlyClose(t *testing.T) {
+	store := s3fstest.NewMemStore()
+	store.WriteFile("big.bin", strings.Repeat("a", 100000))
+
+	myFS := s3fs.NewFS(store, s3fs.WithStreamingConcurrency(2, 10000))
+
+	f, err := myFS.Open("big.bin")
+	require.Nil(t, err)
+
+	_, err = io.ReadFull(f, make([]byte, 5))
+	require.Nil(t, err)
+	require.Nil(t, f.Close())
+
+	_, err = f.Read(make([]byte, 5))
+	require.Error(t, err)
+}
+
+func TestWithStreamingConcurrency_EmptyFile(t *testing.T) {
+	store := s3fstest.NewMemStore()
+	store.StrictRanges = true
+	store.WriteFile("empty.bin", "")
+
+	myFS := s3fs.NewFS(store, s3fs.WithStreamingConcurrency(2, 10000))
+
+	data, err := fs.ReadFile(myFS, "empty.bin")
+	require.Nil(t, err)
+	require.Empty(t, data)
+}
@@ -0,0 +1,44 @@
+package s3fs
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// pingTimeout is how long Ping waits for S3 unless ctx has an earlier deadline.
+const pingTimeout = 5 * time.Second
+
+// Ping checks that the FS's bucket can be reached with its credentials, by listing at
+// most one key, so services can include S3 in their readiness probes. It gives up
+// after 5 seconds, or sooner if ctx says so.
+func (s *S3FS) Ping(ctx context.Context) error {
+	if s.bucketErr != nil {
+		return s.bucketErr
+	}
+
+	if err := s.checkOpen(); err != nil {
+		return err
+	}
+
+	prefix, err := s.key("ping", ".")
+	if err != nil {
+		return err
+	}
+
+	if prefix != "" {
+		prefix += "/"
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, pingTimeout)
+	defer cancel()
+
+	err = s.store.List(ctx, prefix, ListOptions{Delimiter: "/", MaxKeys: 1}, func(*ListPage) bool {
+		return false
+	})
+	if err != nil {
+		return fmt.Errorf("could not reach s3: %w", err)
+	}
+
+	return nil
+}
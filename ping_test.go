@@ -0,0 +1,21 @@
+package s3fs_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/packrat386/s3fs"
+	"github.com/packrat386/s3fs/s3fstest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPing(t *testing.T) {
+	myFS := s3fs.NewFS(s3fstest.NewMemStore())
+	require.Nil(t, myFS.Ping(context.Background()))
+
+	down := s3fs.NewFS(unreachableStore{s3fstest.NewMemStore()})
+	require.NotNil(t, down.Ping(context.Background()))
+
+	require.Nil(t, myFS.Close())
+	require.NotNil(t, myFS.Ping(context.Background()))
+}
@@ -0,0 +1,148 @@
+package s3fs
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+)
+
+// ErrContentChanged is returned by a PinnedFS when an object no longer has the ETag
+// it was pinned to.
+var ErrContentChanged = errors.New("content changed since it was pinned")
+
+// PinnedFS is a read-only view of an FS that only serves the files in a manifest,
+// and only while they still have the ETags recorded there. Create one with
+// NewPinnedFS.
+type PinnedFS struct {
+	fsys *S3FS
+
+	// etags maps keys to the ETags they are pinned to
+	etags map[string]string
+
+	// dirs is every directory that has a pinned file somewhere under it
+	dirs map[string]bool
+}
+
+// NewPinnedFS returns a view of fsys pinned to manifest, which maps names to ETags
+// (the ETag field of the ObjectInfo from a file's Sys method). Files that aren't in
+// the manifest don't exist in the view, and opening one whose ETag has changed fails
+// with an error wrapping ErrContentChanged. Since the ETag is checked on the response
+// that carries the data, what is read always matches the manifest. This gives
+// reproducible builds a tamper-evident view of a mutable bucket.
+func NewPinnedFS(fsys *S3FS, manifest map[string]string) *PinnedFS {
+	p := &PinnedFS{
+		fsys:  fsys,
+		etags: map[string]string{},
+		dirs:  map[string]bool{"": true},
+	}
+
+	for name, etag := range manifest {
+		key, err := fsys.key("pin", name)
+		if err != nil || key == "" {
+			continue
+		}
+
+		p.etags[key] = etag
+
+		for dir := path.Dir(key); dir != "."; dir = path.Dir(dir) {
+			p.dirs[dir] = true
+		}
+	}
+
+	return p
+}
+
+func (p *PinnedFS) Open(name string) (fs.File, error) {
+	key, err := p.fsys.key("open", name)
+	if err != nil {
+		return nil, err
+	}
+
+	etag, pinned := p.etags[key]
+	if !pinned && !p.dirs[key] {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+
+	f, err := p.fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if d, ok := f.(fs.ReadDirFile); ok {
+		if pinned {
+			// was a file when it was pinned
+			d.Close()
+			return nil, &fs.PathError{Op: "open", Path: name, Err: ErrContentChanged}
+		}
+
+		return &pinnedDir{ReadDirFile: d, fs: p, key: key}, nil
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	object, ok := info.Sys().(ObjectInfo)
+	if !ok || object.ETag != etag {
+		f.Close()
+		return nil, &fs.PathError{Op: "open", Path: name, Err: ErrContentChanged}
+	}
+
+	return readOnlyFile{f}, nil
+}
+
+// pinnedDir is a directory of a PinnedFS, which leaves out anything that isn't pinned.
+type pinnedDir struct {
+	fs.ReadDirFile
+
+	fs  *PinnedFS
+	key string
+}
+
+func (d *pinnedDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	out := []fs.DirEntry{}
+
+	for n <= 0 || len(out) < n {
+		want := n - len(out)
+		if n <= 0 {
+			want = -1
+		}
+
+		entries, err := d.ReadDirFile.ReadDir(want)
+		for _, e := range entries {
+			if d.pinned(e) {
+				out = append(out, e)
+			}
+		}
+
+		if err == io.EOF || (n <= 0 && err == nil) {
+			break
+		}
+
+		if err != nil {
+			return out, fmt.Errorf("could not read pinned directory: %w", err)
+		}
+	}
+
+	if n > 0 && len(out) == 0 {
+		return nil, io.EOF
+	}
+
+	return out, nil
+}
+
+func (d *pinnedDir) pinned(e fs.DirEntry) bool {
+	key := path.Join(d.key, e.Name())
+
+	if e.IsDir() {
+		return d.fs.dirs[key]
+	}
+
+	_, ok := d.fs.etags[key]
+
+	return ok
+}
@@ -0,0 +1,53 @@
+package s3fs_test
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+
+	"github.com/packrat386/s3fs"
+	"github.com/packrat386/s3fs/s3fstest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewPinnedFS(t *testing.T) {
+	store := s3fstest.NewMemStore()
+	store.PageSize = 2
+	store.WriteFile("a.txt", "a")
+	store.WriteFile("b.txt", "b")
+	store.WriteFile("dir/c.txt", "c")
+	store.WriteFile("dir/e.txt", "e")
+	store.WriteFile("other/d.txt", "d")
+
+	ctx := context.Background()
+	manifest := map[string]string{}
+	for _, key := range []string{"a.txt", "dir/c.txt"} {
+		info, err := store.Head(ctx, key)
+		require.Nil(t, err)
+		manifest[key] = info.ETag
+	}
+
+	myFS := s3fs.NewFS(store)
+	pinned := s3fs.NewPinnedFS(myFS, manifest)
+
+	if err := fstest.TestFS(pinned, "a.txt", "dir/c.txt"); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := fs.ReadDir(pinned, ".")
+	require.Nil(t, err)
+	require.Equal(t, 2, len(entries))
+
+	_, err = pinned.Open("b.txt")
+	require.True(t, errors.Is(err, fs.ErrNotExist))
+
+	_, err = pinned.Open("other")
+	require.True(t, errors.Is(err, fs.ErrNotExist))
+
+	require.Nil(t, myFS.WriteFile("a.txt", []byte("tampered")))
+
+	_, err = pinned.Open("a.txt")
+	require.True(t, errors.Is(err, s3fs.ErrContentChanged))
+}
@@ -0,0 +1,151 @@
+package s3fs
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// PostPolicyConstraints limit what a browser can upload with a presigned POST.
+type PostPolicyConstraints struct {
+	// Expires is how long the form can be used for. It defaults to an hour.
+	Expires time.Duration
+
+	// MinSize and MaxSize bound the size of uploaded files. A MaxSize of zero leaves
+	// the size unbounded.
+	MinSize int64
+	MaxSize int64
+
+	// ContentType is the Content-Type uploads must have. If it ends in "/", e.g.
+	// "image/", it's a prefix that the Content-Type must start with instead, and the
+	// browser must add a Content-Type field to the form itself.
+	ContentType string
+}
+
+// PresignedPost is an HTML form upload into S3: the form is POSTed to URL as
+// multipart/form-data with Fields, followed by the file in a field named "file".
+type PresignedPost struct {
+	URL    string
+	Fields map[string]string
+}
+
+// postCondition is one condition of a POST policy: either an exact match of a form
+// field, or an operator with its arguments.
+type postCondition []interface{}
+
+func (c postCondition) MarshalJSON() ([]byte, error) {
+	if len(c) == 2 {
+		return json.Marshal(map[string]interface{}{c[0].(string): c[1]})
+	}
+
+	return json.Marshal([]interface{}(c))
+}
+
+// presignPostStore is implemented by stores that can sign POST policies.
+type presignPostStore interface {
+	presignPost(keyPrefix string, expires time.Duration, conditions []postCondition) (*PresignedPost, error)
+}
+
+// PresignPostPolicy returns a form that lets a browser upload files straight into the
+// directory prefix, without them passing through the server. Files are named after
+// the file the user picked, so an upload of "cat.png" lands at prefix+"/cat.png";
+// browsers can choose another name by setting the "key" field, as long as it stays
+// under the same directory. Uploads that break constraints are rejected by S3.
+func (s *S3FS) PresignPostPolicy(prefix string, constraints PostPolicyConstraints) (*PresignedPost, error) {
+	key, err := s.writableKey("presign", prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	key += "/"
+
+	expires := constraints.Expires
+	if expires <= 0 {
+		expires = time.Hour
+	}
+
+	conditions := []postCondition{
+		{"starts-with", "$key", key},
+	}
+
+	if constraints.MaxSize > 0 {
+		conditions = append(conditions, postCondition{"content-length-range", constraints.MinSize, constraints.MaxSize})
+	}
+
+	switch {
+	case constraints.ContentType == "":
+	case strings.HasSuffix(constraints.ContentType, "/"):
+		conditions = append(conditions, postCondition{"starts-with", "$Content-Type", constraints.ContentType})
+	default:
+		conditions = append(conditions, postCondition{"Content-Type", constraints.ContentType})
+	}
+
+	ps, ok := s.baseStore().(presignPostStore)
+	if !ok {
+		return nil, fmt.Errorf("could not presign POST for %s: store doesn't presign POSTs", key)
+	}
+
+	post, err := ps.presignPost(key, expires, conditions)
+	if err != nil {
+		return nil, fmt.Errorf("could not presign POST for %s: %w", key, err)
+	}
+
+	post.Fields["key"] = key + "${filename}"
+	if ct := constraints.ContentType; ct != "" && !strings.HasSuffix(ct, "/") {
+		post.Fields["Content-Type"] = ct
+	}
+
+	return post, nil
+}
+
+// signPostPolicy adds the fields of a SigV4 signed POST policy to fields, for a
+// policy expiring at expiration with conditions.
+func signPostPolicy(fields map[string]string, accessKeyID, secretAccessKey, sessionToken, region string, now, expiration time.Time, conditions []postCondition) error {
+	date := now.UTC().Format("20060102")
+	credential := fmt.Sprintf("%s/%s/%s/s3/aws4_request", accessKeyID, date, region)
+
+	fields["x-amz-algorithm"] = "AWS4-HMAC-SHA256"
+	fields["x-amz-credential"] = credential
+	fields["x-amz-date"] = now.UTC().Format("20060102T150405Z")
+	if sessionToken != "" {
+		fields["x-amz-security-token"] = sessionToken
+	}
+
+	for _, f := range []string{"bucket", "x-amz-algorithm", "x-amz-credential", "x-amz-date", "x-amz-security-token"} {
+		if v, ok := fields[f]; ok {
+			conditions = append(conditions, postCondition{f, v})
+		}
+	}
+
+	policy, err := json.Marshal(map[string]interface{}{
+		"expiration": expiration.UTC().Format("2006-01-02T15:04:05.000Z"),
+		"conditions": conditions,
+	})
+	if err != nil {
+		return fmt.Errorf("could not encode policy: %w", err)
+	}
+
+	encoded := base64.StdEncoding.EncodeToString(policy)
+
+	signingKey := []byte("AWS4" + secretAccessKey)
+	for _, part := range []string{date, region, "s3", "aws4_request"} {
+		signingKey = hmacSHA256(signingKey, part)
+	}
+
+	fields["policy"] = encoded
+	fields["x-amz-signature"] = hex.EncodeToString(hmacSHA256(signingKey, encoded))
+
+	return nil
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+
+	return h.Sum(nil)
+}
@@ -0,0 +1,123 @@
+package s3fs
+
+import (
+	"crypto/rsa"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/cloudfront/sign"
+)
+
+// presignStore is implemented by stores that can hand out URLs for reading objects
+// without credentials.
+type presignStore interface {
+	Presign(key string, expires time.Duration) (string, error)
+}
+
+// cloudFront signs URLs and cookies for a CloudFront distribution in front of the
+// bucket.
+type cloudFront struct {
+	baseURL string
+	urls    *sign.URLSigner
+	cookies *sign.CookieSigner
+}
+
+// WithCloudFront makes PresignURL return CloudFront signed URLs for the distribution
+// at distributionURL (e.g. "https://d111111abcdef8.cloudfront.net") instead of S3
+// presigned URLs, so the links handed out are served from CloudFront's edge caches.
+// keyPairID and privKey are a CloudFront key pair (or trusted key group key) that
+// the distribution trusts. Keys are appended to distributionURL as they are, so the
+// distribution's origin must be the bucket, or the directory of it the FS covers.
+func WithCloudFront(distributionURL string, keyPairID string, privKey *rsa.PrivateKey) Option {
+	return func(s *S3FS) {
+		s.cloudFront = &cloudFront{
+			baseURL: strings.TrimSuffix(distributionURL, "/"),
+			urls:    sign.NewURLSigner(keyPairID, privKey),
+			cookies: sign.NewCookieSigner(keyPairID, privKey),
+		}
+	}
+}
+
+func (c *cloudFront) url(key string) string {
+	segments := strings.Split(key, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+
+	return c.baseURL + "/" + strings.Join(segments, "/")
+}
+
+// PresignURL returns a URL that anyone can use to GET the file at name until expires
+// has passed. Normally that's an S3 presigned URL, which can be valid for at most a
+// week. With WithCloudFront it's a CloudFront signed URL instead.
+func (s *S3FS) PresignURL(name string, expires time.Duration) (string, error) {
+	if s.bucketErr != nil {
+		return "", s.bucketErr
+	}
+
+	key, err := s.key("presign", name)
+	if err != nil {
+		return "", err
+	}
+
+	if key == "" {
+		return "", fmt.Errorf("could not presign %s: is a directory", name)
+	}
+
+	s.auditRead(OpPresign, key)
+
+	if s.cloudFront != nil {
+		signed, err := s.cloudFront.urls.Sign(s.cloudFront.url(key), s.clock.Now().Add(expires))
+		if err != nil {
+			return "", fmt.Errorf("could not sign CloudFront URL for %s: %w", key, err)
+		}
+
+		return signed, nil
+	}
+
+	ps, ok := s.baseStore().(presignStore)
+	if !ok {
+		return "", fmt.Errorf("could not presign %s: store doesn't presign URLs", key)
+	}
+
+	signed, err := ps.Presign(key, expires)
+	if err != nil {
+		return "", fmt.Errorf("could not presign %s: %w", key, err)
+	}
+
+	return signed, nil
+}
+
+// SignedCookies returns CloudFront signed cookies that let a browser read every file
+// under the directory name ("." for all of them) until expires has passed. It
+// requires WithCloudFront. Unlike signed URLs, cookies let relative links between
+// the files (pages, scripts, video segments) work unchanged.
+func (s *S3FS) SignedCookies(name string, expires time.Duration) ([]*http.Cookie, error) {
+	if s.bucketErr != nil {
+		return nil, s.bucketErr
+	}
+
+	key, err := s.key("presign", name)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.cloudFront == nil {
+		return nil, fmt.Errorf("could not sign cookies for %s: CloudFront is not configured", name)
+	}
+
+	resource := s.cloudFront.baseURL + "/*"
+	if key != "" {
+		resource = s.cloudFront.url(key) + "/*"
+	}
+
+	cookies, err := s.cloudFront.cookies.SignWithPolicy(sign.NewCannedPolicy(resource, s.clock.Now().Add(expires)))
+	if err != nil {
+		return nil, fmt.Errorf("could not sign CloudFront cookies for %s: %w", name, err)
+	}
+
+	return cookies, nil
+}
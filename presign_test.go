@@ -0,0 +1,198 @@
+package s3fs_test
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/packrat386/s3fs"
+	"github.com/packrat386/s3fs/s3fstest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPresignURL(t *testing.T) {
+	sess := session.Must(session.NewSession(&aws.Config{
+		Region:      aws.String("us-east-1"),
+		Credentials: credentials.NewStaticCredentials("AKID", "SECRET", ""),
+	}))
+
+	myFS := s3fs.NewS3FS(s3.New(sess), "my-bucket")
+
+	signed, err := myFS.PresignURL("dir/a file.txt", time.Hour)
+	require.Nil(t, err)
+
+	u, err := url.Parse(signed)
+	require.Nil(t, err)
+	require.Equal(t, "my-bucket.s3.amazonaws.com", u.Host)
+	require.Equal(t, "/dir/a%20file.txt", u.EscapedPath())
+	require.Equal(t, "3600", u.Query().Get("X-Amz-Expires"))
+	require.NotEmpty(t, u.Query().Get("X-Amz-Signature"))
+}
+
+func TestPresignURL_Clock(t *testing.T) {
+	sess := session.Must(session.NewSession(&aws.Config{
+		Region:      aws.String("us-east-1"),
+		Credentials: credentials.NewStaticCredentials("AKID", "SECRET", ""),
+	}))
+
+	clock := s3fstest.NewFakeClock(time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC))
+	myFS := s3fs.NewS3FS(s3.New(sess), "my-bucket", s3fs.WithClock(clock))
+
+	signed, err := myFS.PresignURL("a.txt", time.Hour)
+	require.Nil(t, err)
+
+	u, err := url.Parse(signed)
+	require.Nil(t, err)
+	require.Equal(t, "20300101T000000Z", u.Query().Get("X-Amz-Date"))
+}
+
+func TestPresignURL_NotSupported(t *testing.T) {
+	myFS := s3fs.NewFS(s3fstest.NewMemStore())
+
+	_, err := myFS.PresignURL("a.txt", time.Hour)
+	require.NotNil(t, err)
+}
+
+func TestWithCloudFront(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.Nil(t, err)
+
+	myFS := s3fs.NewFS(
+		s3fstest.NewMemStore(),
+		s3fs.WithCloudFront("https://d111111abcdef8.cloudfront.net/", "KEYPAIR", key),
+	)
+
+	signed, err := myFS.PresignURL("dir/a file.txt", time.Hour)
+	require.Nil(t, err)
+
+	u, err := url.Parse(signed)
+	require.Nil(t, err)
+	require.Equal(t, "d111111abcdef8.cloudfront.net", u.Host)
+	require.Equal(t, "/dir/a%20file.txt", u.EscapedPath())
+	require.Equal(t, "KEYPAIR", u.Query().Get("Key-Pair-Id"))
+	require.NotEmpty(t, u.Query().Get("Signature"))
+	require.NotEmpty(t, u.Query().Get("Expires"))
+
+	cookies, err := myFS.SignedCookies("dir", time.Hour)
+	require.Nil(t, err)
+
+	names := []string{}
+	for _, c := range cookies {
+		names = append(names, c.Name)
+	}
+
+	require.ElementsMatch(t, []string{"CloudFront-Policy", "CloudFront-Signature", "CloudFront-Key-Pair-Id"}, names)
+
+	_, err = myFS.PresignURL(".", time.Hour)
+	require.NotNil(t, err)
+	require.True(t, strings.Contains(err.Error(), "directory"))
+}
+
+func TestWithCloudFront_Clock(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.Nil(t, err)
+
+	clock := s3fstest.NewFakeClock(time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC))
+	myFS := s3fs.NewFS(
+		s3fstest.NewMemStore(),
+		s3fs.WithCloudFront("https://d111111abcdef8.cloudfront.net/", "KEYPAIR", key),
+		s3fs.WithClock(clock),
+	)
+
+	signed, err := myFS.PresignURL("a.txt", time.Hour)
+	require.Nil(t, err)
+
+	u, err := url.Parse(signed)
+	require.Nil(t, err)
+	require.Equal(t, strconv.FormatInt(clock.Now().Add(time.Hour).Unix(), 10), u.Query().Get("Expires"))
+
+	cookies, err := myFS.SignedCookies(".", time.Hour)
+	require.Nil(t, err)
+
+	for _, c := range cookies {
+		if c.Name != "CloudFront-Policy" {
+			continue
+		}
+
+		policy, err := base64.StdEncoding.DecodeString(strings.NewReplacer("-", "+", "_", "=", "~", "/").Replace(c.Value))
+		require.Nil(t, err)
+		require.Contains(t, string(policy), strconv.FormatInt(clock.Now().Add(time.Hour).Unix(), 10))
+	}
+}
+
+func TestSignedCookies_RequiresCloudFront(t *testing.T) {
+	myFS := s3fs.NewFS(s3fstest.NewMemStore())
+
+	_, err := myFS.SignedCookies(".", time.Hour)
+	require.NotNil(t, err)
+}
+
+func TestPresignPostPolicy(t *testing.T) {
+	sess := session.Must(session.NewSession(&aws.Config{
+		Region:      aws.String("us-west-2"),
+		Credentials: credentials.NewStaticCredentials("AKID", "SECRET", "TOKEN"),
+	}))
+
+	myFS := s3fs.NewS3FS(s3.New(sess), "my-bucket")
+
+	post, err := myFS.PresignPostPolicy("uploads/user-1", s3fs.PostPolicyConstraints{
+		MaxSize:     10 << 20,
+		ContentType: "image/",
+	})
+	require.Nil(t, err)
+
+	require.Equal(t, "https://my-bucket.s3.us-west-2.amazonaws.com/", post.URL)
+	require.Equal(t, "uploads/user-1/${filename}", post.Fields["key"])
+	require.Equal(t, "TOKEN", post.Fields["x-amz-security-token"])
+	require.True(t, strings.HasPrefix(post.Fields["x-amz-credential"], "AKID/"))
+	require.True(t, strings.HasSuffix(post.Fields["x-amz-credential"], "/us-west-2/s3/aws4_request"))
+
+	raw, err := base64.StdEncoding.DecodeString(post.Fields["policy"])
+	require.Nil(t, err)
+
+	policy := struct {
+		Expiration string
+		Conditions []interface{}
+	}{}
+	require.Nil(t, json.Unmarshal(raw, &policy))
+
+	require.Contains(t, policy.Conditions, []interface{}{"starts-with", "$key", "uploads/user-1/"})
+	require.Contains(t, policy.Conditions, []interface{}{"content-length-range", float64(0), float64(10 << 20)})
+	require.Contains(t, policy.Conditions, []interface{}{"starts-with", "$Content-Type", "image/"})
+	require.Contains(t, policy.Conditions, map[string]interface{}{"bucket": "my-bucket"})
+	require.Contains(t, policy.Conditions, map[string]interface{}{"x-amz-security-token": "TOKEN"})
+
+	signingKey := []byte("AWS4SECRET")
+	for _, part := range []string{post.Fields["x-amz-date"][:8], "us-west-2", "s3", "aws4_request"} {
+		h := hmac.New(sha256.New, signingKey)
+		h.Write([]byte(part))
+		signingKey = h.Sum(nil)
+	}
+
+	h := hmac.New(sha256.New, signingKey)
+	h.Write([]byte(post.Fields["policy"]))
+	require.Equal(t, hex.EncodeToString(h.Sum(nil)), post.Fields["x-amz-signature"])
+
+	_, err = myFS.PresignPostPolicy(".", s3fs.PostPolicyConstraints{})
+	require.NotNil(t, err)
+}
+
+func TestPresignPostPolicy_NotSupported(t *testing.T) {
+	myFS := s3fs.NewFS(s3fstest.NewMemStore())
+
+	_, err := myFS.PresignPostPolicy("uploads", s3fs.PostPolicyConstraints{})
+	require.NotNil(t, err)
+}
@@ -0,0 +1,244 @@
+package s3fs
+
+import (
+	"context"
+	"io"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws/request"
+)
+
+// Priority is how urgently a request should be sent when the FS is limited to a
+// number of requests at once with WithRequestLimit.
+type Priority int
+
+const (
+	// PriorityForeground is for requests someone is waiting on, like Opens serving
+	// a user. It is the priority of any context without one.
+	PriorityForeground Priority = iota
+
+	// PriorityBackground is for requests nobody is waiting on, like prefetching and
+	// cache warming, which only get to go when no foreground request is waiting.
+	PriorityBackground
+)
+
+type priorityKey struct{}
+
+// WithPriority returns a copy of ctx that makes the requests it is used for have
+// priority p. Background work started by the FS's own options always has
+// PriorityBackground.
+func WithPriority(ctx context.Context, p Priority) context.Context {
+	return context.WithValue(ctx, priorityKey{}, p)
+}
+
+func priorityOf(ctx context.Context) Priority {
+	if p, ok := ctx.Value(priorityKey{}).(Priority); ok && p == PriorityBackground {
+		return PriorityBackground
+	}
+
+	return PriorityForeground
+}
+
+// WithRequestLimit limits the FS to n requests to the store at once. Requests beyond
+// the limit wait for a free slot, and waiting foreground requests always get one
+// before waiting background requests (see WithPriority), so interactive Opens jump
+// the queue ahead of prefetch traffic sharing the FS. A request holds its slot until
+// the store responds, so the bodies of open files are read outside the limit.
+//
+// For S3, every request is limited, including those for optional operations like
+// ACLs and restores, and each part of a multipart upload or download takes a slot of
+// its own while it is sent, rather than one being held while the data is streamed.
+// Other stores hold a slot for each call to an ObjectStore method, and their optional
+// operations aren't limited.
+func WithRequestLimit(n int) Option {
+	return func(s *S3FS) {
+		if n > 0 {
+			s.scheduler = &scheduler{limit: n}
+		}
+	}
+}
+
+// scheduler hands out a limited number of slots, foreground waiters first.
+type scheduler struct {
+	mu     sync.Mutex
+	limit  int
+	active int
+
+	// waiting are the queues of waiters of each priority, in arrival order
+	waiting [2][]chan struct{}
+}
+
+// acquire waits for a free slot for a request of priority p, or for ctx to be done.
+func (s *scheduler) acquire(ctx context.Context, p Priority) error {
+	s.mu.Lock()
+
+	ahead := len(s.waiting[PriorityForeground])
+	if p == PriorityBackground {
+		ahead += len(s.waiting[PriorityBackground])
+	}
+
+	if s.active < s.limit && ahead == 0 {
+		s.active++
+		s.mu.Unlock()
+		return nil
+	}
+
+	ready := make(chan struct{})
+	s.waiting[p] = append(s.waiting[p], ready)
+	s.mu.Unlock()
+
+	select {
+	case <-ready:
+		return nil
+	case <-ctx.Done():
+	}
+
+	s.mu.Lock()
+	for i, ch := range s.waiting[p] {
+		if ch == ready {
+			s.waiting[p] = append(s.waiting[p][:i], s.waiting[p][i+1:]...)
+			s.mu.Unlock()
+			return ctx.Err()
+		}
+	}
+	s.mu.Unlock()
+
+	// the slot was handed over just as ctx was done, so pass it on
+	s.release()
+
+	return ctx.Err()
+}
+
+// release frees a slot, handing it straight to the first waiter if there is one.
+func (s *scheduler) release() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for p := range s.waiting {
+		if len(s.waiting[p]) > 0 {
+			close(s.waiting[p][0])
+			s.waiting[p] = s.waiting[p][1:]
+			return
+		}
+	}
+
+	s.active--
+}
+
+// option makes a request wait for a slot before each attempt to send it, and give the
+// slot up once the store has responded. Presigned requests are never sent, so they
+// don't take one.
+func (s *scheduler) option(r *request.Request) {
+	held := false
+
+	r.Handlers.Sign.PushBack(func(r *request.Request) {
+		if r.Error != nil || r.ExpireTime > 0 {
+			return
+		}
+
+		if err := s.acquire(r.Context(), priorityOf(r.Context())); err != nil {
+			r.Error = err
+			return
+		}
+
+		held = true
+	})
+
+	r.Handlers.CompleteAttempt.PushBack(func(r *request.Request) {
+		if held {
+			held = false
+			s.release()
+		}
+	})
+}
+
+// scheduledStore makes every request to store wait for a slot from scheduler.
+type scheduledStore struct {
+	store     ObjectStore
+	scheduler *scheduler
+}
+
+func (s *scheduledStore) do(ctx context.Context, fn func() error) error {
+	if err := s.scheduler.acquire(ctx, priorityOf(ctx)); err != nil {
+		return err
+	}
+	defer s.scheduler.release()
+
+	return fn()
+}
+
+func (s *scheduledStore) List(ctx context.Context, prefix string, opts ListOptions, fn func(*ListPage) bool) error {
+	p := priorityOf(ctx)
+	if err := s.scheduler.acquire(ctx, p); err != nil {
+		return err
+	}
+
+	// every page is a request of its own, so the slot is given up while fn runs
+	held := true
+	var acquireErr error
+	err := s.store.List(ctx, prefix, opts, func(page *ListPage) bool {
+		s.scheduler.release()
+		held = false
+
+		if !fn(page) {
+			return false
+		}
+
+		if acquireErr = s.scheduler.acquire(ctx, p); acquireErr != nil {
+			return false
+		}
+
+		held = true
+
+		return true
+	})
+
+	if held {
+		s.scheduler.release()
+	}
+
+	if err == nil {
+		err = acquireErr
+	}
+
+	return err
+}
+
+func (s *scheduledStore) Head(ctx context.Context, key string) (info ObjectInfo, err error) {
+	err = s.do(ctx, func() error {
+		info, err = s.store.Head(ctx, key)
+		return err
+	})
+
+	return info, err
+}
+
+func (s *scheduledStore) Get(ctx context.Context, key string, opts GetOptions) (object *Object, err error) {
+	err = s.do(ctx, func() error {
+		object, err = s.store.Get(ctx, key, opts)
+		return err
+	})
+
+	return object, err
+}
+
+func (s *scheduledStore) Put(ctx context.Context, key string, body io.Reader, opts PutOptions) (info ObjectInfo, err error) {
+	err = s.do(ctx, func() error {
+		info, err = s.store.Put(ctx, key, body, opts)
+		return err
+	})
+
+	return info, err
+}
+
+func (s *scheduledStore) Delete(ctx context.Context, key string) error {
+	return s.do(ctx, func() error {
+		return s.store.Delete(ctx, key)
+	})
+}
+
+func (s *scheduledStore) Copy(ctx context.Context, src, dst string, opts CopyOptions) error {
+	return s.do(ctx, func() error {
+		return s.store.Copy(ctx, src, dst, opts)
+	})
+}
@@ -0,0 +1,247 @@
+package s3fs
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestScheduler_ForegroundFirst(t *testing.T) {
+	s := &scheduler{limit: 1}
+	ctx := context.Background()
+
+	require.Nil(t, s.acquire(ctx, PriorityForeground))
+
+	order := make(chan string, 3)
+	wg := &sync.WaitGroup{}
+	queue := func(name string, p Priority) {
+		s.mu.Lock()
+		queued := len(s.waiting[PriorityForeground]) + len(s.waiting[PriorityBackground])
+		s.mu.Unlock()
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			require.Nil(t, s.acquire(ctx, p))
+			order <- name
+			s.release()
+		}()
+
+		require.Eventually(t, func() bool {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+
+			return len(s.waiting[PriorityForeground])+len(s.waiting[PriorityBackground]) == queued+1
+		}, time.Second, time.Millisecond)
+	}
+
+	queue("prefetch 1", PriorityBackground)
+	queue("prefetch 2", PriorityBackground)
+	queue("open", PriorityForeground)
+
+	s.release()
+	wg.Wait()
+	close(order)
+
+	got := []string{}
+	for name := range order {
+		got = append(got, name)
+	}
+
+	require.Equal(t, []string{"open", "prefetch 1", "prefetch 2"}, got)
+	require.Equal(t, 0, s.active)
+}
+
+func TestScheduler_Cancel(t *testing.T) {
+	s := &scheduler{limit: 1}
+
+	require.Nil(t, s.acquire(context.Background(), PriorityForeground))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	require.ErrorIs(t, s.acquire(ctx, PriorityBackground), context.DeadlineExceeded)
+	require.Empty(t, s.waiting[PriorityBackground])
+
+	s.release()
+	require.Equal(t, 0, s.active)
+	require.Nil(t, s.acquire(context.Background(), PriorityBackground))
+}
+
+func TestPriorityOf(t *testing.T) {
+	ctx := context.Background()
+
+	require.Equal(t, PriorityForeground, priorityOf(ctx))
+	require.Equal(t, PriorityBackground, priorityOf(WithPriority(ctx, PriorityBackground)))
+	require.Equal(t, PriorityForeground, priorityOf(WithPriority(ctx, PriorityForeground)))
+}
+
+// pagedStore lists the same number of pages for any prefix.
+type pagedStore struct {
+	ObjectStore
+	pages int
+}
+
+func (p pagedStore) List(ctx context.Context, prefix string, opts ListOptions, fn func(*ListPage) bool) error {
+	for i := 0; i < p.pages; i++ {
+		if !fn(&ListPage{Objects: []ObjectInfo{{Key: prefix}}}) {
+			return nil
+		}
+	}
+
+	return nil
+}
+
+func TestScheduledStore_List(t *testing.T) {
+	s := &scheduler{limit: 1}
+	store := &scheduledStore{store: pagedStore{pages: 3}, scheduler: s}
+
+	pages := 0
+	err := store.List(context.Background(), "a", ListOptions{}, func(page *ListPage) bool {
+		// the slot is free while a page is handled, so nested requests can't deadlock
+		require.Equal(t, 0, s.active)
+		require.Nil(t, store.List(context.Background(), "b", ListOptions{}, func(*ListPage) bool { return false }))
+
+		pages++
+		return true
+	})
+
+	require.Nil(t, err)
+	require.Equal(t, 3, pages)
+	require.Equal(t, 0, s.active)
+}
+
+// respond answers r with status and body.
+func respond(r *http.Request, status int, header http.Header, body string) *http.Response {
+	if header == nil {
+		header = http.Header{}
+	}
+
+	header.Set("Content-Length", strconv.Itoa(len(body)))
+
+	return &http.Response{
+		StatusCode: status,
+		Header:     header,
+		Body:       io.NopCloser(strings.NewReader(body)),
+		Request:    r,
+	}
+}
+
+func TestWithRequestLimit_OptionalOperations(t *testing.T) {
+	sent := make(chan string, 10)
+	transport := roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		sent <- r.URL.RawQuery
+		return respond(r, http.StatusOK, nil, `<ListMultipartUploadsResult></ListMultipartUploadsResult>`), nil
+	})
+
+	myFS := NewS3FS(newTestClient(transport, ""), "my-bucket", WithRequestLimit(1))
+
+	// someone else has the only slot
+	require.Nil(t, myFS.scheduler.acquire(context.Background(), PriorityForeground))
+
+	done := make(chan error)
+	go func() {
+		_, err := myFS.AbortStaleUploads(context.Background(), time.Hour)
+		done <- err
+	}()
+
+	select {
+	case <-sent:
+		t.Fatal("request sent without a slot")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	myFS.scheduler.release()
+	require.Nil(t, <-done)
+	require.Contains(t, <-sent, "uploads=")
+	require.Equal(t, 0, myFS.scheduler.active)
+}
+
+func TestWithRequestLimit_Scoped(t *testing.T) {
+	sent := make(chan string, 10)
+	transport := roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		sent <- r.URL.RawQuery
+		return respond(r, http.StatusOK, nil, `<ListMultipartUploadsResult></ListMultipartUploadsResult>`), nil
+	})
+
+	myFS, err := NewScopedS3FS(newTestClient(transport, ""), "my-bucket", "tenant", WithRequestLimit(1))
+	require.Nil(t, err)
+
+	// someone else has the only slot
+	require.Nil(t, myFS.scheduler.acquire(context.Background(), PriorityForeground))
+
+	done := make(chan error)
+	go func() {
+		_, err := myFS.AbortStaleUploads(context.Background(), time.Hour)
+		done <- err
+	}()
+
+	select {
+	case <-sent:
+		t.Fatal("request sent without a slot")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	myFS.scheduler.release()
+	require.Nil(t, <-done)
+	require.Contains(t, <-sent, "prefix=tenant%2F")
+	require.Equal(t, 0, myFS.scheduler.active)
+}
+
+func TestWithRequestLimit_StreamingUpload(t *testing.T) {
+	transport := roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		if r.Body != nil {
+			io.Copy(io.Discard, r.Body)
+		}
+
+		switch {
+		case r.URL.Query().Get("list-type") != "":
+			return respond(r, http.StatusOK, nil, `<ListBucketResult><Contents><Key>other.txt</Key><Size>5</Size></Contents></ListBucketResult>`), nil
+		case r.Method == http.MethodGet:
+			return respond(r, http.StatusOK, nil, "other"), nil
+		case r.URL.Query().Has("uploads"):
+			return respond(r, http.StatusOK, nil, `<InitiateMultipartUploadResult><UploadId>upload</UploadId></InitiateMultipartUploadResult>`), nil
+		case r.Method == http.MethodPost:
+			return respond(r, http.StatusOK, nil, `<CompleteMultipartUploadResult><ETag>"etag"</ETag></CompleteMultipartUploadResult>`), nil
+		default:
+			return respond(r, http.StatusOK, http.Header{"Etag": []string{`"etag"`}}, ""), nil
+		}
+	})
+
+	myFS := NewS3FS(newTestClient(transport, ""), "my-bucket", WithRequestLimit(1))
+
+	w, err := myFS.Create("big.txt")
+	require.Nil(t, err)
+
+	// enough to start streaming to a multipart upload
+	_, err = w.Write(make([]byte, 6<<20))
+	require.Nil(t, err)
+
+	// the upload waiting for more data doesn't hold the only slot
+	read := make(chan error)
+	go func() {
+		_, err := fs.ReadFile(myFS, "other.txt")
+		read <- err
+	}()
+
+	select {
+	case err := <-read:
+		require.Nil(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("read blocked behind a streaming upload")
+	}
+
+	_, err = w.Write(make([]byte, 1<<20))
+	require.Nil(t, err)
+	require.Nil(t, w.Close())
+	require.Equal(t, 0, myFS.scheduler.active)
+}
@@ -0,0 +1,117 @@
+package s3fs
+
+import (
+	"fmt"
+	"io"
+	"sync"
+)
+
+// WithWriteQuota caps how much can be written through the FS: at most maxBytes bytes
+// and maxObjects objects in total, counting every file written, appended to, or
+// copied. Writes that would go over either limit fail with a *QuotaExceededError.
+// Zero means no limit. Usage is only tracked in memory for the life of the FS, so
+// give each tenant its own FS to cap them separately.
+func WithWriteQuota(maxBytes, maxObjects int64) Option {
+	return func(s *S3FS) {
+		s.quota = &writeQuota{
+			maxBytes:   maxBytes,
+			maxObjects: maxObjects,
+		}
+	}
+}
+
+// QuotaExceededError is returned by writes that would go over the limits set with
+// WithWriteQuota.
+type QuotaExceededError struct {
+	// Resource is "bytes" or "objects".
+	Resource string
+
+	// Limit is the most of Resource that may be written.
+	Limit int64
+}
+
+func (e *QuotaExceededError) Error() string {
+	return fmt.Sprintf("write quota exceeded: limit is %d %s", e.Limit, e.Resource)
+}
+
+// QuotaUsage reports how many bytes and objects have been written through the FS so
+// far, including writes that are still in progress. It is always zero unless the FS
+// was created with WithWriteQuota.
+func (s *S3FS) QuotaUsage() (bytes, objects int64) {
+	if s.quota == nil {
+		return 0, 0
+	}
+
+	s.quota.mu.Lock()
+	defer s.quota.mu.Unlock()
+
+	return s.quota.bytes, s.quota.objects
+}
+
+// writeQuota tracks usage against the limits set by WithWriteQuota. A nil writeQuota
+// has no limits.
+type writeQuota struct {
+	maxBytes   int64
+	maxObjects int64
+
+	mu      sync.Mutex
+	bytes   int64
+	objects int64
+}
+
+// reserve counts objects and bytes against the quota, or fails without counting
+// anything if that would go over it.
+func (q *writeQuota) reserve(objects, bytes int64) error {
+	if q == nil {
+		return nil
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.maxObjects > 0 && q.objects+objects > q.maxObjects {
+		return &QuotaExceededError{Resource: "objects", Limit: q.maxObjects}
+	}
+
+	if q.maxBytes > 0 && q.bytes+bytes > q.maxBytes {
+		return &QuotaExceededError{Resource: "bytes", Limit: q.maxBytes}
+	}
+
+	q.objects += objects
+	q.bytes += bytes
+
+	return nil
+}
+
+// release gives back what was reserved for a write that failed.
+func (q *writeQuota) release(objects, bytes int64) {
+	if q == nil {
+		return
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.objects -= objects
+	q.bytes -= bytes
+}
+
+// quotaReader counts everything read from r against quota, failing once it goes over.
+type quotaReader struct {
+	r     io.Reader
+	quota *writeQuota
+	read  int64
+}
+
+func (q *quotaReader) Read(buf []byte) (int, error) {
+	n, err := q.r.Read(buf)
+	if n > 0 {
+		if qerr := q.quota.reserve(0, int64(n)); qerr != nil {
+			return 0, qerr
+		}
+
+		q.read += int64(n)
+	}
+
+	return n, err
+}
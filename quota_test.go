@@ -0,0 +1,44 @@
+package s3fs_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/packrat386/s3fs"
+	"github.com/packrat386/s3fs/s3fstest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithWriteQuota(t *testing.T) {
+	store := s3fstest.NewMemStore()
+	myFS := s3fs.NewFS(store, s3fs.WithWriteQuota(10, 3))
+
+	require.Nil(t, myFS.WriteFile("a.txt", []byte("12345")))
+
+	err := myFS.WriteFile("b.txt", []byte("123456"))
+	qerr := &s3fs.QuotaExceededError{}
+	require.True(t, errors.As(err, &qerr))
+	require.Equal(t, "bytes", qerr.Resource)
+	require.Equal(t, int64(10), qerr.Limit)
+
+	// the failed write doesn't count against the quota, or show up in the store
+	used, objects := myFS.QuotaUsage()
+	require.Equal(t, int64(5), used)
+	require.Equal(t, int64(1), objects)
+
+	_, err = store.Head(context.Background(), "b.txt")
+	require.NotNil(t, err)
+
+	require.Nil(t, myFS.Copy("a.txt", "c.txt"))
+	require.Nil(t, myFS.UploadFrom(context.Background(), "d.txt", bytes.NewReader(nil), 0))
+
+	err = myFS.WriteFileIf("e.txt", nil, "")
+	require.True(t, errors.As(err, &qerr))
+	require.Equal(t, "objects", qerr.Resource)
+
+	used, objects = myFS.QuotaUsage()
+	require.Equal(t, int64(10), used)
+	require.Equal(t, int64(3), objects)
+}
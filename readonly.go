@@ -0,0 +1,95 @@
+package s3fs
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+)
+
+// ErrReadOnly is returned by attempts to write to an FS created with WithReadOnly.
+var ErrReadOnly = errors.New("file system is read-only")
+
+// WithReadOnly makes every method of the FS that would change the bucket fail with
+// ErrReadOnly, so code that gets hold of the *S3FS can't write through it by accident.
+func WithReadOnly() Option {
+	return func(s *S3FS) {
+		s.readOnly = true
+	}
+}
+
+// ReadOnlyFS is a view of an FS that can only be read. Unlike an fs.FS holding an
+// *S3FS, neither it nor the files it opens can be type asserted to anything with
+// write methods, which makes it safe to hand to plugin code. Create one with the
+// ReadOnly method of an FS.
+type ReadOnlyFS struct {
+	fsys *S3FS
+}
+
+// ReadOnly returns a read-only view of the FS. Writes through the FS itself are
+// unaffected unless it was created with WithReadOnly.
+func (s *S3FS) ReadOnly() *ReadOnlyFS {
+	return &ReadOnlyFS{fsys: s}
+}
+
+func (r *ReadOnlyFS) Open(name string) (fs.File, error) {
+	f, err := r.fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if d, ok := f.(fs.ReadDirFile); ok {
+		return readOnlyDir{d}, nil
+	}
+
+	return readOnlyFile{f}, nil
+}
+
+// readOnlyFile hides everything about the file except the fs.File methods.
+type readOnlyFile struct {
+	f fs.File
+}
+
+func (f readOnlyFile) Stat() (fs.FileInfo, error) {
+	return f.f.Stat()
+}
+
+func (f readOnlyFile) Read(buf []byte) (int, error) {
+	return f.f.Read(buf)
+}
+
+func (f readOnlyFile) Close() error {
+	return f.f.Close()
+}
+
+// readOnlyDir hides everything about the directory except the fs.ReadDirFile methods
+// and Seek.
+type readOnlyDir struct {
+	d fs.ReadDirFile
+}
+
+func (d readOnlyDir) Stat() (fs.FileInfo, error) {
+	return d.d.Stat()
+}
+
+func (d readOnlyDir) Read(buf []byte) (int, error) {
+	return d.d.Read(buf)
+}
+
+func (d readOnlyDir) Close() error {
+	return d.d.Close()
+}
+
+func (d readOnlyDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	return d.d.ReadDir(n)
+}
+
+// Seek rewinds the directory to its start.
+func (d readOnlyDir) Seek(offset int64, whence int) (int64, error) {
+	seeker, ok := d.d.(io.Seeker)
+	if !ok {
+		return 0, fmt.Errorf("cannot seek directory")
+	}
+
+	return seeker.Seek(offset, whence)
+}
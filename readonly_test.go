@@ -0,0 +1,49 @@
+package s3fs_test
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+
+	"github.com/packrat386/s3fs"
+	"github.com/packrat386/s3fs/s3fstest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadOnly(t *testing.T) {
+	store := s3fstest.NewMemStore()
+	store.WriteFile("dir/foo.json", `{"data":"foo"}`)
+
+	var roFS fs.FS = s3fs.NewFS(store).ReadOnly()
+
+	if err := fstest.TestFS(roFS, "dir/foo.json"); err != nil {
+		t.Fatal(err)
+	}
+
+	_, ok := roFS.(interface{ WriteFile(string, []byte) error })
+	require.False(t, ok)
+
+	f, err := roFS.Open("dir/foo.json")
+	require.Nil(t, err)
+	defer f.Close()
+
+	_, ok = f.(io.Writer)
+	require.False(t, ok)
+}
+
+func TestWithReadOnly(t *testing.T) {
+	store := s3fstest.NewMemStore()
+	store.WriteFile("foo.json", `{"data":"foo"}`)
+
+	myFS := s3fs.NewFS(store, s3fs.WithReadOnly())
+
+	data, err := fs.ReadFile(myFS, "foo.json")
+	require.Nil(t, err)
+	require.Equal(t, `{"data":"foo"}`, string(data))
+
+	require.True(t, errors.Is(myFS.WriteFile("bar.json", nil), s3fs.ErrReadOnly))
+	require.True(t, errors.Is(myFS.Remove("foo.json"), s3fs.ErrReadOnly))
+	require.True(t, errors.Is(myFS.Copy("foo.json", "bar.json"), s3fs.ErrReadOnly))
+}
@@ -0,0 +1,70 @@
+package s3fs
+
+import (
+	"fmt"
+	"io/fs"
+	"sort"
+	"sync"
+)
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]*S3FS{}
+)
+
+// Register makes fsys available under name to Lookup, so an application can configure
+// its FSes in one place and the libraries it uses can refer to them by name instead
+// of having them passed down through every layer. fsys is unregistered again when it
+// is closed. Like database/sql.Register, it panics if fsys is nil or name is already
+// taken, since both are programming errors best found at startup.
+func Register(name string, fsys *S3FS) {
+	if fsys == nil {
+		panic("s3fs: Register of a nil FS")
+	}
+
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if _, ok := registry[name]; ok {
+		panic(fmt.Sprintf("s3fs: Register called twice for %q", name))
+	}
+
+	registry[name] = fsys
+
+	fsys.closers = append(fsys.closers, func() {
+		registryMu.Lock()
+		defer registryMu.Unlock()
+
+		if registry[name] == fsys {
+			delete(registry, name)
+		}
+	})
+}
+
+// Lookup returns the FS registered under name, or an error wrapping fs.ErrNotExist if
+// there isn't one.
+func Lookup(name string) (*S3FS, error) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	fsys, ok := registry[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: no FS registered as %q", fs.ErrNotExist, name)
+	}
+
+	return fsys, nil
+}
+
+// Registered returns the names FSes are registered under, sorted.
+func Registered() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
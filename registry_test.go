@@ -0,0 +1,48 @@
+package s3fs_test
+
+import (
+	"io/fs"
+	"testing"
+
+	"github.com/packrat386/s3fs"
+	"github.com/packrat386/s3fs/s3fstest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegister(t *testing.T) {
+	store := s3fstest.NewMemStore()
+	store.WriteFile("logo.png", "png")
+
+	assets := s3fs.NewFS(store)
+	s3fs.Register("test-assets", assets)
+
+	found, err := s3fs.Lookup("test-assets")
+	require.Nil(t, err)
+	require.Same(t, assets, found)
+
+	data, err := fs.ReadFile(found, "logo.png")
+	require.Nil(t, err)
+	require.Equal(t, "png", string(data))
+
+	require.Contains(t, s3fs.Registered(), "test-assets")
+
+	_, err = s3fs.Lookup("test-missing")
+	require.ErrorIs(t, err, fs.ErrNotExist)
+
+	require.Panics(t, func() { s3fs.Register("test-assets", s3fs.NewFS(store)) })
+	require.Panics(t, func() { s3fs.Register("test-nil", nil) })
+
+	// closing unregisters it, so the name can be reused
+	require.Nil(t, assets.Close())
+
+	_, err = s3fs.Lookup("test-assets")
+	require.ErrorIs(t, err, fs.ErrNotExist)
+
+	replacement := s3fs.NewFS(store)
+	s3fs.Register("test-assets", replacement)
+	defer replacement.Close()
+
+	found, err = s3fs.Lookup("test-assets")
+	require.Nil(t, err)
+	require.Same(t, replacement, found)
+}
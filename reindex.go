@@ -0,0 +1,78 @@
+package s3fs
+
+import (
+	"context"
+	"strings"
+)
+
+// ReindexSink receives what Reindex finds, for example to build a search index.
+type ReindexSink interface {
+	// Listed is called once, with a manifest of every file under the prefix, before
+	// any changes.
+	Listed(m *Manifest) error
+
+	// Changed is called with each change to a file under the prefix after it was
+	// listed.
+	Changed(event ChangeEvent) error
+}
+
+// Reindex gives sink a full listing of the files under the directory prefix ("." for
+// the whole bucket), followed by the changes to them from the FS's ChangeSource (see
+// Changes) until ctx is done or sink returns an error, which Reindex returns.
+//
+// It subscribes to changes before it lists, so nothing that changes while the
+// listing is taken is missed. Changes that the listing already reflects, i.e.
+// ChangeCreated events for a file with the ETag it was listed with, are dropped the
+// first time they're seen, so a file only uploaded once isn't reported twice. Other
+// changes from that window may be reported although the listing reflects them, and
+// S3 delivers notifications at least once and in no particular order, so sink should
+// apply changes idempotently and compare Time if order matters.
+func (s *S3FS) Reindex(ctx context.Context, prefix string, sink ReindexSink) error {
+	key, err := s.key("reindex", prefix)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	events, err := s.Changes(ctx)
+	if err != nil {
+		return err
+	}
+
+	m, err := s.GenerateManifest(ctx, prefix)
+	if err != nil {
+		return err
+	}
+
+	listed := make(map[string]string, len(m.Entries))
+	for _, e := range m.Entries {
+		listed[e.Path] = e.ETag
+	}
+
+	if err := sink.Listed(m); err != nil {
+		return err
+	}
+
+	for event := range events {
+		if key != "" && !strings.HasPrefix(event.Name, key+"/") {
+			continue
+		}
+
+		etag, ok := listed[event.Name]
+		if ok {
+			delete(listed, event.Name)
+
+			if event.Kind == ChangeCreated && event.ETag == etag {
+				continue
+			}
+		}
+
+		if err := sink.Changed(event); err != nil {
+			return err
+		}
+	}
+
+	return ctx.Err()
+}
@@ -0,0 +1,116 @@
+package s3fs_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/packrat386/s3fs"
+	"github.com/packrat386/s3fs/s3fstest"
+	"github.com/stretchr/testify/require"
+)
+
+// reindexSink records what Reindex gives it, cancelling once it has want changes.
+type reindexSink struct {
+	manifest *s3fs.Manifest
+	changes  []s3fs.ChangeEvent
+	want     int
+	cancel   func()
+}
+
+func (r *reindexSink) Listed(m *s3fs.Manifest) error {
+	r.manifest = m
+	return nil
+}
+
+func (r *reindexSink) Changed(event s3fs.ChangeEvent) error {
+	r.changes = append(r.changes, event)
+	if len(r.changes) == r.want {
+		r.cancel()
+	}
+
+	return nil
+}
+
+func createdEvent(key, etag string) string {
+	return fmt.Sprintf(`{"Records":[{"eventName":"ObjectCreated:Put","eventTime":"2024-01-02T03:04:05.000Z","s3":{"bucket":{"name":"b"},"object":{"key":%q,"size":1,"eTag":%q}}}]}`, key, etag)
+}
+
+func deletedEvent(key string) string {
+	return fmt.Sprintf(`{"Records":[{"eventName":"ObjectRemoved:Delete","eventTime":"2024-01-02T03:04:06.000Z","s3":{"bucket":{"name":"b"},"object":{"key":%q}}}]}`, key)
+}
+
+func TestReindex(t *testing.T) {
+	store := s3fstest.NewMemStore()
+	store.WriteFile("docs/a.txt", "a")
+	store.WriteFile("docs/b.txt", "b")
+	store.WriteFile("other/c.txt", "c")
+
+	src := make(chanSource, 8)
+	myFS := s3fs.NewFS(store, s3fs.WithChangeSource(src))
+
+	m, err := myFS.GenerateManifest(context.Background(), "docs")
+	require.Nil(t, err)
+	require.Len(t, m.Entries, 2)
+
+	// changes made while the listing is taken, which it already reflects or not
+	src <- createdEvent("docs/a.txt", m.Entries[0].ETag)
+	src <- createdEvent("other/c.txt", "c")
+	src <- deletedEvent("docs/b.txt")
+	src <- createdEvent("docs/new.txt", "n")
+
+	// a later upload of the same content is reported
+	src <- createdEvent("docs/a.txt", m.Entries[0].ETag)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	sink := &reindexSink{want: 3, cancel: cancel}
+	err = myFS.Reindex(ctx, "docs", sink)
+	require.ErrorIs(t, err, context.Canceled)
+
+	require.Equal(t, m, sink.manifest)
+	require.Len(t, sink.changes, 3)
+	require.Equal(t, s3fs.ChangeDeleted, sink.changes[0].Kind)
+	require.Equal(t, "docs/b.txt", sink.changes[0].Name)
+	require.Equal(t, s3fs.ChangeCreated, sink.changes[1].Kind)
+	require.Equal(t, "docs/new.txt", sink.changes[1].Name)
+	require.Equal(t, "docs/a.txt", sink.changes[2].Name)
+}
+
+func TestReindex_SinkError(t *testing.T) {
+	src := make(chanSource, 1)
+	myFS := s3fs.NewFS(s3fstest.NewMemStore(), s3fs.WithChangeSource(src))
+
+	src <- createdEvent("new.txt", "n")
+
+	failed := errors.New("index unavailable")
+	sink := &failingSink{err: failed}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	require.ErrorIs(t, myFS.Reindex(ctx, ".", sink), failed)
+}
+
+func TestReindex_NoSource(t *testing.T) {
+	myFS := s3fs.NewFS(s3fstest.NewMemStore())
+
+	err := myFS.Reindex(context.Background(), ".", &failingSink{})
+	require.ErrorIs(t, err, s3fs.ErrNoChangeSource)
+}
+
+// failingSink fails every change with err.
+type failingSink struct {
+	err error
+}
+
+func (f *failingSink) Listed(m *s3fs.Manifest) error {
+	return nil
+}
+
+func (f *failingSink) Changed(event s3fs.ChangeEvent) error {
+	return f.err
+}
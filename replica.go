@@ -0,0 +1,227 @@
+package s3fs
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// NewS3Store returns the ObjectStore NewS3FS uses for bucket, e.g. to pass a bucket in
+// another region to WithReadReplica.
+func NewS3Store(client *s3.S3, bucket string) ObjectStore {
+	return newS3Store(client, bucket)
+}
+
+// WithReadReplica makes reads fall back to replica, typically a bucket that S3
+// replicates the FS's bucket to in another region, so read paths survive a regional
+// S3 incident. Reads go to the FS's own store first. If it fails, or hasn't answered
+// within hedgeAfter, the same read is sent to replica too and whichever succeeds first
+// is used. A hedgeAfter of zero only falls back on errors.
+//
+// Answers that the primary store is authoritative for, such as a file not existing or
+// not being modified, are never retried against the replica, since it may lag behind.
+// Listings only fall back if they fail before returning their first page. Writes
+// always go to the FS's own store. Objects read from an S3 replica have a
+// ReplicationStatus of "REPLICA", so callers can tell when they were served by it.
+func WithReadReplica(replica ObjectStore, hedgeAfter time.Duration) Option {
+	return func(s *S3FS) {
+		s.replica = replica
+		s.hedgeAfter = hedgeAfter
+	}
+}
+
+// failoverStore sends reads to replica when primary fails or is slow.
+type failoverStore struct {
+	primary    ObjectStore
+	replica    ObjectStore
+	hedgeAfter time.Duration
+	clock      Clock
+}
+
+// authoritative reports whether err is an answer from the primary store, rather than
+// a failure to get one.
+func authoritative(err error) bool {
+	return err == nil ||
+		errors.Is(err, fs.ErrNotExist) ||
+		errors.Is(err, ErrPreconditionFailed) ||
+		errors.Is(err, ErrNotModified)
+}
+
+type hedgeResult struct {
+	v       interface{}
+	err     error
+	replica bool
+}
+
+// hedge calls primary, and replica too if primary fails or takes longer than
+// hedgeAfter, returning the first success. The context the returned value was fetched
+// with is only cancelled by calling the returned function, so bodies can still be
+// read. Attempts that lose are cancelled, and their values passed to discard.
+func (f *failoverStore) hedge(ctx context.Context, primary, replica func(context.Context) (interface{}, error), discard func(interface{})) (interface{}, context.CancelFunc, error) {
+	results := make(chan hedgeResult, 2)
+	cancels := map[bool]context.CancelFunc{}
+
+	start := func(fn func(context.Context) (interface{}, error), isReplica bool) {
+		actx, cancel := context.WithCancel(ctx)
+		cancels[isReplica] = cancel
+
+		go func() {
+			v, err := fn(actx)
+			results <- hedgeResult{v: v, err: err, replica: isReplica}
+		}()
+	}
+
+	// stop cancels the attempts still running and discards what they return
+	stop := func(running int, winner bool) {
+		for isReplica, cancel := range cancels {
+			if isReplica != winner {
+				cancel()
+			}
+		}
+
+		go func() {
+			for i := 0; i < running; i++ {
+				if r := <-results; r.err == nil {
+					discard(r.v)
+				}
+			}
+		}()
+	}
+
+	start(primary, false)
+	running := 1
+	hedged := false
+
+	var timer <-chan time.Time
+	if f.hedgeAfter > 0 {
+		timer = f.clock.After(f.hedgeAfter)
+	}
+
+	var primaryErr error
+	primaryDone := false
+
+	for {
+		select {
+		case <-timer:
+			timer = nil
+			if !hedged {
+				hedged = true
+				start(replica, true)
+				running++
+			}
+
+		case r := <-results:
+			running--
+
+			if r.err == nil || (!r.replica && authoritative(r.err)) {
+				stop(running, r.replica)
+				return r.v, cancels[r.replica], r.err
+			}
+
+			cancels[r.replica]()
+
+			if !r.replica {
+				primaryErr = r.err
+				primaryDone = true
+
+				if ctx.Err() != nil {
+					stop(running, false)
+					return nil, func() {}, primaryErr
+				}
+			}
+
+			if !hedged {
+				hedged = true
+				start(replica, true)
+				running++
+				continue
+			}
+
+			if primaryDone && running == 0 {
+				return nil, func() {}, primaryErr
+			}
+		}
+	}
+}
+
+func (f *failoverStore) List(ctx context.Context, prefix string, opts ListOptions, fn func(*ListPage) bool) error {
+	listed := false
+	err := f.primary.List(ctx, prefix, opts, func(page *ListPage) bool {
+		listed = true
+		return fn(page)
+	})
+
+	if err == nil || listed || ctx.Err() != nil || authoritative(err) {
+		return err
+	}
+
+	if f.replica.List(ctx, prefix, opts, fn) != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (f *failoverStore) Head(ctx context.Context, key string) (ObjectInfo, error) {
+	v, cancel, err := f.hedge(
+		ctx,
+		func(ctx context.Context) (interface{}, error) { return f.primary.Head(ctx, key) },
+		func(ctx context.Context) (interface{}, error) { return f.replica.Head(ctx, key) },
+		func(interface{}) {},
+	)
+	cancel()
+
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+
+	return v.(ObjectInfo), nil
+}
+
+func (f *failoverStore) Get(ctx context.Context, key string, opts GetOptions) (*Object, error) {
+	v, cancel, err := f.hedge(
+		ctx,
+		func(ctx context.Context) (interface{}, error) { return f.primary.Get(ctx, key, opts) },
+		func(ctx context.Context) (interface{}, error) { return f.replica.Get(ctx, key, opts) },
+		func(v interface{}) { v.(*Object).Body.Close() },
+	)
+
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	object := v.(*Object)
+	object.Body = &cancelOnClose{ReadCloser: object.Body, cancel: cancel}
+
+	return object, nil
+}
+
+func (f *failoverStore) Put(ctx context.Context, key string, body io.Reader, opts PutOptions) (ObjectInfo, error) {
+	return f.primary.Put(ctx, key, body, opts)
+}
+
+func (f *failoverStore) Delete(ctx context.Context, key string) error {
+	return f.primary.Delete(ctx, key)
+}
+
+func (f *failoverStore) Copy(ctx context.Context, src, dst string, opts CopyOptions) error {
+	return f.primary.Copy(ctx, src, dst, opts)
+}
+
+// cancelOnClose cancels the context a body is being read with once it's closed.
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c *cancelOnClose) Close() error {
+	err := c.ReadCloser.Close()
+	c.cancel()
+
+	return err
+}
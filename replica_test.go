@@ -0,0 +1,72 @@
+package s3fs_test
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"testing"
+	"time"
+
+	"github.com/packrat386/s3fs"
+	"github.com/packrat386/s3fs/s3fstest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithReadReplica(t *testing.T) {
+	primary := unreachableStore{s3fstest.NewMemStore()}
+	replica := s3fstest.NewMemStore()
+	replica.WriteFile("docs/a.txt", "from the replica")
+
+	myFS := s3fs.NewFS(primary, s3fs.WithReadReplica(replica, 0))
+
+	data, err := fs.ReadFile(myFS, "docs/a.txt")
+	require.Nil(t, err)
+	require.Equal(t, "from the replica", string(data))
+
+	entries, err := fs.ReadDir(myFS, "docs")
+	require.Nil(t, err)
+	require.Equal(t, []string{"a.txt"}, entryNames(entries))
+
+	// writes only go to the primary
+	require.Nil(t, myFS.WriteFile("new.txt", []byte("new")))
+	_, err = replica.Head(context.Background(), "new.txt")
+	require.True(t, errors.Is(err, fs.ErrNotExist))
+}
+
+func TestWithReadReplica_Slow(t *testing.T) {
+	primary := &slowStore{MemStore: s3fstest.NewMemStore(), slow: true}
+	primary.WriteFile("a.txt", "primary")
+
+	replica := s3fstest.NewMemStore()
+	replica.WriteFile("a.txt", "replica")
+
+	myFS := s3fs.NewFS(primary, s3fs.WithReadReplica(replica, 5*time.Millisecond))
+
+	f, err := myFS.OpenIf(context.Background(), "a.txt", s3fs.ReadConditions{})
+	require.Nil(t, err)
+
+	buf := make([]byte, 16)
+	n, _ := f.Read(buf)
+	require.Nil(t, f.Close())
+	require.Equal(t, "replica", string(buf[:n]))
+
+	primary.slow = false
+
+	f, err = myFS.OpenIf(context.Background(), "a.txt", s3fs.ReadConditions{})
+	require.Nil(t, err)
+
+	n, _ = f.Read(buf)
+	require.Nil(t, f.Close())
+	require.Equal(t, "primary", string(buf[:n]))
+}
+
+func TestWithReadReplica_NotExist(t *testing.T) {
+	primary := s3fstest.NewMemStore()
+	replica := s3fstest.NewMemStore()
+	replica.WriteFile("deleted.txt", "still replicated")
+
+	myFS := s3fs.NewFS(primary, s3fs.WithReadReplica(replica, 0))
+
+	_, err := myFS.StatFile(context.Background(), "deleted.txt")
+	require.True(t, errors.Is(err, fs.ErrNotExist))
+}
@@ -1,40 +1,255 @@
 package s3fs
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"io/fs"
+	"net/http"
 	"path"
-	"strings"
+	"sync"
 	"time"
 
-	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/arn"
 	"github.com/aws/aws-sdk-go/service/s3"
 )
 
-type s3FS struct {
-	client *s3.S3
-	bucket string
+// S3FS is an fs.FS backed by an ObjectStore, usually S3. Create one with NewS3FS,
+// NewS3FSFromConfig, or NewFS.
+type S3FS struct {
+	store     ObjectStore
+	bucketErr error
+
+	maxConcurrency   int
+	downloadProgress func(done, total int)
+	progress         ProgressFunc
+	uploadHooks      UploadHooks
+	atomicPrefix     string
+	startHooks       []func()
+	writeBack        *writeBack
+	quota            *writeQuota
+	readOnly         bool
+
+	skipStorageClasses map[string]bool
+	leaks              *leakDetector
+	spillDir           string
+	spillThreshold     int
+	lazyListing        bool
+	listingShards      int
+	shardedPrefixes    []string
+	dryRun             *CostReport
+	audit              AuditFunc
+	auditCallers       bool
+	namePolicy         NamePolicy
+	strictPaths        bool
+	conflictPolicy     ConflictPolicy
+	unicodeForm        UnicodeForm
+	blobPrefix         string
+	cloudFront         *cloudFront
+	columnar           bool
+	changeSource       ChangeSource
+	journal            *journal
+	cache              Cache
+	cacheMaxAge        time.Duration
+	offline            bool
+	scheduler          *scheduler
+	buffers            *BufferPool
+	streamParts        int
+	streamPartSize     int64
+	chunkSize          int64
+	encryption         *envelope
+	compression        Compression
+	contentHashes      bool
+	skipUnchanged      bool
+	replica            ObjectStore
+	hedgeAfter         time.Duration
+	clock              Clock
+	httpClient         *http.Client
+	transportTuning    *transportTuning
+	closeDrain         int64
+	maxOpenSize        int64
+	location           *location
+
+	// background is cancelled when the FS is closed, to stop work started by options
+	background     context.Context
+	stopBackground context.CancelFunc
+
+	// closers release resources owned by the FS when it is closed
+	closers   []func()
+	closed    int32
+	closeOnce sync.Once
+	closeErr  error
 }
 
-func NewS3FS(client *s3.S3, bucket string) fs.FS {
-	return &s3FS{
-		client: client,
-		bucket: bucket,
+// NewS3FS returns an fs.FS backed by the given bucket. bucket may be a plain bucket
+// name, an S3 Express One Zone directory bucket name, an access point alias, or an
+// access point ARN, including the ARN of a multi-region access point.
+func NewS3FS(client *s3.S3, bucket string, opts ...Option) *S3FS {
+	s := newFS(newS3Store(client, bucket), opts...)
+	s.bucketErr = checkBucket(bucket)
+	s.start()
+
+	return s
+}
+
+// NewFS returns an fs.FS backed by an arbitrary ObjectStore.
+func NewFS(store ObjectStore, opts ...Option) *S3FS {
+	s := newFS(store, opts...)
+	s.start()
+
+	return s
+}
+
+func newFS(store ObjectStore, opts ...Option) *S3FS {
+	s := &S3FS{
+		store:      store,
+		closeDrain: defaultCloseDrain,
+	}
+	s.background, s.stopBackground = context.WithCancel(context.Background())
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	if s.buffers == nil {
+		s.buffers = NewBufferPool(defaultBufferSize)
+	}
+
+	if s.clock == nil {
+		s.clock = realClock{}
 	}
+
+	s.background = WithPriority(s.background, PriorityBackground)
+
+	if s.replica != nil {
+		s.store = &failoverStore{primary: s.store, replica: s.replica, hedgeAfter: s.hedgeAfter, clock: s.clock}
+	}
+
+	// wrapped after the replica so a dry run doesn't read from it either, and baseStore
+	// stops at it so nothing bypasses it
+	if s.dryRun != nil {
+		s.store = &dryRunStore{store: s.store, report: s.dryRun}
+	}
+
+	stores, allS3 := s3Stores(s.store)
+	for _, st := range stores {
+		st.clock = s.clock
+	}
+
+	// S3 stores take a slot for each request they send themselves, so that optional
+	// operations are limited too and streaming uploads only hold one per part
+	if allS3 && s.scheduler != nil {
+		for _, st := range stores {
+			st.scheduler = s.scheduler
+		}
+	} else if s.scheduler != nil {
+		s.store = &scheduledStore{store: s.store, scheduler: s.scheduler}
+	}
+
+	if s.listingShards > 1 {
+		s.store = &shardedStore{ObjectStore: s.store, shards: s.listingShards, prefixes: s.shardedPrefixes}
+	}
+
+	if s.unicodeForm != 0 {
+		s.store = &normalizedStore{ObjectStore: s.store, form: s.unicodeForm}
+	}
+
+	s.location = newLocation(store)
+
+	return s
+}
+
+// baseStore returns the store the FS was created with, for checking which optional
+// operations it supports.
+func (s *S3FS) baseStore() ObjectStore {
+	store := s.store
+	if normalized, ok := store.(*normalizedStore); ok {
+		store = normalized.ObjectStore
+	}
+
+	if sharded, ok := store.(*shardedStore); ok {
+		store = sharded.ObjectStore
+	}
+
+	if scheduled, ok := store.(*scheduledStore); ok {
+		store = scheduled.store
+	}
+
+	if failover, ok := store.(*failoverStore); ok {
+		store = failover.primary
+	}
+
+	return store
 }
 
-func (s *s3FS) Open(name string) (fs.File, error) {
-	name, err := trimName(name)
+// start kicks off any background work requested by options, once the FS is fully
+// set up.
+func (s *S3FS) start() {
+	for _, hook := range s.startHooks {
+		hook()
+	}
+}
+
+func (s *S3FS) Open(name string) (fs.File, error) {
+	if s.bucketErr != nil {
+		return nil, s.bucketErr
+	}
+
+	if err := s.checkOpen(); err != nil {
+		return nil, err
+	}
+
+	name, err := s.key("open", name)
 	if err != nil {
-		return nil, fmt.Errorf("could not format filename: %w", err)
+		return nil, err
 	}
 
-	// special case root of the bucket
+	s.auditRead(OpOpen, name)
+
+	if s.offline {
+		return openOffline(s, name)
+	}
+
+	// special case root of the bucket, which is always a directory, even if the
+	// bucket is empty
 	if name == "" {
 		return openDir(s, name)
 	}
 
+	if s.writeBack != nil {
+		if f, ok, err := s.writeBack.open(name); ok {
+			return f, err
+		}
+	}
+
+	fileMatch, dirMatch, err := s.lookup(context.Background(), name)
+	if err != nil {
+		return nil, fmt.Errorf("could not list s3 objects: %w", err)
+	}
+
+	if fileMatch && dirMatch {
+		preferFile, err := s.resolveConflict(name)
+		if err != nil {
+			return nil, err
+		}
+
+		fileMatch, dirMatch = preferFile, !preferFile
+	}
+
+	if fileMatch {
+		f, err := openFile(s, name)
+		return s.limitSize(name, f, err)
+	}
+
+	if dirMatch {
+		return openDir(s, name+"/")
+	}
+
+	return nil, fs.ErrNotExist
+}
+
+// lookup reports whether name is a file, a directory, or both.
+func (s *S3FS) lookup(ctx context.Context, name string) (fileMatch bool, dirMatch bool, err error) {
 	// could be either a file or a directory at this point, so list with the name as a prefix.
 	// if we find an exact match for either an object or a common prefix, then open that.
 	// if neither match the name exactly then for our purposes it doesn't exist.
@@ -42,24 +257,19 @@ func (s *s3FS) Open(name string) (fs.File, error) {
 	// note that because s3 isn't really a filesystem, its possible to find both an object
 	// and a common prefix with the same exact name as `name`. If both match return an error.
 
-	fileMatch := false
-	dirMatch := false
-
-	err = s.client.ListObjectsV2Pages(
-		&s3.ListObjectsV2Input{
-			Bucket:    &s.bucket,
-			Delimiter: aws.String("/"),
-			Prefix:    aws.String(name),
-		},
-		func(page *s3.ListObjectsV2Output, lastPage bool) bool {
-			for _, obj := range page.Contents {
-				if *obj.Key == name {
+	err = s.store.List(
+		ctx,
+		name,
+		ListOptions{Delimiter: "/"},
+		func(page *ListPage) bool {
+			for _, obj := range page.Objects {
+				if obj.Key == name {
 					fileMatch = true
 				}
 			}
 
 			for _, cp := range page.CommonPrefixes {
-				if name+"/" == *cp.Prefix {
+				if name+"/" == cp {
 					dirMatch = true
 				}
 			}
@@ -68,67 +278,120 @@ func (s *s3FS) Open(name string) (fs.File, error) {
 		},
 	)
 
-	if err != nil {
-		return nil, fmt.Errorf("could not list s3 objects: %w", err)
-	}
+	return fileMatch, dirMatch, err
+}
 
-	if fileMatch && dirMatch {
-		return nil, fmt.Errorf("directory name matches file name: %s", name)
+func openDir(s *S3FS, name string) (fs.File, error) {
+	if s.lazyListing {
+		return openLazyDir(s, name)
 	}
 
-	if fileMatch {
-		return openFile(s, name)
-	}
+	entries := []fs.DirEntry{}
+	var spill *dirSpill
+	var spillErr error
+	conflicts := s.newDirConflicts()
+
+	// add adds an entry to the directory, moving them all to a spill file once there
+	// are too many to keep in memory
+	add := func(fi *s3FileInfo) bool {
+		if spill == nil && s.spillThreshold > 0 && len(entries) >= s.spillThreshold {
+			spill, spillErr = spillEntries(s.spillDir, entries)
+			entries = nil
+		}
 
-	if dirMatch {
-		return openDir(s, name+"/")
-	}
+		if spillErr != nil {
+			return false
+		}
 
-	return nil, fs.ErrNotExist
-}
+		conflicts.add(fi)
+
+		if spill == nil {
+			entries = append(entries, fi)
+			return true
+		}
+
+		spillErr = spill.add(fi)
+
+		return spillErr == nil
+	}
 
-func openDir(s *s3FS, name string) (fs.File, error) {
-	entries := []fs.DirEntry{}
 	duplicateName := false
-	err := s.client.ListObjectsV2Pages(
-		&s3.ListObjectsV2Input{
-			Bucket:    &s.bucket,
-			Delimiter: aws.String("/"),
-			Prefix:    aws.String(name),
-		},
-		func(page *s3.ListObjectsV2Output, lastPage bool) bool {
-			for _, obj := range page.Contents {
-				if *obj.Key == name {
+	listed := false
+	err := s.store.List(
+		context.Background(),
+		name,
+		ListOptions{Delimiter: "/"},
+		func(page *ListPage) bool {
+			for _, obj := range page.Objects {
+				obj := obj
+				if obj.Key == name {
+					// a directory marker, which shows the directory exists
+					if conflicts != nil {
+						listed = true
+						continue
+					}
+
 					duplicateName = true
 					return false
 				}
 
-				entries = append(
-					entries,
-					&s3FileInfo{
-						name:    path.Base(*obj.Key),
-						mode:    fs.FileMode(0400),
-						size:    *obj.Size,
-						modTime: *obj.LastModified,
-					},
-				)
+				listed = true
+				if s.skipStorageClasses[obj.StorageClass] {
+					continue
+				}
+
+				ok := add(&s3FileInfo{
+					name:    path.Base(obj.Key),
+					mode:    fs.FileMode(0400),
+					size:    obj.Size,
+					modTime: obj.LastModified,
+					object:  &obj,
+					key:     obj.Key,
+					loc:     s.location,
+				})
+				if !ok {
+					return false
+				}
 			}
 
 			for _, cp := range page.CommonPrefixes {
-				entries = append(
-					entries,
-					&s3FileInfo{
-						name: path.Base(*cp.Prefix),
-						mode: fs.FileMode(0400) | fs.ModeDir,
-						size: 0,
-					},
-				)
+				listed = true
+				ok := add(&s3FileInfo{
+					name: path.Base(cp),
+					mode: fs.FileMode(0400) | fs.ModeDir,
+					size: 0,
+					key:  cp,
+					loc:  s.location,
+				})
+				if !ok {
+					return false
+				}
 			}
 
 			return true
 		},
 	)
 
+	if err == nil {
+		err = spillErr
+	}
+
+	if err == nil && spill != nil {
+		spill.conflicts = conflicts
+		err = spill.rewind()
+	}
+
+	// the root always exists, even in an empty bucket
+	if name == "" {
+		listed = true
+	}
+
+	if err != nil || duplicateName || !listed {
+		if spill != nil {
+			spill.close()
+		}
+	}
+
 	if err != nil {
 		return nil, fmt.Errorf("error listing s3 dir: %w", err)
 	}
@@ -137,55 +400,122 @@ func openDir(s *s3FS, name string) (fs.File, error) {
 		return nil, fmt.Errorf("directory name matches file name: %s", name)
 	}
 
-	if len(entries) == 0 {
+	if !listed {
 		return nil, fs.ErrNotExist
 	}
 
+	if spill == nil {
+		entries = conflicts.filter(entries)
+		s.cacheListing(name, entries)
+	}
+
 	return &s3Directory{
 		entries: entries,
-		ptr:     0,
+		spill:   spill,
 		fileInfo: s3FileInfo{
 			name: path.Base(name),
 			mode: fs.FileMode(0400) | fs.ModeDir,
 			size: 0,
+			key:  name,
+			loc:  s.location,
 		},
 	}, nil
 }
 
-func openFile(s *s3FS, name string) (fs.File, error) {
-	object, err := s.client.GetObject(&s3.GetObjectInput{
-		Bucket: &s.bucket,
-		Key:    &name,
-	})
+func openFile(s *S3FS, name string) (fs.File, error) {
+	if s.encryption != nil || s.compression != nil {
+		return openDecoded(context.Background(), s, name, GetOptions{})
+	}
+
+	if s.chunkSize > 0 {
+		f, err := openChunked(context.Background(), s, name, GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+
+		return f, nil
+	}
+
+	if s.columnar {
+		return openColumnar(s, name)
+	}
+
+	if s.streamParts > 1 {
+		return openParallel(context.Background(), s, name)
+	}
+
+	if s.cache != nil {
+		return openCached(context.Background(), s, name)
+	}
 
+	return openObject(context.Background(), s, name, GetOptions{})
+}
+
+func openObject(ctx context.Context, s *S3FS, name string, opts GetOptions) (fs.File, error) {
+	stats := TransferStats{}
+	object, err := getObject(ctx, s.store, name, opts, &stats)
 	if err != nil {
 		return nil, fmt.Errorf("error getting s3 object: %w", err)
 	}
 
-	return &s3File{
-		body: object.Body,
+	if err := s.checkSize(name, object.Info.Size); err != nil {
+		object.Body.Close()
+		return nil, err
+	}
+
+	f := newS3File(s, name, object)
+	f.stats = stats
+
+	return f, nil
+}
+
+// newS3File returns an open file streaming object.
+func newS3File(s *S3FS, name string, object *Object) *s3File {
+	f := &s3File{
+		name:       name,
+		progress:   s.progress,
+		buffers:    s.buffers,
+		closeDrain: s.closeDrain,
+		body:       object.Body,
 		fileInfo: s3FileInfo{
 			name:    path.Base(name),
 			mode:    fs.FileMode(0400),
-			size:    *object.ContentLength,
-			modTime: *object.LastModified,
+			size:    object.Info.Size,
+			modTime: object.Info.LastModified,
+			object:  &object.Info,
+			key:     name,
+			loc:     s.location,
 		},
-	}, nil
+	}
+	s.leaks.track(f)
+
+	return f
 }
 
-func trimName(name string) (string, error) {
-	if !fs.ValidPath(name) {
-		return "", fmt.Errorf("invalid name: %s", name)
+// checkBucket validates a bucket given as an ARN. The SDK resolves access point ARNs
+// to the right endpoint on its own (set S3UseARNRegion on the session if the access
+// point is in a different region than the client), and the store sends requests for
+// multi-region access points itself, so all we have to do is reject the ARNs neither
+// can handle.
+func checkBucket(bucket string) error {
+	if !arn.IsARN(bucket) {
+		return nil
 	}
 
-	if name == "." {
-		return "", nil
+	a, err := arn.Parse(bucket)
+	if err != nil {
+		return fmt.Errorf("invalid bucket ARN: %w", err)
 	}
 
-	return strings.TrimPrefix(
-		name,
-		"./",
-	), nil
+	if a.Service != "s3" && a.Service != "s3-outposts" && a.Service != "s3-object-lambda" {
+		return fmt.Errorf("invalid bucket ARN: unsupported service: %s", a.Service)
+	}
+
+	if isMultiRegionAccessPoint(a) && a.Partition != "aws" {
+		return fmt.Errorf("invalid bucket ARN: multi-region access points are only in the aws partition: %s", bucket)
+	}
+
+	return nil
 }
 
 type s3FileInfo struct {
@@ -193,6 +523,14 @@ type s3FileInfo struct {
 	size    int64
 	modTime time.Time
 	mode    fs.FileMode
+
+	// object is the metadata of the file, nil for directories
+	object *ObjectInfo
+
+	// key is the key of the file, or the prefix of the keys in the directory ending
+	// with a slash ("" for the root), and loc the bucket and scope it's in
+	key string
+	loc *location
 }
 
 func (fi *s3FileInfo) Name() string {
@@ -215,8 +553,14 @@ func (fi *s3FileInfo) IsDir() bool {
 	return fi.Mode().IsDir()
 }
 
+// Sys returns the ObjectInfo of a file, which includes its key and ETag, or nil for a
+// directory.
 func (fi *s3FileInfo) Sys() interface{} {
-	return nil
+	if fi.object == nil {
+		return nil
+	}
+
+	return *fi.object
 }
 
 func (fi *s3FileInfo) Info() (fs.FileInfo, error) {
@@ -228,8 +572,25 @@ func (fi *s3FileInfo) Type() fs.FileMode {
 }
 
 type s3File struct {
+	name     string
 	body     io.ReadCloser
 	fileInfo s3FileInfo
+
+	read     int64
+	progress ProgressFunc
+	stats    TransferStats
+
+	// buffers is where WriteTo takes its copy buffer from
+	buffers *BufferPool
+
+	// closeDrain is how much of the body Close may read, see WithCloseDrain, and
+	// bodyErr the error other than io.EOF that reading it failed with, if any
+	closeDrain int64
+	bodyErr    error
+
+	// set if leak detection is on
+	leaks  *leakDetector
+	leakID int64
 }
 
 func (f *s3File) Stat() (fs.FileInfo, error) {
@@ -237,17 +598,42 @@ func (f *s3File) Stat() (fs.FileInfo, error) {
 }
 
 func (f *s3File) Read(buf []byte) (int, error) {
-	return f.body.Read(buf)
+	n, err := f.body.Read(buf)
+	f.read += int64(n)
+	err = f.checkLength(err)
+
+	if err != nil && err != io.EOF {
+		f.bodyErr = err
+	}
+
+	if f.progress != nil && n > 0 {
+		f.progress(f.name, f.read, f.fileInfo.size)
+	}
+
+	return n, err
 }
 
 func (f *s3File) Close() error {
+	if f.leaks != nil {
+		f.leaks.closed(f)
+	}
+
+	f.drain()
+
 	return f.body.Close()
 }
 
+// s3Directory is an open directory. It is safe for concurrent use, and can be read
+// again from the start by seeking to offset 0.
 type s3Directory struct {
 	entries  []fs.DirEntry
-	ptr      int
 	fileInfo s3FileInfo
+
+	// spill holds the entries instead if there were too many, see WithListingSpill
+	spill *dirSpill
+
+	mu  sync.Mutex
+	ptr int
 }
 
 func (d *s3Directory) Stat() (fs.FileInfo, error) {
@@ -259,10 +645,40 @@ func (d *s3Directory) Read(buf []byte) (int, error) {
 }
 
 func (d *s3Directory) Close() error {
+	if d.spill != nil {
+		return d.spill.close()
+	}
+
 	return nil
 }
 
+// Seek rewinds the directory so the next ReadDir starts from the first entry again,
+// like os.File does for directories. The only offset it supports is 0 from the start.
+func (d *s3Directory) Seek(offset int64, whence int) (int64, error) {
+	if offset != 0 || whence != io.SeekStart {
+		return 0, fmt.Errorf("can only seek a directory to its start")
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.spill != nil {
+		return 0, d.spill.rewind()
+	}
+
+	d.ptr = 0
+
+	return 0, nil
+}
+
 func (d *s3Directory) ReadDir(n int) ([]fs.DirEntry, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.spill != nil {
+		return d.spill.read(n)
+	}
+
 	out := []fs.DirEntry{}
 	if n <= 0 {
 		for d.ptr < len(d.entries) {
@@ -2,6 +2,7 @@ package s3fs
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/fs"
 	"os"
@@ -133,7 +134,26 @@ func TestS3FS_FileEndingWithSlash(t *testing.T) {
 	myFS := NewS3FS(client, bucket)
 	_, err = myFS.Open("weird/")
 	require.NotNil(t, err)
-	require.Contains(t, err.Error(), "invalid name")
+	require.True(t, errors.Is(err, fs.ErrInvalid))
+}
+
+func TestCheckBucket(t *testing.T) {
+	require.Nil(t, checkBucket("my-bucket"))
+	require.Nil(t, checkBucket("my-ap-abcdefghijklmnopqrstuvwxyz-s3alias"))
+	require.Nil(t, checkBucket("arn:aws:s3:us-west-2:123456789012:accesspoint/my-ap"))
+	require.Nil(t, checkBucket("arn:aws:s3-outposts:us-west-2:123456789012:outpost/op-01ac5d28a6a232904/accesspoint/my-ap"))
+
+	require.Nil(t, checkBucket("arn:aws:s3::123456789012:accesspoint/mfzwi23gnjvgw.mrap"))
+
+	err := checkBucket("arn:aws-cn:s3::123456789012:accesspoint/mfzwi23gnjvgw.mrap")
+	require.NotNil(t, err)
+	require.Contains(t, err.Error(), "only in the aws partition")
+
+	require.Nil(t, checkBucket("my-bucket--usw2-az1--x-s3"))
+
+	err = checkBucket("arn:aws:sqs:us-west-2:123456789012:my-queue")
+	require.NotNil(t, err)
+	require.Contains(t, err.Error(), "unsupported service")
 }
 
 func dirEntriesContains(entries []fs.DirEntry, name string) bool {
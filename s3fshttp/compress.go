@@ -0,0 +1,106 @@
+package s3fshttp
+
+import (
+	"compress/gzip"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// encoding is a Content-Encoding that precompressed variants of files can be stored
+// in, next to the file with ext appended to its name.
+type encoding struct {
+	name string
+	ext  string
+}
+
+// precompressedEncodings are the variants WithPrecompressed looks for, in order of
+// preference.
+var precompressedEncodings = []encoding{
+	{name: "br", ext: ".br"},
+	{name: "zstd", ext: ".zst"},
+	{name: "gzip", ext: ".gz"},
+}
+
+// gzipMinSize is the smallest file WithGzip compresses; for anything smaller the
+// gzip header and footer eat up most of the savings.
+const gzipMinSize = 1024
+
+// WithPrecompressed makes the handler serve precompressed variants of files, stored
+// next to them as name.br, name.zst or name.gz, to clients whose Accept-Encoding
+// allows it, preferring brotli, then zstd, then gzip. Variants are served with the
+// content type of the original file's extension. Every GET of a file first looks
+// for the variants the client accepts, so it's only worth using for buckets that
+// have them.
+func WithPrecompressed() Option {
+	return func(h *Handler) {
+		h.precompressed = true
+	}
+}
+
+// WithGzip makes the handler gzip files of compressible types, like text, JSON,
+// JavaScript and SVG, on the fly for clients that accept it, unless a precompressed
+// variant was served. Compressed responses have no Content-Length, and a weak ETag
+// since their bytes differ from the object's.
+func WithGzip() Option {
+	return func(h *Handler) {
+		h.gzip = true
+	}
+}
+
+// accepts reports whether the Accept-Encoding of r allows coding.
+func accepts(r *http.Request, coding string) bool {
+	wildcard := false
+
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		fields := strings.Split(part, ";")
+		name := strings.ToLower(strings.TrimSpace(fields[0]))
+
+		q := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if v, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					q = v
+				}
+			}
+		}
+
+		switch name {
+		case coding:
+			return q > 0
+		case "*":
+			wildcard = q > 0
+		}
+	}
+
+	return wildcard
+}
+
+// compressible reports whether files of contentType are worth gzipping.
+func compressible(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	if strings.HasPrefix(mediaType, "text/") || strings.HasSuffix(mediaType, "+json") || strings.HasSuffix(mediaType, "+xml") {
+		return true
+	}
+
+	switch mediaType {
+	case "application/json", "application/javascript", "application/xml", "application/wasm", "image/svg+xml":
+		return true
+	}
+
+	return false
+}
+
+// gzipTo copies f to w through gzip.
+func gzipTo(w io.Writer, f io.Reader, buf []byte) {
+	zw := gzip.NewWriter(w)
+	io.CopyBuffer(zw, f, buf)
+	zw.Close()
+}
@@ -0,0 +1,115 @@
+package s3fshttp
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/packrat386/s3fs"
+	"github.com/packrat386/s3fs/s3fstest"
+	"github.com/stretchr/testify/require"
+)
+
+func serveEncoded(h http.Handler, method, target, acceptEncoding string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, target, nil)
+	r.Header.Set("Accept-Encoding", acceptEncoding)
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	return w
+}
+
+func TestWithPrecompressed(t *testing.T) {
+	store := s3fstest.NewMemStore()
+	store.WriteFile("app.js", "plain")
+	store.WriteFile("app.js.br", "brotli")
+	store.WriteFile("app.js.gz", "gzipped")
+	store.WriteFile("style.css", "body {}")
+
+	h := NewHandler(s3fs.NewFS(store), WithPrecompressed())
+
+	w := serveEncoded(h, "GET", "/app.js", "gzip, deflate, br")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "brotli", w.Body.String())
+	require.Equal(t, "br", w.Header().Get("Content-Encoding"))
+	require.Equal(t, "Accept-Encoding", w.Header().Get("Vary"))
+	require.Contains(t, w.Header().Get("Content-Type"), "javascript")
+
+	w = serveEncoded(h, "GET", "/app.js", "gzip, br;q=0")
+	require.Equal(t, "gzipped", w.Body.String())
+	require.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+
+	w = serveEncoded(h, "HEAD", "/app.js", "zstd, gzip")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+	require.Equal(t, "7", w.Header().Get("Content-Length"))
+
+	w = serveEncoded(h, "GET", "/app.js", "")
+	require.Equal(t, "plain", w.Body.String())
+	require.Empty(t, w.Header().Get("Content-Encoding"))
+
+	// no variants
+	w = serveEncoded(h, "GET", "/style.css", "br, gzip")
+	require.Equal(t, "body {}", w.Body.String())
+	require.Empty(t, w.Header().Get("Content-Encoding"))
+}
+
+func TestWithGzip(t *testing.T) {
+	text := strings.Repeat("hello, world\n", 200)
+
+	store := s3fstest.NewMemStore()
+	store.WriteFile("big.txt", text)
+	store.WriteFile("small.txt", "hello")
+	store.WriteFile("photo.jpg", text)
+
+	h := NewHandler(s3fs.NewFS(store), WithGzip())
+
+	w := serveEncoded(h, "GET", "/big.txt", "gzip")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+	require.Empty(t, w.Header().Get("Content-Length"))
+	require.True(t, strings.HasPrefix(w.Header().Get("ETag"), "W/"))
+	require.Less(t, w.Body.Len(), len(text))
+
+	zr, err := gzip.NewReader(w.Body)
+	require.Nil(t, err)
+	data, err := io.ReadAll(zr)
+	require.Nil(t, err)
+	require.Equal(t, text, string(data))
+
+	// the weak ETag still matches
+	r := httptest.NewRequest("GET", "/big.txt", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	r.Header.Set("If-None-Match", w.Header().Get("ETag"))
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	require.Equal(t, http.StatusNotModified, w.Code)
+
+	w = serveEncoded(h, "GET", "/big.txt", "identity")
+	require.Equal(t, text, w.Body.String())
+	require.Empty(t, w.Header().Get("Content-Encoding"))
+
+	w = serveEncoded(h, "GET", "/small.txt", "gzip")
+	require.Equal(t, "hello", w.Body.String())
+
+	w = serveEncoded(h, "GET", "/photo.jpg", "gzip")
+	require.Empty(t, w.Header().Get("Content-Encoding"))
+}
+
+func TestAccepts(t *testing.T) {
+	r := httptest.NewRequest("GET", "/", nil)
+	require.False(t, accepts(r, "gzip"))
+
+	r.Header.Set("Accept-Encoding", "gzip;q=0.5, br;q=0")
+	require.True(t, accepts(r, "gzip"))
+	require.False(t, accepts(r, "br"))
+	require.False(t, accepts(r, "zstd"))
+
+	r.Header.Set("Accept-Encoding", "*, br;q=0")
+	require.True(t, accepts(r, "zstd"))
+	require.False(t, accepts(r, "br"))
+}
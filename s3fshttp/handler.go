@@ -0,0 +1,439 @@
+// Package s3fshttp serves an s3fs.S3FS over HTTP.
+//
+// It's meant for simple static sites and file shares: files are streamed straight
+// from the store with their stored content type, directories resolve to their
+// index.html, and directories without one can optionally be rendered as index pages.
+package s3fshttp
+
+import (
+	"bytes"
+	"errors"
+	"html/template"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/packrat386/s3fs"
+)
+
+// indexFile is served in place of a directory that contains it.
+const indexFile = "index.html"
+
+// Handler is an http.Handler serving the files of an FS. Create one with NewHandler.
+type Handler struct {
+	fsys *s3fs.S3FS
+
+	// index renders directories without an index.html, or is nil if they aren't
+	// served
+	index *template.Template
+
+	// see WithPrecompressed and WithGzip
+	precompressed bool
+	gzip          bool
+}
+
+// Option configures optional behavior of a Handler.
+type Option func(*Handler)
+
+// DirectoryIndex is what directory index templates are executed with.
+type DirectoryIndex struct {
+	// Path is the URL path of the directory, ending with a slash.
+	Path string
+
+	// Entries are the contents of the directory, sorted by name.
+	Entries []IndexEntry
+}
+
+// IndexEntry is a file or subdirectory in a DirectoryIndex.
+type IndexEntry struct {
+	Name    string
+	IsDir   bool
+	Size    int64
+	ModTime time.Time
+}
+
+// DefaultIndexTemplate is used by WithDirectoryIndex when it isn't given a template.
+var DefaultIndexTemplate = template.Must(template.New("index").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Index of {{.Path}}</title></head>
+<body>
+<h1>Index of {{.Path}}</h1>
+<ul>
+{{- if ne .Path "/"}}
+<li><a href="../">../</a></li>
+{{- end}}
+{{- range .Entries}}
+<li><a href="{{.Name}}{{if .IsDir}}/{{end}}">{{.Name}}{{if .IsDir}}/{{end}}</a></li>
+{{- end}}
+</ul>
+</body>
+</html>
+`))
+
+// WithDirectoryIndex makes the handler render a page listing the contents of
+// directories that don't have an index.html, using tmpl, which is executed with a
+// DirectoryIndex. If tmpl is nil DefaultIndexTemplate is used. Without this option
+// such directories are not found.
+func WithDirectoryIndex(tmpl *template.Template) Option {
+	return func(h *Handler) {
+		if tmpl == nil {
+			tmpl = DefaultIndexTemplate
+		}
+
+		h.index = tmpl
+	}
+}
+
+// NewHandler returns a Handler serving the files of fsys, with URL paths mapped
+// directly to names. Requests for a directory are redirected to its path with a
+// trailing slash and then served its index.html.
+func NewHandler(fsys *s3fs.S3FS, opts ...Option) *Handler {
+	h := &Handler{fsys: fsys}
+	for _, opt := range opts {
+		opt(h)
+	}
+
+	return h
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if h.precompressed || h.gzip {
+		w.Header().Set("Vary", "Accept-Encoding")
+	}
+
+	urlPath := path.Clean("/" + r.URL.Path)
+	name := strings.TrimPrefix(urlPath, "/")
+	if name == "" {
+		name = "."
+	}
+
+	// most requests are for files, which can be served without listing
+	if name != "." && !strings.HasSuffix(r.URL.Path, "/") && h.serveObject(w, r, name) {
+		return
+	}
+
+	f, err := h.fsys.Open(name)
+	if err != nil {
+		h.error(w, err)
+		return
+	}
+	defer f.Close()
+
+	dir, ok := f.(fs.ReadDirFile)
+	if !ok {
+		h.serveFile(w, r, f, name)
+		return
+	}
+
+	if !strings.HasSuffix(r.URL.Path, "/") {
+		redirect(w, r, path.Base(urlPath)+"/")
+		return
+	}
+
+	if h.serveObject(w, r, path.Join(name, indexFile)) {
+		return
+	}
+
+	if h.index == nil {
+		h.error(w, fs.ErrNotExist)
+		return
+	}
+
+	h.serveIndex(w, r, dir, urlPath)
+}
+
+// serveObject serves the file at name, or a precompressed variant of it, answering
+// HEAD requests with a single HEAD of the object and forwarding the conditions of GET
+// requests to the store, so that unchanged files aren't transferred. It returns false
+// without responding if there is no such file.
+func (h *Handler) serveObject(w http.ResponseWriter, r *http.Request, name string) bool {
+	if h.precompressed {
+		for _, enc := range precompressedEncodings {
+			if accepts(r, enc.name) && h.serveVariant(w, r, name+enc.ext, name, enc.name) {
+				return true
+			}
+		}
+	}
+
+	return h.serveVariant(w, r, name, name, "")
+}
+
+// serveVariant serves the file at key as name, encoded with the Content-Encoding
+// coding if it isn't empty. It returns false without responding if there is no such
+// file.
+func (h *Handler) serveVariant(w http.ResponseWriter, r *http.Request, key, name, coding string) bool {
+	if r.Method == http.MethodHead {
+		info, err := h.fsys.StatFile(r.Context(), key)
+		if errors.Is(err, fs.ErrNotExist) {
+			return false
+		}
+
+		if err != nil {
+			h.error(w, err)
+			return true
+		}
+
+		if websiteRedirect(w, info) {
+			return true
+		}
+
+		if notModified(r, info) {
+			writeNotModified(w, info)
+			return true
+		}
+
+		writeHeaders(w, info, name, coding, h.encoding(r, info, name, coding))
+
+		return true
+	}
+
+	f, err := h.fsys.OpenIf(r.Context(), key, conditions(r))
+	switch {
+	case errors.Is(err, s3fs.ErrNotModified):
+		w.WriteHeader(http.StatusNotModified)
+		return true
+	case errors.Is(err, fs.ErrNotExist):
+		return false
+	case err != nil:
+		h.error(w, err)
+		return true
+	}
+	defer f.Close()
+
+	h.serveFileAs(w, r, f, name, coding)
+
+	return true
+}
+
+func (h *Handler) serveFile(w http.ResponseWriter, r *http.Request, f fs.File, name string) {
+	h.serveFileAs(w, r, f, name, "")
+}
+
+// serveFileAs serves f as name, encoded with the Content-Encoding coding if it isn't
+// empty.
+func (h *Handler) serveFileAs(w http.ResponseWriter, r *http.Request, f fs.File, name, coding string) {
+	info, err := f.Stat()
+	if err != nil {
+		h.error(w, err)
+		return
+	}
+
+	if websiteRedirect(w, info) {
+		return
+	}
+
+	sent := h.encoding(r, info, name, coding)
+	writeHeaders(w, info, name, coding, sent)
+
+	if r.Method != http.MethodHead {
+		buf := h.fsys.Buffers().Get()
+		defer h.fsys.Buffers().Put(buf)
+
+		if sent != coding {
+			gzipTo(w, f, buf)
+			return
+		}
+
+		// hide w's ReadFrom, which would copy with a buffer of its own
+		io.CopyBuffer(struct{ io.Writer }{w}, f, buf)
+	}
+}
+
+// encoding returns the Content-Encoding to send info with, which is stored, the
+// encoding it is stored in, unless the handler should gzip it on the fly.
+func (h *Handler) encoding(r *http.Request, info fs.FileInfo, name, stored string) string {
+	if stored != "" || !h.gzip || info.Size() < gzipMinSize || !accepts(r, "gzip") {
+		return stored
+	}
+
+	if !compressible(contentType(info, name, false)) {
+		return stored
+	}
+
+	return "gzip"
+}
+
+// contentType returns the content type of info served as name. Precompressed
+// variants, whose stored content type is likely to be that of the compressed file,
+// get the type of name's extension.
+func contentType(info fs.FileInfo, name string, variant bool) string {
+	contentType := mime.TypeByExtension(path.Ext(name))
+	if object, ok := info.Sys().(s3fs.ObjectInfo); ok && object.ContentType != "" && !variant {
+		contentType = object.ContentType
+	}
+
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	return contentType
+}
+
+// writeHeaders responds with a 200 and the headers describing info, stored with the
+// Content-Encoding stored, served as name with the Content-Encoding sent. If they
+// differ the body is compressed on the fly, so its length isn't known and its ETag is
+// weak.
+func writeHeaders(w http.ResponseWriter, info fs.FileInfo, name, stored, sent string) {
+	onTheFly := sent != stored
+
+	if object, ok := info.Sys().(s3fs.ObjectInfo); ok && object.ETag != "" {
+		etag := object.ETag
+		if onTheFly {
+			etag = "W/" + etag
+		}
+
+		w.Header().Set("ETag", etag)
+	}
+
+	w.Header().Set("Content-Type", contentType(info, name, stored != ""))
+	if sent != "" {
+		w.Header().Set("Content-Encoding", sent)
+	}
+	if info.Size() >= 0 && !onTheFly {
+		w.Header().Set("Content-Length", strconv.FormatInt(info.Size(), 10))
+	}
+	if !info.ModTime().IsZero() {
+		w.Header().Set("Last-Modified", info.ModTime().UTC().Format(http.TimeFormat))
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// conditions returns the conditions of r to forward to the store. As in RFC 7232,
+// If-Modified-Since is ignored when If-None-Match is given.
+func conditions(r *http.Request) s3fs.ReadConditions {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		// S3 compares ETags weakly for If-None-Match anyway, and WithGzip hands out
+		// weak versions of them
+		return s3fs.ReadConditions{IfNoneMatch: strings.ReplaceAll(inm, "W/", "")}
+	}
+
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil {
+		return s3fs.ReadConditions{}
+	}
+
+	return s3fs.ReadConditions{IfModifiedSince: since}
+}
+
+// notModified evaluates the conditions of r against info, for HEAD requests which
+// have nothing to save by forwarding them.
+func notModified(r *http.Request, info fs.FileInfo) bool {
+	cond := conditions(r)
+
+	if cond.IfNoneMatch != "" {
+		object, _ := info.Sys().(s3fs.ObjectInfo)
+
+		for _, etag := range strings.Split(cond.IfNoneMatch, ",") {
+			etag = strings.TrimPrefix(strings.TrimSpace(etag), "W/")
+			if etag == "*" || (object.ETag != "" && etag == object.ETag) {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	return !cond.IfModifiedSince.IsZero() && !info.ModTime().Truncate(time.Second).After(cond.IfModifiedSince)
+}
+
+func writeNotModified(w http.ResponseWriter, info fs.FileInfo) {
+	if object, ok := info.Sys().(s3fs.ObjectInfo); ok && object.ETag != "" {
+		w.Header().Set("ETag", object.ETag)
+	}
+
+	w.WriteHeader(http.StatusNotModified)
+}
+
+func (h *Handler) serveIndex(w http.ResponseWriter, r *http.Request, dir fs.ReadDirFile, urlPath string) {
+	entries, err := dir.ReadDir(-1)
+	if err != nil {
+		h.error(w, err)
+		return
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+
+	if !strings.HasSuffix(urlPath, "/") {
+		urlPath += "/"
+	}
+
+	data := DirectoryIndex{Path: urlPath}
+	for _, e := range entries {
+		entry := IndexEntry{Name: e.Name(), IsDir: e.IsDir()}
+		if info, err := e.Info(); err == nil {
+			entry.Size = info.Size()
+			entry.ModTime = info.ModTime()
+		}
+
+		data.Entries = append(data.Entries, entry)
+	}
+
+	buf := &bytes.Buffer{}
+	if err := h.index.Execute(buf, data); err != nil {
+		h.error(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	w.WriteHeader(http.StatusOK)
+
+	if r.Method != http.MethodHead {
+		buf.WriteTo(w)
+	}
+}
+
+// error responds with the status that best describes err.
+func (h *Handler) error(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, fs.ErrNotExist), errors.Is(err, fs.ErrInvalid):
+		http.Error(w, "not found", http.StatusNotFound)
+	case errors.Is(err, fs.ErrPermission):
+		http.Error(w, "forbidden", http.StatusForbidden)
+	default:
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+	}
+}
+
+// websiteRedirect responds with a permanent redirect if info is an object with a
+// website redirect location, like S3's static website endpoints do, reporting
+// whether it did. The location is sent as is, so paths are relative to the root of
+// the bucket.
+func websiteRedirect(w http.ResponseWriter, info fs.FileInfo) bool {
+	object, ok := info.Sys().(s3fs.ObjectInfo)
+	if !ok || object.WebsiteRedirectLocation == "" {
+		return false
+	}
+
+	w.Header().Set("Location", object.WebsiteRedirectLocation)
+	w.WriteHeader(http.StatusMovedPermanently)
+
+	return true
+}
+
+// redirect sends a permanent redirect to the relative URL target, keeping the query.
+// The Location is left relative, so that it still works when the handler is mounted
+// under http.StripPrefix.
+func redirect(w http.ResponseWriter, r *http.Request, target string) {
+	if r.URL.RawQuery != "" {
+		target += "?" + r.URL.RawQuery
+	}
+
+	w.Header().Set("Location", target)
+	w.WriteHeader(http.StatusMovedPermanently)
+}
@@ -0,0 +1,192 @@
+package s3fshttp
+
+import (
+	"context"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/packrat386/s3fs"
+	"github.com/packrat386/s3fs/s3fstest"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestFS() *s3fs.S3FS {
+	store := s3fstest.NewMemStore()
+	store.WriteFile("index.html", "<h1>home</h1>")
+	store.WriteFile("style.css", "body {}")
+	store.WriteFile("docs/a.txt", "a")
+	store.WriteFile("docs/sub/b.txt", "b")
+
+	return s3fs.NewFS(store)
+}
+
+func serve(h http.Handler, method, target string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(method, target, nil))
+
+	return w
+}
+
+func TestHandler(t *testing.T) {
+	h := NewHandler(newTestFS())
+
+	w := serve(h, "GET", "/style.css")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "body {}", w.Body.String())
+	require.Equal(t, "text/css; charset=utf-8", w.Header().Get("Content-Type"))
+	require.Equal(t, "7", w.Header().Get("Content-Length"))
+	require.NotEmpty(t, w.Header().Get("ETag"))
+
+	w = serve(h, "GET", "/")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "<h1>home</h1>", w.Body.String())
+
+	w = serve(h, "GET", "/docs")
+	require.Equal(t, http.StatusMovedPermanently, w.Code)
+	require.Equal(t, "docs/", w.Header().Get("Location"))
+
+	w = serve(h, "GET", "/docs/")
+	require.Equal(t, http.StatusNotFound, w.Code)
+
+	w = serve(h, "GET", "/missing.txt")
+	require.Equal(t, http.StatusNotFound, w.Code)
+
+	w = serve(h, "POST", "/style.css")
+	require.Equal(t, http.StatusMethodNotAllowed, w.Code)
+}
+
+func TestWithDirectoryIndex(t *testing.T) {
+	h := NewHandler(newTestFS(), WithDirectoryIndex(nil))
+
+	w := serve(h, "GET", "/docs/")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "text/html; charset=utf-8", w.Header().Get("Content-Type"))
+	require.Contains(t, w.Body.String(), `<a href="a.txt">a.txt</a>`)
+	require.Contains(t, w.Body.String(), `<a href="sub/">sub/</a>`)
+	require.Contains(t, w.Body.String(), `<a href="../">../</a>`)
+
+	w = serve(h, "GET", "/")
+	require.Equal(t, "<h1>home</h1>", w.Body.String())
+
+	tmpl := template.Must(template.New("custom").Parse(`{{.Path}}:{{range .Entries}} {{.Name}}{{end}}`))
+	h = NewHandler(newTestFS(), WithDirectoryIndex(tmpl))
+
+	w = serve(h, "GET", "/docs/")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "/docs/: a.txt sub", w.Body.String())
+}
+
+// countingStore counts the requests made to a MemStore.
+type countingStore struct {
+	*s3fstest.MemStore
+
+	lists, heads, gets int
+}
+
+func (c *countingStore) List(ctx context.Context, prefix string, opts s3fs.ListOptions, fn func(*s3fs.ListPage) bool) error {
+	c.lists++
+	return c.MemStore.List(ctx, prefix, opts, fn)
+}
+
+func (c *countingStore) Head(ctx context.Context, key string) (s3fs.ObjectInfo, error) {
+	c.heads++
+	return c.MemStore.Head(ctx, key)
+}
+
+func (c *countingStore) Get(ctx context.Context, key string, opts s3fs.GetOptions) (*s3fs.Object, error) {
+	c.gets++
+	return c.MemStore.Get(ctx, key, opts)
+}
+
+func TestHandler_Head(t *testing.T) {
+	store := &countingStore{MemStore: s3fstest.NewMemStore()}
+	store.WriteFile("style.css", "body {}")
+
+	h := NewHandler(s3fs.NewFS(store))
+
+	w := serve(h, "HEAD", "/style.css")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "7", w.Header().Get("Content-Length"))
+	require.Empty(t, w.Body.String())
+	require.Equal(t, 1, store.heads)
+	require.Equal(t, 0, store.gets)
+	require.Equal(t, 0, store.lists)
+
+	etag := w.Header().Get("ETag")
+	r := httptest.NewRequest("HEAD", "/style.css", nil)
+	r.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	require.Equal(t, http.StatusNotModified, w.Code)
+	require.Equal(t, etag, w.Header().Get("ETag"))
+}
+
+func TestHandler_Conditional(t *testing.T) {
+	store := &countingStore{MemStore: s3fstest.NewMemStore()}
+	store.WriteFile("style.css", "body {}")
+
+	h := NewHandler(s3fs.NewFS(store))
+
+	w := serve(h, "GET", "/style.css")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, 1, store.gets)
+	require.Equal(t, 0, store.lists)
+
+	etag := w.Header().Get("ETag")
+	modified := w.Header().Get("Last-Modified")
+
+	r := httptest.NewRequest("GET", "/style.css", nil)
+	r.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	require.Equal(t, http.StatusNotModified, w.Code)
+	require.Empty(t, w.Body.String())
+
+	r = httptest.NewRequest("GET", "/style.css", nil)
+	r.Header.Set("If-Modified-Since", modified)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	require.Equal(t, http.StatusNotModified, w.Code)
+
+	r = httptest.NewRequest("GET", "/style.css", nil)
+	r.Header.Set("If-None-Match", `"other"`)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "body {}", w.Body.String())
+}
+
+func TestHandler_WebsiteRedirect(t *testing.T) {
+	store := s3fstest.NewMemStore()
+	store.WriteFile("new.html", "new")
+	_, err := store.Put(context.Background(), "old.html", strings.NewReader(""), s3fs.PutOptions{WebsiteRedirectLocation: "/new.html"})
+	require.Nil(t, err)
+	_, err = store.Put(context.Background(), "moved/index.html", strings.NewReader(""), s3fs.PutOptions{WebsiteRedirectLocation: "https://example.com/"})
+	require.Nil(t, err)
+
+	myFS := s3fs.NewFS(store)
+	h := NewHandler(myFS)
+
+	w := serve(h, "GET", "/old.html")
+	require.Equal(t, http.StatusMovedPermanently, w.Code)
+	require.Equal(t, "/new.html", w.Header().Get("Location"))
+
+	w = serve(h, "HEAD", "/old.html")
+	require.Equal(t, http.StatusMovedPermanently, w.Code)
+	require.Equal(t, "/new.html", w.Header().Get("Location"))
+
+	w = serve(h, "GET", "/moved/")
+	require.Equal(t, http.StatusMovedPermanently, w.Code)
+	require.Equal(t, "https://example.com/", w.Header().Get("Location"))
+
+	w = serve(h, "GET", "/new.html")
+	require.Equal(t, http.StatusOK, w.Code)
+
+	info, err := fs.Stat(myFS, "old.html")
+	require.Nil(t, err)
+	require.Equal(t, "/new.html", info.Sys().(s3fs.ObjectInfo).WebsiteRedirectLocation)
+}
@@ -0,0 +1,87 @@
+package s3fstest
+
+import (
+	"sync"
+	"time"
+
+	"github.com/packrat386/s3fs"
+)
+
+var _ s3fs.Clock = (*FakeClock)(nil)
+
+// FakeClock is an s3fs.Clock that only moves when Advance is called, for testing
+// cache expiry and polling deterministically with s3fs.WithClock. It is safe for
+// concurrent use.
+type FakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []fakeWaiter
+}
+
+type fakeWaiter struct {
+	at time.Time
+	c  chan time.Time
+}
+
+// NewFakeClock returns a FakeClock set to now.
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+func (c *FakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- c.now
+		return ch
+	}
+
+	c.waiters = append(c.waiters, fakeWaiter{at: c.now.Add(d), c: ch})
+
+	return ch
+}
+
+// Advance moves the clock forward by d, firing the channels returned by After whose
+// time has come.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+
+	waiting := c.waiters[:0]
+	for _, w := range c.waiters {
+		if w.at.After(c.now) {
+			waiting = append(waiting, w)
+			continue
+		}
+
+		w.c <- c.now
+	}
+	c.waiters = waiting
+}
+
+// Waiters returns how many channels returned by After haven't fired yet, so tests can
+// tell when the code under test has started waiting before calling Advance.
+func (c *FakeClock) Waiters() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return len(c.waiters)
+}
+
+// BlockUntil waits until at least n channels returned by After are waiting to fire.
+func (c *FakeClock) BlockUntil(n int) {
+	for c.Waiters() < n {
+		time.Sleep(time.Millisecond)
+	}
+}
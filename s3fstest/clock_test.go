@@ -0,0 +1,33 @@
+package s3fstest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFakeClock(t *testing.T) {
+	start := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+
+	short := clock.After(time.Second)
+	long := clock.After(time.Minute)
+	require.Equal(t, 2, clock.Waiters())
+
+	clock.Advance(time.Second)
+	require.Equal(t, start.Add(time.Second), <-short)
+	require.Equal(t, 1, clock.Waiters())
+
+	select {
+	case <-long:
+		t.Fatal("fired early")
+	default:
+	}
+
+	clock.Advance(time.Hour)
+	require.Equal(t, start.Add(time.Hour+time.Second), <-long)
+	require.Equal(t, start.Add(time.Hour+time.Second), clock.Now())
+
+	require.Equal(t, clock.Now(), <-clock.After(0))
+}
@@ -0,0 +1,120 @@
+package s3fstest
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strings"
+	"testing/fstest"
+)
+
+// ConformanceFiles are a set of files for Conformance to check an FS against, with
+// files at the root and nested a few directories deep, and directories that hold
+// both files and other directories.
+var ConformanceFiles = map[string]string{
+	"top.json":             `{"data":"top"}`,
+	"deep/down/below.json": `{"data":"below"}`,
+	"dir-a/one.json":       `{"data":"one"}`,
+	"dir-a/two.json":       `{"data":"two"}`,
+	"dir-a/sub/three.json": `{"data":"three"}`,
+	"dir-b/foo.json":       `{"data":"foo"}`,
+}
+
+// InvalidNames are names that fs.ValidPath rejects, which Conformance checks every
+// way of opening fails with fs.ErrInvalid.
+var InvalidNames = []string{
+	"",
+	"/",
+	"/top.json",
+	"top.json/",
+	"dir-a/",
+	"./top.json",
+	"dir-a/./one.json",
+	"../top.json",
+	"dir-a/../top.json",
+	"dir-a//one.json",
+}
+
+// Conformance checks that fsys behaves as the io/fs contract says with fstest.TestFS,
+// given that it holds exactly files, by name and content, and that it rejects every
+// one of InvalidNames with an error wrapping fs.ErrInvalid from Open, fs.Stat,
+// fs.ReadDir, fs.ReadFile and fs.Sub. It takes no testing.T, so it can be run against
+// an FS of MemStore in unit tests and of a real bucket in integration tests alike.
+func Conformance(fsys fs.FS, files map[string]string) error {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	problems := []string{}
+	fail := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if err := fstest.TestFS(fsys, names...); err != nil {
+		fail("%v", err)
+	}
+
+	for _, name := range names {
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			fail("ReadFile(%q): %v", name, err)
+		} else if string(data) != files[name] {
+			fail("ReadFile(%q) = %q, want %q", name, data, files[name])
+		}
+	}
+
+	for _, name := range InvalidNames {
+		checkInvalid(fail, "Open", name, func() error {
+			f, err := fsys.Open(name)
+			if err == nil {
+				f.Close()
+			}
+
+			return err
+		})
+
+		checkInvalid(fail, "Stat", name, func() error {
+			_, err := fs.Stat(fsys, name)
+			return err
+		})
+
+		checkInvalid(fail, "ReadDir", name, func() error {
+			_, err := fs.ReadDir(fsys, name)
+			return err
+		})
+
+		checkInvalid(fail, "ReadFile", name, func() error {
+			_, err := fs.ReadFile(fsys, name)
+			return err
+		})
+
+		checkInvalid(fail, "Sub", name, func() error {
+			_, err := fs.Sub(fsys, name)
+			return err
+		})
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("conformance failed:\n%s", strings.Join(problems, "\n"))
+	}
+
+	return nil
+}
+
+// checkInvalid reports to fail if call, which does op with the invalid name, doesn't
+// fail with fs.ErrInvalid.
+func checkInvalid(fail func(format string, args ...interface{}), op, name string, call func() error) {
+	err := call()
+	if err == nil {
+		fail("%s(%q) succeeded, want fs.ErrInvalid", op, name)
+		return
+	}
+
+	if !errors.Is(err, fs.ErrInvalid) {
+		fail("%s(%q): %v, want fs.ErrInvalid", op, name, err)
+	}
+}
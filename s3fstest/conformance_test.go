@@ -0,0 +1,44 @@
+package s3fstest
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/packrat386/s3fs"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConformance(t *testing.T) {
+	store := NewMemStore()
+	for name, data := range ConformanceFiles {
+		store.WriteFile(name, data)
+	}
+
+	require.Nil(t, Conformance(s3fs.NewFS(store, s3fs.WithStrictPaths()), ConformanceFiles))
+}
+
+func TestConformance_WrongContent(t *testing.T) {
+	store := NewMemStore()
+	for name, data := range ConformanceFiles {
+		store.WriteFile(name, data)
+	}
+	store.WriteFile("top.json", `{"data":"changed"}`)
+
+	err := Conformance(s3fs.NewFS(store), ConformanceFiles)
+	require.NotNil(t, err)
+	require.Contains(t, err.Error(), `ReadFile("top.json")`)
+}
+
+func TestConformance_NotExistForInvalid(t *testing.T) {
+	mapFS := fstest.MapFS{}
+	for name, data := range ConformanceFiles {
+		mapFS[name] = &fstest.MapFile{Data: []byte(data)}
+	}
+
+	// MapFS rejects invalid names with fs.ErrNotExist, which io/fs allows but
+	// Conformance doesn't
+	err := Conformance(mapFS, ConformanceFiles)
+	require.NotNil(t, err)
+	require.Contains(t, err.Error(), `Open("/top.json")`)
+	require.NotContains(t, err.Error(), "Sub(")
+}
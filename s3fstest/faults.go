@@ -0,0 +1,199 @@
+package s3fstest
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/packrat386/s3fs"
+)
+
+var _ s3fs.ObjectStore = (*FaultStore)(nil)
+
+// Op is an ObjectStore operation that faults can be injected into.
+type Op string
+
+const (
+	OpList   Op = "List"
+	OpHead   Op = "Head"
+	OpGet    Op = "Get"
+	OpPut    Op = "Put"
+	OpDelete Op = "Delete"
+	OpCopy   Op = "Copy"
+)
+
+// ErrInjected is the error FaultStore fails operations with when Fault.Err isn't set.
+var ErrInjected = errors.New("injected fault")
+
+// Fault describes how an operation of a FaultStore misbehaves.
+type Fault struct {
+	// Latency is added to every call, before it's made.
+	Latency time.Duration
+
+	// ErrorRate is the fraction of calls, between 0 and 1, that fail with Err, or
+	// ErrInjected if Err is nil.
+	ErrorRate float64
+	Err       error
+
+	// ThrottleRate is the fraction of calls that fail the way S3 throttles requests,
+	// with a 503 SlowDown error.
+	ThrottleRate float64
+
+	// MaxPerSecond, if positive, throttles calls beyond that many within a second, as
+	// S3 does when a prefix gets more requests than it can take.
+	MaxPerSecond int
+}
+
+// FaultStore wraps an ObjectStore, injecting latency, errors and throttling into its
+// operations, for testing retry and fallback logic. Which calls fail is decided by a
+// random number generator seeded by NewFaultStore, so a test that makes the same calls
+// in the same order sees the same failures every run. It is safe for concurrent use.
+type FaultStore struct {
+	Store s3fs.ObjectStore
+
+	// Clock, if set, is what Latency and MaxPerSecond are measured with instead of the
+	// system clock.
+	Clock s3fs.Clock
+
+	mu     sync.Mutex
+	rng    *rand.Rand
+	faults map[Op]Fault
+	window map[Op]time.Time
+	calls  map[Op]int
+}
+
+// NewFaultStore returns a FaultStore around store that doesn't inject anything until
+// SetFault is called.
+func NewFaultStore(store s3fs.ObjectStore, seed int64) *FaultStore {
+	return &FaultStore{
+		Store:  store,
+		rng:    rand.New(rand.NewSource(seed)),
+		faults: map[Op]Fault{},
+		window: map[Op]time.Time{},
+		calls:  map[Op]int{},
+	}
+}
+
+// SetFault makes op misbehave as fault describes, replacing any fault set before. The
+// zero Fault makes op behave normally again.
+func (f *FaultStore) SetFault(op Op, fault Fault) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.faults[op] = fault
+}
+
+func (f *FaultStore) now() time.Time {
+	if f.Clock != nil {
+		return f.Clock.Now()
+	}
+
+	return time.Now()
+}
+
+func (f *FaultStore) after(d time.Duration) <-chan time.Time {
+	if f.Clock != nil {
+		return f.Clock.After(d)
+	}
+
+	return time.After(d)
+}
+
+// inject applies the fault configured for op, returning the error the call should
+// fail with, if any.
+func (f *FaultStore) inject(ctx context.Context, op Op) error {
+	f.mu.Lock()
+	fault := f.faults[op]
+
+	var err error
+	switch roll := f.rng.Float64(); {
+	case roll < fault.ErrorRate:
+		err = fault.Err
+		if err == nil {
+			err = ErrInjected
+		}
+	case roll < fault.ErrorRate+fault.ThrottleRate:
+		err = slowDown()
+	}
+
+	if fault.MaxPerSecond > 0 {
+		now := f.now()
+		if now.Sub(f.window[op]) >= time.Second {
+			f.window[op] = now
+			f.calls[op] = 0
+		}
+
+		f.calls[op]++
+		if f.calls[op] > fault.MaxPerSecond && err == nil {
+			err = slowDown()
+		}
+	}
+	f.mu.Unlock()
+
+	if fault.Latency > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-f.after(fault.Latency):
+		}
+	}
+
+	return err
+}
+
+// slowDown returns the error S3 responds with when it throttles a request.
+func slowDown() error {
+	return awserr.NewRequestFailure(awserr.New("SlowDown", "Please reduce your request rate.", nil), 503, "")
+}
+
+func (f *FaultStore) List(ctx context.Context, prefix string, opts s3fs.ListOptions, fn func(*s3fs.ListPage) bool) error {
+	if err := f.inject(ctx, OpList); err != nil {
+		return err
+	}
+
+	return f.Store.List(ctx, prefix, opts, fn)
+}
+
+func (f *FaultStore) Head(ctx context.Context, key string) (s3fs.ObjectInfo, error) {
+	if err := f.inject(ctx, OpHead); err != nil {
+		return s3fs.ObjectInfo{}, err
+	}
+
+	return f.Store.Head(ctx, key)
+}
+
+func (f *FaultStore) Get(ctx context.Context, key string, opts s3fs.GetOptions) (*s3fs.Object, error) {
+	if err := f.inject(ctx, OpGet); err != nil {
+		return nil, err
+	}
+
+	return f.Store.Get(ctx, key, opts)
+}
+
+func (f *FaultStore) Put(ctx context.Context, key string, body io.Reader, opts s3fs.PutOptions) (s3fs.ObjectInfo, error) {
+	if err := f.inject(ctx, OpPut); err != nil {
+		return s3fs.ObjectInfo{}, err
+	}
+
+	return f.Store.Put(ctx, key, body, opts)
+}
+
+func (f *FaultStore) Delete(ctx context.Context, key string) error {
+	if err := f.inject(ctx, OpDelete); err != nil {
+		return err
+	}
+
+	return f.Store.Delete(ctx, key)
+}
+
+func (f *FaultStore) Copy(ctx context.Context, src, dst string, opts s3fs.CopyOptions) error {
+	if err := f.inject(ctx, OpCopy); err != nil {
+		return err
+	}
+
+	return f.Store.Copy(ctx, src, dst, opts)
+}
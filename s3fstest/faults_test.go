@@ -0,0 +1,108 @@
+package s3fstest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/packrat386/s3fs"
+	"github.com/stretchr/testify/require"
+)
+
+func failures(f *FaultStore, n int) []bool {
+	failed := []bool{}
+	for i := 0; i < n; i++ {
+		_, err := f.Head(context.Background(), "a.txt")
+		failed = append(failed, err != nil)
+	}
+
+	return failed
+}
+
+func TestFaultStore_ErrorRate(t *testing.T) {
+	m := NewMemStore()
+	m.WriteFile("a.txt", "a")
+
+	f := NewFaultStore(m, 42)
+	require.Equal(t, make([]bool, 10), failures(f, 10))
+
+	f.SetFault(OpHead, Fault{ErrorRate: 0.5})
+	first := failures(f, 100)
+
+	count := 0
+	for _, failed := range first {
+		if failed {
+			count++
+		}
+	}
+	require.InDelta(t, 50, count, 20)
+
+	// the same seed fails the same calls
+	again := NewFaultStore(m, 42)
+	failures(again, 10)
+	again.SetFault(OpHead, Fault{ErrorRate: 0.5})
+	require.Equal(t, first, failures(again, 100))
+
+	_, err := f.Get(context.Background(), "a.txt", s3fs.GetOptions{})
+	require.Nil(t, err)
+
+	f.SetFault(OpHead, Fault{ErrorRate: 1})
+	_, err = f.Head(context.Background(), "a.txt")
+	require.True(t, errors.Is(err, ErrInjected))
+}
+
+func TestFaultStore_Throttle(t *testing.T) {
+	m := NewMemStore()
+	m.WriteFile("a.txt", "a")
+
+	clock := NewFakeClock(time.Now())
+	f := NewFaultStore(m, 1)
+	f.Clock = clock
+
+	f.SetFault(OpHead, Fault{ThrottleRate: 1})
+	_, err := f.Head(context.Background(), "a.txt")
+
+	var awsErr awserr.RequestFailure
+	require.True(t, errors.As(err, &awsErr))
+	require.Equal(t, "SlowDown", awsErr.Code())
+	require.Equal(t, 503, awsErr.StatusCode())
+
+	f.SetFault(OpHead, Fault{MaxPerSecond: 3})
+	require.Equal(t, []bool{false, false, false, true, true}, failures(f, 5))
+
+	clock.Advance(time.Second)
+	require.Equal(t, []bool{false, false, false, true}, failures(f, 4))
+}
+
+func TestFaultStore_Latency(t *testing.T) {
+	m := NewMemStore()
+	m.WriteFile("a.txt", "a")
+
+	clock := NewFakeClock(time.Now())
+	f := NewFaultStore(m, 1)
+	f.Clock = clock
+	f.SetFault(OpHead, Fault{Latency: time.Second})
+
+	done := make(chan error)
+	go func() {
+		_, err := f.Head(context.Background(), "a.txt")
+		done <- err
+	}()
+
+	clock.BlockUntil(1)
+	select {
+	case <-done:
+		t.Fatal("returned before the latency passed")
+	default:
+	}
+
+	clock.Advance(time.Second)
+	require.Nil(t, <-done)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := f.Head(ctx, "a.txt")
+	require.True(t, errors.Is(err, context.Canceled))
+}
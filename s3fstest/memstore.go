@@ -0,0 +1,298 @@
+// Package s3fstest provides an in-memory s3fs.ObjectStore for testing code that uses
+// s3fs without talking to S3.
+package s3fstest
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/packrat386/s3fs"
+)
+
+var _ s3fs.ObjectStore = (*MemStore)(nil)
+
+// MemStore is an in-memory s3fs.ObjectStore. It is safe for concurrent use.
+type MemStore struct {
+	// PageSize is the maximum number of objects and common prefixes returned in each
+	// List page. Defaults to 1000, same as S3.
+	PageSize int
+
+	// Clock, if set, is where objects' LastModified times come from instead of the
+	// system clock.
+	Clock s3fs.Clock
+
+	// StrictRanges, if set, makes Get fail with s3fs.ErrInvalidRange for any range of
+	// an empty object, like S3 does.
+	StrictRanges bool
+
+	mu      sync.Mutex
+	objects map[string]memObject
+}
+
+type memObject struct {
+	data []byte
+	info s3fs.ObjectInfo
+}
+
+func (m *MemStore) now() time.Time {
+	if m.Clock != nil {
+		return m.Clock.Now()
+	}
+
+	return time.Now()
+}
+
+// NewMemStore returns an empty MemStore.
+func NewMemStore() *MemStore {
+	return &MemStore{
+		objects: map[string]memObject{},
+	}
+}
+
+// WriteFile is a shortcut for putting body at key that panics on failure.
+func (m *MemStore) WriteFile(key, body string) {
+	_, err := m.Put(context.Background(), key, strings.NewReader(body), s3fs.PutOptions{})
+	if err != nil {
+		panic(err)
+	}
+}
+
+func (m *MemStore) List(ctx context.Context, prefix string, opts s3fs.ListOptions, fn func(*s3fs.ListPage) bool) error {
+	// tokens are the last key or common prefix of the page before, so listings
+	// carry on after it
+	after := opts.StartAfter
+	lastPrefix := ""
+	if opts.ContinuationToken != "" {
+		decoded, err := base64.RawURLEncoding.DecodeString(opts.ContinuationToken)
+		if err != nil {
+			return fmt.Errorf("invalid continuation token: %w", err)
+		}
+
+		after = string(decoded)
+		if opts.Delimiter != "" && strings.HasSuffix(after, opts.Delimiter) {
+			lastPrefix = after
+		}
+	}
+
+	m.mu.Lock()
+	keys := []string{}
+	infos := map[string]s3fs.ObjectInfo{}
+	for k, obj := range m.objects {
+		if strings.HasPrefix(k, prefix) && k > after {
+			keys = append(keys, k)
+			infos[k] = obj.info
+		}
+	}
+	m.mu.Unlock()
+
+	sort.Strings(keys)
+
+	pageSize := m.PageSize
+	if pageSize <= 0 {
+		pageSize = 1000
+	}
+
+	if opts.MaxKeys > 0 && opts.MaxKeys < pageSize {
+		pageSize = opts.MaxKeys
+	}
+
+	page := &s3fs.ListPage{}
+	count := 0
+	last := ""
+
+	for _, k := range keys {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		cp := ""
+		if opts.Delimiter != "" {
+			if i := strings.Index(k[len(prefix):], opts.Delimiter); i >= 0 {
+				cp = k[:len(prefix)+i+len(opts.Delimiter)]
+				if cp == lastPrefix {
+					continue
+				}
+			}
+		}
+
+		if count == pageSize {
+			page.NextContinuationToken = base64.RawURLEncoding.EncodeToString([]byte(last))
+			if !fn(page) {
+				return nil
+			}
+
+			page = &s3fs.ListPage{}
+			count = 0
+		}
+
+		if cp != "" {
+			lastPrefix = cp
+			last = cp
+			page.CommonPrefixes = append(page.CommonPrefixes, cp)
+		} else {
+			last = k
+			page.Objects = append(page.Objects, infos[k])
+		}
+		count++
+	}
+
+	fn(page)
+
+	return nil
+}
+
+func (m *MemStore) Head(ctx context.Context, key string) (s3fs.ObjectInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	obj, ok := m.objects[key]
+	if !ok {
+		return s3fs.ObjectInfo{}, fmt.Errorf("%w: %s", fs.ErrNotExist, key)
+	}
+
+	return obj.info, nil
+}
+
+func (m *MemStore) Get(ctx context.Context, key string, opts s3fs.GetOptions) (*s3fs.Object, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	obj, ok := m.objects[key]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", fs.ErrNotExist, key)
+	}
+
+	if opts.IfNoneMatch != "" && (opts.IfNoneMatch == "*" || opts.IfNoneMatch == obj.info.ETag) {
+		return nil, fmt.Errorf("%w: %s has ETag %s", s3fs.ErrNotModified, key, obj.info.ETag)
+	}
+
+	if !opts.IfModifiedSince.IsZero() && !obj.info.LastModified.Truncate(time.Second).After(opts.IfModifiedSince) {
+		return nil, fmt.Errorf("%w: %s not modified since %s", s3fs.ErrNotModified, key, opts.IfModifiedSince)
+	}
+
+	if opts.Offset < 0 || (opts.Offset > 0 && opts.Offset >= int64(len(obj.data))) {
+		return nil, fmt.Errorf("%w: offset %d for object of size %d", s3fs.ErrInvalidRange, opts.Offset, len(obj.data))
+	}
+
+	if m.StrictRanges && len(obj.data) == 0 && opts.Length > 0 {
+		return nil, fmt.Errorf("%w: %s is empty", s3fs.ErrInvalidRange, key)
+	}
+
+	end := int64(len(obj.data))
+	if opts.Length > 0 && opts.Offset+opts.Length < end {
+		end = opts.Offset + opts.Length
+	}
+
+	return &s3fs.Object{
+		Body: io.NopCloser(bytes.NewReader(obj.data[opts.Offset:end])),
+		Info: obj.info,
+	}, nil
+}
+
+func (m *MemStore) Put(ctx context.Context, key string, body io.Reader, opts s3fs.PutOptions) (s3fs.ObjectInfo, error) {
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return s3fs.ObjectInfo{}, err
+	}
+
+	sum := md5.Sum(data)
+
+	info := s3fs.ObjectInfo{
+		Key:          key,
+		Size:         int64(len(data)),
+		LastModified: m.now().UTC(),
+		ETag:         `"` + hex.EncodeToString(sum[:]) + `"`,
+		ContentType:  opts.ContentType,
+		Metadata:     copyMetadata(opts.Metadata),
+
+		ContentEncoding: opts.ContentEncoding,
+
+		WebsiteRedirectLocation: opts.WebsiteRedirectLocation,
+	}
+
+	m.mu.Lock()
+	if err := m.checkPreconditions(key, opts.IfMatch, opts.IfNoneMatch); err != nil {
+		m.mu.Unlock()
+		return s3fs.ObjectInfo{}, err
+	}
+
+	m.objects[key] = memObject{
+		data: data,
+		info: info,
+	}
+	m.mu.Unlock()
+
+	if opts.PartUploaded != nil {
+		opts.PartUploaded(1, info.Size)
+	}
+
+	return info, nil
+}
+
+func (m *MemStore) Delete(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.objects, key)
+
+	return nil
+}
+
+func (m *MemStore) Copy(ctx context.Context, src, dst string, opts s3fs.CopyOptions) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	obj, ok := m.objects[src]
+	if !ok {
+		return fmt.Errorf("%w: %s", fs.ErrNotExist, src)
+	}
+
+	if err := m.checkPreconditions(dst, opts.IfMatch, opts.IfNoneMatch); err != nil {
+		return err
+	}
+
+	obj.info.Key = dst
+	obj.info.LastModified = m.now().UTC()
+	obj.info.Metadata = copyMetadata(obj.info.Metadata)
+	m.objects[dst] = obj
+
+	return nil
+}
+
+// checkPreconditions must be called with m.mu held.
+func (m *MemStore) checkPreconditions(key, ifMatch, ifNoneMatch string) error {
+	obj, exists := m.objects[key]
+
+	if ifNoneMatch == "*" && exists {
+		return fmt.Errorf("%w: %s exists", s3fs.ErrPreconditionFailed, key)
+	}
+
+	if ifMatch != "" && (!exists || obj.info.ETag != ifMatch) {
+		return fmt.Errorf("%w: %s does not have ETag %s", s3fs.ErrPreconditionFailed, key, ifMatch)
+	}
+
+	return nil
+}
+
+func copyMetadata(md map[string]string) map[string]string {
+	if md == nil {
+		return nil
+	}
+
+	out := make(map[string]string, len(md))
+	for k, v := range md {
+		out[k] = v
+	}
+
+	return out
+}
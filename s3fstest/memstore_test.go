@@ -0,0 +1,142 @@
+package s3fstest
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"testing"
+
+	"github.com/packrat386/s3fs"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemStore_List(t *testing.T) {
+	m := NewMemStore()
+	m.PageSize = 2
+
+	m.WriteFile("a/one.json", "1")
+	m.WriteFile("a/two.json", "2")
+	m.WriteFile("a/sub/three.json", "3")
+	m.WriteFile("a/sub/four.json", "4")
+	m.WriteFile("b/five.json", "5")
+
+	objects := []string{}
+	prefixes := []string{}
+	pages := 0
+
+	err := m.List(context.Background(), "a/", s3fs.ListOptions{Delimiter: "/"}, func(page *s3fs.ListPage) bool {
+		pages++
+		for _, obj := range page.Objects {
+			objects = append(objects, obj.Key)
+		}
+		prefixes = append(prefixes, page.CommonPrefixes...)
+		return true
+	})
+
+	require.Nil(t, err)
+	require.Equal(t, []string{"a/one.json", "a/two.json"}, objects)
+	require.Equal(t, []string{"a/sub/"}, prefixes)
+	require.Equal(t, 2, pages)
+
+	objects = []string{}
+	err = m.List(context.Background(), "", s3fs.ListOptions{}, func(page *s3fs.ListPage) bool {
+		for _, obj := range page.Objects {
+			objects = append(objects, obj.Key)
+		}
+		return false
+	})
+
+	require.Nil(t, err)
+	require.Equal(t, []string{"a/one.json", "a/sub/four.json"}, objects)
+}
+
+func TestMemStore_Get(t *testing.T) {
+	m := NewMemStore()
+	m.WriteFile("foo.txt", "hello world")
+
+	obj, err := m.Get(context.Background(), "foo.txt", s3fs.GetOptions{Offset: 6, Length: 3})
+	require.Nil(t, err)
+	require.Equal(t, int64(11), obj.Info.Size)
+
+	data, err := io.ReadAll(obj.Body)
+	require.Nil(t, err)
+	require.Equal(t, "wor", string(data))
+
+	_, err = m.Get(context.Background(), "nope.txt", s3fs.GetOptions{})
+	require.True(t, errors.Is(err, fs.ErrNotExist))
+
+	_, err = m.Head(context.Background(), "nope.txt")
+	require.True(t, errors.Is(err, fs.ErrNotExist))
+}
+
+func TestMemStore_StrictRanges(t *testing.T) {
+	m := NewMemStore()
+	m.WriteFile("empty.txt", "")
+
+	_, err := m.Get(context.Background(), "empty.txt", s3fs.GetOptions{Length: 10})
+	require.Nil(t, err)
+
+	m.StrictRanges = true
+
+	_, err = m.Get(context.Background(), "empty.txt", s3fs.GetOptions{Length: 10})
+	require.True(t, errors.Is(err, s3fs.ErrInvalidRange))
+
+	obj, err := m.Get(context.Background(), "empty.txt", s3fs.GetOptions{})
+	require.Nil(t, err)
+	require.Equal(t, int64(0), obj.Info.Size)
+}
+
+func TestMemStore_CopyDelete(t *testing.T) {
+	m := NewMemStore()
+	m.WriteFile("foo.txt", "hello")
+
+	require.Nil(t, m.Copy(context.Background(), "foo.txt", "bar.txt", s3fs.CopyOptions{}))
+	require.Nil(t, m.Delete(context.Background(), "foo.txt"))
+
+	_, err := m.Head(context.Background(), "foo.txt")
+	require.True(t, errors.Is(err, fs.ErrNotExist))
+
+	info, err := m.Head(context.Background(), "bar.txt")
+	require.Nil(t, err)
+	require.Equal(t, int64(5), info.Size)
+}
+
+func TestMemStore_ListContinuationToken(t *testing.T) {
+	m := NewMemStore()
+	m.PageSize = 2
+
+	m.WriteFile("a/one.json", "1")
+	m.WriteFile("a/sub/three.json", "3")
+	m.WriteFile("a/sub/four.json", "4")
+	m.WriteFile("a/two.json", "2")
+	m.WriteFile("a/z.json", "z")
+
+	// list one page at a time, as a paged UI would
+	items := []string{}
+	token := ""
+	for pages := 0; ; pages++ {
+		require.Less(t, pages, 5)
+
+		next := ""
+		err := m.List(context.Background(), "a/", s3fs.ListOptions{Delimiter: "/", ContinuationToken: token}, func(page *s3fs.ListPage) bool {
+			for _, obj := range page.Objects {
+				items = append(items, obj.Key)
+			}
+			items = append(items, page.CommonPrefixes...)
+			next = page.NextContinuationToken
+			return false
+		})
+		require.Nil(t, err)
+
+		if next == "" {
+			break
+		}
+		token = next
+	}
+
+	require.Equal(t, []string{"a/one.json", "a/sub/", "a/two.json", "a/z.json"}, items)
+
+	err := m.List(context.Background(), "a/", s3fs.ListOptions{ContinuationToken: "!!!"}, func(*s3fs.ListPage) bool { return true })
+	require.NotNil(t, err)
+}
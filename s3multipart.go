@@ -0,0 +1,349 @@
+package s3fs
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// Append adds body to the end of key. Objects too small to be copied as a part are
+// rewritten instead.
+func (s *s3Store) Append(ctx context.Context, key string, body io.Reader, opts PutOptions) (ObjectInfo, error) {
+	info, err := s.Head(ctx, key)
+	if errors.Is(err, fs.ErrNotExist) {
+		return s.Put(ctx, key, body, opts)
+	}
+
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+
+	if info.Size < minUploadPartSize {
+		return appendByRewrite(ctx, s, key, body, opts)
+	}
+
+	partSize := opts.PartSize
+	if partSize < minUploadPartSize {
+		partSize = minUploadPartSize
+	}
+
+	counter := &countingReader{r: body}
+
+	// the object must not change between the copy and completing the upload
+	conds := preconditionOption(info.ETag, "")
+
+	err = s.multipart(ctx, key, info, conds, func(upload *multipartUpload) error {
+		if err := upload.copyParts(ctx, info); err != nil {
+			return err
+		}
+
+		if opts.PartUploaded != nil {
+			upload.opts = append(upload.opts, partUploadedOption(opts.PartUploaded))
+		}
+
+		return upload.uploadParts(ctx, counter, partSize)
+	})
+
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+
+	return ObjectInfo{
+		Key:         key,
+		Size:        info.Size + counter.n,
+		ContentType: info.ContentType,
+		Metadata:    info.Metadata,
+
+		ContentEncoding: info.ContentEncoding,
+
+		WebsiteRedirectLocation: info.WebsiteRedirectLocation,
+	}, nil
+}
+
+// AbortStaleUploads aborts multipart uploads under prefix initiated more than
+// olderThan ago.
+func (s *s3Store) AbortStaleUploads(ctx context.Context, prefix string, olderThan time.Duration) (int, error) {
+	cutoff := s.clock.Now().Add(-olderThan)
+	stale := []*s3.MultipartUpload{}
+
+	err := s.client.ListMultipartUploadsPagesWithContext(
+		ctx,
+		&s3.ListMultipartUploadsInput{
+			Bucket: &s.bucket,
+			Prefix: aws.String(prefix),
+		},
+		func(page *s3.ListMultipartUploadsOutput, lastPage bool) bool {
+			for _, upload := range page.Uploads {
+				if aws.TimeValue(upload.Initiated).Before(cutoff) {
+					stale = append(stale, upload)
+				}
+			}
+
+			return true
+		},
+		s.requestOptions(prefix)...,
+	)
+
+	if err != nil {
+		return 0, fmt.Errorf("could not list multipart uploads: %w", convertS3Error(err))
+	}
+
+	aborted := 0
+	for _, upload := range stale {
+		_, err := s.client.AbortMultipartUploadWithContext(
+			ctx,
+			&s3.AbortMultipartUploadInput{
+				Bucket:   &s.bucket,
+				Key:      upload.Key,
+				UploadId: upload.UploadId,
+			},
+			s.requestOptions(*upload.Key)...,
+		)
+
+		if err != nil {
+			return aborted, fmt.Errorf("could not abort upload of %s: %w", *upload.Key, convertS3Error(err))
+		}
+
+		aborted++
+	}
+
+	return aborted, nil
+}
+
+// copyPartSize is the largest part size used for copying parts of an object.
+const copyPartSize = 512 << 20
+
+// copyConcurrency is how many parts of a multipart copy are copied at once.
+const copyConcurrency = 8
+
+// multipartUpload is an in progress multipart upload of key, for the things the
+// s3manager uploader can't do, like building an object out of copied parts.
+type multipartUpload struct {
+	store    *s3Store
+	key      string
+	uploadID *string
+	opts     []request.Option
+
+	mu    sync.Mutex
+	parts []*s3.CompletedPart
+}
+
+// multipart runs fn with a new multipart upload to key, then completes the upload if
+// fn succeeded or aborts it if not. The new object gets the content type and
+// metadata from info. complete is applied to the request that completes the upload,
+// e.g. for preconditions.
+func (s *s3Store) multipart(ctx context.Context, key string, info ObjectInfo, complete request.Option, fn func(*multipartUpload) error) error {
+	opts := s.requestOptions(key)
+
+	input := &s3.CreateMultipartUploadInput{
+		Bucket: &s.bucket,
+		Key:    &key,
+	}
+
+	if info.ContentType != "" {
+		input.ContentType = aws.String(info.ContentType)
+	}
+
+	if info.ContentEncoding != "" {
+		input.ContentEncoding = aws.String(info.ContentEncoding)
+	}
+
+	if info.Metadata != nil {
+		input.Metadata = aws.StringMap(info.Metadata)
+	}
+
+	if info.WebsiteRedirectLocation != "" {
+		input.WebsiteRedirectLocation = aws.String(info.WebsiteRedirectLocation)
+	}
+
+	out, err := s.client.CreateMultipartUploadWithContext(ctx, input, opts...)
+	if err != nil {
+		return convertS3Error(err)
+	}
+
+	upload := &multipartUpload{
+		store:    s,
+		key:      key,
+		uploadID: out.UploadId,
+		opts:     opts,
+	}
+
+	err = fn(upload)
+	if err == nil {
+		err = upload.complete(ctx, complete)
+	}
+
+	if err != nil {
+		upload.abort()
+		return convertS3Error(err)
+	}
+
+	return nil
+}
+
+// copyParts copies all of src into the upload as the next parts, a few at a time.
+// The parts are all at least the minimum part size as long as src is.
+func (u *multipartUpload) copyParts(ctx context.Context, src ObjectInfo) error {
+	// split evenly so that the last part is never tiny
+	n := (src.Size + copyPartSize - 1) / copyPartSize
+	partSize := (src.Size + n - 1) / n
+	first := u.nextPartNumber()
+
+	work := make(chan int64)
+	mu := sync.Mutex{}
+	wg := sync.WaitGroup{}
+	var firstErr error
+
+	for i := 0; i < copyConcurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for i := range work {
+				start := i * partSize
+				end := start + partSize - 1
+				if end >= src.Size {
+					end = src.Size - 1
+				}
+
+				out, err := u.store.client.UploadPartCopyWithContext(
+					ctx,
+					&s3.UploadPartCopyInput{
+						Bucket:            &u.store.bucket,
+						Key:               &u.key,
+						UploadId:          u.uploadID,
+						PartNumber:        aws.Int64(first + i),
+						CopySource:        aws.String(u.store.copySource(src.Key)),
+						CopySourceRange:   aws.String(fmt.Sprintf("bytes=%d-%d", start, end)),
+						CopySourceIfMatch: aws.String(src.ETag),
+					},
+					u.opts...,
+				)
+
+				if err != nil {
+					mu.Lock()
+					if firstErr == nil {
+						firstErr = err
+					}
+					mu.Unlock()
+					continue
+				}
+
+				u.addPart(first+i, out.CopyPartResult.ETag)
+			}
+		}()
+	}
+
+	for i := int64(0); i*partSize < src.Size; i++ {
+		work <- i
+	}
+
+	close(work)
+	wg.Wait()
+
+	return firstErr
+}
+
+// uploadParts uploads everything read from r into the upload as the next parts.
+func (u *multipartUpload) uploadParts(ctx context.Context, r io.Reader, partSize int64) error {
+	buf := make([]byte, partSize)
+
+	for {
+		n, err := io.ReadFull(r, buf)
+		if n > 0 {
+			partNumber := u.nextPartNumber()
+
+			out, uploadErr := u.store.client.UploadPartWithContext(
+				ctx,
+				&s3.UploadPartInput{
+					Bucket:     &u.store.bucket,
+					Key:        &u.key,
+					UploadId:   u.uploadID,
+					PartNumber: aws.Int64(partNumber),
+					Body:       bytes.NewReader(buf[:n]),
+				},
+				u.opts...,
+			)
+
+			if uploadErr != nil {
+				return uploadErr
+			}
+
+			u.addPart(partNumber, out.ETag)
+		}
+
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (u *multipartUpload) nextPartNumber() int64 {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	last := int64(0)
+	for _, part := range u.parts {
+		if *part.PartNumber > last {
+			last = *part.PartNumber
+		}
+	}
+
+	return last + 1
+}
+
+func (u *multipartUpload) addPart(partNumber int64, etag *string) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	u.parts = append(u.parts, &s3.CompletedPart{
+		PartNumber: aws.Int64(partNumber),
+		ETag:       etag,
+	})
+}
+
+func (u *multipartUpload) complete(ctx context.Context, extra request.Option) error {
+	sort.Slice(u.parts, func(i, j int) bool {
+		return *u.parts[i].PartNumber < *u.parts[j].PartNumber
+	})
+
+	_, err := u.store.client.CompleteMultipartUploadWithContext(
+		ctx,
+		&s3.CompleteMultipartUploadInput{
+			Bucket:          &u.store.bucket,
+			Key:             &u.key,
+			UploadId:        u.uploadID,
+			MultipartUpload: &s3.CompletedMultipartUpload{Parts: u.parts},
+		},
+		append(u.opts, extra)...,
+	)
+
+	return err
+}
+
+func (u *multipartUpload) abort() {
+	// use a fresh context, the upload's context may be why we're aborting
+	u.store.client.AbortMultipartUploadWithContext(
+		context.Background(),
+		&s3.AbortMultipartUploadInput{
+			Bucket:   &u.store.bucket,
+			Key:      &u.key,
+			UploadId: u.uploadID,
+		},
+		u.opts...,
+	)
+}
@@ -0,0 +1,692 @@
+package s3fs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/arn"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/request"
+	v4 "github.com/aws/aws-sdk-go/aws/signer/v4"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+)
+
+// s3Store is the ObjectStore driver for S3.
+type s3Store struct {
+	client *s3.S3
+	bucket string
+
+	prefixCreds map[string]*credentials.Credentials
+
+	// multiRegion is set when bucket is the alias of a multi-region access point, whose
+	// ARN the store was created with, to send requests to it
+	multiRegion *multiRegionAccessPoint
+
+	// directory is set when bucket is an S3 Express One Zone directory bucket
+	directory *directoryBucket
+
+	// scheduler, if set, is what each request waits for a slot from
+	scheduler *scheduler
+
+	// clock is the FS's clock, for judging how old uploads are
+	clock Clock
+
+	// noObjectLock is set once we find out that the bucket doesn't have Object Lock
+	// enabled, so there's no point asking about objects' locks.
+	noObjectLock int32
+}
+
+// s3Stores returns the S3 stores that store sends its requests to, and whether it
+// sends all of them to S3 stores.
+func s3Stores(store ObjectStore) ([]*s3Store, bool) {
+	switch st := store.(type) {
+	case *s3Store:
+		return []*s3Store{st}, true
+	case *dryRunStore:
+		return s3Stores(st.store)
+	case *prefixStore:
+		return s3Stores(st.store)
+	case *failoverStore:
+		primary, primaryOK := s3Stores(st.primary)
+		replica, replicaOK := s3Stores(st.replica)
+
+		return append(primary, replica...), primaryOK && replicaOK
+	}
+
+	return nil, false
+}
+
+func newS3Store(client *s3.S3, bucket string) *s3Store {
+	s := &s3Store{
+		client:      client,
+		bucket:      bucket,
+		clock:       realClock{},
+		multiRegion: newMultiRegionAccessPoint(bucket),
+		directory:   newDirectoryBucket(bucket),
+	}
+
+	if s.directory != nil {
+		// directory buckets don't support Object Lock
+		s.noObjectLock = 1
+	}
+
+	if s.multiRegion != nil {
+		s.bucket = s.multiRegion.alias
+	}
+
+	return s
+}
+
+func (s *s3Store) List(ctx context.Context, prefix string, opts ListOptions, fn func(*ListPage) bool) error {
+	if s.directory != nil {
+		return s.listDirectoryBucket(ctx, prefix, opts, fn)
+	}
+
+	return s.listPages(ctx, prefix, opts, fn)
+}
+
+// listPages lists the bucket with ListObjectsV2, calling fn with each page.
+func (s *s3Store) listPages(ctx context.Context, prefix string, opts ListOptions, fn func(*ListPage) bool) error {
+	input := &s3.ListObjectsV2Input{
+		Bucket: &s.bucket,
+		Prefix: aws.String(prefix),
+	}
+
+	if opts.Delimiter != "" {
+		input.Delimiter = aws.String(opts.Delimiter)
+	}
+
+	if opts.MaxKeys > 0 {
+		input.MaxKeys = aws.Int64(int64(opts.MaxKeys))
+	}
+
+	if opts.StartAfter != "" {
+		input.StartAfter = aws.String(opts.StartAfter)
+	}
+
+	if opts.ContinuationToken != "" {
+		input.ContinuationToken = aws.String(opts.ContinuationToken)
+	}
+
+	err := s.client.ListObjectsV2PagesWithContext(
+		ctx,
+		input,
+		func(page *s3.ListObjectsV2Output, lastPage bool) bool {
+			out := &ListPage{NextContinuationToken: aws.StringValue(page.NextContinuationToken)}
+
+			for _, obj := range page.Contents {
+				out.Objects = append(
+					out.Objects,
+					ObjectInfo{
+						Key:          *obj.Key,
+						Size:         aws.Int64Value(obj.Size),
+						LastModified: aws.TimeValue(obj.LastModified),
+						ETag:         aws.StringValue(obj.ETag),
+						StorageClass: aws.StringValue(obj.StorageClass),
+					},
+				)
+			}
+
+			for _, cp := range page.CommonPrefixes {
+				out.CommonPrefixes = append(out.CommonPrefixes, *cp.Prefix)
+			}
+
+			return fn(out)
+		},
+		s.requestOptions(prefix)...,
+	)
+
+	return convertS3Error(err)
+}
+
+func (s *s3Store) Head(ctx context.Context, key string) (ObjectInfo, error) {
+	object, err := s.client.HeadObjectWithContext(
+		ctx,
+		&s3.HeadObjectInput{
+			Bucket: &s.bucket,
+			Key:    &key,
+		},
+		s.requestOptions(key)...,
+	)
+
+	if err != nil {
+		return ObjectInfo{}, convertS3Error(err)
+	}
+
+	return ObjectInfo{
+		Key:          key,
+		Size:         aws.Int64Value(object.ContentLength),
+		LastModified: aws.TimeValue(object.LastModified),
+		ETag:         aws.StringValue(object.ETag),
+		ContentType:  aws.StringValue(object.ContentType),
+		Metadata:     aws.StringValueMap(object.Metadata),
+		StorageClass: aws.StringValue(object.StorageClass),
+
+		ContentEncoding: aws.StringValue(object.ContentEncoding),
+
+		Expiration:        aws.StringValue(object.Expiration),
+		Restore:           aws.StringValue(object.Restore),
+		ReplicationStatus: aws.StringValue(object.ReplicationStatus),
+
+		WebsiteRedirectLocation: aws.StringValue(object.WebsiteRedirectLocation),
+	}, nil
+}
+
+func (s *s3Store) Get(ctx context.Context, key string, opts GetOptions) (*Object, error) {
+	input := &s3.GetObjectInput{
+		Bucket: &s.bucket,
+		Key:    &key,
+	}
+
+	if opts.Offset > 0 || opts.Length > 0 {
+		end := ""
+		if opts.Length > 0 {
+			end = strconv.FormatInt(opts.Offset+opts.Length-1, 10)
+		}
+
+		input.Range = aws.String(fmt.Sprintf("bytes=%d-%s", opts.Offset, end))
+	}
+
+	if opts.IfNoneMatch != "" {
+		input.IfNoneMatch = aws.String(opts.IfNoneMatch)
+	}
+
+	if !opts.IfModifiedSince.IsZero() {
+		input.IfModifiedSince = aws.Time(opts.IfModifiedSince)
+	}
+
+	retries := 0
+	countRetries := func(r *request.Request) {
+		r.Handlers.Complete.PushBack(func(r *request.Request) {
+			retries = r.RetryCount
+		})
+	}
+
+	object, err := s.client.GetObjectWithContext(ctx, input, append(s.requestOptions(key), identityEncoding, countRetries)...)
+	if err != nil {
+		return nil, convertS3Error(err)
+	}
+
+	size := aws.Int64Value(object.ContentLength)
+	if object.ContentRange != nil {
+		// bytes <first>-<last>/<total>
+		if i := strings.LastIndex(*object.ContentRange, "/"); i >= 0 {
+			if total, err := strconv.ParseInt((*object.ContentRange)[i+1:], 10, 64); err == nil {
+				size = total
+			}
+		}
+	}
+
+	return &Object{
+		Body: object.Body,
+		Info: ObjectInfo{
+			Key:          key,
+			Size:         size,
+			LastModified: aws.TimeValue(object.LastModified),
+			ETag:         aws.StringValue(object.ETag),
+			ContentType:  aws.StringValue(object.ContentType),
+			Metadata:     aws.StringValueMap(object.Metadata),
+			StorageClass: aws.StringValue(object.StorageClass),
+
+			ContentEncoding: aws.StringValue(object.ContentEncoding),
+
+			Expiration:        aws.StringValue(object.Expiration),
+			Restore:           aws.StringValue(object.Restore),
+			ReplicationStatus: aws.StringValue(object.ReplicationStatus),
+
+			WebsiteRedirectLocation: aws.StringValue(object.WebsiteRedirectLocation),
+		},
+		Retries: retries,
+	}, nil
+}
+
+func (s *s3Store) Put(ctx context.Context, key string, body io.Reader, opts PutOptions) (ObjectInfo, error) {
+	input := &s3manager.UploadInput{
+		Bucket: &s.bucket,
+		Key:    &key,
+		Body:   &countingReader{r: body},
+	}
+
+	if opts.ContentType != "" {
+		input.ContentType = aws.String(opts.ContentType)
+	}
+
+	if opts.ContentEncoding != "" {
+		input.ContentEncoding = aws.String(opts.ContentEncoding)
+	}
+
+	if opts.Metadata != nil {
+		input.Metadata = aws.StringMap(opts.Metadata)
+	}
+
+	if opts.WebsiteRedirectLocation != "" {
+		input.WebsiteRedirectLocation = aws.String(opts.WebsiteRedirectLocation)
+	}
+
+	reqOpts := s.requestOptions(key)
+	if opts.IfMatch != "" || opts.IfNoneMatch != "" {
+		reqOpts = append(reqOpts, preconditionOption(opts.IfMatch, opts.IfNoneMatch))
+	}
+
+	if opts.PartUploaded != nil {
+		reqOpts = append(reqOpts, partUploadedOption(opts.PartUploaded))
+	}
+
+	if opts.VerifyContent {
+		reqOpts = append(reqOpts, contentMD5Option)
+	}
+
+	uploader := s3manager.NewUploaderWithClient(s.client, func(u *s3manager.Uploader) {
+		if opts.PartSize > 0 {
+			u.PartSize = opts.PartSize
+		}
+	})
+	out, err := uploader.UploadWithContext(
+		ctx,
+		input,
+		s3manager.WithUploaderRequestOptions(reqOpts...),
+	)
+
+	if err != nil {
+		return ObjectInfo{}, convertS3Error(err)
+	}
+
+	return ObjectInfo{
+		Key:         key,
+		Size:        input.Body.(*countingReader).n,
+		ETag:        aws.StringValue(out.ETag),
+		ContentType: opts.ContentType,
+		Metadata:    opts.Metadata,
+
+		ContentEncoding: opts.ContentEncoding,
+
+		WebsiteRedirectLocation: opts.WebsiteRedirectLocation,
+	}, nil
+}
+
+func (s *s3Store) downloadRange(ctx context.Context, key string, w io.WriterAt, offset, length int64, etag string) (int64, error) {
+	input := &s3.GetObjectInput{
+		Bucket: &s.bucket,
+		Key:    &key,
+		Range:  aws.String(fmt.Sprintf("bytes=%d-%d", offset, offset+length-1)),
+	}
+
+	if etag != "" {
+		input.IfMatch = aws.String(etag)
+	}
+
+	// with a range, the downloader makes one request for it and writes it from offset
+	// zero
+	downloader := s3manager.NewDownloaderWithClient(s.client)
+	n, err := downloader.DownloadWithContext(
+		ctx,
+		&shiftedWriterAt{w: w, off: offset},
+		input,
+		s3manager.WithDownloaderRequestOptions(append(s.requestOptions(key), identityEncoding)...),
+	)
+
+	return n, convertS3Error(err)
+}
+
+func (s *s3Store) Delete(ctx context.Context, key string) error {
+	_, err := s.client.DeleteObjectWithContext(
+		ctx,
+		&s3.DeleteObjectInput{
+			Bucket: &s.bucket,
+			Key:    &key,
+		},
+		s.requestOptions(key)...,
+	)
+
+	return convertS3Error(err)
+}
+
+// maxCopyObjectSize is the largest object CopyObject can copy. Anything bigger has
+// to be copied in parts with UploadPartCopy.
+const maxCopyObjectSize = 5 << 30
+
+func (s *s3Store) Copy(ctx context.Context, src, dst string, opts CopyOptions) error {
+	info, err := s.Head(ctx, src)
+	if err != nil {
+		return err
+	}
+
+	conds := preconditionOption(opts.IfMatch, opts.IfNoneMatch)
+
+	if info.Size > maxCopyObjectSize {
+		return s.multipart(ctx, dst, info, conds, func(upload *multipartUpload) error {
+			return upload.copyParts(ctx, info)
+		})
+	}
+
+	_, err = s.client.CopyObjectWithContext(
+		ctx,
+		&s3.CopyObjectInput{
+			Bucket:     &s.bucket,
+			Key:        &dst,
+			CopySource: aws.String(s.copySource(src)),
+		},
+		append(s.requestOptions(dst), conds)...,
+	)
+
+	return convertS3Error(err)
+}
+
+func (s *s3Store) Restore(ctx context.Context, key string, tier RestoreTier, days int) error {
+	_, err := s.client.RestoreObjectWithContext(
+		ctx,
+		&s3.RestoreObjectInput{
+			Bucket: &s.bucket,
+			Key:    &key,
+			RestoreRequest: &s3.RestoreRequest{
+				Days: aws.Int64(int64(days)),
+				GlacierJobParameters: &s3.GlacierJobParameters{
+					Tier: aws.String(string(tier)),
+				},
+			},
+		},
+		s.requestOptions(key)...,
+	)
+
+	var awsErr awserr.Error
+	if errors.As(err, &awsErr) && (awsErr.Code() == "RestoreAlreadyInProgress" || awsErr.Code() == s3.ErrCodeObjectAlreadyInActiveTierError) {
+		return nil
+	}
+
+	return convertS3Error(err)
+}
+
+func (s *s3Store) ObjectLock(ctx context.Context, key string) (ObjectLock, error) {
+	if atomic.LoadInt32(&s.noObjectLock) == 1 {
+		return ObjectLock{}, nil
+	}
+
+	lock := ObjectLock{}
+
+	retention, err := s.client.GetObjectRetentionWithContext(
+		ctx,
+		&s3.GetObjectRetentionInput{
+			Bucket: &s.bucket,
+			Key:    &key,
+		},
+		s.requestOptions(key)...,
+	)
+
+	switch {
+	case err == nil && retention.Retention != nil:
+		lock.Mode = aws.StringValue(retention.Retention.Mode)
+		lock.RetainUntil = aws.TimeValue(retention.Retention.RetainUntilDate)
+	case err == nil || s.objectLockMissing(err):
+	default:
+		return ObjectLock{}, convertS3Error(err)
+	}
+
+	if atomic.LoadInt32(&s.noObjectLock) == 1 {
+		return ObjectLock{}, nil
+	}
+
+	hold, err := s.client.GetObjectLegalHoldWithContext(
+		ctx,
+		&s3.GetObjectLegalHoldInput{
+			Bucket: &s.bucket,
+			Key:    &key,
+		},
+		s.requestOptions(key)...,
+	)
+
+	switch {
+	case err == nil && hold.LegalHold != nil:
+		lock.LegalHold = aws.StringValue(hold.LegalHold.Status) == s3.ObjectLockLegalHoldStatusOn
+	case err == nil || s.objectLockMissing(err):
+	default:
+		return ObjectLock{}, convertS3Error(err)
+	}
+
+	return lock, nil
+}
+
+func (s *s3Store) ACL(ctx context.Context, key string) (ACL, error) {
+	out, err := s.client.GetObjectAclWithContext(
+		ctx,
+		&s3.GetObjectAclInput{
+			Bucket: &s.bucket,
+			Key:    &key,
+		},
+		s.requestOptions(key)...,
+	)
+
+	if err != nil {
+		return ACL{}, convertS3Error(err)
+	}
+
+	acl := ACL{}
+	if out.Owner != nil {
+		acl.Owner = Grantee{
+			Type:        s3.TypeCanonicalUser,
+			ID:          aws.StringValue(out.Owner.ID),
+			DisplayName: aws.StringValue(out.Owner.DisplayName),
+		}
+	}
+
+	for _, g := range out.Grants {
+		grant := Grant{Permission: aws.StringValue(g.Permission)}
+		if g.Grantee != nil {
+			grant.Grantee = Grantee{
+				Type:        aws.StringValue(g.Grantee.Type),
+				ID:          aws.StringValue(g.Grantee.ID),
+				DisplayName: aws.StringValue(g.Grantee.DisplayName),
+				Email:       aws.StringValue(g.Grantee.EmailAddress),
+				URI:         aws.StringValue(g.Grantee.URI),
+			}
+		}
+
+		acl.Grants = append(acl.Grants, grant)
+	}
+
+	return acl, nil
+}
+
+func (s *s3Store) Presign(key string, expires time.Duration) (string, error) {
+	req, _ := s.client.GetObjectRequest(&s3.GetObjectInput{
+		Bucket: &s.bucket,
+		Key:    &key,
+	})
+
+	// the URL expires by the FS's clock
+	req.Handlers.Sign.Swap(v4.SignRequestHandler.Name, request.NamedHandler{
+		Name: v4.SignRequestHandler.Name,
+		Fn: func(r *request.Request) {
+			v4.SignSDKRequestWithCurrentTime(r, s.clock.Now)
+		},
+	})
+	req.ApplyOptions(s.requestOptions(key)...)
+
+	return req.Presign(expires)
+}
+
+func (s *s3Store) presignPost(keyPrefix string, expires time.Duration, conditions []postCondition) (*PresignedPost, error) {
+	if s.multiRegion != nil {
+		return nil, fmt.Errorf("multi-region access points don't accept POST uploads")
+	}
+
+	// the form is posted to the bucket's endpoint, which is easiest to get by building
+	// a request for the bucket
+	req, _ := s.client.ListObjectsV2Request(&s3.ListObjectsV2Input{Bucket: &s.bucket})
+	req.ApplyOptions(s.requestOptions(keyPrefix)...)
+	if err := req.Build(); err != nil {
+		return nil, err
+	}
+
+	creds, err := req.Config.Credentials.Get()
+	if err != nil {
+		return nil, fmt.Errorf("could not get credentials: %w", err)
+	}
+
+	region := req.ClientInfo.SigningRegion
+	if region == "" {
+		region = aws.StringValue(req.Config.Region)
+	}
+
+	u := *req.HTTPRequest.URL
+	u.RawQuery = ""
+
+	now := s.clock.Now()
+	fields := map[string]string{"bucket": s.bucket}
+	err = signPostPolicy(fields, creds.AccessKeyID, creds.SecretAccessKey, creds.SessionToken, region, now, now.Add(expires), conditions)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PresignedPost{URL: u.String(), Fields: fields}, nil
+}
+
+// objectLockMissing reports whether err means that there is no retention or legal
+// hold to get, noting if that's because the whole bucket doesn't use Object Lock.
+func (s *s3Store) objectLockMissing(err error) bool {
+	var awsErr awserr.Error
+	if !errors.As(err, &awsErr) {
+		return false
+	}
+
+	switch awsErr.Code() {
+	case "NoSuchObjectLockConfiguration":
+		return true
+	case "InvalidRequest":
+		// "Bucket is missing Object Lock Configuration"
+		atomic.StoreInt32(&s.noObjectLock, 1)
+		return true
+	}
+
+	return false
+}
+
+// preconditionOption sets the conditional write headers on the requests that create
+// an object. The version of the SDK we use predates S3 conditional writes, so they
+// aren't fields on the inputs.
+func preconditionOption(ifMatch, ifNoneMatch string) request.Option {
+	return func(r *request.Request) {
+		r.Handlers.Build.PushBack(func(r *request.Request) {
+			switch r.Operation.Name {
+			case "PutObject", "CopyObject", "CompleteMultipartUpload":
+			default:
+				return
+			}
+
+			if ifMatch != "" {
+				r.HTTPRequest.Header.Set("If-Match", ifMatch)
+			}
+
+			if ifNoneMatch != "" {
+				r.HTTPRequest.Header.Set("If-None-Match", ifNoneMatch)
+			}
+		})
+	}
+}
+
+// partUploadedOption calls fn whenever a PutObject or UploadPart request made by the
+// uploader succeeds.
+func partUploadedOption(fn func(partNumber int, partBytes int64)) request.Option {
+	return func(r *request.Request) {
+		r.Handlers.Complete.PushBack(func(r *request.Request) {
+			if r.Error != nil {
+				return
+			}
+
+			switch in := r.Params.(type) {
+			case *s3.PutObjectInput:
+				fn(1, r.HTTPRequest.ContentLength)
+			case *s3.UploadPartInput:
+				fn(int(aws.Int64Value(in.PartNumber)), r.HTTPRequest.ContentLength)
+			}
+		})
+	}
+}
+
+// identityEncoding asks for an object as it is stored. Otherwise Go's transport asks
+// for gzip itself, and then transparently decompresses objects stored with a gzip
+// Content-Encoding and drops their Content-Length.
+func identityEncoding(r *request.Request) {
+	r.HTTPRequest.Header.Set("Accept-Encoding", "identity")
+}
+
+// contentMD5Option makes the SDK send Content-MD5 with PutObject and UploadPart
+// requests even if the client was configured not to, so S3 rejects any part that
+// doesn't arrive as it was sent. Unlike an ETag, that works however the bucket
+// encrypts the object.
+func contentMD5Option(r *request.Request) {
+	r.Config.S3DisableContentMD5Validation = aws.Bool(false)
+}
+
+// copySource formats key in the bucket for the x-amz-copy-source header.
+func (s *s3Store) copySource(key string) string {
+	if s.multiRegion != nil {
+		return url.PathEscape(s.multiRegion.arn + "/object/" + key)
+	}
+
+	if arn.IsARN(s.bucket) {
+		return url.PathEscape(s.bucket + "/object/" + key)
+	}
+
+	return url.PathEscape(s.bucket + "/" + key)
+}
+
+// convertS3Error makes errors for missing keys wrap fs.ErrNotExist, and the other
+// errors we expose wrap their sentinels.
+func convertS3Error(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var awsErr awserr.Error
+	if errors.As(err, &awsErr) && (awsErr.Code() == s3.ErrCodeNoSuchKey || awsErr.Code() == "NotFound") {
+		return fmt.Errorf("%w: %s", fs.ErrNotExist, err)
+	}
+
+	if errors.As(err, &awsErr) && awsErr.Code() == s3.ErrCodeInvalidObjectState {
+		return fmt.Errorf("%w: %s", ErrObjectArchived, err)
+	}
+
+	if errors.As(err, &awsErr) && awsErr.Code() == "InvalidRange" {
+		return fmt.Errorf("%w: %s", ErrInvalidRange, err)
+	}
+
+	if errors.As(err, &awsErr) && awsErr.Code() == "NotModified" {
+		return fmt.Errorf("%w: %s", ErrNotModified, err)
+	}
+
+	if errors.As(err, &awsErr) && (awsErr.Code() == "PreconditionFailed" || awsErr.Code() == "ConditionalRequestConflict") {
+		return fmt.Errorf("%w: %s", ErrPreconditionFailed, err)
+	}
+
+	return err
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(buf []byte) (int, error) {
+	n, err := c.r.Read(buf)
+	c.n += int64(n)
+	return n, err
+}
+
+func (s *s3Store) bucketName() string {
+	if s.multiRegion != nil {
+		return s.multiRegion.arn
+	}
+
+	return s.bucket
+}
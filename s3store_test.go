@@ -0,0 +1,250 @@
+package s3fs
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/stretchr/testify/require"
+)
+
+func TestS3Store_DownloadTo(t *testing.T) {
+	content := "hello, ranged world"
+
+	mu := sync.Mutex{}
+	ranges := []string{}
+	transport := roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		header := http.Header{"Etag": []string{`"v1"`}}
+		body := ""
+
+		if r.Method == http.MethodGet {
+			mu.Lock()
+			ranges = append(ranges, r.Header.Get("Range"))
+			mu.Unlock()
+
+			if r.Header.Get("If-Match") != `"v1"` {
+				return &http.Response{StatusCode: http.StatusPreconditionFailed, Body: io.NopCloser(strings.NewReader("")), Request: r}, nil
+			}
+
+			var first, last int
+			fmt.Sscanf(r.Header.Get("Range"), "bytes=%d-%d", &first, &last)
+			body = content[first : last+1]
+			header.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", first, last, len(content)))
+		}
+
+		header.Set("Content-Length", strconv.Itoa(len(content)))
+		if r.Method == http.MethodGet {
+			header.Set("Content-Length", strconv.Itoa(len(body)))
+		}
+
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     header,
+			Body:       io.NopCloser(strings.NewReader(body)),
+			Request:    r,
+		}, nil
+	})
+
+	myFS := NewS3FS(newTestClient(transport, ""), "my-bucket")
+
+	dst := filepath.Join(t.TempDir(), "hello.txt")
+	require.Nil(t, myFS.DownloadTo(context.Background(), "hello.txt", dst))
+
+	data, err := os.ReadFile(dst)
+	require.Nil(t, err)
+	require.Equal(t, content, string(data))
+
+	// the downloader fetched the one part by range, pinned to the ETag it was sized by
+	require.Equal(t, []string{fmt.Sprintf("bytes=0-%d", len(content)-1)}, ranges)
+}
+
+func TestS3Store_UploadFromSendsContentMD5(t *testing.T) {
+	var sent *http.Request
+	var sentBody []byte
+	transport := roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		sent = r
+		sentBody, _ = io.ReadAll(r.Body)
+
+		// objects encrypted with SSE-KMS don't have MD5s for ETags
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header: http.Header{
+				"Etag":                         []string{`"not-an-md5"`},
+				"X-Amz-Server-Side-Encryption": []string{"aws:kms"},
+			},
+			Body:    io.NopCloser(strings.NewReader("")),
+			Request: r,
+		}, nil
+	})
+
+	client := newTestClient(transport, "")
+	client.Config.S3DisableContentMD5Validation = aws.Bool(true)
+	myFS := NewS3FS(client, "my-bucket")
+
+	require.Nil(t, myFS.UploadFrom(context.Background(), "hello.txt", strings.NewReader("hello"), 5))
+
+	sum := md5.Sum([]byte("hello"))
+	require.Equal(t, http.MethodPut, sent.Method)
+	require.Equal(t, "hello", string(sentBody))
+	require.Equal(t, base64.StdEncoding.EncodeToString(sum[:]), sent.Header.Get("Content-Md5"))
+}
+
+// s3Stub is a bucket served over HTTP that stores objects with the headers they were
+// put with, and serves them back as stored, like S3.
+type s3Stub struct {
+	mu      sync.Mutex
+	objects map[string]stubObject
+}
+
+type stubObject struct {
+	header http.Header
+	body   []byte
+}
+
+func (s *s3Stub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := strings.TrimPrefix(r.URL.Path, "/my-bucket/")
+
+	switch {
+	case r.Method == http.MethodPut:
+		body, _ := io.ReadAll(r.Body)
+		header := http.Header{"Etag": []string{`"etag"`}}
+		for k, v := range r.Header {
+			if k == "Content-Type" || k == "Content-Encoding" || strings.HasPrefix(k, "X-Amz-Meta-") {
+				header[k] = v
+			}
+		}
+
+		s.objects[key] = stubObject{header: header, body: body}
+		w.Header().Set("Etag", `"etag"`)
+	case r.URL.Query().Get("list-type") != "":
+		contents := ""
+		for k, obj := range s.objects {
+			if strings.HasPrefix(k, r.URL.Query().Get("prefix")) {
+				contents += fmt.Sprintf("<Contents><Key>%s</Key><Size>%d</Size></Contents>", k, len(obj.body))
+			}
+		}
+
+		io.WriteString(w, "<ListBucketResult>"+contents+"</ListBucketResult>")
+	default:
+		obj, ok := s.objects[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			io.WriteString(w, "<Error><Code>NoSuchKey</Code></Error>")
+			return
+		}
+
+		for k, v := range obj.header {
+			w.Header()[k] = v
+		}
+
+		body := obj.body
+		status := http.StatusOK
+		if rng := r.Header.Get("Range"); rng != "" {
+			first, last := 0, len(body)-1
+			fmt.Sscanf(rng, "bytes=%d-%d", &first, &last)
+			if last >= len(body) {
+				last = len(body) - 1
+			}
+
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", first, last, len(body)))
+			body = body[first : last+1]
+			status = http.StatusPartialContent
+		}
+
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		w.WriteHeader(status)
+		if r.Method != http.MethodHead {
+			w.Write(body)
+		}
+	}
+}
+
+func TestS3Store_GzipContentEncoding(t *testing.T) {
+	server := httptest.NewServer(&s3Stub{objects: map[string]stubObject{}})
+	defer server.Close()
+
+	sess := session.Must(session.NewSession(&aws.Config{
+		Region:           aws.String("us-east-1"),
+		Credentials:      credentials.NewStaticCredentials("AKID", "SECRET", ""),
+		Endpoint:         aws.String(server.URL),
+		S3ForcePathStyle: aws.Bool(true),
+	}))
+
+	// a real transport, which decompresses gzip responses unless told not to
+	client := s3.New(sess, &aws.Config{HTTPClient: &http.Client{Transport: &http.Transport{}}})
+	myFS := NewS3FS(client, "my-bucket", WithWriteCompression(Gzip))
+
+	content := strings.Repeat("hello, compressed world\n", 1000)
+	require.Nil(t, myFS.WriteFile("hello.txt", []byte(content)))
+
+	data, err := fs.ReadFile(myFS, "hello.txt")
+	require.Nil(t, err)
+	require.Equal(t, content, string(data))
+
+	object, err := myFS.store.Get(context.Background(), "hello.txt", GetOptions{})
+	require.Nil(t, err)
+	defer object.Body.Close()
+
+	stored, err := io.ReadAll(object.Body)
+	require.Nil(t, err)
+	require.Equal(t, "gzip", object.Info.ContentEncoding)
+	require.Equal(t, int64(len(stored)), object.Info.Size)
+	require.Less(t, len(stored), len(content))
+}
+
+// fixedClock is a Clock that is always at now.
+type fixedClock struct {
+	now time.Time
+}
+
+func (c fixedClock) Now() time.Time {
+	return c.now
+}
+
+func (c fixedClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+func TestS3Store_ScopedClock(t *testing.T) {
+	aborted := make(chan string, 10)
+	transport := roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		if r.Method == http.MethodDelete {
+			aborted <- r.URL.Path
+			return respond(r, http.StatusNoContent, nil, ""), nil
+		}
+
+		return respond(r, http.StatusOK, nil, `<ListMultipartUploadsResult>
+			<Upload><Key>tenant/old.txt</Key><UploadId>old</UploadId><Initiated>2030-01-01T00:00:00Z</Initiated></Upload>
+			<Upload><Key>tenant/new.txt</Key><UploadId>new</UploadId><Initiated>2030-06-01T00:00:00Z</Initiated></Upload>
+		</ListMultipartUploadsResult>`), nil
+	})
+
+	clock := fixedClock{now: time.Date(2030, 6, 1, 12, 0, 0, 0, time.UTC)}
+	myFS, err := NewScopedS3FS(newTestClient(transport, ""), "my-bucket", "tenant", WithClock(clock))
+	require.Nil(t, err)
+
+	// only the upload that is old by the FS's clock is stale
+	n, err := myFS.AbortStaleUploads(context.Background(), 24*time.Hour)
+	require.Nil(t, err)
+	require.Equal(t, 1, n)
+	require.Equal(t, "/tenant/old.txt", <-aborted)
+}
@@ -0,0 +1,302 @@
+package s3fs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// NewScopedS3FS returns an fs.FS backed by the directory prefix of bucket, for handing
+// a restricted view of a bucket to code that shouldn't see the rest of it, such as
+// plugins. Names are relative to prefix, and every key the FS computes is checked to
+// be inside it before a request is made, so no name can reach outside it: keys with
+// empty, "." or ".." segments, which the SDK or S3 could resolve to somewhere else,
+// are refused with an error wrapping fs.ErrPermission whatever the NamePolicy.
+//
+// That is enforced by the FS itself. For S3 to enforce it too, read the bucket with
+// credentials limited to prefix, e.g. from NewS3FSAssumeRole's provider with
+// ScopedSessionPolicy.
+func NewScopedS3FS(client *s3.S3, bucket, prefix string, opts ...Option) (*S3FS, error) {
+	store, err := newPrefixStore(newS3Store(client, bucket), prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	s := newFS(store, opts...)
+	s.bucketErr = checkBucket(bucket)
+	s.start()
+
+	return s, nil
+}
+
+// NewScopedFS is NewScopedS3FS for an arbitrary ObjectStore.
+func NewScopedFS(store ObjectStore, prefix string, opts ...Option) (*S3FS, error) {
+	scoped, err := newPrefixStore(store, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewFS(scoped, opts...), nil
+}
+
+// ScopedSessionPolicy returns an option for stscreds.AssumeRoleProvider, such as
+// NewS3FSAssumeRole takes, that attaches a session policy to the assumed role
+// limiting it to listing and using objects under the directory prefix of bucket. S3
+// then refuses any request outside prefix, whatever the role itself allows.
+func ScopedSessionPolicy(bucket, prefix string) func(*stscreds.AssumeRoleProvider) {
+	prefix = strings.Trim(prefix, "/")
+
+	policy, _ := json.Marshal(map[string]interface{}{
+		"Version": "2012-10-17",
+		"Statement": []interface{}{
+			map[string]interface{}{
+				"Effect":   "Allow",
+				"Action":   []string{"s3:ListBucket", "s3:ListBucketMultipartUploads"},
+				"Resource": bucketARN(bucket),
+				"Condition": map[string]interface{}{
+					"StringLike": map[string]interface{}{"s3:prefix": []string{prefix + "/*"}},
+				},
+			},
+			map[string]interface{}{
+				"Effect":   "Allow",
+				"Action":   "s3:*",
+				"Resource": bucketARN(bucket) + "/" + prefix + "/*",
+			},
+		},
+	})
+
+	return func(p *stscreds.AssumeRoleProvider) {
+		p.Policy = aws.String(string(policy))
+	}
+}
+
+// prefixStore keeps every request to store under prefix.
+type prefixStore struct {
+	store  ObjectStore
+	prefix string
+}
+
+func newPrefixStore(store ObjectStore, prefix string) (*prefixStore, error) {
+	prefix = strings.Trim(prefix, "/")
+	if prefix == "" || !fs.ValidPath(prefix) || prefix == "." {
+		return nil, fmt.Errorf("invalid scope prefix: %q", prefix)
+	}
+
+	return &prefixStore{store: store, prefix: prefix + "/"}, nil
+}
+
+// scoped returns the key in store for key, or an error if it could end up outside
+// prefix.
+func (p *prefixStore) scoped(key string) (string, error) {
+	segments := strings.Split(key, "/")
+	for i, segment := range segments {
+		// a trailing slash is fine, as in directory prefixes
+		if segment == "" && i == len(segments)-1 {
+			continue
+		}
+
+		if segment == "" || segment == "." || segment == ".." {
+			return "", fmt.Errorf("%w: %q is outside of %s", fs.ErrPermission, key, p.prefix)
+		}
+	}
+
+	return p.prefix + key, nil
+}
+
+// unscoped returns the key relative to prefix for a key in store, reporting whether it
+// is under prefix.
+func (p *prefixStore) unscoped(key string) (string, bool) {
+	if !strings.HasPrefix(key, p.prefix) {
+		return "", false
+	}
+
+	return strings.TrimPrefix(key, p.prefix), true
+}
+
+func (p *prefixStore) List(ctx context.Context, prefix string, opts ListOptions, fn func(*ListPage) bool) error {
+	scopedPrefix, err := p.scoped(prefix)
+	if err != nil {
+		return err
+	}
+
+	if opts.StartAfter != "" {
+		opts.StartAfter = p.prefix + opts.StartAfter
+	}
+
+	return p.store.List(ctx, scopedPrefix, opts, func(page *ListPage) bool {
+		out := &ListPage{NextContinuationToken: page.NextContinuationToken}
+
+		for _, obj := range page.Objects {
+			if key, ok := p.unscoped(obj.Key); ok {
+				obj.Key = key
+				out.Objects = append(out.Objects, obj)
+			}
+		}
+
+		for _, cp := range page.CommonPrefixes {
+			if key, ok := p.unscoped(cp); ok {
+				out.CommonPrefixes = append(out.CommonPrefixes, key)
+			}
+		}
+
+		return fn(out)
+	})
+}
+
+func (p *prefixStore) Head(ctx context.Context, key string) (ObjectInfo, error) {
+	scopedKey, err := p.scoped(key)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+
+	info, err := p.store.Head(ctx, scopedKey)
+	info.Key = key
+
+	return info, err
+}
+
+func (p *prefixStore) Get(ctx context.Context, key string, opts GetOptions) (*Object, error) {
+	scopedKey, err := p.scoped(key)
+	if err != nil {
+		return nil, err
+	}
+
+	object, err := p.store.Get(ctx, scopedKey, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	object.Info.Key = key
+
+	return object, nil
+}
+
+func (p *prefixStore) Put(ctx context.Context, key string, body io.Reader, opts PutOptions) (ObjectInfo, error) {
+	scopedKey, err := p.scoped(key)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+
+	info, err := p.store.Put(ctx, scopedKey, body, opts)
+	info.Key = key
+
+	return info, err
+}
+
+func (p *prefixStore) Delete(ctx context.Context, key string) error {
+	scopedKey, err := p.scoped(key)
+	if err != nil {
+		return err
+	}
+
+	return p.store.Delete(ctx, scopedKey)
+}
+
+func (p *prefixStore) Copy(ctx context.Context, src, dst string, opts CopyOptions) error {
+	scopedSrc, err := p.scoped(src)
+	if err != nil {
+		return err
+	}
+
+	scopedDst, err := p.scoped(dst)
+	if err != nil {
+		return err
+	}
+
+	return p.store.Copy(ctx, scopedSrc, scopedDst, opts)
+}
+
+func (p *prefixStore) Append(ctx context.Context, key string, body io.Reader, opts PutOptions) (ObjectInfo, error) {
+	as, ok := p.store.(appendStore)
+	if !ok {
+		return appendByRewrite(ctx, p, key, body, opts)
+	}
+
+	scopedKey, err := p.scoped(key)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+
+	info, err := as.Append(ctx, scopedKey, body, opts)
+	info.Key = key
+
+	return info, err
+}
+
+func (p *prefixStore) Restore(ctx context.Context, key string, tier RestoreTier, days int) error {
+	rs, ok := p.store.(restoreStore)
+	if !ok {
+		return fmt.Errorf("could not restore %s: store doesn't archive objects", key)
+	}
+
+	scopedKey, err := p.scoped(key)
+	if err != nil {
+		return err
+	}
+
+	return rs.Restore(ctx, scopedKey, tier, days)
+}
+
+func (p *prefixStore) ObjectLock(ctx context.Context, key string) (ObjectLock, error) {
+	ls, ok := p.store.(objectLockStore)
+	if !ok {
+		return ObjectLock{}, nil
+	}
+
+	scopedKey, err := p.scoped(key)
+	if err != nil {
+		return ObjectLock{}, err
+	}
+
+	return ls.ObjectLock(ctx, scopedKey)
+}
+
+func (p *prefixStore) ACL(ctx context.Context, key string) (ACL, error) {
+	as, ok := p.store.(aclStore)
+	if !ok {
+		return ACL{}, fmt.Errorf("could not get ACL of %s: store doesn't have ACLs", key)
+	}
+
+	scopedKey, err := p.scoped(key)
+	if err != nil {
+		return ACL{}, err
+	}
+
+	return as.ACL(ctx, scopedKey)
+}
+
+func (p *prefixStore) Presign(key string, expires time.Duration) (string, error) {
+	ps, ok := p.store.(presignStore)
+	if !ok {
+		return "", fmt.Errorf("store doesn't presign URLs")
+	}
+
+	scopedKey, err := p.scoped(key)
+	if err != nil {
+		return "", err
+	}
+
+	return ps.Presign(scopedKey, expires)
+}
+
+func (p *prefixStore) AbortStaleUploads(ctx context.Context, prefix string, olderThan time.Duration) (int, error) {
+	aborter, ok := p.store.(staleUploadAborter)
+	if !ok {
+		return 0, nil
+	}
+
+	scopedPrefix, err := p.scoped(prefix)
+	if err != nil {
+		return 0, err
+	}
+
+	return aborter.AbortStaleUploads(ctx, scopedPrefix, olderThan)
+}
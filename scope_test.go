@@ -0,0 +1,114 @@
+package s3fs_test
+
+import (
+	"context"
+	"encoding/json"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
+	"github.com/packrat386/s3fs"
+	"github.com/packrat386/s3fs/s3fstest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewScopedFS(t *testing.T) {
+	store := s3fstest.NewMemStore()
+	store.WriteFile("tenants/a/config.json", `{"tenant":"a"}`)
+	store.WriteFile("tenants/a/data/1.json", `{}`)
+	store.WriteFile("tenants/b/config.json", `{"tenant":"b"}`)
+	store.WriteFile("secret.txt", "secret")
+
+	myFS, err := s3fs.NewScopedFS(store, "tenants/a", s3fs.WithNamePolicy(s3fs.RelaxedNames))
+	require.Nil(t, err)
+
+	if err := fstest.TestFS(myFS, "config.json", "data/1.json"); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := fs.ReadFile(myFS, "config.json")
+	require.Nil(t, err)
+	require.Equal(t, `{"tenant":"a"}`, string(data))
+
+	info, err := myFS.StatFile(context.Background(), "config.json")
+	require.Nil(t, err)
+	require.Equal(t, "config.json", info.Sys().(s3fs.ObjectInfo).Key)
+
+	require.Nil(t, myFS.WriteFile("data/2.json", []byte(`{}`)))
+	_, err = store.Head(context.Background(), "tenants/a/data/2.json")
+	require.Nil(t, err)
+
+	require.Nil(t, myFS.Copy("data/2.json", "data/3.json"))
+	require.Nil(t, myFS.Remove("data/2.json"))
+
+	_, err = fs.ReadFile(myFS, "../b/config.json")
+	require.ErrorIs(t, err, fs.ErrInvalid)
+
+	// even names the policy lets through can't leave the prefix
+	escaping := func(name string) (string, bool) { return name, true }
+	loose, err := s3fs.NewScopedFS(store, "tenants/a", s3fs.WithNamePolicy(escaping))
+	require.Nil(t, err)
+
+	for _, name := range []string{"../b/config.json", "data/../../b/config.json", "./config.json", "/secret.txt", "data//1.json"} {
+		_, err = loose.OpenIf(context.Background(), name, s3fs.ReadConditions{})
+		require.ErrorIs(t, err, fs.ErrPermission, name)
+
+		err = loose.WriteFile(name, []byte("x"))
+		require.ErrorIs(t, err, fs.ErrPermission, name)
+	}
+
+	_, err = s3fs.NewScopedFS(store, "../")
+	require.NotNil(t, err)
+
+	_, err = s3fs.NewScopedFS(store, "")
+	require.NotNil(t, err)
+}
+
+func TestNewScopedFS_Changes(t *testing.T) {
+	src := make(chanSource, 1)
+	myFS, err := s3fs.NewScopedFS(s3fstest.NewMemStore(), "dir", s3fs.WithChangeSource(src))
+	require.Nil(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	events, err := myFS.Changes(ctx)
+	require.Nil(t, err)
+
+	src <- s3Event
+
+	select {
+	case e := <-events:
+		require.Equal(t, "a file.txt", e.Name)
+	case <-time.After(time.Second):
+		t.Fatal("no event")
+	}
+
+	select {
+	case e := <-events:
+		t.Fatalf("got event outside of the scope: %+v", e)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestScopedSessionPolicy(t *testing.T) {
+	p := &stscreds.AssumeRoleProvider{}
+	s3fs.ScopedSessionPolicy("my-bucket", "/tenants/a/")(p)
+
+	policy := struct {
+		Statement []struct {
+			Action    interface{}
+			Resource  string
+			Condition map[string]map[string][]string
+		}
+	}{}
+	require.Nil(t, json.Unmarshal([]byte(aws.StringValue(p.Policy)), &policy))
+
+	require.Len(t, policy.Statement, 2)
+	require.Equal(t, "arn:aws:s3:::my-bucket", policy.Statement[0].Resource)
+	require.Equal(t, []string{"tenants/a/*"}, policy.Statement[0].Condition["StringLike"]["s3:prefix"])
+	require.Equal(t, "arn:aws:s3:::my-bucket/tenants/a/*", policy.Statement[1].Resource)
+}
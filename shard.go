@@ -0,0 +1,312 @@
+package s3fs
+
+import (
+	"context"
+	"strings"
+	"sync"
+)
+
+// shardBoundaries are where the key ranges that sharded listings are split into can
+// start: after each printable ASCII character that can follow the listed prefix.
+var shardBoundaries = func() []string {
+	out := []string{}
+	for c := byte('!'); c <= '~'; c++ {
+		out = append(out, string(c))
+	}
+
+	return out
+}()
+
+// WithShardedListing speeds up listing prefixes with millions of keys by splitting
+// them into up to shards key ranges that are listed concurrently. It applies to
+// listings of prefixes and anything under them, or to every listing if no prefixes
+// are given, and works for directories as well as flat listings such as WalkParallel
+// and GenerateManifest do.
+//
+// A listing only gets sharded once it is clear that it's big: its first page is
+// always listed on its own, and listings that fit in it cost nothing extra. After
+// that a quick sampling pass asks S3 for the first key after each character that can
+// follow the prefix, one request each, and the ranges that turn out to have keys are
+// spread over the shards. That works best when names are spread evenly over their
+// first character, as hashed, random or hex names are. Pages are still handed over in
+// key order, so pages of later ranges are held in memory until the ranges before them
+// have been listed.
+func WithShardedListing(shards int, prefixes ...string) Option {
+	return func(s *S3FS) {
+		s.listingShards = shards
+		s.shardedPrefixes = prefixes
+	}
+}
+
+// shardedStore splits big listings into key ranges listed concurrently.
+type shardedStore struct {
+	ObjectStore
+
+	shards   int
+	prefixes []string
+}
+
+// applies reports whether listings of prefix should be sharded.
+func (s *shardedStore) applies(prefix string) bool {
+	if len(s.prefixes) == 0 {
+		return true
+	}
+
+	for _, p := range s.prefixes {
+		if strings.HasPrefix(prefix, strings.TrimPrefix(p, "/")) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// keyRange is the part of a listing after start, up to and including end. An empty
+// end has no upper bound.
+type keyRange struct {
+	start string
+	end   string
+}
+
+func (s *shardedStore) List(ctx context.Context, prefix string, opts ListOptions, fn func(*ListPage) bool) error {
+	if s.shards < 2 || opts.ContinuationToken != "" || !s.applies(prefix) {
+		return s.ObjectStore.List(ctx, prefix, opts, fn)
+	}
+
+	first := &ListPage{}
+	more := true
+	err := s.ObjectStore.List(ctx, prefix, opts, func(page *ListPage) bool {
+		first = page
+		more = fn(page)
+
+		return false
+	})
+
+	if err != nil || !more || first.NextContinuationToken == "" {
+		return err
+	}
+
+	// the rest of the listing starts after the last key or common prefix of the first
+	// page. Keys under a common prefix come after it, so skip it if it shows up again.
+	last := lastListed(first)
+	ranges, err := s.sample(ctx, prefix, last, opts.Delimiter)
+	if err != nil {
+		return err
+	}
+
+	return s.listRanges(ctx, prefix, opts, last, ranges, fn)
+}
+
+// lastListed returns the last key or common prefix on page.
+func lastListed(page *ListPage) string {
+	last := ""
+	if n := len(page.Objects); n > 0 {
+		last = page.Objects[n-1].Key
+	}
+
+	if n := len(page.CommonPrefixes); n > 0 && page.CommonPrefixes[n-1] > last {
+		last = page.CommonPrefixes[n-1]
+	}
+
+	return last
+}
+
+// sample splits the part of the listing of prefix after last into ranges that have
+// keys, and spreads them over the shards.
+func (s *shardedStore) sample(ctx context.Context, prefix, last, delimiter string) ([]keyRange, error) {
+	starts := []string{last}
+	for _, b := range shardBoundaries {
+		if prefix+b > last {
+			starts = append(starts, prefix+b)
+		}
+	}
+
+	// the first key after each start, if it's under prefix
+	firsts := make([]string, len(starts))
+	errs := make([]error, len(starts))
+
+	sem := make(chan struct{}, s.shards)
+	wg := sync.WaitGroup{}
+	for i, start := range starts {
+		wg.Add(1)
+		sem <- struct{}{}
+
+		go func(i int, start string) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			errs[i] = s.ObjectStore.List(ctx, prefix, ListOptions{Delimiter: delimiter, StartAfter: start, MaxKeys: 1}, func(page *ListPage) bool {
+				firsts[i] = lastListed(page)
+				return false
+			})
+		}(i, start)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// a range has keys if the first key after its start isn't past its end
+	nonEmpty := []keyRange{}
+	for i, start := range starts {
+		end := ""
+		if i+1 < len(starts) {
+			end = starts[i+1]
+		}
+
+		if firsts[i] == "" || (end != "" && firsts[i] > end) {
+			continue
+		}
+
+		nonEmpty = append(nonEmpty, keyRange{start: start, end: end})
+	}
+
+	shards := s.shards
+	if shards > len(nonEmpty) {
+		shards = len(nonEmpty)
+	}
+
+	// merge neighboring ranges so there's one per shard
+	ranges := []keyRange{}
+	for i := 0; i < shards; i++ {
+		lo := i * len(nonEmpty) / shards
+		hi := (i+1)*len(nonEmpty)/shards - 1
+		ranges = append(ranges, keyRange{start: nonEmpty[lo].start, end: nonEmpty[hi].end})
+	}
+
+	return ranges, nil
+}
+
+// pageQueue holds the pages of a range that have been listed but not handed over yet.
+type pageQueue struct {
+	mu    sync.Mutex
+	cond  *sync.Cond
+	pages []*ListPage
+	done  bool
+	err   error
+}
+
+func newPageQueue() *pageQueue {
+	q := &pageQueue{}
+	q.cond = sync.NewCond(&q.mu)
+
+	return q
+}
+
+func (q *pageQueue) push(page *ListPage) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.pages = append(q.pages, page)
+	q.cond.Signal()
+}
+
+func (q *pageQueue) finish(err error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.done = true
+	q.err = err
+	q.cond.Signal()
+}
+
+// pop waits for the next page, returning nil once the range has been listed.
+func (q *pageQueue) pop() (*ListPage, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for len(q.pages) == 0 && !q.done {
+		q.cond.Wait()
+	}
+
+	if len(q.pages) == 0 {
+		return nil, q.err
+	}
+
+	page := q.pages[0]
+	q.pages = q.pages[1:]
+
+	return page, nil
+}
+
+// listRanges lists ranges concurrently, passing their pages to fn in order.
+func (s *shardedStore) listRanges(ctx context.Context, prefix string, opts ListOptions, last string, ranges []keyRange, fn func(*ListPage) bool) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	queues := make([]*pageQueue, len(ranges))
+	for i, r := range ranges {
+		queues[i] = newPageQueue()
+
+		go func(q *pageQueue, r keyRange) {
+			rangeOpts := opts
+			rangeOpts.StartAfter = r.start
+
+			err := s.ObjectStore.List(ctx, prefix, rangeOpts, func(page *ListPage) bool {
+				page, done := clipPage(page, r, last)
+				q.push(page)
+
+				return !done && ctx.Err() == nil
+			})
+
+			q.finish(err)
+		}(queues[i], r)
+	}
+
+	for _, q := range queues {
+		for {
+			page, err := q.pop()
+			if err != nil {
+				return err
+			}
+
+			if page == nil {
+				break
+			}
+
+			if len(page.Objects) == 0 && len(page.CommonPrefixes) == 0 {
+				continue
+			}
+
+			if !fn(page) {
+				return nil
+			}
+		}
+	}
+
+	return nil
+}
+
+// clipPage returns the part of page that is in r, and whether the range is done, with
+// common prefixes equal to last dropped because they were already listed.
+func clipPage(page *ListPage, r keyRange, last string) (*ListPage, bool) {
+	out := &ListPage{}
+	done := page.NextContinuationToken == ""
+
+	for _, obj := range page.Objects {
+		if r.end != "" && obj.Key > r.end {
+			done = true
+			break
+		}
+
+		out.Objects = append(out.Objects, obj)
+	}
+
+	for _, cp := range page.CommonPrefixes {
+		if r.end != "" && cp > r.end {
+			done = true
+			break
+		}
+
+		if cp != last {
+			out.CommonPrefixes = append(out.CommonPrefixes, cp)
+		}
+	}
+
+	return out, done
+}
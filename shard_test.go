@@ -0,0 +1,99 @@
+package s3fs_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"testing"
+
+	"github.com/packrat386/s3fs"
+	"github.com/packrat386/s3fs/s3fstest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithShardedListing(t *testing.T) {
+	store := &tokenStore{MemStore: s3fstest.NewMemStore()}
+	store.PageSize = 10
+	for i := 0; i < 300; i++ {
+		sum := sha256.Sum256([]byte(fmt.Sprint(i)))
+		name := hex.EncodeToString(sum[:4])
+		if i%10 == 0 {
+			store.WriteFile("hot/"+name+"/nested.json", `{}`)
+		} else {
+			store.WriteFile("hot/"+name+".json", `{}`)
+		}
+	}
+	store.WriteFile("hot/~last.json", `{}`)
+	store.WriteFile("cold/a.json", `{}`)
+
+	plain := s3fs.NewFS(store.MemStore)
+	sharded := s3fs.NewFS(store, s3fs.WithShardedListing(4, "hot"))
+
+	want, err := plain.GenerateManifest(context.Background(), "hot")
+	require.Nil(t, err)
+
+	store.reset()
+	got, err := sharded.GenerateManifest(context.Background(), "hot")
+	require.Nil(t, err)
+	require.Equal(t, want.Entries, got.Entries)
+
+	// one page, one probe for each possible first character, and the shards
+	require.Greater(t, len(store.tokens), 1+len("0123456789abcdef"))
+
+	wantEntries, err := fs.ReadDir(plain, "hot")
+	require.Nil(t, err)
+
+	gotEntries, err := fs.ReadDir(sharded, "hot")
+	require.Nil(t, err)
+	require.Equal(t, entryNames(wantEntries), entryNames(gotEntries))
+	require.Len(t, gotEntries, 301)
+
+	if err := walkEqual(plain, sharded, "hot"); err != nil {
+		t.Fatal(err)
+	}
+
+	// small and unlisted prefixes are listed as usual
+	store.reset()
+	_, err = fs.ReadDir(sharded, "cold")
+	require.Nil(t, err)
+	require.Len(t, store.tokens, 2)
+
+	// stopping after the first page doesn't shard at all: opening only looks the
+	// name up and lists the first page
+	store.reset()
+	f, err := s3fs.NewFS(store, s3fs.WithShardedListing(4), s3fs.WithLazyListing()).Open("hot")
+	require.Nil(t, err)
+	require.Nil(t, f.Close())
+	require.Len(t, store.tokens, 2)
+}
+
+// walkEqual checks that walking root in a and b finds the same paths.
+func walkEqual(a, b fs.FS, root string) error {
+	walk := func(fsys fs.FS) ([]string, error) {
+		paths := []string{}
+		err := fs.WalkDir(fsys, root, func(p string, d fs.DirEntry, err error) error {
+			paths = append(paths, p)
+			return err
+		})
+
+		return paths, err
+	}
+
+	want, err := walk(a)
+	if err != nil {
+		return err
+	}
+
+	got, err := walk(b)
+	if err != nil {
+		return err
+	}
+
+	if fmt.Sprint(want) != fmt.Sprint(got) {
+		return fmt.Errorf("walked %v, want %v", got, want)
+	}
+
+	return nil
+}
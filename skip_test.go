@@ -0,0 +1,53 @@
+package s3fs_test
+
+import (
+	"context"
+	"io"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/packrat386/s3fs"
+	"github.com/packrat386/s3fs/s3fstest"
+	"github.com/stretchr/testify/require"
+)
+
+// putCountingStore counts the Puts made to it.
+type putCountingStore struct {
+	*s3fstest.MemStore
+	puts *int32
+}
+
+func (s putCountingStore) Put(ctx context.Context, key string, body io.Reader, opts s3fs.PutOptions) (s3fs.ObjectInfo, error) {
+	atomic.AddInt32(s.puts, 1)
+
+	return s.MemStore.Put(ctx, key, body, opts)
+}
+
+func TestWithSkipUnchanged(t *testing.T) {
+	store := putCountingStore{s3fstest.NewMemStore(), new(int32)}
+	myFS := s3fs.NewFS(store, s3fs.WithSkipUnchanged())
+
+	require.Nil(t, myFS.WriteFile("file.txt", []byte("hello")))
+	require.Nil(t, myFS.WriteFile("file.txt", []byte("hello")))
+	require.Equal(t, int32(1), atomic.LoadInt32(store.puts))
+
+	require.Nil(t, myFS.WriteFile("file.txt", []byte("world")))
+	require.Equal(t, int32(2), atomic.LoadInt32(store.puts))
+
+	require.Nil(t, myFS.UploadFrom(context.Background(), "file.txt", strings.NewReader("world"), 5))
+	require.Equal(t, int32(2), atomic.LoadInt32(store.puts))
+
+	_, data := rawObject(t, store, "file.txt")
+	require.Equal(t, "world", string(data))
+}
+
+func TestWithSkipUnchanged_ContentHashes(t *testing.T) {
+	store := putCountingStore{s3fstest.NewMemStore(), new(int32)}
+	myFS := s3fs.NewFS(store, s3fs.WithSkipUnchanged(), s3fs.WithContentHashes(), s3fs.WithWriteCompression(s3fs.Gzip))
+
+	content := []byte(strings.Repeat("same ", 100))
+	require.Nil(t, myFS.WriteFile("file.txt", content))
+	require.Nil(t, myFS.WriteFile("file.txt", content))
+	require.Equal(t, int32(1), atomic.LoadInt32(store.puts))
+}
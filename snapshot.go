@@ -0,0 +1,106 @@
+package s3fs
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+	"sync"
+	"testing/fstest"
+)
+
+// SnapshotToMapFS downloads every file under the directory prefix ("." for the whole
+// bucket) into an fstest.MapFS, so tests and services that can't afford S3 latency at
+// startup can take one copy and then run without making any requests. Paths in the
+// snapshot are relative to prefix, and files keep their modification times. Files are
+// downloaded in parallel (see WithConcurrency).
+//
+// The whole snapshot is held in memory, so it fails without downloading anything if
+// the files add up to more than maxBytes, and stops if they turn out to be bigger than
+// listed. A maxBytes of zero or less means no limit.
+func (s *S3FS) SnapshotToMapFS(ctx context.Context, prefix string, maxBytes int64) (fstest.MapFS, error) {
+	names := []string{}
+	var total int64
+
+	err := fs.WalkDir(s, prefix, func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if d.IsDir() {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		total += info.Size()
+		if maxBytes > 0 && total > maxBytes {
+			return fmt.Errorf("snapshot of %s is bigger than %d bytes", prefix, maxBytes)
+		}
+
+		names = append(names, name)
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("could not snapshot %s: %w", prefix, err)
+	}
+
+	snapshot := fstest.MapFS{}
+	var read int64
+	mu := sync.Mutex{}
+
+	err = s.DownloadMany(ctx, names, func(name string, f fs.File) error {
+		info, err := f.Stat()
+		if err != nil {
+			return err
+		}
+
+		var r io.Reader = f
+		if maxBytes > 0 {
+			r = io.LimitReader(f, maxBytes+1)
+		}
+
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return err
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		read += int64(len(data))
+		if maxBytes > 0 && read > maxBytes {
+			return fmt.Errorf("snapshot of %s is bigger than %d bytes", prefix, maxBytes)
+		}
+
+		rel := name
+		if prefix != "." {
+			rel = strings.TrimPrefix(name, path.Clean(prefix)+"/")
+		}
+
+		snapshot[rel] = &fstest.MapFile{
+			Data:    data,
+			Mode:    info.Mode(),
+			ModTime: info.ModTime(),
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("could not snapshot %s: %w", prefix, err)
+	}
+
+	return snapshot, nil
+}
@@ -0,0 +1,39 @@
+package s3fs_test
+
+import (
+	"context"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+
+	"github.com/packrat386/s3fs"
+	"github.com/packrat386/s3fs/s3fstest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnapshotToMapFS(t *testing.T) {
+	store := s3fstest.NewMemStore()
+	store.WriteFile("site/index.html", "<h1>hi</h1>")
+	store.WriteFile("site/css/main.css", "body {}")
+	store.WriteFile("other.txt", "not included")
+
+	myFS := s3fs.NewFS(store)
+
+	snapshot, err := myFS.SnapshotToMapFS(context.Background(), "site", 0)
+	require.Nil(t, err)
+	require.Nil(t, fstest.TestFS(snapshot, "index.html", "css/main.css"))
+
+	data, err := fs.ReadFile(snapshot, "css/main.css")
+	require.Nil(t, err)
+	require.Equal(t, "body {}", string(data))
+
+	_, err = snapshot.Open("other.txt")
+	require.Error(t, err)
+
+	whole, err := myFS.SnapshotToMapFS(context.Background(), ".", 0)
+	require.Nil(t, err)
+	require.Len(t, whole, 3)
+
+	_, err = myFS.SnapshotToMapFS(context.Background(), "site", 10)
+	require.Error(t, err)
+}
@@ -0,0 +1,169 @@
+package s3fs
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"time"
+)
+
+// WithListingSpill keeps memory use of huge directories bounded: once a directory
+// being opened has more than threshold entries, they are written to a temporary file
+// in dir (the system temp dir if empty) instead of being held in memory, and ReadDir
+// streams them back from there. The file is removed when the directory is closed.
+// Reading such a directory with ReadDir(-1), or fs.ReadDir, still loads all of it.
+func WithListingSpill(dir string, threshold int) Option {
+	return func(s *S3FS) {
+		s.spillDir = dir
+		s.spillThreshold = threshold
+	}
+}
+
+// spilledEntry is how a directory entry is stored in a spill file.
+type spilledEntry struct {
+	Name    string      `json:"name"`
+	Dir     bool        `json:"dir,omitempty"`
+	Size    int64       `json:"size,omitempty"`
+	ModTime time.Time   `json:"mod_time"`
+	Object  *ObjectInfo `json:"object,omitempty"`
+	Key     string      `json:"key,omitempty"`
+}
+
+// dirSpill is a temporary file of directory entries, one JSON object per line.
+type dirSpill struct {
+	f *os.File
+
+	// set while writing
+	w   *bufio.Writer
+	enc *json.Encoder
+
+	// set once reading
+	dec *json.Decoder
+
+	// loc is where the entries are, which is the same for all of them
+	loc *location
+
+	// conflicts, if set, drops entries hidden by the ConflictPolicy as they are read
+	conflicts *dirConflicts
+}
+
+func newDirSpill(dir string) (*dirSpill, error) {
+	f, err := os.CreateTemp(dir, "s3fs-listing-*")
+	if err != nil {
+		return nil, fmt.Errorf("could not create listing spill file: %w", err)
+	}
+
+	w := bufio.NewWriter(f)
+
+	return &dirSpill{
+		f:   f,
+		w:   w,
+		enc: json.NewEncoder(w),
+	}, nil
+}
+
+// spillEntries starts a spill file with entries.
+func spillEntries(dir string, entries []fs.DirEntry) (*dirSpill, error) {
+	d, err := newDirSpill(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, e := range entries {
+		if err := d.add(e.(*s3FileInfo)); err != nil {
+			d.close()
+			return nil, err
+		}
+	}
+
+	return d, nil
+}
+
+func (d *dirSpill) add(fi *s3FileInfo) error {
+	d.loc = fi.loc
+
+	if err := d.enc.Encode(spilledEntry{
+		Name:    fi.name,
+		Dir:     fi.IsDir(),
+		Size:    fi.size,
+		ModTime: fi.modTime,
+		Object:  fi.object,
+		Key:     fi.key,
+	}); err != nil {
+		return fmt.Errorf("could not write listing spill file: %w", err)
+	}
+
+	return nil
+}
+
+// rewind finishes writing, if we were, and starts reading from the first entry.
+func (d *dirSpill) rewind() error {
+	if d.w != nil {
+		if err := d.w.Flush(); err != nil {
+			return fmt.Errorf("could not write listing spill file: %w", err)
+		}
+
+		d.w, d.enc = nil, nil
+	}
+
+	if _, err := d.f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	d.dec = json.NewDecoder(bufio.NewReader(d.f))
+
+	return nil
+}
+
+// read reads the next n entries, or all of the rest if n <= 0.
+func (d *dirSpill) read(n int) ([]fs.DirEntry, error) {
+	out := []fs.DirEntry{}
+
+	for n <= 0 || len(out) < n {
+		entry := spilledEntry{}
+		err := d.dec.Decode(&entry)
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return out, fmt.Errorf("could not read listing spill file: %w", err)
+		}
+
+		fi := &s3FileInfo{
+			name:    entry.Name,
+			mode:    fs.FileMode(0400),
+			size:    entry.Size,
+			modTime: entry.ModTime,
+			object:  entry.Object,
+			key:     entry.Key,
+			loc:     d.loc,
+		}
+
+		if entry.Dir {
+			fi.mode |= fs.ModeDir
+		}
+
+		if d.conflicts.drops(fi) {
+			continue
+		}
+
+		out = append(out, fi)
+	}
+
+	if n > 0 && len(out) == 0 {
+		return nil, io.EOF
+	}
+
+	return out, nil
+}
+
+func (d *dirSpill) close() error {
+	err := d.f.Close()
+	os.Remove(d.f.Name())
+
+	return err
+}
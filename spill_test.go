@@ -0,0 +1,75 @@
+package s3fs_test
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"testing"
+	"testing/fstest"
+
+	"github.com/packrat386/s3fs"
+	"github.com/packrat386/s3fs/s3fstest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithListingSpill(t *testing.T) {
+	store := s3fstest.NewMemStore()
+	store.PageSize = 4
+	for i := 0; i < 10; i++ {
+		store.WriteFile(fmt.Sprintf("big/%02d.json", i), `{}`)
+	}
+	store.WriteFile("big/sub/deep.json", `{}`)
+
+	spillDir := t.TempDir()
+	myFS := s3fs.NewFS(store, s3fs.WithListingSpill(spillDir, 3))
+
+	if err := fstest.TestFS(myFS, "big/00.json", "big/sub/deep.json"); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := myFS.Open("big")
+	require.Nil(t, err)
+
+	spilled, err := os.ReadDir(spillDir)
+	require.Nil(t, err)
+	require.Equal(t, 1, len(spilled))
+
+	dir := f.(fs.ReadDirFile)
+	names := []string{}
+	for {
+		entries, err := dir.ReadDir(4)
+		if err == io.EOF {
+			break
+		}
+
+		require.Nil(t, err)
+		require.LessOrEqual(t, len(entries), 4)
+
+		for _, e := range entries {
+			names = append(names, e.Name())
+			if e.Name() == "sub" {
+				require.True(t, e.IsDir())
+			} else {
+				info, err := e.Info()
+				require.Nil(t, err)
+				require.Equal(t, int64(2), info.Size())
+				require.Equal(t, "big/"+e.Name(), info.Sys().(s3fs.ObjectInfo).Key)
+			}
+		}
+	}
+	require.Equal(t, 11, len(names))
+
+	_, err = f.(io.Seeker).Seek(0, io.SeekStart)
+	require.Nil(t, err)
+
+	entries, err := dir.ReadDir(-1)
+	require.Nil(t, err)
+	require.Equal(t, 11, len(entries))
+
+	require.Nil(t, f.Close())
+
+	spilled, err = os.ReadDir(spillDir)
+	require.Nil(t, err)
+	require.Equal(t, 0, len(spilled))
+}
@@ -0,0 +1,239 @@
+// Package sqlitevfs reads SQLite databases stored in an s3fs.S3FS page by page, so
+// they can be queried without downloading the whole file.
+//
+// This package is experimental. It provides the file half of a read-only SQLite VFS:
+// File maps SQLite's page reads to ranged GETs through a page cache, and has the
+// methods VFS bindings such as github.com/psanford/sqlite3vfs expect of a file.
+// SQLite itself isn't a dependency of s3fs, so registering the VFS with a driver is
+// left to the program that has one; its Open should call Open here and return the
+// File.
+package sqlitevfs
+
+import (
+	"bytes"
+	"container/list"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/packrat386/s3fs"
+)
+
+// ErrReadOnly is returned by the methods of File that would modify the database.
+var ErrReadOnly = errors.New("database is read only")
+
+// DefaultCachePages is the number of pages a File caches unless told otherwise.
+const DefaultCachePages = 1024
+
+// header is the magic string every SQLite database starts with.
+var header = []byte("SQLite format 3\x00")
+
+// iocapImmutable is SQLITE_IOCAP_IMMUTABLE, which tells SQLite the file can't change
+// while it's open, so it doesn't bother with locks or journals.
+const iocapImmutable = 0x00002000
+
+// File is a read-only SQLite database file in an FS. It is safe for concurrent use.
+type File struct {
+	r        io.ReaderAt
+	closer   io.Closer
+	size     int64
+	pageSize int64
+
+	mu        sync.Mutex
+	maxPages  int
+	pages     map[int64]*list.Element
+	lru       *list.List // of *page, most recently used first
+	pageReads int64
+}
+
+type page struct {
+	number int64
+	data   []byte
+}
+
+// Open opens the database at name in fsys, which must have been created with
+// s3fs.WithColumnarAccess so that its files support ranged reads. Up to cachePages
+// pages are kept in memory; zero means DefaultCachePages.
+func Open(fsys *s3fs.S3FS, name string, cachePages int) (*File, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	r, ok := f.(io.ReaderAt)
+	if !ok {
+		f.Close()
+		return nil, fmt.Errorf("could not open %s: file doesn't support ranged reads, use s3fs.WithColumnarAccess", name)
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	hdr := make([]byte, 100)
+	if _, err := r.ReadAt(hdr, 0); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("could not read header of %s: %w", name, err)
+	}
+
+	if !bytes.HasPrefix(hdr, header) {
+		f.Close()
+		return nil, fmt.Errorf("%s is not a SQLite database", name)
+	}
+
+	// stored big endian at offset 16, with 1 meaning 65536
+	pageSize := int64(binary.BigEndian.Uint16(hdr[16:18]))
+	if pageSize == 1 {
+		pageSize = 65536
+	}
+
+	if pageSize < 512 || pageSize&(pageSize-1) != 0 {
+		f.Close()
+		return nil, fmt.Errorf("%s has invalid page size %d", name, pageSize)
+	}
+
+	if cachePages <= 0 {
+		cachePages = DefaultCachePages
+	}
+
+	return &File{
+		r:        r,
+		closer:   f,
+		size:     info.Size(),
+		pageSize: pageSize,
+		maxPages: cachePages,
+		pages:    map[int64]*list.Element{},
+		lru:      list.New(),
+	}, nil
+}
+
+// PageSize returns the page size of the database.
+func (f *File) PageSize() int64 {
+	return f.pageSize
+}
+
+// ReadAt reads len(buf) bytes starting at off, a page at a time through the cache.
+func (f *File) ReadAt(buf []byte, off int64) (int, error) {
+	n := 0
+	for n < len(buf) {
+		pos := off + int64(n)
+		if pos >= f.size {
+			return n, io.EOF
+		}
+
+		data, err := f.page(pos / f.pageSize)
+		if err != nil {
+			return n, err
+		}
+
+		n += copy(buf[n:], data[pos%f.pageSize:])
+	}
+
+	return n, nil
+}
+
+// page returns page number n (counting from zero), fetching it if it isn't cached.
+func (f *File) page(n int64) ([]byte, error) {
+	f.mu.Lock()
+	if e, ok := f.pages[n]; ok {
+		f.lru.MoveToFront(e)
+		f.mu.Unlock()
+
+		return e.Value.(*page).data, nil
+	}
+	f.mu.Unlock()
+
+	length := f.pageSize
+	if rest := f.size - n*f.pageSize; rest < length {
+		length = rest
+	}
+
+	data := make([]byte, length)
+	if _, err := f.r.ReadAt(data, n*f.pageSize); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("could not read page %d: %w", n+1, err)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.pageReads++
+	if _, ok := f.pages[n]; !ok {
+		f.pages[n] = f.lru.PushFront(&page{number: n, data: data})
+	}
+
+	for f.lru.Len() > f.maxPages {
+		oldest := f.lru.Back()
+		f.lru.Remove(oldest)
+		delete(f.pages, oldest.Value.(*page).number)
+	}
+
+	return data, nil
+}
+
+// PageReads returns how many pages have been fetched from the store, which is how
+// many cache misses there have been.
+func (f *File) PageReads() int64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.pageReads
+}
+
+// WriteAt always fails with ErrReadOnly.
+func (f *File) WriteAt(buf []byte, off int64) (int, error) {
+	return 0, ErrReadOnly
+}
+
+// Truncate always fails with ErrReadOnly.
+func (f *File) Truncate(size int64) error {
+	return ErrReadOnly
+}
+
+// Sync does nothing, since nothing is ever written.
+func (f *File) Sync(flags int) error {
+	return nil
+}
+
+// FileSize returns the size of the database file.
+func (f *File) FileSize() (int64, error) {
+	return f.size, nil
+}
+
+// Lock does nothing. The file is immutable, so there's nothing to coordinate.
+func (f *File) Lock(level int) error {
+	return nil
+}
+
+// Unlock does nothing, see Lock.
+func (f *File) Unlock(level int) error {
+	return nil
+}
+
+// CheckReservedLock always reports that nobody holds a reserved lock.
+func (f *File) CheckReservedLock() (bool, error) {
+	return false, nil
+}
+
+// SectorSize returns the page size, the unit the file is read in.
+func (f *File) SectorSize() int64 {
+	return f.pageSize
+}
+
+// DeviceCharacteristics tells SQLite the file is immutable.
+func (f *File) DeviceCharacteristics() int {
+	return iocapImmutable
+}
+
+// Close releases the cached pages and closes the underlying file.
+func (f *File) Close() error {
+	f.mu.Lock()
+	f.pages = map[int64]*list.Element{}
+	f.lru.Init()
+	f.mu.Unlock()
+
+	return f.closer.Close()
+}
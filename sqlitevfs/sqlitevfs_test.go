@@ -0,0 +1,83 @@
+package sqlitevfs
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	"github.com/packrat386/s3fs"
+	"github.com/packrat386/s3fs/s3fstest"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeDatabase returns a file with a SQLite header and pages pages of pageSize bytes,
+// each filled with its page number.
+func fakeDatabase(pageSize, pages int) []byte {
+	db := make([]byte, pageSize*pages)
+	for i := 0; i < pages; i++ {
+		for j := 0; j < pageSize; j++ {
+			db[i*pageSize+j] = byte(i + 1)
+		}
+	}
+
+	copy(db, header)
+	binary.BigEndian.PutUint16(db[16:18], uint16(pageSize))
+
+	return db
+}
+
+func TestOpen(t *testing.T) {
+	store := s3fstest.NewMemStore()
+	store.WriteFile("app.db", string(fakeDatabase(4096, 8)))
+
+	fsys := s3fs.NewFS(store, s3fs.WithColumnarAccess())
+
+	f, err := Open(fsys, "app.db", 2)
+	require.Nil(t, err)
+	defer f.Close()
+
+	require.Equal(t, int64(4096), f.PageSize())
+
+	size, err := f.FileSize()
+	require.Nil(t, err)
+	require.Equal(t, int64(8*4096), size)
+
+	buf := make([]byte, 4096)
+	n, err := f.ReadAt(buf, 3*4096)
+	require.Nil(t, err)
+	require.Equal(t, 4096, n)
+	require.Equal(t, bytes.Repeat([]byte{4}, 4096), buf)
+	require.Equal(t, int64(1), f.PageReads())
+
+	_, err = f.ReadAt(buf[:10], 3*4096+100)
+	require.Nil(t, err)
+	require.Equal(t, int64(1), f.PageReads())
+
+	// spans pages 5 and 6
+	_, err = f.ReadAt(buf, 4*4096+2048)
+	require.Nil(t, err)
+	require.Equal(t, byte(5), buf[0])
+	require.Equal(t, byte(6), buf[4095])
+	require.Equal(t, int64(3), f.PageReads())
+
+	// page 4 was evicted
+	_, err = f.ReadAt(buf, 3*4096)
+	require.Nil(t, err)
+	require.Equal(t, int64(4), f.PageReads())
+
+	_, err = f.WriteAt(buf, 0)
+	require.True(t, errors.Is(err, ErrReadOnly))
+}
+
+func TestOpen_Invalid(t *testing.T) {
+	store := s3fstest.NewMemStore()
+	store.WriteFile("app.db", string(fakeDatabase(4096, 1)))
+	store.WriteFile("notes.txt", string(bytes.Repeat([]byte("x"), 200)))
+
+	_, err := Open(s3fs.NewFS(store), "app.db", 0)
+	require.NotNil(t, err)
+
+	_, err = Open(s3fs.NewFS(store, s3fs.WithColumnarAccess()), "notes.txt", 0)
+	require.NotNil(t, err)
+}
@@ -0,0 +1,112 @@
+package s3fs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+)
+
+// Stat returns the FileInfo of the file or directory at name, making FS an
+// fs.StatFS. Opening a directory lists all of it, and finding out whether a name is a
+// file or a directory lists every key it's a prefix of, so where fs.Stat would have
+// to do that, Stat makes two requests whatever is there: a HEAD for the file and a
+// listing of at most one key for the directory.
+func (s *S3FS) Stat(name string) (fs.FileInfo, error) {
+	if s.bucketErr != nil {
+		return nil, s.bucketErr
+	}
+
+	if err := s.checkOpen(); err != nil {
+		return nil, err
+	}
+
+	key, err := s.key("stat", name)
+	if err != nil {
+		return nil, err
+	}
+
+	if key == "" {
+		return &s3FileInfo{name: ".", mode: fs.FileMode(0400) | fs.ModeDir, loc: s.location}, nil
+	}
+
+	// neither of these make requests for files
+	if s.offline || s.writeBack != nil {
+		return s.statOpen(name)
+	}
+
+	s.auditRead(OpStat, key)
+
+	ctx := context.Background()
+
+	head, err := s.store.Head(ctx, key)
+	isFile := err == nil
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("could not stat %s: %w", key, err)
+	}
+
+	isDir, err := s.dirExists(ctx, key)
+	if err != nil {
+		return nil, fmt.Errorf("could not list s3 objects: %w", err)
+	}
+
+	if isFile && isDir {
+		preferFile, err := s.resolveConflict(key)
+		if err != nil {
+			return nil, err
+		}
+
+		isFile, isDir = preferFile, !preferFile
+	}
+
+	switch {
+	case isDir:
+		return &s3FileInfo{name: path.Base(key), mode: fs.FileMode(0400) | fs.ModeDir, key: key + "/", loc: s.location}, nil
+	case !isFile:
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+	}
+
+	// the size of encoded and chunked files is only known once they're opened
+	if s.encryption != nil || s.compression != nil || s.chunkSize > 0 {
+		return s.statOpen(name)
+	}
+
+	return &s3FileInfo{
+		name:    path.Base(key),
+		mode:    fs.FileMode(0400),
+		size:    head.Size,
+		modTime: head.LastModified,
+		object:  &head,
+		key:     key,
+		loc:     s.location,
+	}, nil
+}
+
+// statOpen returns the FileInfo of name by opening it.
+func (s *S3FS) statOpen(name string) (fs.FileInfo, error) {
+	f, err := s.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return f.Stat()
+}
+
+// dirExists reports whether anything is in the directory key, listing at most one
+// key of it.
+func (s *S3FS) dirExists(ctx context.Context, key string) (bool, error) {
+	prefix := key
+	if prefix != "" {
+		prefix += "/"
+	}
+
+	found := false
+	err := s.store.List(ctx, prefix, ListOptions{Delimiter: "/", MaxKeys: 1}, func(page *ListPage) bool {
+		found = len(page.Objects) > 0 || len(page.CommonPrefixes) > 0
+		return false
+	})
+
+	return found, err
+}
@@ -0,0 +1,94 @@
+package s3fs_test
+
+import (
+	"fmt"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+
+	"github.com/packrat386/s3fs"
+	"github.com/packrat386/s3fs/s3fstest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStat(t *testing.T) {
+	store := s3fstest.NewMemStore()
+	store.PageSize = 2
+	for i := 0; i < 20; i++ {
+		store.WriteFile(fmt.Sprintf("big/%02d.txt", i), "x")
+		store.WriteFile(fmt.Sprintf("big-%02d.txt", i), "x")
+	}
+	store.WriteFile("a.txt", "aaa")
+
+	report := &s3fs.CostReport{}
+	myFS := s3fs.NewFS(store, s3fs.WithDryRun(report))
+
+	info, err := fs.Stat(myFS, "big")
+	require.Nil(t, err)
+	require.True(t, info.IsDir())
+	require.Equal(t, "big", info.Name())
+
+	// a HEAD and a single page, rather than all of big/ and its siblings
+	require.Equal(t, int64(1), report.Heads)
+	require.Equal(t, int64(1), report.Lists)
+
+	info, err = fs.Stat(myFS, "a.txt")
+	require.Nil(t, err)
+	require.False(t, info.IsDir())
+	require.Equal(t, int64(3), info.Size())
+	require.NotEmpty(t, info.Sys().(s3fs.ObjectInfo).ETag)
+	require.Equal(t, int64(0), report.Gets)
+
+	info, err = fs.Stat(myFS, ".")
+	require.Nil(t, err)
+	require.True(t, info.IsDir())
+
+	_, err = fs.Stat(myFS, "missing")
+	require.ErrorIs(t, err, fs.ErrNotExist)
+
+	_, err = fs.Stat(myFS, "../a.txt")
+	require.ErrorIs(t, err, fs.ErrInvalid)
+
+	store.WriteFile("both", "file")
+	store.WriteFile("both/child", "file")
+	_, err = fs.Stat(myFS, "both")
+	require.NotNil(t, err)
+}
+
+func TestSub(t *testing.T) {
+	store := s3fstest.NewMemStore()
+	store.PageSize = 2
+	for i := 0; i < 20; i++ {
+		store.WriteFile(fmt.Sprintf("site/assets/%02d.css", i), "x")
+	}
+	store.WriteFile("site/index.html", "home")
+
+	report := &s3fs.CostReport{}
+	myFS := s3fs.NewFS(store, s3fs.WithDryRun(report))
+
+	site, err := fs.Sub(myFS, "site")
+	require.Nil(t, err)
+	require.Equal(t, int64(1), report.Lists)
+
+	if err := fstest.TestFS(site, "index.html", "assets/00.css", "assets/19.css"); err != nil {
+		t.Fatal(err)
+	}
+
+	assets, err := fs.Sub(site, "assets")
+	require.Nil(t, err)
+
+	info, err := fs.Stat(assets, "07.css")
+	require.Nil(t, err)
+	require.Equal(t, int64(1), info.Size())
+
+	_, err = fs.Sub(myFS, "missing")
+	require.ErrorIs(t, err, fs.ErrNotExist)
+
+	_, err = fs.Stat(site, "missing.html")
+	pathErr := &fs.PathError{}
+	require.ErrorAs(t, err, &pathErr)
+	require.Equal(t, "missing.html", pathErr.Path)
+
+	_, err = site.Open("../secret")
+	require.ErrorIs(t, err, fs.ErrInvalid)
+}
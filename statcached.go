@@ -0,0 +1,36 @@
+package s3fs
+
+// CachedStatFile is implemented by the files and directories the FS opens. Type
+// assert an fs.File to it to find out whether Stat is free to call, e.g. in a wrapper
+// that stats every file it serves.
+type CachedStatFile interface {
+	// StatCached reports whether Stat returns the FileInfo captured when the file was
+	// opened, without making a request. It is true for everything the FS opens: the
+	// FileInfo of a file is from the response that opened it, and its Sys is the
+	// ObjectInfo with the ETag, content type and metadata from that response.
+	StatCached() bool
+}
+
+func (f *s3File) StatCached() bool {
+	return true
+}
+
+func (d *s3Directory) StatCached() bool {
+	return true
+}
+
+func (d *lazyDir) StatCached() bool {
+	return true
+}
+
+func (f *cachedFile) StatCached() bool {
+	return true
+}
+
+func (f *columnarFile) StatCached() bool {
+	return true
+}
+
+func (f *metadataFile) StatCached() bool {
+	return true
+}
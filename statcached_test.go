@@ -0,0 +1,98 @@
+package s3fs_test
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/packrat386/s3fs"
+	"github.com/packrat386/s3fs/s3fstest"
+	"github.com/stretchr/testify/require"
+)
+
+// requestCountingStore counts the requests made to an ObjectStore.
+type requestCountingStore struct {
+	s3fs.ObjectStore
+	requests int64
+}
+
+func (c *requestCountingStore) count() int64 {
+	return atomic.LoadInt64(&c.requests)
+}
+
+func (c *requestCountingStore) List(ctx context.Context, prefix string, opts s3fs.ListOptions, fn func(*s3fs.ListPage) bool) error {
+	atomic.AddInt64(&c.requests, 1)
+	return c.ObjectStore.List(ctx, prefix, opts, fn)
+}
+
+func (c *requestCountingStore) Head(ctx context.Context, key string) (s3fs.ObjectInfo, error) {
+	atomic.AddInt64(&c.requests, 1)
+	return c.ObjectStore.Head(ctx, key)
+}
+
+func (c *requestCountingStore) Get(ctx context.Context, key string, opts s3fs.GetOptions) (*s3fs.Object, error) {
+	atomic.AddInt64(&c.requests, 1)
+	return c.ObjectStore.Get(ctx, key, opts)
+}
+
+func TestStatCached(t *testing.T) {
+	for name, opts := range map[string][]s3fs.Option{
+		"default":   nil,
+		"cache":     {s3fs.WithCache(s3fs.NewMemoryCache(1<<20), 0)},
+		"lazy":      {s3fs.WithLazyListing()},
+		"columnar":  {s3fs.WithColumnarAccess()},
+		"streaming": {s3fs.WithStreamingConcurrency(2, 4)},
+		"chunking":  {s3fs.WithChunking(1 << 20)},
+	} {
+		t.Run(name, func(t *testing.T) {
+			mem := s3fstest.NewMemStore()
+			_, err := mem.Put(context.Background(), "dir/a.json", strings.NewReader(`{"data":"a"}`), s3fs.PutOptions{ContentType: "application/json"})
+			require.Nil(t, err)
+
+			store := &requestCountingStore{ObjectStore: mem}
+			myFS := s3fs.NewFS(store, opts...)
+
+			for _, name := range []string{"dir/a.json", "dir", "."} {
+				f, err := myFS.Open(name)
+				require.Nil(t, err, name)
+
+				cached, ok := f.(s3fs.CachedStatFile)
+				require.True(t, ok, name)
+				require.True(t, cached.StatCached(), name)
+
+				before := store.count()
+				for i := 0; i < 3; i++ {
+					info, err := f.Stat()
+					require.Nil(t, err, name)
+
+					if !info.IsDir() {
+						object := info.Sys().(s3fs.ObjectInfo)
+						require.NotEmpty(t, object.ETag)
+						require.Equal(t, "application/json", object.ContentType)
+						require.Equal(t, int64(12), info.Size())
+					}
+				}
+
+				require.Equal(t, before, store.count(), name)
+				require.Nil(t, f.Close())
+			}
+		})
+	}
+}
+
+func TestStatCached_MetadataOnly(t *testing.T) {
+	mem := s3fstest.NewMemStore()
+	mem.WriteFile("a.json", `{"data":"a"}`)
+
+	store := &requestCountingStore{ObjectStore: mem}
+
+	f, err := s3fs.NewFS(store).MetadataOnly().Open("a.json")
+	require.Nil(t, err)
+
+	before := store.count()
+	_, err = f.Stat()
+	require.Nil(t, err)
+	require.True(t, f.(s3fs.CachedStatFile).StatCached())
+	require.Equal(t, before, store.count())
+}
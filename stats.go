@@ -0,0 +1,66 @@
+package s3fs
+
+import (
+	"context"
+	"time"
+)
+
+// TransferStats is what reading an open file has cost so far.
+type TransferStats struct {
+	// BytesRead is how many bytes of the object have been received from the store.
+	// Files served from the cache didn't receive any.
+	BytesRead int64
+
+	// Requests is how many GET requests were made for the file.
+	Requests int
+
+	// Retries is how many times those requests were retried, if the store reports
+	// it. S3 does.
+	Retries int
+
+	// Latency is the total time spent waiting for the store to respond to those
+	// requests, not counting the time spent reading their bodies.
+	Latency time.Duration
+}
+
+// StatsFile is implemented by the files opened by the FS. Type assert an fs.File to
+// it to see its TransferStats, e.g. to log what the S3 reads of a slow endpoint cost.
+type StatsFile interface {
+	TransferStats() TransferStats
+}
+
+// getObject gets key from store, adding the request to stats.
+func getObject(ctx context.Context, store ObjectStore, key string, opts GetOptions, stats *TransferStats) (*Object, error) {
+	start := time.Now()
+	object, err := store.Get(ctx, key, opts)
+
+	stats.Requests++
+	stats.Latency += time.Since(start)
+	if object != nil {
+		stats.Retries += object.Retries
+	}
+
+	return object, err
+}
+
+func (f *s3File) TransferStats() TransferStats {
+	if body, ok := f.body.(*parallelBody); ok {
+		return body.transferStats()
+	}
+
+	stats := f.stats
+	stats.BytesRead = f.read
+
+	return stats
+}
+
+func (f *cachedFile) TransferStats() TransferStats {
+	return f.stats
+}
+
+func (f *columnarFile) TransferStats() TransferStats {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.stats
+}
@@ -0,0 +1,102 @@
+package s3fs_test
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/packrat386/s3fs"
+	"github.com/packrat386/s3fs/s3fstest"
+	"github.com/stretchr/testify/require"
+)
+
+// retryingStore reports that every Get needed two retries, and takes a while to
+// respond.
+type retryingStore struct {
+	*s3fstest.MemStore
+}
+
+func (s retryingStore) Get(ctx context.Context, key string, opts s3fs.GetOptions) (*s3fs.Object, error) {
+	time.Sleep(time.Millisecond)
+
+	object, err := s.MemStore.Get(ctx, key, opts)
+	if object != nil {
+		object.Retries = 2
+	}
+
+	return object, err
+}
+
+func TestTransferStats(t *testing.T) {
+	store := retryingStore{s3fstest.NewMemStore()}
+	store.WriteFile("a.txt", "hello world")
+
+	myFS := s3fs.NewFS(store)
+
+	f, err := myFS.Open("a.txt")
+	require.Nil(t, err)
+	defer f.Close()
+
+	buf := make([]byte, 5)
+	_, err = io.ReadFull(f, buf)
+	require.Nil(t, err)
+
+	stats := f.(s3fs.StatsFile).TransferStats()
+	require.Equal(t, int64(5), stats.BytesRead)
+	require.Equal(t, 1, stats.Requests)
+	require.Equal(t, 2, stats.Retries)
+	require.GreaterOrEqual(t, stats.Latency, time.Millisecond)
+
+	_, err = io.ReadAll(f)
+	require.Nil(t, err)
+	require.Equal(t, int64(11), f.(s3fs.StatsFile).TransferStats().BytesRead)
+}
+
+func TestTransferStats_Cached(t *testing.T) {
+	store := retryingStore{s3fstest.NewMemStore()}
+	store.WriteFile("a.txt", "hello world")
+
+	myFS := s3fs.NewFS(store, s3fs.WithCache(s3fs.NewMemoryCache(1<<20), time.Hour))
+
+	statsOf := func() s3fs.TransferStats {
+		f, err := myFS.Open("a.txt")
+		require.Nil(t, err)
+		defer f.Close()
+
+		_, err = io.ReadAll(f)
+		require.Nil(t, err)
+
+		return f.(s3fs.StatsFile).TransferStats()
+	}
+
+	stats := statsOf()
+	require.Equal(t, int64(11), stats.BytesRead)
+	require.Equal(t, 1, stats.Requests)
+
+	require.Equal(t, s3fs.TransferStats{}, statsOf())
+}
+
+func TestTransferStats_Columnar(t *testing.T) {
+	store := retryingStore{s3fstest.NewMemStore()}
+	store.WriteFile("a.parquet", string(make([]byte, 200<<10)))
+
+	myFS := s3fs.NewFS(store, s3fs.WithColumnarAccess())
+
+	f, err := myFS.Open("a.parquet")
+	require.Nil(t, err)
+	defer f.Close()
+
+	// the footer is fetched on open
+	stats := f.(s3fs.StatsFile).TransferStats()
+	require.Equal(t, int64(64<<10), stats.BytesRead)
+	require.Equal(t, 1, stats.Requests)
+
+	_, err = f.(io.ReaderAt).ReadAt(make([]byte, 10), 0)
+	require.Nil(t, err)
+
+	stats = f.(s3fs.StatsFile).TransferStats()
+	require.Equal(t, int64(128<<10), stats.BytesRead)
+	require.Equal(t, 2, stats.Requests)
+	require.Equal(t, 4, stats.Retries)
+}
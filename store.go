@@ -0,0 +1,178 @@
+package s3fs
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+)
+
+// ErrPreconditionFailed is returned when a conditional write is rejected because the
+// destination doesn't match what the caller expected.
+var ErrPreconditionFailed = errors.New("precondition failed")
+
+// ErrNotModified is returned when a conditional read is skipped because the object
+// hasn't changed.
+var ErrNotModified = errors.New("not modified")
+
+// ErrInvalidRange is returned when a ranged read asks for bytes the object doesn't
+// have, which for S3 includes any range of an empty object.
+var ErrInvalidRange = errors.New("invalid range")
+
+// ObjectStore is the set of operations the FS needs from an object storage backend.
+// The S3 implementation is used by NewS3FS; other backends can be plugged in with
+// NewFS.
+//
+// Keys are "/" delimited and never start with a "/". Head and Get must return an
+// error that wraps fs.ErrNotExist if the key doesn't exist. Writes that fail one of
+// their preconditions must return an error that wraps ErrPreconditionFailed.
+type ObjectStore interface {
+	// List calls fn with each page of objects and common prefixes whose keys start
+	// with prefix, in lexical order, until fn returns false or there are no more pages.
+	List(ctx context.Context, prefix string, opts ListOptions, fn func(*ListPage) bool) error
+
+	// Head returns the metadata of the object at key.
+	Head(ctx context.Context, key string) (ObjectInfo, error)
+
+	// Get returns the body and metadata of the object at key. The caller must close
+	// the body.
+	Get(ctx context.Context, key string, opts GetOptions) (*Object, error)
+
+	// Put writes body to key, replacing anything already there.
+	Put(ctx context.Context, key string, body io.Reader, opts PutOptions) (ObjectInfo, error)
+
+	// Delete removes the object at key. Deleting a key that doesn't exist is not an error.
+	Delete(ctx context.Context, key string) error
+
+	// Copy copies the object at src to dst without the data leaving the store.
+	Copy(ctx context.Context, src, dst string, opts CopyOptions) error
+}
+
+// ListOptions controls a List call.
+type ListOptions struct {
+	// Delimiter groups keys that contain it after the prefix into common prefixes.
+	// No grouping is done if it is empty.
+	Delimiter string
+
+	// MaxKeys, if set, is the most objects and common prefixes to return in each page.
+	MaxKeys int
+
+	// StartAfter, if set, skips keys up to and including it, starting the listing
+	// with the first key that sorts after it.
+	StartAfter string
+
+	// ContinuationToken, if set, resumes an earlier listing of the same prefix from
+	// the page after the one it was the NextContinuationToken of. It takes precedence
+	// over StartAfter.
+	ContinuationToken string
+}
+
+// ListPage is one page of List results.
+type ListPage struct {
+	Objects []ObjectInfo
+
+	// CommonPrefixes include the trailing delimiter.
+	CommonPrefixes []string
+
+	// NextContinuationToken is set if there are more pages, and can be passed as
+	// ListOptions.ContinuationToken to carry on listing from the next one later.
+	NextContinuationToken string
+}
+
+// GetOptions controls a Get call.
+type GetOptions struct {
+	// Offset is the first byte of the object to return.
+	Offset int64
+
+	// Length is the number of bytes to return starting at Offset. Zero means read to
+	// the end of the object.
+	Length int64
+
+	// IfNoneMatch, if set, makes Get fail with ErrNotModified if the object has this
+	// ETag.
+	IfNoneMatch string
+
+	// IfModifiedSince, if set, makes Get fail with ErrNotModified if the object hasn't
+	// been modified since.
+	IfModifiedSince time.Time
+}
+
+// PutOptions controls a Put call.
+type PutOptions struct {
+	ContentType     string
+	ContentEncoding string
+	Metadata        map[string]string
+
+	// WebsiteRedirectLocation, if set, makes the object redirect to this URL or path
+	// when it is requested through the bucket's static website endpoint.
+	WebsiteRedirectLocation string
+
+	// IfMatch, if set, only allows the write if key currently has this ETag.
+	IfMatch string
+
+	// IfNoneMatch, if set to "*", only allows the write if key doesn't exist.
+	IfNoneMatch string
+
+	// PartSize, if set, is the size of the parts to use if the store does a multipart
+	// upload.
+	PartSize int64
+
+	// PartUploaded, if set, is called as each part of the object is stored. Stores
+	// that don't upload in parts call it once with the whole object as part 1.
+	PartUploaded func(partNumber int, partBytes int64)
+
+	// VerifyContent, if set, makes the store send a checksum of the data with each
+	// request that carries some, so that data corrupted on the way is rejected
+	// rather than stored.
+	VerifyContent bool
+}
+
+// CopyOptions controls a Copy call.
+type CopyOptions struct {
+	// IfMatch, if set, only allows the copy if dst currently has this ETag.
+	IfMatch string
+
+	// IfNoneMatch, if set to "*", only allows the copy if dst doesn't exist.
+	IfNoneMatch string
+}
+
+// ObjectInfo is the metadata of a single object.
+type ObjectInfo struct {
+	Key          string
+	Size         int64
+	LastModified time.Time
+	ETag         string
+	ContentType  string
+	Metadata     map[string]string
+
+	// ContentEncoding is the Content-Encoding the object was stored with, if any.
+	// Listings don't report it.
+	ContentEncoding string
+
+	// StorageClass is the storage class of the object, e.g. "STANDARD" or
+	// "GLACIER". Empty means the store's default.
+	StorageClass string
+
+	// Expiration, Restore and ReplicationStatus are the raw values of S3's
+	// x-amz-expiration, x-amz-restore and x-amz-replication-status headers. Use
+	// the Lifecycle method to make sense of them.
+	Expiration        string
+	Restore           string
+	ReplicationStatus string
+
+	// WebsiteRedirectLocation is the x-amz-website-redirect-location of the object,
+	// the URL or path that the bucket's static website endpoint redirects requests for
+	// it to. Listings don't report it.
+	WebsiteRedirectLocation string
+}
+
+// Object is an object's body along with its metadata. For ranged reads Info.Size is
+// still the size of the whole object.
+type Object struct {
+	Body io.ReadCloser
+	Info ObjectInfo
+
+	// Retries is how many times the store retried the request before it succeeded,
+	// if it knows.
+	Retries int
+}
@@ -0,0 +1,98 @@
+package s3fs_test
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/packrat386/s3fs"
+	"github.com/packrat386/s3fs/s3fstest"
+	"github.com/stretchr/testify/require"
+)
+
+func conformanceStore() *s3fstest.MemStore {
+	store := s3fstest.NewMemStore()
+	for name, data := range s3fstest.ConformanceFiles {
+		store.WriteFile(name, data)
+	}
+
+	return store
+}
+
+func TestWithStrictPaths_Conformance(t *testing.T) {
+	myFS := s3fs.NewFS(conformanceStore(), s3fs.WithStrictPaths())
+
+	require.Nil(t, s3fstest.Conformance(myFS, s3fstest.ConformanceFiles))
+}
+
+func TestWithStrictPaths_OverridesNamePolicy(t *testing.T) {
+	store := conformanceStore()
+
+	relaxed := s3fs.NewFS(store, s3fs.WithNamePolicy(s3fs.RelaxedNames))
+	require.NotNil(t, s3fstest.Conformance(relaxed, s3fstest.ConformanceFiles))
+
+	strict := s3fs.NewFS(store, s3fs.WithNamePolicy(s3fs.RelaxedNames), s3fs.WithStrictPaths())
+	require.Nil(t, s3fstest.Conformance(strict, s3fstest.ConformanceFiles))
+
+	_, err := strict.Open("dir-a/")
+	require.ErrorIs(t, err, fs.ErrInvalid)
+
+	var pathErr *fs.PathError
+	require.True(t, errors.As(err, &pathErr))
+	require.Equal(t, "dir-a/", pathErr.Path)
+}
+
+func TestWithStrictPaths_Prefixes(t *testing.T) {
+	myFS := s3fs.NewFS(conformanceStore(), s3fs.WithStrictPaths())
+	ctx := context.Background()
+
+	_, err := myFS.GenerateManifest(ctx, "/dir-a")
+	require.ErrorIs(t, err, fs.ErrInvalid)
+
+	err = myFS.WalkParallel(ctx, "dir-a/", 2, func(name string, info fs.FileInfo) error { return nil })
+	require.ErrorIs(t, err, fs.ErrInvalid)
+
+	_, err = myFS.SnapshotToMapFS(ctx, "../dir-a", 0)
+	require.ErrorIs(t, err, fs.ErrInvalid)
+}
+
+func TestWithStrictPaths_Scoped(t *testing.T) {
+	store := conformanceStore()
+
+	scoped, err := s3fs.NewScopedFS(store, "dir-a", s3fs.WithNamePolicy(func(name string) (string, bool) {
+		return name, true
+	}))
+	require.Nil(t, err)
+
+	_, err = scoped.Open("../top.json")
+	require.ErrorIs(t, err, fs.ErrPermission)
+
+	strict, err := s3fs.NewScopedFS(store, "dir-a", s3fs.WithStrictPaths(), s3fs.WithNamePolicy(func(name string) (string, bool) {
+		return name, true
+	}))
+	require.Nil(t, err)
+
+	_, err = strict.Open("../top.json")
+	require.ErrorIs(t, err, fs.ErrInvalid)
+}
+
+func TestS3FS_Conformance(t *testing.T) {
+	bucket := os.Getenv("S3FS_TESTING_BUCKET")
+	require.NotEqual(t, "", bucket, "S3FS_TESTING_BUCKET must be set")
+
+	sess, err := session.NewSession()
+	require.Nil(t, err)
+
+	myFS := s3fs.NewS3FS(s3.New(sess), bucket, s3fs.WithStrictPaths())
+
+	for name, data := range s3fstest.ConformanceFiles {
+		require.Nil(t, myFS.WriteFile(name, []byte(data)))
+		defer myFS.Remove(name)
+	}
+
+	require.Nil(t, s3fstest.Conformance(myFS, s3fstest.ConformanceFiles))
+}
@@ -0,0 +1,126 @@
+package s3fs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+)
+
+// Sub returns the FS of the directory dir, making FS an fs.SubFS. Unlike fs.Sub it
+// checks that dir exists, which takes a listing of at most one key.
+func (s *S3FS) Sub(dir string) (fs.FS, error) {
+	if !fs.ValidPath(dir) {
+		return nil, &fs.PathError{Op: "sub", Path: dir, Err: fs.ErrInvalid}
+	}
+
+	if dir == "." {
+		return s, nil
+	}
+
+	if s.bucketErr != nil {
+		return nil, s.bucketErr
+	}
+
+	if err := s.checkOpen(); err != nil {
+		return nil, err
+	}
+
+	key, err := s.key("sub", dir)
+	if err != nil {
+		return nil, err
+	}
+
+	exists, err := s.dirExists(context.Background(), key)
+	if err != nil {
+		return nil, fmt.Errorf("could not list s3 objects: %w", err)
+	}
+
+	if !exists {
+		return nil, &fs.PathError{Op: "sub", Path: dir, Err: fs.ErrNotExist}
+	}
+
+	return &subFS{fsys: s, dir: dir}, nil
+}
+
+// subFS is the FS of a directory of an S3FS.
+type subFS struct {
+	fsys *S3FS
+	dir  string
+}
+
+// full returns the name in the parent FS of name, which is relative to the directory.
+func (f *subFS) full(op, name string) (string, error) {
+	if !fs.ValidPath(name) {
+		return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+
+	return path.Join(f.dir, name), nil
+}
+
+// shorten makes the paths of errors from the parent FS relative to the directory.
+func (f *subFS) shorten(name string, err error) error {
+	pathErr := &fs.PathError{}
+	if errors.As(err, &pathErr) {
+		return &fs.PathError{Op: pathErr.Op, Path: name, Err: pathErr.Err}
+	}
+
+	return err
+}
+
+func (f *subFS) Open(name string) (fs.File, error) {
+	full, err := f.full("open", name)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := f.fsys.Open(full)
+	if err != nil {
+		return nil, f.shorten(name, err)
+	}
+
+	return file, nil
+}
+
+func (f *subFS) Stat(name string) (fs.FileInfo, error) {
+	full, err := f.full("stat", name)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := f.fsys.Stat(full)
+	if err != nil {
+		return nil, f.shorten(name, err)
+	}
+
+	return info, nil
+}
+
+func (f *subFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	full, err := f.full("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := fs.ReadDir(f.fsys, full)
+	if err != nil {
+		return nil, f.shorten(name, err)
+	}
+
+	return entries, nil
+}
+
+func (f *subFS) Sub(dir string) (fs.FS, error) {
+	full, err := f.full("sub", dir)
+	if err != nil {
+		return nil, err
+	}
+
+	sub, err := f.fsys.Sub(full)
+	if err != nil {
+		return nil, f.shorten(dir, err)
+	}
+
+	return sub, nil
+}
@@ -0,0 +1,364 @@
+package s3fs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// SyncResult reports what a sync did.
+type SyncResult struct {
+	// Transferred are the names of the files that were copied because they were
+	// missing or different.
+	Transferred []string
+
+	// Skipped are the names of the files that were left alone because both sides
+	// already had the same content.
+	Skipped []string
+}
+
+// SyncFromDir uploads the files under localDir to the directory name ("." for the
+// whole bucket), skipping files whose content the bucket already has. Files are
+// compared by size and then by ETag, which is recomputed from the local file, so
+// unchanged files cost a HEAD and a local read rather than an upload. Files in the
+// bucket that aren't in localDir are left alone.
+func (s *S3FS) SyncFromDir(ctx context.Context, localDir string, name string) (*SyncResult, error) {
+	if _, err := s.writableKey("sync", name); err != nil {
+		return nil, err
+	}
+
+	result := &SyncResult{}
+	err := filepath.WalkDir(localDir, func(localPath string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(localDir, localPath)
+		if err != nil {
+			return err
+		}
+
+		target := path.Join(name, filepath.ToSlash(rel))
+		key, err := s.key("sync", target)
+		if err != nil {
+			return err
+		}
+
+		info, err := s.store.Head(ctx, key)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("could not stat %s: %w", key, err)
+		}
+
+		if err == nil {
+			same, err := localMatches(localPath, info)
+			if err != nil {
+				return err
+			}
+
+			if same {
+				result.Skipped = append(result.Skipped, target)
+				return nil
+			}
+		}
+
+		if err := s.uploadFile(ctx, target, localPath); err != nil {
+			return err
+		}
+
+		result.Transferred = append(result.Transferred, target)
+
+		return nil
+	})
+
+	return result, err
+}
+
+func (s *S3FS) uploadFile(ctx context.Context, name string, localPath string) error {
+	f, err := os.Open(localPath)
+	if err != nil {
+		return fmt.Errorf("could not open %s: %w", localPath, err)
+	}
+	defer f.Close()
+
+	stat, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("could not stat %s: %w", localPath, err)
+	}
+
+	return s.UploadFrom(ctx, name, f, stat.Size())
+}
+
+// SyncToDir downloads the files under the directory name ("." for the whole bucket)
+// to localDir, skipping local files that already have the same content. Files are
+// compared by size and then by ETag, which is recomputed from the local file, so
+// unchanged files are never downloaded. Local files that aren't in the bucket are
+// left alone.
+func (s *S3FS) SyncToDir(ctx context.Context, name string, localDir string) (*SyncResult, error) {
+	if s.bucketErr != nil {
+		return nil, s.bucketErr
+	}
+
+	key, err := s.key("sync", name)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := ""
+	if key != "" {
+		prefix = key + "/"
+	}
+
+	objects := []ObjectInfo{}
+	err = s.store.List(ctx, prefix, ListOptions{}, func(page *ListPage) bool {
+		for _, obj := range page.Objects {
+			if !strings.HasSuffix(obj.Key, "/") {
+				objects = append(objects, obj)
+			}
+		}
+
+		return true
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("could not list s3 objects: %w", err)
+	}
+
+	result := &SyncResult{}
+	for _, obj := range objects {
+		localPath := filepath.Join(localDir, filepath.FromSlash(strings.TrimPrefix(obj.Key, prefix)))
+
+		same, err := localMatches(localPath, obj)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return result, err
+		}
+
+		if same {
+			result.Skipped = append(result.Skipped, obj.Key)
+			continue
+		}
+
+		if err := os.MkdirAll(filepath.Dir(localPath), 0755); err != nil {
+			return result, fmt.Errorf("could not create directory for %s: %w", localPath, err)
+		}
+
+		if err := s.DownloadTo(ctx, obj.Key, localPath); err != nil {
+			return result, err
+		}
+
+		result.Transferred = append(result.Transferred, obj.Key)
+	}
+
+	return result, nil
+}
+
+// commonPartSizes are the multipart part sizes used by popular S3 clients, tried when
+// checking a local file against a multipart ETag.
+var commonPartSizes = []int64{5 << 20, 8 << 20, 16 << 20, 64 << 20, 100 << 20}
+
+// localMatches reports whether the file at localPath has the content of the object
+// described by info.
+func localMatches(localPath string, info ObjectInfo) (bool, error) {
+	f, err := os.Open(localPath)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	stat, err := f.Stat()
+	if err != nil {
+		return false, fmt.Errorf("could not stat %s: %w", localPath, err)
+	}
+
+	if !stat.Mode().IsRegular() {
+		return false, nil
+	}
+
+	same, err := contentMatches(f, stat.Size(), info)
+	if err != nil {
+		return false, fmt.Errorf("could not read %s: %w", localPath, err)
+	}
+
+	return same, nil
+}
+
+// contentMatches reports whether the size bytes read from r are the content of the
+// object described by info, judging by its ETag. A multipart ETag depends on the part
+// size of the upload, which isn't recorded anywhere, so the content is hashed with
+// every plausible part size at once: the one UploadFrom would use, the defaults of
+// common clients, and the object's size divided evenly between its parts.
+func contentMatches(r io.Reader, size int64, info ObjectInfo) (bool, error) {
+	if size != info.Size {
+		return false, nil
+	}
+
+	hashers := []*partHasher{}
+	writers := []io.Writer{}
+	for _, partSize := range candidatePartSizes(info.Size, info.ETag) {
+		h := newPartHasher(partSize)
+		hashers = append(hashers, h)
+		writers = append(writers, h)
+	}
+
+	if len(hashers) == 0 {
+		return false, nil
+	}
+
+	if _, err := io.Copy(io.MultiWriter(writers...), r); err != nil {
+		return false, err
+	}
+
+	for _, h := range hashers {
+		if h.matches(info.ETag) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// candidatePartSizes returns the part sizes that could have produced etag for an
+// object of size bytes. Single PUT ETags don't depend on the part size, so any one
+// will do.
+func candidatePartSizes(size int64, etag string) []int64 {
+	etag = strings.Trim(etag, `"`)
+
+	i := strings.LastIndex(etag, "-")
+	if i < 0 {
+		return []int64{uploadPartSize(size)}
+	}
+
+	parts, err := strconv.ParseInt(etag[i+1:], 10, 64)
+	if err != nil || parts < 1 {
+		return nil
+	}
+
+	// spread evenly, rounded up to a whole MiB as most clients do
+	even := (size + parts - 1) / parts
+	evenMiB := (even + 1<<20 - 1) >> 20 << 20
+
+	candidates := append([]int64{uploadPartSize(size), even, evenMiB}, commonPartSizes...)
+
+	seen := map[int64]bool{}
+	sizes := []int64{}
+	for _, partSize := range candidates {
+		if partSize <= 0 || seen[partSize] {
+			continue
+		}
+
+		seen[partSize] = true
+
+		// the last part may be empty when size is a multiple of partSize
+		n := (size + partSize - 1) / partSize
+		if n == parts || (size%partSize == 0 && n+1 == parts) {
+			sizes = append(sizes, partSize)
+		}
+	}
+
+	return sizes
+}
+
+// VerifyReport is what Verify found different between a local directory and a
+// directory in the bucket. Names are slash separated and relative to both.
+type VerifyReport struct {
+	// Missing files are in the local directory but not in the bucket.
+	Missing []string
+
+	// Extra files are in the bucket but not in the local directory.
+	Extra []string
+
+	// Mismatched files are in both but have different content.
+	Mismatched []string
+}
+
+// OK reports whether the two directories have the same files with the same content.
+func (r *VerifyReport) OK() bool {
+	return len(r.Missing) == 0 && len(r.Extra) == 0 && len(r.Mismatched) == 0
+}
+
+// Verify compares the files under localDir with the files under the directory name
+// ("." for the whole bucket), e.g. to check a deployment or a backup. Files are
+// compared as SyncFromDir compares them, by size and then by ETag recomputed from the
+// local file, so nothing is downloaded. Differences are reported in the VerifyReport
+// rather than as an error.
+func (s *S3FS) Verify(ctx context.Context, localDir string, name string) (*VerifyReport, error) {
+	m, err := s.GenerateManifest(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := ""
+	if dir := path.Clean(name); dir != "." {
+		prefix = dir + "/"
+	}
+
+	remote := make(map[string]ManifestEntry, len(m.Entries))
+	for _, e := range m.Entries {
+		remote[strings.TrimPrefix(e.Path, prefix)] = e
+	}
+
+	report := &VerifyReport{}
+	err = filepath.WalkDir(localDir, func(localPath string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(localDir, localPath)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		e, ok := remote[rel]
+		if !ok {
+			report.Missing = append(report.Missing, rel)
+			return nil
+		}
+		delete(remote, rel)
+
+		same, err := localMatches(localPath, ObjectInfo{Key: e.Path, Size: e.Size, ETag: e.ETag})
+		if err != nil {
+			return err
+		}
+
+		if !same {
+			report.Mismatched = append(report.Mismatched, rel)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("could not verify %s against %s: %w", localDir, name, err)
+	}
+
+	for rel := range remote {
+		report.Extra = append(report.Extra, rel)
+	}
+	sort.Strings(report.Extra)
+
+	return report, nil
+}
@@ -0,0 +1,111 @@
+package s3fs_test
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/packrat386/s3fs"
+	"github.com/packrat386/s3fs/s3fstest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSyncFromDir(t *testing.T) {
+	dir := t.TempDir()
+	require.Nil(t, os.MkdirAll(filepath.Join(dir, "sub"), 0755))
+	require.Nil(t, os.WriteFile(filepath.Join(dir, "same.txt"), []byte("same"), 0644))
+	require.Nil(t, os.WriteFile(filepath.Join(dir, "changed.txt"), []byte("new"), 0644))
+	require.Nil(t, os.WriteFile(filepath.Join(dir, "sub", "added.txt"), []byte("added"), 0644))
+
+	store := s3fstest.NewMemStore()
+	store.WriteFile("site/same.txt", "same")
+	store.WriteFile("site/changed.txt", "old")
+	store.WriteFile("site/extra.txt", "extra")
+
+	myFS := s3fs.NewFS(store)
+
+	result, err := myFS.SyncFromDir(context.Background(), dir, "site")
+	require.Nil(t, err)
+	require.ElementsMatch(t, []string{"site/changed.txt", "site/sub/added.txt"}, result.Transferred)
+	require.Equal(t, []string{"site/same.txt"}, result.Skipped)
+
+	data, err := fs.ReadFile(myFS, "site/changed.txt")
+	require.Nil(t, err)
+	require.Equal(t, "new", string(data))
+
+	data, err = fs.ReadFile(myFS, "site/extra.txt")
+	require.Nil(t, err)
+	require.Equal(t, "extra", string(data))
+
+	result, err = myFS.SyncFromDir(context.Background(), dir, "site")
+	require.Nil(t, err)
+	require.Empty(t, result.Transferred)
+	require.Len(t, result.Skipped, 3)
+}
+
+func TestSyncToDir(t *testing.T) {
+	dir := t.TempDir()
+	require.Nil(t, os.WriteFile(filepath.Join(dir, "same.txt"), []byte("same"), 0644))
+	require.Nil(t, os.WriteFile(filepath.Join(dir, "changed.txt"), []byte("old"), 0644))
+	require.Nil(t, os.WriteFile(filepath.Join(dir, "local.txt"), []byte("local"), 0644))
+
+	store := s3fstest.NewMemStore()
+	store.WriteFile("site/same.txt", "same")
+	store.WriteFile("site/changed.txt", "new")
+	store.WriteFile("site/sub/added.txt", "added")
+
+	myFS := s3fs.NewFS(store)
+
+	result, err := myFS.SyncToDir(context.Background(), "site", dir)
+	require.Nil(t, err)
+	require.ElementsMatch(t, []string{"site/changed.txt", "site/sub/added.txt"}, result.Transferred)
+	require.Equal(t, []string{"site/same.txt"}, result.Skipped)
+
+	for name, want := range map[string]string{
+		"same.txt":      "same",
+		"changed.txt":   "new",
+		"sub/added.txt": "added",
+		"local.txt":     "local",
+	} {
+		data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+		require.Nil(t, err)
+		require.Equal(t, want, string(data))
+	}
+
+	result, err = myFS.SyncToDir(context.Background(), "site", dir)
+	require.Nil(t, err)
+	require.Empty(t, result.Transferred)
+	require.Len(t, result.Skipped, 3)
+}
+
+func TestVerify(t *testing.T) {
+	dir := t.TempDir()
+	require.Nil(t, os.MkdirAll(filepath.Join(dir, "sub"), 0755))
+	require.Nil(t, os.WriteFile(filepath.Join(dir, "same.txt"), []byte("same"), 0644))
+	require.Nil(t, os.WriteFile(filepath.Join(dir, "changed.txt"), []byte("new"), 0644))
+	require.Nil(t, os.WriteFile(filepath.Join(dir, "sub", "missing.txt"), []byte("missing"), 0644))
+
+	store := s3fstest.NewMemStore()
+	store.WriteFile("site/same.txt", "same")
+	store.WriteFile("site/changed.txt", "old")
+	store.WriteFile("site/extra.txt", "extra")
+
+	myFS := s3fs.NewFS(store)
+
+	report, err := myFS.Verify(context.Background(), dir, "site")
+	require.Nil(t, err)
+	require.False(t, report.OK())
+	require.Equal(t, []string{"sub/missing.txt"}, report.Missing)
+	require.Equal(t, []string{"extra.txt"}, report.Extra)
+	require.Equal(t, []string{"changed.txt"}, report.Mismatched)
+
+	_, err = myFS.SyncFromDir(context.Background(), dir, "site")
+	require.Nil(t, err)
+	require.Nil(t, myFS.Remove("site/extra.txt"))
+
+	report, err = myFS.Verify(context.Background(), dir, "site")
+	require.Nil(t, err)
+	require.True(t, report.OK())
+}
@@ -0,0 +1,181 @@
+package s3fs
+
+import (
+	"fmt"
+	"io/fs"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// TemplatedFS hands out FSes scoped to prefixes built from a template like
+// "tenants/{tenant}/data", so the layout of keys is defined in one place instead of
+// by every service that reads them. Create one with NewTemplatedS3FS or
+// NewTemplatedFS. It is safe for concurrent use.
+type TemplatedFS struct {
+	template string
+	vars     []string
+	newFS    func(prefix string) (*S3FS, error)
+
+	mu     sync.Mutex
+	scoped map[string]*S3FS
+	closed bool
+}
+
+// NewTemplatedS3FS returns a TemplatedFS for bucket. template is a directory prefix
+// with variables in braces, e.g. "tenants/{tenant}/data" or "{env}/{tenant}", and
+// the FSes it hands out are created like NewScopedS3FS with opts.
+func NewTemplatedS3FS(client *s3.S3, bucket, template string, opts ...Option) (*TemplatedFS, error) {
+	return newTemplatedFS(template, func(prefix string) (*S3FS, error) {
+		return NewScopedS3FS(client, bucket, prefix, opts...)
+	})
+}
+
+// NewTemplatedFS is NewTemplatedS3FS for an arbitrary ObjectStore.
+func NewTemplatedFS(store ObjectStore, template string, opts ...Option) (*TemplatedFS, error) {
+	return newTemplatedFS(template, func(prefix string) (*S3FS, error) {
+		return NewScopedFS(store, prefix, opts...)
+	})
+}
+
+func newTemplatedFS(template string, newFS func(prefix string) (*S3FS, error)) (*TemplatedFS, error) {
+	template = strings.Trim(template, "/")
+
+	vars, err := templateVars(template)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TemplatedFS{
+		template: template,
+		vars:     vars,
+		newFS:    newFS,
+		scoped:   map[string]*S3FS{},
+	}, nil
+}
+
+// templateVars returns the names of the variables in template, checking that it is
+// well formed and that each variable is a whole path segment.
+func templateVars(template string) ([]string, error) {
+	vars := []string{}
+
+	for _, segment := range strings.Split(template, "/") {
+		if !strings.ContainsAny(segment, "{}") {
+			continue
+		}
+
+		if len(segment) < 3 || segment[0] != '{' || segment[len(segment)-1] != '}' || strings.ContainsAny(segment[1:len(segment)-1], "{}") {
+			return nil, fmt.Errorf("invalid template %q: variables must be whole path segments like {name}", template)
+		}
+
+		vars = append(vars, segment[1:len(segment)-1])
+	}
+
+	if len(vars) == 0 {
+		return nil, fmt.Errorf("invalid template %q: it has no variables", template)
+	}
+
+	return vars, nil
+}
+
+// Vars returns the names of the template's variables, in the order they appear.
+func (t *TemplatedFS) Vars() []string {
+	return append([]string(nil), t.vars...)
+}
+
+// Prefix returns the prefix that values, which must set every variable of the
+// template, fill it in to. Values must be single path segments, so no value can
+// reach another part of the bucket; others are rejected with an error wrapping
+// fs.ErrInvalid.
+func (t *TemplatedFS) Prefix(values map[string]string) (string, error) {
+	for name, value := range values {
+		if value == "" || value == "." || value == ".." || strings.Contains(value, "/") {
+			return "", fmt.Errorf("%w: value of {%s} must be a single path segment, got %q", fs.ErrInvalid, name, value)
+		}
+	}
+
+	segments := strings.Split(t.template, "/")
+	for i, segment := range segments {
+		if !strings.HasPrefix(segment, "{") {
+			continue
+		}
+
+		name := segment[1 : len(segment)-1]
+		value, ok := values[name]
+		if !ok {
+			return "", fmt.Errorf("%w: no value for {%s} in %s", fs.ErrInvalid, name, t.template)
+		}
+
+		segments[i] = value
+	}
+
+	unknown := []string{}
+	for name := range values {
+		if !strings.Contains(t.template, "{"+name+"}") {
+			unknown = append(unknown, "{"+name+"}")
+		}
+	}
+
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return "", fmt.Errorf("%w: %s has no variables %s", fs.ErrInvalid, t.template, strings.Join(unknown, ", "))
+	}
+
+	return strings.Join(segments, "/"), nil
+}
+
+// For returns the FS scoped to the prefix values fill the template in to, as
+// Prefix does. FSes are created the first time they are asked for and then reused,
+// so it can be called for every request.
+func (t *TemplatedFS) For(values map[string]string) (*S3FS, error) {
+	prefix, err := t.Prefix(values)
+	if err != nil {
+		return nil, err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.closed {
+		return nil, fs.ErrClosed
+	}
+
+	if s, ok := t.scoped[prefix]; ok {
+		return s, nil
+	}
+
+	s, err := t.newFS(prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	t.scoped[prefix] = s
+
+	return s, nil
+}
+
+// ForTenant is For with just the {tenant} variable set.
+func (t *TemplatedFS) ForTenant(tenant string) (*S3FS, error) {
+	return t.For(map[string]string{"tenant": tenant})
+}
+
+// Close closes every FS that For has handed out, returning the first error.
+func (t *TemplatedFS) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.closed = true
+
+	var firstErr error
+	for prefix, s := range t.scoped {
+		if err := s.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+
+		delete(t.scoped, prefix)
+	}
+
+	return firstErr
+}
@@ -0,0 +1,75 @@
+package s3fs_test
+
+import (
+	"io/fs"
+	"testing"
+
+	"github.com/packrat386/s3fs"
+	"github.com/packrat386/s3fs/s3fstest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewTemplatedFS(t *testing.T) {
+	store := s3fstest.NewMemStore()
+	store.WriteFile("tenants/acme/data/report.csv", "acme")
+	store.WriteFile("tenants/globex/data/report.csv", "globex")
+
+	tmpl, err := s3fs.NewTemplatedFS(store, "/tenants/{tenant}/data/")
+	require.Nil(t, err)
+	require.Equal(t, []string{"tenant"}, tmpl.Vars())
+
+	acme, err := tmpl.ForTenant("acme")
+	require.Nil(t, err)
+
+	data, err := fs.ReadFile(acme, "report.csv")
+	require.Nil(t, err)
+	require.Equal(t, "acme", string(data))
+
+	again, err := tmpl.ForTenant("acme")
+	require.Nil(t, err)
+	require.Same(t, acme, again)
+
+	globex, err := tmpl.For(map[string]string{"tenant": "globex"})
+	require.Nil(t, err)
+
+	data, err = fs.ReadFile(globex, "report.csv")
+	require.Nil(t, err)
+	require.Equal(t, "globex", string(data))
+
+	for _, tenant := range []string{"", ".", "..", "acme/../globex"} {
+		_, err = tmpl.ForTenant(tenant)
+		require.ErrorIs(t, err, fs.ErrInvalid, tenant)
+	}
+
+	_, err = tmpl.For(map[string]string{"tenant": "acme", "env": "prod"})
+	require.ErrorIs(t, err, fs.ErrInvalid)
+
+	require.Nil(t, tmpl.Close())
+
+	_, err = fs.ReadFile(acme, "report.csv")
+	require.ErrorIs(t, err, fs.ErrClosed)
+
+	_, err = tmpl.ForTenant("acme")
+	require.ErrorIs(t, err, fs.ErrClosed)
+}
+
+func TestNewTemplatedFS_Vars(t *testing.T) {
+	store := s3fstest.NewMemStore()
+	store.WriteFile("prod/acme/config.json", "{}")
+
+	tmpl, err := s3fs.NewTemplatedFS(store, "{env}/{tenant}")
+	require.Nil(t, err)
+	require.Equal(t, []string{"env", "tenant"}, tmpl.Vars())
+
+	prefix, err := tmpl.Prefix(map[string]string{"env": "prod", "tenant": "acme"})
+	require.Nil(t, err)
+	require.Equal(t, "prod/acme", prefix)
+
+	_, err = tmpl.ForTenant("acme")
+	require.ErrorIs(t, err, fs.ErrInvalid)
+
+	for _, bad := range []string{"tenants", "tenants/{tenant", "tenants/x{tenant}", "tenants/{}", "{a{b}}"} {
+		_, err = s3fs.NewTemplatedFS(store, bad)
+		require.NotNil(t, err, bad)
+	}
+}
@@ -0,0 +1,134 @@
+// Package templates loads html/template sets from an s3fs.S3FS and reloads them when
+// they change, so templates can be edited in the bucket without restarting anything.
+//
+// Changes are detected by polling the ETags of the template files, which costs a
+// listing per pattern and a HEAD per file. The set is only parsed again when something
+// changed, and is swapped in atomically, so Template always returns a complete set. If
+// a changed set fails to parse, the previous one stays in use.
+package templates
+
+import (
+	"context"
+	"fmt"
+	"html/template"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/packrat386/s3fs"
+)
+
+// Loader holds the template set parsed from the files matching some patterns, and
+// reloads it when they change.
+type Loader struct {
+	fsys     *s3fs.S3FS
+	funcs    template.FuncMap
+	patterns []string
+
+	mu          sync.RWMutex
+	tmpl        *template.Template
+	fingerprint string
+}
+
+// NewLoader parses the files in fsys matching patterns, as template.ParseFS does, with
+// funcs available to them. funcs may be nil.
+func NewLoader(ctx context.Context, fsys *s3fs.S3FS, funcs template.FuncMap, patterns ...string) (*Loader, error) {
+	l := &Loader{fsys: fsys, funcs: funcs, patterns: patterns}
+
+	if _, err := l.Reload(ctx); err != nil {
+		return nil, err
+	}
+
+	return l, nil
+}
+
+// Template returns the current template set. It must not be modified, though it can be
+// cloned.
+func (l *Loader) Template() *template.Template {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	return l.tmpl
+}
+
+// Reload parses the templates again if any of them were added, removed or changed
+// since they were last parsed, and reports whether they were.
+func (l *Loader) Reload(ctx context.Context) (bool, error) {
+	fingerprint, err := l.currentFingerprint(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	l.mu.RLock()
+	unchanged := l.tmpl != nil && fingerprint == l.fingerprint
+	l.mu.RUnlock()
+
+	if unchanged {
+		return false, nil
+	}
+
+	tmpl, err := template.New("").Funcs(l.funcs).ParseFS(l.fsys, l.patterns...)
+	if err != nil {
+		return false, fmt.Errorf("could not parse templates: %w", err)
+	}
+
+	l.mu.Lock()
+	l.tmpl = tmpl
+	l.fingerprint = fingerprint
+	l.mu.Unlock()
+
+	return true, nil
+}
+
+// currentFingerprint describes the names and ETags of the template files as they are
+// now.
+func (l *Loader) currentFingerprint(ctx context.Context) (string, error) {
+	names := []string{}
+	for _, pattern := range l.patterns {
+		matches, err := l.fsys.Glob(pattern)
+		if err != nil {
+			return "", fmt.Errorf("could not match %s: %w", pattern, err)
+		}
+
+		names = append(names, matches...)
+	}
+
+	sort.Strings(names)
+
+	b := strings.Builder{}
+	for _, name := range names {
+		info, err := l.fsys.StatFile(ctx, name)
+		if err != nil {
+			return "", fmt.Errorf("could not stat template %s: %w", name, err)
+		}
+
+		etag := ""
+		if object, ok := info.Sys().(s3fs.ObjectInfo); ok {
+			etag = object.ETag
+		}
+
+		fmt.Fprintf(&b, "%s %s\n", name, etag)
+	}
+
+	return b.String(), nil
+}
+
+// Watch calls Reload every interval until ctx is done. Errors, e.g. from templates
+// that don't parse, are passed to onError if it isn't nil, and the previous templates
+// stay in use.
+func (l *Loader) Watch(ctx context.Context, interval time.Duration, onError func(error)) {
+	clock := l.fsys.Clock()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-clock.After(interval):
+		}
+
+		if _, err := l.Reload(ctx); err != nil && onError != nil {
+			onError(err)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package templates
+
+import (
+	"context"
+	"html/template"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/packrat386/s3fs"
+	"github.com/packrat386/s3fs/s3fstest"
+	"github.com/stretchr/testify/require"
+)
+
+func render(t *testing.T, l *Loader, name string) string {
+	b := strings.Builder{}
+	require.Nil(t, l.Template().ExecuteTemplate(&b, name, "world"))
+
+	return b.String()
+}
+
+func TestLoader(t *testing.T) {
+	ctx := context.Background()
+	store := s3fstest.NewMemStore()
+	store.WriteFile("templates/hello.html", `Hello, {{shout .}}!`)
+
+	funcs := template.FuncMap{"shout": strings.ToUpper}
+	l, err := NewLoader(ctx, s3fs.NewFS(store), funcs, "templates/*.html")
+	require.Nil(t, err)
+	require.Equal(t, "Hello, WORLD!", render(t, l, "hello.html"))
+
+	changed, err := l.Reload(ctx)
+	require.Nil(t, err)
+	require.False(t, changed)
+
+	store.WriteFile("templates/hello.html", `Goodbye, {{.}}!`)
+
+	changed, err = l.Reload(ctx)
+	require.Nil(t, err)
+	require.True(t, changed)
+	require.Equal(t, "Goodbye, world!", render(t, l, "hello.html"))
+
+	// a broken edit leaves the last good templates in place
+	store.WriteFile("templates/hello.html", `{{.`)
+
+	_, err = l.Reload(ctx)
+	require.Error(t, err)
+	require.Equal(t, "Goodbye, world!", render(t, l, "hello.html"))
+}
+
+func TestLoader_Watch(t *testing.T) {
+	store := s3fstest.NewMemStore()
+	store.WriteFile("templates/hello.html", `one`)
+
+	l, err := NewLoader(context.Background(), s3fs.NewFS(store), nil, "templates/*.html")
+	require.Nil(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go l.Watch(ctx, time.Millisecond, nil)
+
+	store.WriteFile("templates/hello.html", `two`)
+
+	require.Eventually(t, func() bool {
+		return render(t, l, "hello.html") == "two"
+	}, 5*time.Second, time.Millisecond)
+}
+
+func TestLoader_WatchClock(t *testing.T) {
+	clock := s3fstest.NewFakeClock(time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC))
+	store := s3fstest.NewMemStore()
+	store.WriteFile("templates/hello.html", `one`)
+
+	l, err := NewLoader(context.Background(), s3fs.NewFS(store, s3fs.WithClock(clock)), nil, "templates/*.html")
+	require.Nil(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go l.Watch(ctx, time.Minute, nil)
+	clock.BlockUntil(1)
+
+	store.WriteFile("templates/hello.html", `two`)
+	require.Equal(t, "one", render(t, l, "hello.html"))
+
+	// the reload happens once the FS's clock says the interval is up
+	clock.Advance(time.Minute)
+	clock.BlockUntil(1)
+	require.Equal(t, "two", render(t, l, "hello.html"))
+}
+
+func TestNewLoader_NoMatches(t *testing.T) {
+	_, err := NewLoader(context.Background(), s3fs.NewFS(s3fstest.NewMemStore()), nil, "templates/*.html")
+	require.Error(t, err)
+}
@@ -0,0 +1,31 @@
+package s3fs
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+)
+
+// ErrTruncated is returned by Read, instead of io.EOF, when the body of a file ends
+// before as many bytes as its size said have been read, or after more, as when a
+// connection is cut off in a way that looks like the end of the body. Without it, a
+// copy of the file would silently come out short. Opening a file with WithCache and
+// DownloadTo fail with it too, rather than caching or writing a short file.
+var ErrTruncated = errors.New("file truncated")
+
+// checkLength returns err, unless it's the end of the body and the number of bytes
+// read doesn't match the size the file was opened with, in which case it returns an
+// error wrapping ErrTruncated. Files of unknown size, like compressed ones stored
+// without their original size, aren't checked.
+func (f *s3File) checkLength(err error) error {
+	if err != io.EOF || f.fileInfo.size < 0 || f.read == f.fileInfo.size {
+		return err
+	}
+
+	return &fs.PathError{
+		Op:   "read",
+		Path: f.name,
+		Err:  fmt.Errorf("%w: read %d of %d bytes", ErrTruncated, f.read, f.fileInfo.size),
+	}
+}
@@ -0,0 +1,89 @@
+package s3fs_test
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/packrat386/s3fs"
+	"github.com/packrat386/s3fs/s3fstest"
+	"github.com/stretchr/testify/require"
+)
+
+// truncatingStore cuts the bodies of objects short by cut bytes, or reports them as
+// that much shorter than they are if cut is negative, like a dropped connection or a
+// bad proxy would.
+type truncatingStore struct {
+	s3fs.ObjectStore
+	cut int64
+}
+
+func (t truncatingStore) Get(ctx context.Context, key string, opts s3fs.GetOptions) (*s3fs.Object, error) {
+	object, err := t.ObjectStore.Get(ctx, key, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if t.cut < 0 {
+		object.Info.Size += t.cut
+		return object, nil
+	}
+
+	object.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.LimitReader(object.Body, object.Info.Size-t.cut), object.Body}
+
+	return object, nil
+}
+
+func TestErrTruncated(t *testing.T) {
+	store := s3fstest.NewMemStore()
+	store.WriteFile("a.txt", "0123456789")
+
+	for _, cut := range []int64{3, -3} {
+		myFS := s3fs.NewFS(truncatingStore{ObjectStore: store, cut: cut})
+
+		_, err := fs.ReadFile(myFS, "a.txt")
+		require.ErrorIs(t, err, s3fs.ErrTruncated, cut)
+
+		f, err := myFS.Open("a.txt")
+		require.Nil(t, err)
+
+		_, err = io.Copy(&strings.Builder{}, f)
+		require.ErrorIs(t, err, s3fs.ErrTruncated, cut)
+		require.Contains(t, err.Error(), "a.txt")
+		require.Nil(t, f.Close())
+	}
+
+	data, err := fs.ReadFile(s3fs.NewFS(truncatingStore{ObjectStore: store}), "a.txt")
+	require.Nil(t, err)
+	require.Equal(t, "0123456789", string(data))
+}
+
+func TestErrTruncated_NotCached(t *testing.T) {
+	store := s3fstest.NewMemStore()
+	store.WriteFile("a.txt", "0123456789")
+
+	cache := s3fs.NewMemoryCache(1 << 20)
+
+	myFS := s3fs.NewFS(truncatingStore{ObjectStore: store, cut: 3}, s3fs.WithCache(cache, 0))
+	_, err := myFS.Open("a.txt")
+	require.ErrorIs(t, err, s3fs.ErrTruncated)
+
+	_, ok := cache.Get("a.txt")
+	require.False(t, ok)
+}
+
+func TestErrTruncated_DownloadTo(t *testing.T) {
+	store := s3fstest.NewMemStore()
+	store.WriteFile("a.txt", "0123456789")
+
+	myFS := s3fs.NewFS(truncatingStore{ObjectStore: store, cut: 3})
+
+	err := myFS.DownloadTo(context.Background(), "a.txt", filepath.Join(t.TempDir(), "a.txt"))
+	require.ErrorIs(t, err, s3fs.ErrTruncated)
+}
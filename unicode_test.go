@@ -0,0 +1,57 @@
+package s3fs_test
+
+import (
+	"context"
+	"io/fs"
+	"testing"
+
+	"github.com/packrat386/s3fs"
+	"github.com/packrat386/s3fs/s3fstest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithUnicodeNormalization(t *testing.T) {
+	store := s3fstest.NewMemStore()
+	store.WriteFile("docs/café/résumé.txt", "nfd")
+
+	_, err := fs.ReadFile(s3fs.NewFS(store), "docs/café/résumé.txt")
+	require.ErrorIs(t, err, fs.ErrNotExist)
+
+	myFS := s3fs.NewFS(store, s3fs.WithUnicodeNormalization(s3fs.NFD))
+
+	data, err := fs.ReadFile(myFS, "docs/café/résumé.txt")
+	require.Nil(t, err)
+	require.Equal(t, "nfd", string(data))
+
+	entries, err := fs.ReadDir(myFS, "docs/café")
+	require.Nil(t, err)
+	require.Len(t, entries, 1)
+	require.Equal(t, "résumé.txt", entries[0].Name())
+
+	require.Nil(t, myFS.WriteFile("docs/café/naïve.txt", []byte("written")))
+
+	_, err = store.Head(context.Background(), "docs/café/naïve.txt")
+	require.Nil(t, err)
+}
+
+func TestWithUnicodeNormalization_NFC(t *testing.T) {
+	store := s3fstest.NewMemStore()
+	store.WriteFile("\ud55c/caf\u00e9.txt", "nfc")
+	store.WriteFile("\u1112\u1161\u11ab.txt", "nfd")
+
+	myFS := s3fs.NewFS(store, s3fs.WithUnicodeNormalization(s3fs.NFC))
+
+	data, err := fs.ReadFile(myFS, "\u1112\u1161\u11ab/cafe\u0301.txt")
+	require.Nil(t, err)
+	require.Equal(t, "nfc", string(data))
+
+	// keys in the other form are listed in form, but can't be opened
+	entries, err := fs.ReadDir(myFS, ".")
+	require.Nil(t, err)
+	require.Len(t, entries, 2)
+	require.Equal(t, "\ud55c", entries[0].Name())
+	require.Equal(t, "\ud55c.txt", entries[1].Name())
+
+	_, err = fs.ReadFile(myFS, "\ud55c.txt")
+	require.ErrorIs(t, err, fs.ErrNotExist)
+}
@@ -0,0 +1,200 @@
+package s3fs
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ErrChecksumMismatch is returned when the checksum reported by the store doesn't
+// match the data that was sent to it.
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+const (
+	// minUploadPartSize is the smallest part S3 allows in a multipart upload.
+	minUploadPartSize = 5 << 20
+
+	// maxUploadParts is the most parts S3 allows in a multipart upload.
+	maxUploadParts = 10000
+)
+
+// UploadFrom streams r to name. size is the number of bytes r will produce, or -1 if
+// that isn't known. Small uploads are done with a single PUT and larger ones with a
+// multipart upload. Each request is sent with a checksum of the data it carries, so a
+// part that is corrupted on the way fails the upload rather than being stored.
+func (s *S3FS) UploadFrom(ctx context.Context, name string, r io.Reader, size int64) error {
+	key, err := s.writableKey("upload", name)
+	if err != nil {
+		return err
+	}
+
+	if rs, ok := r.(io.ReadSeeker); ok && s.skipUnchanged {
+		if _, same, err := s.unchanged(ctx, key, rs); err != nil || same {
+			return err
+		}
+	}
+
+	if err := s.quota.reserve(1, 0); err != nil {
+		return fmt.Errorf("could not write %s: %w", key, err)
+	}
+
+	partSize := uploadPartSize(size)
+	qr := &quotaReader{r: r, quota: s.quota}
+
+	opts := s.putOptions(key)
+	opts.PartSize = partSize
+	opts.VerifyContent = true
+	if s.compression != nil && size >= 0 {
+		opts.Metadata = map[string]string{originalSizeMetadata: strconv.FormatInt(size, 10)}
+	}
+
+	if s.contentHashes {
+		if opts, err = hashContent(r, opts); err != nil {
+			s.quota.release(1, 0)
+			return fmt.Errorf("could not upload %s: %w", key, err)
+		}
+	}
+
+	// this replaces anything still staged
+	if s.writeBack != nil {
+		s.writeBack.forget(key)
+	}
+
+	_, err = s.put(ctx, key, qr, opts)
+	if err == nil && size >= 0 && qr.read != size {
+		err = fmt.Errorf("expected %d bytes but read %d", size, qr.read)
+	}
+
+	if err != nil {
+		s.quota.release(1, qr.read)
+
+		err = fmt.Errorf("could not upload %s: %w", key, err)
+		if s.uploadHooks.Aborted != nil {
+			s.uploadHooks.Aborted(key, qr.read, err)
+		}
+
+		return err
+	}
+
+	if s.uploadHooks.Completed != nil {
+		s.uploadHooks.Completed(key, qr.read)
+	}
+
+	return nil
+}
+
+// storedAsSent reports whether the object described by info holds exactly the bytes
+// that were written to it, so that its ETag can be checked against them. Chunked files
+// are checked chunk by chunk as they are written instead, encrypted files are
+// authenticated when they are read, and compressed files are checked by their
+// decompressor.
+func storedAsSent(info ObjectInfo) bool {
+	_, chunked := metadataValue(info.Metadata, chunkedMetadata)
+
+	return !chunked && !encrypted(info) && !compressed(info)
+}
+
+// uploadPartSize picks a part size big enough that an upload of size bytes fits in
+// the maximum number of parts.
+func uploadPartSize(size int64) int64 {
+	if size <= 0 {
+		return minUploadPartSize
+	}
+
+	partSize := (size + maxUploadParts - 1) / maxUploadParts
+	if partSize < minUploadPartSize {
+		return minUploadPartSize
+	}
+
+	return partSize
+}
+
+// partHasher computes the MD5 of everything written to it, as well as the MD5 of each
+// partSize chunk, so that it can check both single PUT and multipart ETags.
+type partHasher struct {
+	partSize int64
+	total    int64
+
+	whole     hash.Hash
+	part      hash.Hash
+	partBytes int64
+	partSums  [][]byte
+}
+
+func newPartHasher(partSize int64) *partHasher {
+	return &partHasher{
+		partSize: partSize,
+		whole:    md5.New(),
+		part:     md5.New(),
+	}
+}
+
+func (p *partHasher) Write(buf []byte) (int, error) {
+	written := len(buf)
+	p.whole.Write(buf)
+	p.total += int64(written)
+
+	for len(buf) > 0 {
+		n := p.partSize - p.partBytes
+		if n > int64(len(buf)) {
+			n = int64(len(buf))
+		}
+
+		p.part.Write(buf[:n])
+		p.partBytes += n
+		buf = buf[n:]
+
+		if p.partBytes == p.partSize {
+			p.partSums = append(p.partSums, p.part.Sum(nil))
+			p.part.Reset()
+			p.partBytes = 0
+		}
+	}
+
+	return written, nil
+}
+
+// matches reports whether etag is the ETag S3 would give the data written so far.
+// Multipart ETags are the MD5 of the concatenated part MD5s, followed by a dash and
+// the number of parts.
+func (p *partHasher) matches(etag string) bool {
+	etag = strings.Trim(etag, `"`)
+
+	i := strings.LastIndex(etag, "-")
+	if i < 0 {
+		return etag == hex.EncodeToString(p.whole.Sum(nil))
+	}
+
+	n, err := strconv.Atoi(etag[i+1:])
+	if err != nil {
+		return false
+	}
+
+	sums := append([][]byte{}, p.partSums...)
+	if p.partBytes > 0 {
+		sums = append(sums, p.part.Sum(nil))
+	}
+
+	// a body that is an exact multiple of the part size can end with an empty part
+	for len(sums) < n {
+		empty := md5.Sum(nil)
+		sums = append(sums, empty[:])
+	}
+
+	if len(sums) != n {
+		return false
+	}
+
+	all := md5.New()
+	for _, sum := range sums {
+		all.Write(sum)
+	}
+
+	return etag[:i] == hex.EncodeToString(all.Sum(nil))
+}
@@ -0,0 +1,80 @@
+package s3fs
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestUploadPartSize(t *testing.T) {
+	require.Equal(t, int64(minUploadPartSize), uploadPartSize(-1))
+	require.Equal(t, int64(minUploadPartSize), uploadPartSize(100))
+	require.Equal(t, int64(minUploadPartSize), uploadPartSize(minUploadPartSize*maxUploadParts))
+	require.Equal(t, int64(minUploadPartSize+1), uploadPartSize(minUploadPartSize*maxUploadParts+maxUploadParts))
+}
+
+func TestPartHasher(t *testing.T) {
+	data := bytes.Repeat([]byte("abc"), 10)
+
+	h := newPartHasher(8)
+	n, err := h.Write(data[:5])
+	require.Nil(t, err)
+	require.Equal(t, 5, n)
+	h.Write(data[5:])
+
+	whole := md5.Sum(data)
+	require.True(t, h.matches(`"`+hex.EncodeToString(whole[:])+`"`))
+
+	all := md5.New()
+	for i := 0; i < len(data); i += 8 {
+		end := i + 8
+		if end > len(data) {
+			end = len(data)
+		}
+
+		sum := md5.Sum(data[i:end])
+		all.Write(sum[:])
+	}
+
+	multipart := hex.EncodeToString(all.Sum(nil)) + "-4"
+	require.True(t, h.matches(`"`+multipart+`"`))
+	require.False(t, h.matches(`"`+multipart[:len(multipart)-1]+`3"`))
+	require.False(t, h.matches(`"d41d8cd98f00b204e9800998ecf8427e"`))
+}
+
+func TestLocalMatches_Multipart(t *testing.T) {
+	// uploaded by a client using 8 MiB parts
+	data := bytes.Repeat([]byte("0123456789abcdef"), (20<<20)/16)
+
+	all := md5.New()
+	for i := 0; i < len(data); i += 8 << 20 {
+		end := i + 8<<20
+		if end > len(data) {
+			end = len(data)
+		}
+
+		sum := md5.Sum(data[i:end])
+		all.Write(sum[:])
+	}
+
+	etag := `"` + hex.EncodeToString(all.Sum(nil)) + `-3"`
+
+	localPath := filepath.Join(t.TempDir(), "data")
+	require.Nil(t, os.WriteFile(localPath, data, 0644))
+
+	same, err := localMatches(localPath, ObjectInfo{Size: int64(len(data)), ETag: etag})
+	require.Nil(t, err)
+	require.True(t, same)
+
+	data[len(data)-1] = 'x'
+	require.Nil(t, os.WriteFile(localPath, data, 0644))
+
+	same, err = localMatches(localPath, ObjectInfo{Size: int64(len(data)), ETag: etag})
+	require.Nil(t, err)
+	require.False(t, same)
+}
@@ -0,0 +1,49 @@
+package s3fs
+
+import (
+	"context"
+	"time"
+)
+
+// staleUploadAborter is implemented by stores that can leave incomplete uploads behind.
+type staleUploadAborter interface {
+	AbortStaleUploads(ctx context.Context, prefix string, olderThan time.Duration) (int, error)
+}
+
+// AbortStaleUploads aborts any incomplete multipart uploads in the FS that were started
+// more than olderThan ago, and returns how many it aborted. Writers that crash in the
+// middle of a multipart upload leave its parts behind, and they are billed for until
+// the upload is aborted even though they don't show up in listings. Stores that
+// don't do multipart uploads have nothing to abort.
+func (s *S3FS) AbortStaleUploads(ctx context.Context, olderThan time.Duration) (int, error) {
+	if s.bucketErr != nil {
+		return 0, s.bucketErr
+	}
+
+	if s.readOnly {
+		return 0, ErrReadOnly
+	}
+
+	aborter, ok := s.baseStore().(staleUploadAborter)
+	if !ok {
+		return 0, nil
+	}
+
+	return aborter.AbortStaleUploads(ctx, "", olderThan)
+}
+
+// WithAbortStaleUploadsOnStart runs AbortStaleUploads in the background when the FS
+// is created. onErr, if not nil, is called if it fails.
+func WithAbortStaleUploadsOnStart(olderThan time.Duration, onErr func(error)) Option {
+	return func(s *S3FS) {
+		s.startHooks = append(s.startHooks, func() {
+			go func() {
+				_, err := s.AbortStaleUploads(s.background, olderThan)
+				// being stopped by Close isn't a failure
+				if err != nil && onErr != nil && s.background.Err() == nil {
+					onErr(err)
+				}
+			}()
+		})
+	}
+}
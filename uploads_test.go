@@ -0,0 +1,37 @@
+package s3fs_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/packrat386/s3fs"
+	"github.com/packrat386/s3fs/s3fstest"
+	"github.com/stretchr/testify/require"
+)
+
+type abortingStore struct {
+	*s3fstest.MemStore
+	calls chan time.Duration
+}
+
+func (a *abortingStore) AbortStaleUploads(ctx context.Context, prefix string, olderThan time.Duration) (int, error) {
+	a.calls <- olderThan
+	return 3, nil
+}
+
+func TestAbortStaleUploads(t *testing.T) {
+	n, err := s3fs.NewFS(s3fstest.NewMemStore()).AbortStaleUploads(context.Background(), time.Hour)
+	require.Nil(t, err)
+	require.Equal(t, 0, n)
+
+	store := &abortingStore{MemStore: s3fstest.NewMemStore(), calls: make(chan time.Duration, 2)}
+
+	myFS := s3fs.NewFS(store, s3fs.WithAbortStaleUploadsOnStart(24*time.Hour, nil))
+	require.Equal(t, 24*time.Hour, <-store.calls)
+
+	n, err = myFS.AbortStaleUploads(context.Background(), time.Hour)
+	require.Nil(t, err)
+	require.Equal(t, 3, n)
+	require.Equal(t, time.Hour, <-store.calls)
+}
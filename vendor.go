@@ -0,0 +1,136 @@
+package s3fs
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// SnapshotToDir copies every file under the directory prefix ("." for the whole
+// bucket) into localDir, for vendoring remote assets into a build. The files are
+// listed once into a Manifest and then read through a PinnedFS, so the copy is
+// exactly the listed content even if the prefix changes in the meantime: a file that
+// changes fails the snapshot with an error wrapping ErrContentChanged. The manifest is
+// returned so it can be recorded next to the copy.
+func (s *S3FS) SnapshotToDir(ctx context.Context, prefix string, localDir string) (*Manifest, error) {
+	return s.snapshot(ctx, prefix, func(rel string, size int64, r io.Reader) error {
+		localPath := filepath.Join(localDir, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(localPath), 0755); err != nil {
+			return fmt.Errorf("could not create directory for %s: %w", localPath, err)
+		}
+
+		f, err := os.Create(localPath)
+		if err != nil {
+			return fmt.Errorf("could not create %s: %w", localPath, err)
+		}
+
+		if _, err := io.Copy(f, r); err != nil {
+			f.Close()
+			return fmt.Errorf("could not write %s: %w", localPath, err)
+		}
+
+		return f.Close()
+	})
+}
+
+// SnapshotToTar writes every file under the directory prefix ("." for the whole
+// bucket) to w as a tar archive, read consistently as SnapshotToDir does. The archive
+// is reproducible: entries are in path order, and their times, owners and modes are
+// fixed, so the same content always makes the same bytes.
+func (s *S3FS) SnapshotToTar(ctx context.Context, prefix string, w io.Writer) (*Manifest, error) {
+	tw := tar.NewWriter(w)
+
+	m, err := s.snapshot(ctx, prefix, func(rel string, size int64, r io.Reader) error {
+		err := tw.WriteHeader(&tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     rel,
+			Size:     size,
+			Mode:     0644,
+			ModTime:  time.Unix(0, 0),
+			Format:   tar.FormatPAX,
+		})
+		if err != nil {
+			return fmt.Errorf("could not write tar header for %s: %w", rel, err)
+		}
+
+		if _, err := io.Copy(tw, r); err != nil {
+			return fmt.Errorf("could not write %s to tar: %w", rel, err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tw.Close(); err != nil {
+		return nil, fmt.Errorf("could not finish tar: %w", err)
+	}
+
+	return m, nil
+}
+
+// snapshot lists prefix into a Manifest and calls fn with each file in it, by its path
+// relative to prefix, as pinned by the manifest.
+func (s *S3FS) snapshot(ctx context.Context, prefix string, fn func(rel string, size int64, r io.Reader) error) (*Manifest, error) {
+	m, err := s.GenerateManifest(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	pinned := NewPinnedFS(s, m.ETags())
+
+	for _, e := range m.Entries {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		rel := e.Path
+		if dir := path.Clean(prefix); dir != "." {
+			rel = strings.TrimPrefix(e.Path, dir+"/")
+		}
+
+		f, err := pinned.Open(e.Path)
+		if err != nil {
+			return nil, fmt.Errorf("could not snapshot %s: %w", e.Path, err)
+		}
+
+		err = snapshotFile(rel, f, fn)
+		f.Close()
+
+		if err != nil {
+			return nil, fmt.Errorf("could not snapshot %s: %w", e.Path, err)
+		}
+	}
+
+	return m, nil
+}
+
+// snapshotFile calls fn with the content of f and its size, which is the size of the
+// file rather than of the object if the FS compresses or encrypts it.
+func snapshotFile(rel string, f fs.File, fn func(rel string, size int64, r io.Reader) error) error {
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	if info.Size() >= 0 {
+		return fn(rel, info.Size(), f)
+	}
+
+	// compressed files whose size wasn't recorded
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return err
+	}
+
+	return fn(rel, int64(len(data)), bytes.NewReader(data))
+}
@@ -0,0 +1,76 @@
+package s3fs_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/packrat386/s3fs"
+	"github.com/packrat386/s3fs/s3fstest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnapshotToDir(t *testing.T) {
+	store := s3fstest.NewMemStore()
+	store.WriteFile("assets/logo.svg", "<svg/>")
+	store.WriteFile("assets/fonts/a.woff", "font")
+	store.WriteFile("other.txt", "not included")
+
+	myFS := s3fs.NewFS(store)
+	dir := t.TempDir()
+
+	m, err := myFS.SnapshotToDir(context.Background(), "assets", dir)
+	require.Nil(t, err)
+	require.Len(t, m.Entries, 2)
+
+	data, err := os.ReadFile(filepath.Join(dir, "fonts", "a.woff"))
+	require.Nil(t, err)
+	require.Equal(t, "font", string(data))
+
+	_, err = os.Stat(filepath.Join(dir, "other.txt"))
+	require.True(t, os.IsNotExist(err))
+}
+
+func TestSnapshotToTar(t *testing.T) {
+	store := s3fstest.NewMemStore()
+	store.WriteFile("assets/b.txt", "bbb")
+	store.WriteFile("assets/a.txt", "a")
+
+	myFS := s3fs.NewFS(store)
+
+	first := &bytes.Buffer{}
+	_, err := myFS.SnapshotToTar(context.Background(), "assets", first)
+	require.Nil(t, err)
+
+	// the same content makes the same archive
+	store.WriteFile("assets/a.txt", "a")
+
+	second := &bytes.Buffer{}
+	_, err = myFS.SnapshotToTar(context.Background(), "assets", second)
+	require.Nil(t, err)
+	require.Equal(t, first.Bytes(), second.Bytes())
+
+	tr := tar.NewReader(first)
+	files := map[string]string{}
+	names := []string{}
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		require.Nil(t, err)
+
+		data, err := io.ReadAll(tr)
+		require.Nil(t, err)
+
+		files[hdr.Name] = string(data)
+		names = append(names, hdr.Name)
+	}
+
+	require.Equal(t, []string{"a.txt", "b.txt"}, names)
+	require.Equal(t, map[string]string{"a.txt": "a", "b.txt": "bbb"}, files)
+}
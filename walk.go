@@ -0,0 +1,163 @@
+package s3fs
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// WalkParallelFunc is called by WalkParallel for each file. It is called from
+// several goroutines at once, so it must be safe for concurrent use.
+type WalkParallelFunc func(name string, info fs.FileInfo) error
+
+// WalkParallelError is returned by WalkParallel when walking some of the
+// directories failed. Errors are keyed by the directory that was being walked.
+type WalkParallelError struct {
+	Errors map[string]error
+}
+
+func (e *WalkParallelError) Error() string {
+	dirs := make([]string, 0, len(e.Errors))
+	for dir := range e.Errors {
+		dirs = append(dirs, dir)
+	}
+
+	sort.Strings(dirs)
+
+	msgs := make([]string, 0, len(dirs))
+	for _, dir := range dirs {
+		msgs = append(msgs, fmt.Sprintf("%s: %s", dir, e.Errors[dir]))
+	}
+
+	return fmt.Sprintf("walking %d directories failed: %s", len(dirs), strings.Join(msgs, "; "))
+}
+
+// WalkParallel calls fn for every file under the directory root, using workers
+// goroutines (zero means the WithConcurrency setting). Each subdirectory of root is
+// walked by one worker with a flat listing, which needs one request per 1000 files
+// no matter how deeply they are nested, and the files directly in root are walked
+// while the workers run. Files are visited in order within a subdirectory, but
+// subdirectories are walked concurrently.
+//
+// If fn returns an error, or listing fails, the rest of that subdirectory is
+// skipped and the walk carries on with the others. All failures are returned in a
+// *WalkParallelError. If ctx is cancelled, subdirectories that haven't been started
+// yet fail with the context's error.
+func (s *S3FS) WalkParallel(ctx context.Context, root string, workers int, fn WalkParallelFunc) error {
+	files := []ObjectInfo{}
+	dirs := []string{}
+
+	err := s.listChildren(ctx, root, ListOptions{}, func(page *ListPage) bool {
+		files = append(files, page.Objects...)
+		dirs = append(dirs, page.CommonPrefixes...)
+
+		return true
+	})
+
+	if err != nil {
+		return err
+	}
+
+	if workers <= 0 {
+		workers = s.concurrency()
+	}
+
+	work := make(chan string)
+	errs := map[string]error{}
+	mu := sync.Mutex{}
+	wg := sync.WaitGroup{}
+
+	fail := func(dir string, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		errs[dir] = err
+	}
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for prefix := range work {
+				if err := s.walkPrefix(ctx, prefix, fn); err != nil {
+					fail(strings.TrimSuffix(prefix, "/"), err)
+				}
+			}
+		}()
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		if err := s.walkObjects(files, fn); err != nil {
+			fail(root, err)
+		}
+	}()
+
+	for _, prefix := range dirs {
+		if ctx.Err() != nil {
+			fail(strings.TrimSuffix(prefix, "/"), ctx.Err())
+			continue
+		}
+
+		work <- prefix
+	}
+
+	close(work)
+	wg.Wait()
+
+	if len(errs) > 0 {
+		return &WalkParallelError{Errors: errs}
+	}
+
+	return nil
+}
+
+// walkPrefix calls fn for every file under prefix, using a flat listing.
+func (s *S3FS) walkPrefix(ctx context.Context, prefix string, fn WalkParallelFunc) error {
+	var fnErr error
+
+	err := s.store.List(ctx, prefix, ListOptions{}, func(page *ListPage) bool {
+		fnErr = s.walkObjects(page.Objects, fn)
+		return fnErr == nil
+	})
+
+	if err != nil {
+		return fmt.Errorf("could not list s3 objects: %w", err)
+	}
+
+	return fnErr
+}
+
+// walkObjects calls fn for each of objects, leaving out directory markers and
+// objects hidden by WithSkipStorageClasses.
+func (s *S3FS) walkObjects(objects []ObjectInfo, fn WalkParallelFunc) error {
+	for _, obj := range objects {
+		obj := obj
+		if strings.HasSuffix(obj.Key, "/") || s.skipStorageClasses[obj.StorageClass] {
+			continue
+		}
+
+		err := fn(obj.Key, &s3FileInfo{
+			name:    path.Base(obj.Key),
+			mode:    fs.FileMode(0400),
+			size:    obj.Size,
+			modTime: obj.LastModified,
+			object:  &obj,
+			key:     obj.Key,
+			loc:     s.location,
+		})
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,101 @@
+package s3fs_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/packrat386/s3fs"
+	"github.com/packrat386/s3fs/s3fstest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWalkParallel(t *testing.T) {
+	store := s3fstest.NewMemStore()
+	store.PageSize = 2
+	store.WriteFile("top.txt", "top")
+	store.WriteFile("a/1.txt", "1")
+	store.WriteFile("a/b/2.txt", "22")
+	store.WriteFile("a/b/c/3.txt", "333")
+	store.WriteFile("d/", "")
+	store.WriteFile("d/4.txt", "4444")
+	store.WriteFile("e/5.txt", "55555")
+
+	myFS := s3fs.NewFS(store)
+
+	mu := sync.Mutex{}
+	got := map[string]int64{}
+
+	err := myFS.WalkParallel(context.Background(), ".", 2, func(name string, info fs.FileInfo) error {
+		mu.Lock()
+		defer mu.Unlock()
+
+		got[name] = info.Size()
+		return nil
+	})
+
+	require.Nil(t, err)
+	require.Equal(t, map[string]int64{
+		"top.txt":     3,
+		"a/1.txt":     1,
+		"a/b/2.txt":   2,
+		"a/b/c/3.txt": 3,
+		"d/4.txt":     4,
+		"e/5.txt":     5,
+	}, got)
+
+	names := []string{}
+	err = myFS.WalkParallel(context.Background(), "a", 0, func(name string, info fs.FileInfo) error {
+		mu.Lock()
+		defer mu.Unlock()
+
+		names = append(names, name)
+		return nil
+	})
+
+	require.Nil(t, err)
+	sort.Strings(names)
+	require.Equal(t, []string{"a/1.txt", "a/b/2.txt", "a/b/c/3.txt"}, names)
+}
+
+func TestWalkParallel_Errors(t *testing.T) {
+	store := s3fstest.NewMemStore()
+	store.WriteFile("top.txt", "top")
+	store.WriteFile("a/1.txt", "1")
+	store.WriteFile("a/2.txt", "2")
+	store.WriteFile("b/3.txt", "3")
+	store.WriteFile("c/4.txt", "4")
+
+	myFS := s3fs.NewFS(store)
+
+	mu := sync.Mutex{}
+	visited := []string{}
+
+	err := myFS.WalkParallel(context.Background(), ".", 2, func(name string, info fs.FileInfo) error {
+		mu.Lock()
+		defer mu.Unlock()
+
+		visited = append(visited, name)
+		if name == "a/1.txt" || name == "top.txt" || name == "c/4.txt" {
+			return fmt.Errorf("bad file %s", name)
+		}
+
+		return nil
+	})
+
+	var walkErr *s3fs.WalkParallelError
+	require.True(t, errors.As(err, &walkErr))
+	require.Equal(t, map[string]error{
+		".": fmt.Errorf("bad file top.txt"),
+		"a": fmt.Errorf("bad file a/1.txt"),
+		"c": fmt.Errorf("bad file c/4.txt"),
+	}, walkErr.Errors)
+	require.Equal(t, "walking 3 directories failed: .: bad file top.txt; a: bad file a/1.txt; c: bad file c/4.txt", err.Error())
+
+	sort.Strings(visited)
+	require.Equal(t, []string{"a/1.txt", "b/3.txt", "c/4.txt", "top.txt"}, visited)
+}
@@ -0,0 +1,389 @@
+package s3fs
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"sync"
+)
+
+// UploadHooks are called at points in the lifecycle of uploads made through the FS.
+// Any of them may be nil.
+type UploadHooks struct {
+	// PartUploaded is called each time a part of name has been stored. Small files are
+	// uploaded as a single part. It may be called concurrently for the same upload.
+	PartUploaded func(name string, partNumber int, partBytes int64)
+
+	// Completed is called once name has been stored in full.
+	Completed func(name string, totalBytes int64)
+
+	// Aborted is called when the upload of name fails or is abandoned, after
+	// writtenBytes were written to it.
+	Aborted func(name string, writtenBytes int64, err error)
+}
+
+// Writer is a file being written to the FS. Small files are buffered in memory and
+// uploaded on Close (or Sync). Once more than 5 MiB has been written the Writer
+// switches to streaming the data to a multipart upload. Nothing is visible at name
+// until Close or Sync returns successfully.
+type Writer struct {
+	name  string
+	hooks UploadHooks
+	quota *writeQuota
+
+	// upload stores everything read from r at name
+	upload func(r io.Reader) error
+
+	// syncable is whether upload can be called more than once with everything written
+	// so far, to make it visible before Close
+	syncable bool
+
+	buf     bytes.Buffer
+	dirty   bool
+	synced  bool
+	written int64
+
+	// set once streaming
+	pw   *io.PipeWriter
+	done chan struct{}
+	err  error
+
+	closeOnce sync.Once
+	closeErr  error
+}
+
+// writerBufferSize is how much a Writer buffers before it starts streaming.
+const writerBufferSize = minUploadPartSize
+
+// Create starts writing a new file at name, replacing anything already there once
+// the Writer is closed.
+func (s *S3FS) Create(name string) (*Writer, error) {
+	key, err := s.writableKey("create", name)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := context.Background()
+
+	if s.writeBack != nil {
+		return s.newWriter(key, true, func(r io.Reader) error {
+			return s.writeBack.stage(key, r)
+		})
+	}
+
+	if s.atomicPrefix != "" {
+		// the file must not change between now and when we're done
+		conds, err := s.atomicConds(ctx, key)
+		if err != nil {
+			return nil, fmt.Errorf("could not stat %s: %w", key, err)
+		}
+
+		return s.newWriter(key, false, func(r io.Reader) error {
+			body, opts, err := s.encode(ctx, r, s.putOptions(key))
+			if err != nil {
+				return err
+			}
+
+			_, err = s.putAtomic(ctx, key, body, opts, conds)
+			return err
+		})
+	}
+
+	return s.newWriter(key, true, func(r io.Reader) error {
+		_, err := s.put(ctx, key, r, s.putOptions(key))
+		return err
+	})
+}
+
+func (s *S3FS) newWriter(key string, syncable bool, upload func(r io.Reader) error) (*Writer, error) {
+	if err := s.quota.reserve(1, 0); err != nil {
+		return nil, fmt.Errorf("could not write %s: %w", key, err)
+	}
+
+	return &Writer{
+		name:     key,
+		hooks:    s.uploadHooks,
+		quota:    s.quota,
+		upload:   upload,
+		syncable: syncable,
+		dirty:    true,
+	}, nil
+}
+
+// Write writes buf to the file.
+func (w *Writer) Write(buf []byte) (int, error) {
+	if err := w.quota.reserve(0, int64(len(buf))); err != nil {
+		return 0, fmt.Errorf("could not write %s: %w", w.name, err)
+	}
+
+	if w.pw != nil {
+		n, err := w.pw.Write(buf)
+		w.written += int64(n)
+
+		return n, err
+	}
+
+	n, _ := w.buf.Write(buf)
+	w.written += int64(n)
+	w.dirty = true
+
+	if w.buf.Len() > writerBufferSize {
+		if err := w.stream(); err != nil {
+			return n, err
+		}
+	}
+
+	return n, nil
+}
+
+// stream starts the upload in the background and switches the Writer to writing
+// straight to it.
+func (w *Writer) stream() error {
+	pr, pw := io.Pipe()
+	w.pw = pw
+	w.done = make(chan struct{})
+
+	go func() {
+		defer close(w.done)
+
+		err := w.upload(pr)
+		pr.CloseWithError(err)
+		w.err = err
+	}()
+
+	_, err := pw.Write(w.buf.Bytes())
+	w.buf = bytes.Buffer{}
+
+	return err
+}
+
+// Sync makes sure everything written so far is stored. For files small enough to
+// still be buffered this uploads them now, so they are visible at name before
+// Close. Once a Writer is streaming, Sync only waits for the data to be handed to
+// the upload: S3 can't make part of a multipart upload visible, so the file
+// appears at name on Close. Sync does nothing for atomic writes or appends, which
+// only ever become visible on Close.
+func (w *Writer) Sync() error {
+	if w.pw != nil || !w.syncable || !w.dirty {
+		return nil
+	}
+
+	if err := w.upload(bytes.NewReader(w.buf.Bytes())); err != nil {
+		return fmt.Errorf("could not upload %s: %w", w.name, err)
+	}
+
+	w.dirty = false
+	w.synced = true
+
+	return nil
+}
+
+// Close finishes the upload and reports whether it succeeded.
+func (w *Writer) Close() error {
+	w.closeOnce.Do(func() {
+		var err error
+
+		if w.pw != nil {
+			w.pw.Close()
+			<-w.done
+			err = w.err
+		} else if w.dirty {
+			err = w.upload(bytes.NewReader(w.buf.Bytes()))
+		}
+
+		if err != nil {
+			w.closeErr = fmt.Errorf("could not upload %s: %w", w.name, err)
+			w.aborted(w.closeErr)
+			return
+		}
+
+		if w.hooks.Completed != nil {
+			w.hooks.Completed(w.name, w.written)
+		}
+	})
+
+	return w.closeErr
+}
+
+// Abort abandons the upload. Nothing more is written to name, though anything already
+// stored by Sync stays there.
+func (w *Writer) Abort() error {
+	w.closeOnce.Do(func() {
+		if w.pw != nil {
+			w.pw.CloseWithError(errAborted)
+			<-w.done
+		}
+
+		w.closeErr = errAborted
+		w.aborted(errAborted)
+	})
+
+	if w.closeErr == errAborted {
+		return nil
+	}
+
+	return w.closeErr
+}
+
+func (w *Writer) aborted(err error) {
+	// anything Sync stored is still there, so it still counts
+	if !w.synced {
+		w.quota.release(1, w.written)
+	}
+
+	if w.hooks.Aborted != nil {
+		w.hooks.Aborted(w.name, w.written, err)
+	}
+}
+
+var errAborted = fmt.Errorf("upload aborted")
+
+// WriteFile writes data to name, replacing anything already there.
+func (s *S3FS) WriteFile(name string, data []byte) error {
+	w, err := s.Create(name)
+	if err != nil {
+		return err
+	}
+
+	if _, err := w.Write(data); err != nil {
+		w.Abort()
+		return err
+	}
+
+	return w.Close()
+}
+
+// WriteFileIf writes data to name only if name hasn't changed since it was read,
+// returning an error wrapping ErrPreconditionFailed if it has. expectedETag is the
+// ETag name had when it was read, from the ObjectInfo returned by the Sys method of
+// its fs.FileInfo; if it is empty, name must not exist yet. This makes
+// read-modify-write of shared objects safe against concurrent writers.
+func (s *S3FS) WriteFileIf(name string, data []byte, expectedETag string) error {
+	key, err := s.writableKey("write", name)
+	if err != nil {
+		return err
+	}
+
+	// a chunked file is several objects, which can't be replaced in one conditional put
+	if s.chunkSize > 0 {
+		return fmt.Errorf("could not write %s: chunked files can't be written conditionally", key)
+	}
+
+	if err := s.quota.reserve(1, int64(len(data))); err != nil {
+		return fmt.Errorf("could not write %s: %w", key, err)
+	}
+
+	opts := s.putOptions(key)
+	if expectedETag == "" {
+		opts.IfNoneMatch = "*"
+	} else {
+		opts.IfMatch = expectedETag
+	}
+
+	// the precondition is checked against what the staged writes leave behind
+	if s.writeBack != nil {
+		s.writeBack.wait(key)
+	}
+
+	body, opts, err := s.encode(context.Background(), bytes.NewReader(data), opts)
+
+	var info ObjectInfo
+	if err == nil {
+		info, err = s.store.Put(context.Background(), key, body, opts)
+	}
+
+	if err != nil {
+		s.quota.release(1, int64(len(data)))
+
+		err = fmt.Errorf("could not upload %s: %w", key, err)
+		if s.uploadHooks.Aborted != nil {
+			s.uploadHooks.Aborted(key, 0, err)
+		}
+
+		return err
+	}
+
+	s.mutated("put", key, "", info.ETag)
+
+	if s.uploadHooks.Completed != nil {
+		s.uploadHooks.Completed(key, int64(len(data)))
+	}
+
+	return nil
+}
+
+// Remove deletes the file at name. Removing a file that doesn't exist is not an error.
+// Files protected by Object Lock aren't removed, and an *ObjectLockedError is returned.
+func (s *S3FS) Remove(name string) error {
+	key, err := s.writableKey("remove", name)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+
+	if err := s.checkNotLocked(ctx, key); err != nil {
+		return err
+	}
+
+	if s.writeBack != nil {
+		s.writeBack.forget(key)
+	}
+
+	var chunks *chunkManifest
+	if s.chunkSize > 0 {
+		if chunks, err = s.chunkManifest(ctx, key); err != nil {
+			return err
+		}
+	}
+
+	if err := s.store.Delete(ctx, key); err != nil {
+		return fmt.Errorf("could not delete %s: %w", key, err)
+	}
+
+	s.mutated("delete", key, "", "")
+	s.deleteChunks(chunks)
+
+	return nil
+}
+
+// putOptions returns the PutOptions for uploading key.
+func (s *S3FS) putOptions(key string) PutOptions {
+	opts := PutOptions{}
+
+	if s.uploadHooks.PartUploaded != nil {
+		opts.PartUploaded = func(partNumber int, partBytes int64) {
+			s.uploadHooks.PartUploaded(key, partNumber, partBytes)
+		}
+	}
+
+	return opts
+}
+
+// writableKey validates name as something that can be written to by op.
+func (s *S3FS) writableKey(op, name string) (string, error) {
+	if s.bucketErr != nil {
+		return "", s.bucketErr
+	}
+
+	if s.readOnly {
+		return "", ErrReadOnly
+	}
+
+	if err := s.checkOpen(); err != nil {
+		return "", err
+	}
+
+	key, err := s.key(op, name)
+	if err != nil {
+		return "", err
+	}
+
+	if key == "" {
+		return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+
+	return key, nil
+}
@@ -0,0 +1,189 @@
+package s3fs_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/fs"
+	"strings"
+	"testing"
+
+	"github.com/packrat386/s3fs"
+	"github.com/packrat386/s3fs/s3fstest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteFile(t *testing.T) {
+	store := s3fstest.NewMemStore()
+	myFS := s3fs.NewFS(store)
+
+	require.Nil(t, myFS.WriteFile("dir/foo.txt", []byte("hello")))
+
+	data, err := fs.ReadFile(myFS, "dir/foo.txt")
+	require.Nil(t, err)
+	require.Equal(t, "hello", string(data))
+
+	require.Nil(t, myFS.Remove("dir/foo.txt"))
+
+	_, err = myFS.Open("dir/foo.txt")
+	require.NotNil(t, err)
+
+	require.NotNil(t, myFS.WriteFile(".", []byte("root")))
+	require.NotNil(t, myFS.WriteFile("/abs", []byte("abs")))
+}
+
+func TestCreate_Hooks(t *testing.T) {
+	parts := 0
+	var completed int64
+	var aborted []string
+
+	myFS := s3fs.NewFS(
+		s3fstest.NewMemStore(),
+		s3fs.WithUploadHooks(s3fs.UploadHooks{
+			PartUploaded: func(name string, partNumber int, partBytes int64) {
+				require.Equal(t, "foo.txt", name)
+				parts++
+			},
+			Completed: func(name string, totalBytes int64) {
+				require.Equal(t, "foo.txt", name)
+				completed = totalBytes
+			},
+			Aborted: func(name string, writtenBytes int64, err error) {
+				aborted = append(aborted, name)
+			},
+		}),
+	)
+
+	w, err := myFS.Create("foo.txt")
+	require.Nil(t, err)
+
+	_, err = w.Write([]byte("hello "))
+	require.Nil(t, err)
+	_, err = w.Write([]byte("world"))
+	require.Nil(t, err)
+	require.Nil(t, w.Close())
+
+	require.Equal(t, 1, parts)
+	require.Equal(t, int64(11), completed)
+
+	w, err = myFS.Create("bar.txt")
+	require.Nil(t, err)
+
+	_, err = w.Write([]byte("partial"))
+	require.Nil(t, err)
+	require.Nil(t, w.Abort())
+
+	require.Equal(t, []string{"bar.txt"}, aborted)
+
+	_, err = myFS.Open("bar.txt")
+	require.NotNil(t, err)
+}
+
+func TestUploadFrom(t *testing.T) {
+	myFS := s3fs.NewFS(s3fstest.NewMemStore())
+
+	err := myFS.UploadFrom(context.Background(), "foo.txt", strings.NewReader("hello world"), 11)
+	require.Nil(t, err)
+
+	data, err := fs.ReadFile(myFS, "foo.txt")
+	require.Nil(t, err)
+	require.Equal(t, "hello world", string(data))
+
+	err = myFS.UploadFrom(context.Background(), "short.txt", strings.NewReader("hello"), 11)
+	require.NotNil(t, err)
+	require.Contains(t, err.Error(), "expected 11 bytes but read 5")
+
+	err = myFS.UploadFrom(context.Background(), "unknown.txt", strings.NewReader("hello"), -1)
+	require.Nil(t, err)
+}
+
+func TestAppend(t *testing.T) {
+	myFS := s3fs.NewFS(s3fstest.NewMemStore())
+
+	w, err := myFS.Append("log.txt")
+	require.Nil(t, err)
+	_, err = w.Write([]byte("one\n"))
+	require.Nil(t, err)
+	require.Nil(t, w.Close())
+
+	w, err = myFS.Append("log.txt")
+	require.Nil(t, err)
+	_, err = w.Write([]byte("two\n"))
+	require.Nil(t, err)
+	require.Nil(t, w.Close())
+
+	data, err := fs.ReadFile(myFS, "log.txt")
+	require.Nil(t, err)
+	require.Equal(t, "one\ntwo\n", string(data))
+}
+
+func TestWriteFileIf(t *testing.T) {
+	myFS := s3fs.NewFS(s3fstest.NewMemStore())
+
+	require.Nil(t, myFS.WriteFileIf("state.json", []byte(`{"n":1}`), ""))
+
+	err := myFS.WriteFileIf("state.json", []byte(`{"n":1}`), "")
+	require.True(t, errors.Is(err, s3fs.ErrPreconditionFailed))
+
+	info, err := fs.Stat(myFS, "state.json")
+	require.Nil(t, err)
+	etag := info.Sys().(s3fs.ObjectInfo).ETag
+	require.NotEqual(t, "", etag)
+
+	require.Nil(t, myFS.WriteFileIf("state.json", []byte(`{"n":2}`), etag))
+
+	// etag is stale now
+	err = myFS.WriteFileIf("state.json", []byte(`{"n":3}`), etag)
+	require.True(t, errors.Is(err, s3fs.ErrPreconditionFailed))
+
+	data, err := fs.ReadFile(myFS, "state.json")
+	require.Nil(t, err)
+	require.Equal(t, `{"n":2}`, string(data))
+}
+
+func TestWriter_Sync(t *testing.T) {
+	myFS := s3fs.NewFS(s3fstest.NewMemStore())
+
+	w, err := myFS.Create("app.log")
+	require.Nil(t, err)
+
+	_, err = w.Write([]byte("line one\n"))
+	require.Nil(t, err)
+
+	_, err = myFS.Open("app.log")
+	require.NotNil(t, err)
+
+	require.Nil(t, w.Sync())
+
+	data, err := fs.ReadFile(myFS, "app.log")
+	require.Nil(t, err)
+	require.Equal(t, "line one\n", string(data))
+
+	_, err = w.Write([]byte("line two\n"))
+	require.Nil(t, err)
+	require.Nil(t, w.Close())
+
+	data, err = fs.ReadFile(myFS, "app.log")
+	require.Nil(t, err)
+	require.Equal(t, "line one\nline two\n", string(data))
+}
+
+func TestWriter_Streaming(t *testing.T) {
+	myFS := s3fs.NewFS(s3fstest.NewMemStore())
+
+	w, err := myFS.Create("big.bin")
+	require.Nil(t, err)
+
+	chunk := bytes.Repeat([]byte("x"), 1<<20)
+	for i := 0; i < 7; i++ {
+		_, err = w.Write(chunk)
+		require.Nil(t, err)
+	}
+
+	require.Nil(t, w.Sync())
+	require.Nil(t, w.Close())
+
+	info, err := fs.Stat(myFS, "big.bin")
+	require.Nil(t, err)
+	require.Equal(t, int64(7<<20), info.Size())
+}
@@ -0,0 +1,603 @@
+package s3fs
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WithWriteBack makes writes through Create and WriteFile complete as soon as the
+// data is staged in a file under dir. Background workers (see WithConcurrency) then
+// upload staged files, retrying failures with backoff. Use Drain to wait for the
+// uploads to finish.
+//
+// Files with pending uploads can be opened by name and are read from the staging
+// area, but they don't show up in directory listings until they are uploaded. Upload
+// hooks see a write as completed once it is staged. Writes that aren't staged, like
+// WriteFileIf, Append and Copy, wait for the staged writes to the same file to be
+// uploaded first, and those that replace the file, like UploadFrom, drop them.
+func WithWriteBack(dir string) Option {
+	return func(s *S3FS) {
+		s.writeBack = &writeBack{
+			fs:       s,
+			dir:      dir,
+			latest:   map[string]string{},
+			inflight: map[string]string{},
+			failed:   map[string]string{},
+			errs:     map[string]error{},
+		}
+		s.writeBack.cond = sync.NewCond(&s.writeBack.mu)
+
+		s.startHooks = append(s.startHooks, s.writeBack.start)
+	}
+}
+
+// writeBackAttempts is how many times an upload is tried before it is given up on.
+const writeBackAttempts = 5
+
+// WriteBackError is returned by Drain when uploads have failed. The failed uploads
+// are left in the staging area for Recover.
+type WriteBackError struct {
+	Errors map[string]error
+}
+
+func (e *WriteBackError) Error() string {
+	names := make([]string, 0, len(e.Errors))
+	for name := range e.Errors {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	msgs := make([]string, 0, len(names))
+	for _, name := range names {
+		msgs = append(msgs, fmt.Sprintf("%s: %s", name, e.Errors[name]))
+	}
+
+	return fmt.Sprintf("%d uploads failed: %s", len(names), strings.Join(msgs, "; "))
+}
+
+// Drain waits until every staged write has been uploaded or given up on, or until
+// ctx is done. If any uploads failed since the last Drain it returns a
+// *WriteBackError describing them. Their staged data is kept, and can still be read,
+// until the file is written again or Recover queues it for another try. It does
+// nothing if write-back isn't enabled.
+func (s *S3FS) Drain(ctx context.Context) error {
+	if s.writeBack == nil {
+		return nil
+	}
+
+	return s.writeBack.drain(ctx)
+}
+
+type writeBack struct {
+	fs  *S3FS
+	dir string
+
+	journalMu sync.Mutex
+	journal   *os.File
+
+	// journalLines is how many entries the journal has had since it was compacted
+	journalLines int
+
+	mu   sync.Mutex
+	cond *sync.Cond
+
+	// latest is the newest staged file for each key that hasn't started uploading
+	latest map[string]string
+
+	// inflight is the staged file currently being uploaded for each key
+	inflight map[string]string
+
+	// failed is the staged file for each key whose upload was given up on, kept for
+	// Recover
+	failed map[string]string
+
+	// queue is the keys waiting to be uploaded, oldest first
+	queue []string
+
+	errs map[string]error
+
+	// stopped tells the workers to exit once the queue is empty
+	stopped bool
+}
+
+func (wb *writeBack) start() {
+	for i := 0; i < wb.fs.concurrency(); i++ {
+		go wb.work()
+	}
+}
+
+// stage copies r to a new file in the staging area and queues it for upload to key.
+func (wb *writeBack) stage(key string, r io.Reader) error {
+	if err := os.MkdirAll(wb.dir, 0700); err != nil {
+		return fmt.Errorf("could not create staging dir: %w", err)
+	}
+
+	f, err := os.CreateTemp(wb.dir, "s3fs-*")
+	if err != nil {
+		return fmt.Errorf("could not create staging file: %w", err)
+	}
+
+	_, err = io.Copy(f, r)
+	if err == nil {
+		err = f.Sync()
+	}
+
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		os.Remove(f.Name())
+		return fmt.Errorf("could not stage %s: %w", key, err)
+	}
+
+	if err := wb.record(journalEntry{Op: "stage", Key: key, File: filepath.Base(f.Name())}); err != nil {
+		os.Remove(f.Name())
+		return fmt.Errorf("could not stage %s: %w", key, err)
+	}
+
+	wb.enqueue(key, f.Name())
+
+	return nil
+}
+
+// enqueue queues staged for upload to key, and reports whether it wasn't already.
+func (wb *writeBack) enqueue(key string, staged string) bool {
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
+
+	// already queued or being uploaded, e.g. when Recover is called more than once
+	if wb.latest[key] == staged || wb.inflight[key] == staged {
+		return false
+	}
+
+	if failed, ok := wb.failed[key]; ok {
+		// retried, or superseded by a new write
+		if failed != staged {
+			wb.discard(failed)
+		}
+		delete(wb.failed, key)
+	}
+
+	if old, ok := wb.latest[key]; ok {
+		// superseded before it was uploaded, and the key is already queued
+		wb.discard(old)
+	} else if _, ok := wb.inflight[key]; !ok {
+		wb.queue = append(wb.queue, key)
+	}
+
+	wb.latest[key] = staged
+	wb.cond.Broadcast()
+
+	return true
+}
+
+func (wb *writeBack) work() {
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
+
+	for {
+		if len(wb.queue) == 0 {
+			if wb.stopped {
+				return
+			}
+
+			wb.cond.Wait()
+			continue
+		}
+
+		key := wb.queue[0]
+		wb.queue = wb.queue[1:]
+
+		staged := wb.latest[key]
+		delete(wb.latest, key)
+		wb.inflight[key] = staged
+
+		wb.mu.Unlock()
+		err := wb.upload(key, staged)
+		wb.mu.Lock()
+
+		delete(wb.inflight, key)
+
+		if err != nil {
+			wb.errs[key] = err
+		} else {
+			delete(wb.errs, key)
+		}
+
+		if _, ok := wb.latest[key]; ok {
+			// written again while we were uploading
+			wb.discard(staged)
+			wb.queue = append(wb.queue, key)
+		} else if err != nil {
+			// keep the data, and its journal entry, so Recover can try again
+			wb.failed[key] = staged
+		} else {
+			wb.discard(staged)
+		}
+
+		wb.cond.Broadcast()
+	}
+}
+
+func (wb *writeBack) upload(key string, staged string) error {
+	backoff := 100 * time.Millisecond
+
+	var err error
+	for attempt := 0; attempt < writeBackAttempts; attempt++ {
+		if attempt > 0 {
+			<-wb.fs.clock.After(backoff)
+			backoff *= 2
+		}
+
+		err = wb.uploadOnce(key, staged)
+		if err == nil {
+			return nil
+		}
+	}
+
+	return err
+}
+
+func (wb *writeBack) uploadOnce(key string, staged string) error {
+	f, err := os.Open(staged)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = wb.fs.put(context.Background(), key, f, wb.fs.putOptions(key))
+
+	return err
+}
+
+// forget drops any pending upload of key and waits for an in progress one to finish.
+func (wb *writeBack) forget(key string) {
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
+
+	if staged, ok := wb.failed[key]; ok {
+		wb.discard(staged)
+		delete(wb.failed, key)
+	}
+
+	if staged, ok := wb.latest[key]; ok {
+		wb.discard(staged)
+		delete(wb.latest, key)
+
+		for i, k := range wb.queue {
+			if k == key {
+				wb.queue = append(wb.queue[:i], wb.queue[i+1:]...)
+				break
+			}
+		}
+	}
+
+	for {
+		if _, ok := wb.inflight[key]; !ok {
+			break
+		}
+
+		wb.cond.Wait()
+	}
+
+	delete(wb.errs, key)
+}
+
+// wait waits until nothing is staged for key or being uploaded to it, so that a write
+// that doesn't go through the staging area lands after the staged ones rather than
+// being replaced by them.
+func (wb *writeBack) wait(key string) {
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
+
+	for {
+		_, staged := wb.latest[key]
+		_, inflight := wb.inflight[key]
+		if !staged && !inflight {
+			return
+		}
+
+		wb.cond.Wait()
+	}
+}
+
+// open opens the staged data for key, if it has any.
+func (wb *writeBack) open(key string) (fs.File, bool, error) {
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
+
+	staged, ok := wb.latest[key]
+	if !ok {
+		staged, ok = wb.inflight[key]
+	}
+
+	if !ok {
+		staged, ok = wb.failed[key]
+	}
+
+	if !ok {
+		return nil, false, nil
+	}
+
+	f, err := os.Open(staged)
+	if err != nil {
+		return nil, true, err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, true, err
+	}
+
+	file := &s3File{
+		name:     key,
+		progress: wb.fs.progress,
+		buffers:  wb.fs.buffers,
+		body:     f,
+		fileInfo: s3FileInfo{
+			name:    path.Base(key),
+			mode:    fs.FileMode(0400),
+			size:    info.Size(),
+			modTime: info.ModTime(),
+			key:     key,
+			loc:     wb.fs.location,
+		},
+	}
+	wb.fs.leaks.track(file)
+
+	return file, true, nil
+}
+
+func (wb *writeBack) drain(ctx context.Context) error {
+	stop := make(chan struct{})
+	defer close(stop)
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			wb.mu.Lock()
+			wb.cond.Broadcast()
+			wb.mu.Unlock()
+		case <-stop:
+		}
+	}()
+
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
+
+	for len(wb.latest) > 0 || len(wb.inflight) > 0 {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		wb.cond.Wait()
+	}
+
+	if len(wb.errs) == 0 {
+		return nil
+	}
+
+	errs := wb.errs
+	wb.errs = map[string]error{}
+
+	return &WriteBackError{Errors: errs}
+}
+
+// stop makes the workers exit once they have nothing left to upload, and closes the
+// journal.
+func (wb *writeBack) stop() {
+	wb.mu.Lock()
+	wb.stopped = true
+	wb.cond.Broadcast()
+	wb.mu.Unlock()
+
+	wb.journalMu.Lock()
+	defer wb.journalMu.Unlock()
+
+	if wb.journal != nil {
+		wb.journal.Close()
+		wb.journal = nil
+	}
+}
+
+// journalName is the file in the staging dir that records which staged files are
+// still waiting to be uploaded, so that Recover can find them after a crash.
+const journalName = "journal"
+
+// journalEntry is a line in the journal. A "stage" entry means File holds data for
+// Key that hasn't been uploaded. A "done" entry means File has been dealt with.
+type journalEntry struct {
+	Op   string `json:"op"`
+	Key  string `json:"key,omitempty"`
+	File string `json:"file"`
+}
+
+// record appends entry to the journal and syncs it to disk.
+func (wb *writeBack) record(entry journalEntry) error {
+	wb.journalMu.Lock()
+	defer wb.journalMu.Unlock()
+
+	if wb.journal == nil {
+		f, err := os.OpenFile(filepath.Join(wb.dir, journalName), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+		if err != nil {
+			return fmt.Errorf("could not open journal: %w", err)
+		}
+
+		wb.journal = f
+	}
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	if _, err := wb.journal.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("could not write journal: %w", err)
+	}
+
+	wb.journalLines++
+
+	return wb.journal.Sync()
+}
+
+// discard removes a staged file that has been uploaded, superseded, or given up on.
+// wb.mu must be held. Once the workers have been stopped the journal is closed, so it
+// does nothing and leaves the file for Recover.
+func (wb *writeBack) discard(staged string) {
+	if wb.stopped {
+		return
+	}
+
+	os.Remove(staged)
+
+	// if this fails the worst case is that Recover uploads the file again, and
+	// it won't find the file since we just removed it
+	wb.record(journalEntry{Op: "done", File: filepath.Base(staged)})
+
+	// once most of the journal is about files that are gone, rewrite it with only
+	// the ones that aren't, so it doesn't grow forever
+	wb.journalMu.Lock()
+	lines := wb.journalLines
+	wb.journalMu.Unlock()
+
+	if lines > 2*(len(wb.latest)+len(wb.inflight)+len(wb.failed)) {
+		wb.compactJournal()
+	}
+}
+
+// Recover queues the uploads that were staged but not finished by a previous process
+// using the same staging dir, e.g. one that crashed, along with those that Drain
+// reported as failed. It returns how many uploads it queued. It should be called
+// before anything is written through the FS, or after Drain to retry failed uploads.
+// It does nothing if write-back isn't enabled.
+func (s *S3FS) Recover() (int, error) {
+	if s.writeBack == nil {
+		return 0, nil
+	}
+
+	if s.readOnly {
+		return 0, ErrReadOnly
+	}
+
+	return s.writeBack.recover()
+}
+
+func (wb *writeBack) recover() (int, error) {
+	recovered, err := wb.compactJournal()
+	if err != nil {
+		return 0, err
+	}
+
+	n := 0
+	for _, entry := range recovered {
+		if wb.enqueue(entry.Key, filepath.Join(wb.dir, entry.File)) {
+			n++
+		}
+	}
+
+	return n, nil
+}
+
+// compactJournal rewrites the journal with only the uploads that are still pending,
+// and returns them in the order they were staged.
+func (wb *writeBack) compactJournal() ([]journalEntry, error) {
+	wb.journalMu.Lock()
+	defer wb.journalMu.Unlock()
+
+	journalPath := filepath.Join(wb.dir, journalName)
+
+	f, err := os.Open(journalPath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("could not open journal: %w", err)
+	}
+
+	pending := []journalEntry{}
+	index := map[string]int{}
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		entry := journalEntry{}
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			// a torn write from the crash, everything before it is still good
+			continue
+		}
+
+		switch entry.Op {
+		case "stage":
+			index[entry.File] = len(pending)
+			pending = append(pending, entry)
+		case "done":
+			if i, ok := index[entry.File]; ok {
+				pending[i].Op = "done"
+			}
+		}
+	}
+
+	f.Close()
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("could not read journal: %w", err)
+	}
+
+	// rewrite the journal with only what is still pending
+	tmp, err := os.CreateTemp(wb.dir, "journal-*")
+	if err != nil {
+		return nil, fmt.Errorf("could not compact journal: %w", err)
+	}
+
+	recovered := []journalEntry{}
+	for _, entry := range pending {
+		if entry.Op != "stage" {
+			continue
+		}
+
+		if _, err := os.Stat(filepath.Join(wb.dir, entry.File)); err != nil {
+			continue
+		}
+
+		line, _ := json.Marshal(entry)
+		tmp.Write(append(line, '\n'))
+		recovered = append(recovered, entry)
+	}
+
+	wb.journalLines = len(recovered)
+
+	err = tmp.Sync()
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err == nil {
+		if wb.journal != nil {
+			wb.journal.Close()
+			wb.journal = nil
+		}
+
+		err = os.Rename(tmp.Name(), journalPath)
+	}
+
+	if err != nil {
+		os.Remove(tmp.Name())
+		return nil, fmt.Errorf("could not compact journal: %w", err)
+	}
+
+	return recovered, nil
+}
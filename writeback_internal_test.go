@@ -0,0 +1,31 @@
+package s3fs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteBack_DiscardAfterStop(t *testing.T) {
+	dir := t.TempDir()
+	s := &S3FS{}
+	WithWriteBack(dir)(s)
+
+	staged := filepath.Join(dir, "s3fs-staged")
+	require.Nil(t, os.WriteFile(staged, []byte("data"), 0600))
+
+	s.writeBack.stop()
+
+	s.writeBack.mu.Lock()
+	s.writeBack.discard(staged)
+	s.writeBack.mu.Unlock()
+
+	// the journal wasn't reopened, and the file is left for Recover
+	_, err := os.Stat(filepath.Join(dir, journalName))
+	require.True(t, os.IsNotExist(err))
+
+	_, err = os.Stat(staged)
+	require.Nil(t, err)
+}
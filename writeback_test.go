@@ -0,0 +1,316 @@
+package s3fs_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/packrat386/s3fs"
+	"github.com/packrat386/s3fs/s3fstest"
+	"github.com/stretchr/testify/require"
+)
+
+// flakyStore fails the first failures puts, and blocks puts while gate is locked.
+type flakyStore struct {
+	*s3fstest.MemStore
+
+	gate     sync.RWMutex
+	mu       sync.Mutex
+	failures int
+}
+
+func (f *flakyStore) Put(ctx context.Context, key string, body io.Reader, opts s3fs.PutOptions) (s3fs.ObjectInfo, error) {
+	f.gate.RLock()
+	defer f.gate.RUnlock()
+
+	f.mu.Lock()
+	if f.failures > 0 {
+		f.failures--
+		f.mu.Unlock()
+		return s3fs.ObjectInfo{}, errors.New("slow down")
+	}
+	f.mu.Unlock()
+
+	return f.MemStore.Put(ctx, key, body, opts)
+}
+
+func TestWithWriteBack(t *testing.T) {
+	store := &flakyStore{MemStore: s3fstest.NewMemStore(), failures: 2}
+	myFS := s3fs.NewFS(store, s3fs.WithWriteBack(t.TempDir()))
+
+	store.gate.Lock()
+
+	require.Nil(t, myFS.WriteFile("a.txt", []byte("one")))
+	require.Nil(t, myFS.WriteFile("a.txt", []byte("two")))
+	require.Nil(t, myFS.WriteFile("b.txt", []byte("bee")))
+
+	// nothing has reached the store yet, but we can read our writes
+	_, err := store.Head(context.Background(), "a.txt")
+	require.True(t, errors.Is(err, fs.ErrNotExist))
+
+	data, err := fs.ReadFile(myFS, "a.txt")
+	require.Nil(t, err)
+	require.Equal(t, "two", string(data))
+
+	store.gate.Unlock()
+	require.Nil(t, myFS.Drain(context.Background()))
+
+	data, err = fs.ReadFile(myFS, "a.txt")
+	require.Nil(t, err)
+	require.Equal(t, "two", string(data))
+
+	data, err = fs.ReadFile(myFS, "b.txt")
+	require.Nil(t, err)
+	require.Equal(t, "bee", string(data))
+}
+
+func TestWithWriteBack_GiveUp(t *testing.T) {
+	store := &flakyStore{MemStore: s3fstest.NewMemStore(), failures: 100}
+	myFS := s3fs.NewFS(store, s3fs.WithWriteBack(t.TempDir()), s3fs.WithConcurrency(1))
+
+	require.Nil(t, myFS.WriteFile("a.txt", []byte("one")))
+
+	err := myFS.Drain(context.Background())
+
+	var wbErr *s3fs.WriteBackError
+	require.True(t, errors.As(err, &wbErr))
+	require.Contains(t, wbErr.Errors["a.txt"].Error(), "slow down")
+
+	// errors are only reported once
+	require.Nil(t, myFS.Drain(context.Background()))
+
+	// but the data is kept, and can be uploaded once the store recovers
+	data, err := fs.ReadFile(myFS, "a.txt")
+	require.Nil(t, err)
+	require.Equal(t, "one", string(data))
+
+	store.mu.Lock()
+	store.failures = 0
+	store.mu.Unlock()
+
+	n, err := myFS.Recover()
+	require.Nil(t, err)
+	require.Equal(t, 1, n)
+	require.Nil(t, myFS.Drain(context.Background()))
+
+	_, err = store.Head(context.Background(), "a.txt")
+	require.Nil(t, err)
+
+	n, err = myFS.Recover()
+	require.Nil(t, err)
+	require.Equal(t, 0, n)
+}
+
+func TestWithWriteBack_RecoverFailed(t *testing.T) {
+	dir := t.TempDir()
+
+	failing := &flakyStore{MemStore: s3fstest.NewMemStore(), failures: 100}
+	failingFS := s3fs.NewFS(failing, s3fs.WithWriteBack(dir), s3fs.WithConcurrency(1))
+
+	require.Nil(t, failingFS.WriteFile("a.txt", []byte("one")))
+	require.Error(t, failingFS.Drain(context.Background()))
+	require.Nil(t, failingFS.Close())
+
+	// a new process with the same staging dir picks up what the old one gave up on
+	store := s3fstest.NewMemStore()
+	myFS := s3fs.NewFS(store, s3fs.WithWriteBack(dir))
+
+	n, err := myFS.Recover()
+	require.Nil(t, err)
+	require.Equal(t, 1, n)
+	require.Nil(t, myFS.Drain(context.Background()))
+
+	data, err := fs.ReadFile(s3fs.NewFS(store), "a.txt")
+	require.Nil(t, err)
+	require.Equal(t, "one", string(data))
+}
+
+func TestWithWriteBack_DrainTimeout(t *testing.T) {
+	store := &flakyStore{MemStore: s3fstest.NewMemStore()}
+	myFS := s3fs.NewFS(store, s3fs.WithWriteBack(t.TempDir()))
+
+	store.gate.Lock()
+	defer store.gate.Unlock()
+
+	require.Nil(t, myFS.WriteFile("a.txt", []byte("one")))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	require.True(t, errors.Is(myFS.Drain(ctx), context.Canceled))
+}
+
+func TestWithWriteBack_Recover(t *testing.T) {
+	dir := t.TempDir()
+
+	// the first process stages some writes and "crashes" before uploading them
+	crashed := &flakyStore{MemStore: s3fstest.NewMemStore()}
+	crashedFS := s3fs.NewFS(crashed, s3fs.WithWriteBack(dir))
+
+	require.Nil(t, crashedFS.WriteFile("z.txt", []byte("zed")))
+	require.Nil(t, crashedFS.Drain(context.Background()))
+
+	crashed.gate.Lock()
+	require.Nil(t, crashedFS.WriteFile("a.txt", []byte("two")))
+	require.Nil(t, crashedFS.WriteFile("b.txt", []byte("bee")))
+
+	store := s3fstest.NewMemStore()
+	myFS := s3fs.NewFS(store, s3fs.WithWriteBack(dir))
+
+	n, err := myFS.Recover()
+	require.Nil(t, err)
+	require.Equal(t, 2, n)
+	require.Nil(t, myFS.Drain(context.Background()))
+
+	data, err := fs.ReadFile(myFS, "a.txt")
+	require.Nil(t, err)
+	require.Equal(t, "two", string(data))
+
+	data, err = fs.ReadFile(myFS, "b.txt")
+	require.Nil(t, err)
+	require.Equal(t, "bee", string(data))
+
+	// and now there is nothing left to recover
+	n, err = s3fs.NewFS(store, s3fs.WithWriteBack(dir)).Recover()
+	require.Nil(t, err)
+	require.Equal(t, 0, n)
+}
+
+func TestWithWriteBack_CompactsJournal(t *testing.T) {
+	dir := t.TempDir()
+	myFS := s3fs.NewFS(s3fstest.NewMemStore(), s3fs.WithWriteBack(dir))
+
+	for i := 0; i < 50; i++ {
+		require.Nil(t, myFS.WriteFile(fmt.Sprintf("%d.txt", i), []byte("data")))
+	}
+
+	require.Nil(t, myFS.Drain(context.Background()))
+
+	// everything was uploaded, so there is nothing left in the journal
+	data, err := os.ReadFile(filepath.Join(dir, "journal"))
+	require.Nil(t, err)
+	require.Empty(t, string(data))
+}
+
+// holdingStore holds back puts of the data held until release is closed.
+type holdingStore struct {
+	*s3fstest.MemStore
+
+	held    string
+	release chan struct{}
+}
+
+func (h *holdingStore) Put(ctx context.Context, key string, body io.Reader, opts s3fs.PutOptions) (s3fs.ObjectInfo, error) {
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return s3fs.ObjectInfo{}, err
+	}
+
+	if string(data) == h.held {
+		<-h.release
+	}
+
+	return h.MemStore.Put(ctx, key, bytes.NewReader(data), opts)
+}
+
+// stageHeld stages data at name, and holds back its upload until shortly after, once
+// the write under test has had a chance to run.
+func stageHeld(t *testing.T, myFS *s3fs.S3FS, store *holdingStore, name, data string) {
+	store.held = data
+	store.release = make(chan struct{})
+	require.Nil(t, myFS.WriteFile(name, []byte(data)))
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		close(store.release)
+	}()
+}
+
+func TestWithWriteBack_WriteFileIf(t *testing.T) {
+	store := &holdingStore{MemStore: s3fstest.NewMemStore()}
+	myFS := s3fs.NewFS(store, s3fs.WithWriteBack(t.TempDir()))
+
+	stageHeld(t, myFS, store, "a.txt", "staged")
+
+	// the staged write has been acknowledged, so the file isn't new
+	err := myFS.WriteFileIf("a.txt", []byte("conditional"), "")
+	require.True(t, errors.Is(err, s3fs.ErrPreconditionFailed))
+
+	require.Nil(t, myFS.Drain(context.Background()))
+
+	data, err := fs.ReadFile(myFS, "a.txt")
+	require.Nil(t, err)
+	require.Equal(t, "staged", string(data))
+}
+
+func TestWithWriteBack_UploadFrom(t *testing.T) {
+	store := &holdingStore{MemStore: s3fstest.NewMemStore()}
+	myFS := s3fs.NewFS(store, s3fs.WithWriteBack(t.TempDir()))
+
+	stageHeld(t, myFS, store, "a.txt", "staged")
+
+	require.Nil(t, myFS.UploadFrom(context.Background(), "a.txt", strings.NewReader("uploaded"), 8))
+	require.Nil(t, myFS.Drain(context.Background()))
+
+	data, err := fs.ReadFile(myFS, "a.txt")
+	require.Nil(t, err)
+	require.Equal(t, "uploaded", string(data))
+}
+
+func TestWithWriteBack_Append(t *testing.T) {
+	store := &holdingStore{MemStore: s3fstest.NewMemStore()}
+	myFS := s3fs.NewFS(store, s3fs.WithWriteBack(t.TempDir()))
+
+	stageHeld(t, myFS, store, "a.txt", "staged")
+
+	w, err := myFS.Append("a.txt")
+	require.Nil(t, err)
+
+	_, err = w.Write([]byte(" and appended"))
+	require.Nil(t, err)
+	require.Nil(t, w.Close())
+	require.Nil(t, myFS.Drain(context.Background()))
+
+	data, err := fs.ReadFile(myFS, "a.txt")
+	require.Nil(t, err)
+	require.Equal(t, "staged and appended", string(data))
+}
+
+func TestWithWriteBack_Copy(t *testing.T) {
+	store := &holdingStore{MemStore: s3fstest.NewMemStore()}
+	myFS := s3fs.NewFS(store, s3fs.WithWriteBack(t.TempDir()))
+
+	require.Nil(t, myFS.WriteFile("b.txt", []byte("old")))
+	stageHeld(t, myFS, store, "a.txt", "staged")
+
+	require.Nil(t, myFS.Copy("a.txt", "b.txt"))
+	require.Nil(t, myFS.Drain(context.Background()))
+
+	data, err := fs.ReadFile(myFS, "b.txt")
+	require.Nil(t, err)
+	require.Equal(t, "staged", string(data))
+}
+
+func TestWithWriteBack_WriteFileDedup(t *testing.T) {
+	store := &holdingStore{MemStore: s3fstest.NewMemStore()}
+	myFS := s3fs.NewFS(store, s3fs.WithWriteBack(t.TempDir()))
+
+	stageHeld(t, myFS, store, "a.txt", "staged")
+
+	_, err := myFS.WriteFileDedup("a.txt", []byte("deduplicated"))
+	require.Nil(t, err)
+	require.Nil(t, myFS.Drain(context.Background()))
+
+	data, err := fs.ReadFile(myFS, "a.txt")
+	require.Nil(t, err)
+	require.Equal(t, "deduplicated", string(data))
+}
@@ -0,0 +1,37 @@
+package s3fs
+
+import "io"
+
+// WriteTo writes the rest of the file to w, making the file an io.WriterTo so that
+// io.Copy uses a buffer from the FS's BufferPool (see WithBufferSize) instead of
+// allocating a small one of its own for every file. Files opened with
+// WithStreamingConcurrency are downloaded in parallel ranges as they are written.
+func (f *s3File) WriteTo(w io.Writer) (int64, error) {
+	buf := f.buffers.Get()
+	defer f.buffers.Put(buf)
+
+	var written int64
+	for {
+		n, err := f.Read(buf)
+		if n > 0 {
+			m, writeErr := w.Write(buf[:n])
+			written += int64(m)
+
+			if writeErr != nil {
+				return written, writeErr
+			}
+
+			if m != n {
+				return written, io.ErrShortWrite
+			}
+		}
+
+		if err == io.EOF {
+			return written, nil
+		}
+
+		if err != nil {
+			return written, err
+		}
+	}
+}
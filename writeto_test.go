@@ -0,0 +1,81 @@
+package s3fs_test
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/packrat386/s3fs"
+	"github.com/packrat386/s3fs/s3fstest"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingWriter collects what is written to it, and the size of the biggest write.
+type recordingWriter struct {
+	strings.Builder
+	biggest int
+}
+
+func (w *recordingWriter) Write(p []byte) (int, error) {
+	if len(p) > w.biggest {
+		w.biggest = len(p)
+	}
+
+	return w.Builder.Write(p)
+}
+
+// failingWriter fails every write.
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func TestWriteTo(t *testing.T) {
+	for name, opts := range map[string][]s3fs.Option{
+		"default":   nil,
+		"streaming": {s3fs.WithStreamingConcurrency(3, 4)},
+	} {
+		t.Run(name, func(t *testing.T) {
+			store := s3fstest.NewMemStore()
+			store.WriteFile("a.txt", "0123456789abcdef")
+
+			var progress int64
+			opts := append(opts, s3fs.WithBufferSize(5), s3fs.WithProgress(func(path string, read, total int64) {
+				progress = read
+			}))
+			myFS := s3fs.NewFS(store, opts...)
+
+			f, err := myFS.Open("a.txt")
+			require.Nil(t, err)
+			defer f.Close()
+
+			_, ok := f.(io.WriterTo)
+			require.True(t, ok)
+
+			w := &recordingWriter{}
+			n, err := io.Copy(w, f)
+			require.Nil(t, err)
+			require.Equal(t, int64(16), n)
+			require.Equal(t, "0123456789abcdef", w.String())
+			require.Equal(t, int64(16), progress)
+
+			// copied with the pool's buffers rather than io.Copy's
+			require.LessOrEqual(t, w.biggest, 5)
+		})
+	}
+}
+
+func TestWriteTo_WriteError(t *testing.T) {
+	store := s3fstest.NewMemStore()
+	store.WriteFile("a.txt", "0123456789")
+
+	f, err := s3fs.NewFS(store).Open("a.txt")
+	require.Nil(t, err)
+	defer f.Close()
+
+	n, err := io.Copy(failingWriter{}, f)
+	require.EqualError(t, err, "disk full")
+	require.Equal(t, int64(0), n)
+}